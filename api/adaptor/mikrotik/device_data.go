@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/dataservice/model"
 )
 
 type SystemIdentity struct {
@@ -55,12 +56,15 @@ type IPAddress struct {
 	Network         string `json:"network"`
 }
 
-func (a *Adaptor) FetchDeviceInfo(c context.Context) (*SystemInfo, error) {
+func (a *Adaptor) FetchDeviceInfo(c context.Context, server model.Server) (*SystemInfo, error) {
 	var systemInfo SystemInfo
 
-	httpClient := a.mwpClients.GetClient(nil)
+	httpClient, err := a.mwpClients.GetClient(server)
+	if err != nil {
+		return nil, err
+	}
 
-	err := httpClient.Get(
+	err = httpClient.Get(
 		c,
 		common.DeviceInfoPath,
 		&systemInfo,
@@ -72,12 +76,15 @@ func (a *Adaptor) FetchDeviceInfo(c context.Context) (*SystemInfo, error) {
 	return &systemInfo, nil
 }
 
-func (a *Adaptor) FetchDeviceIdentity(c context.Context) (*SystemIdentity, error) {
+func (a *Adaptor) FetchDeviceIdentity(c context.Context, server model.Server) (*SystemIdentity, error) {
 	var systemIdentity SystemIdentity
 
-	httpClient := a.mwpClients.GetClient(nil)
+	httpClient, err := a.mwpClients.GetClient(server)
+	if err != nil {
+		return nil, err
+	}
 
-	err := httpClient.Get(
+	err = httpClient.Get(
 		c,
 		common.DeviceIdentityPath,
 		&systemIdentity,
@@ -89,12 +96,15 @@ func (a *Adaptor) FetchDeviceIdentity(c context.Context) (*SystemIdentity, error
 	return &systemIdentity, nil
 }
 
-func (a *Adaptor) FetchDNSConfig(c context.Context) (*DNSConfig, error) {
+func (a *Adaptor) FetchDNSConfig(c context.Context, server model.Server) (*DNSConfig, error) {
 	var dnsConfig DNSConfig
 
-	httpClient := a.mwpClients.GetClient(nil)
+	httpClient, err := a.mwpClients.GetClient(server)
+	if err != nil {
+		return nil, err
+	}
 
-	err := httpClient.Get(
+	err = httpClient.Get(
 		c,
 		common.DeviceDnsPath,
 		&dnsConfig,
@@ -106,12 +116,15 @@ func (a *Adaptor) FetchDNSConfig(c context.Context) (*DNSConfig, error) {
 	return &dnsConfig, nil
 }
 
-func (a *Adaptor) FetchIPv4Addresses(c context.Context) ([]IPAddress, error) {
+func (a *Adaptor) FetchIPv4Addresses(c context.Context, server model.Server) ([]IPAddress, error) {
 	var ipv4Address []IPAddress
 
-	httpClient := a.mwpClients.GetClient(nil)
+	httpClient, err := a.mwpClients.GetClient(server)
+	if err != nil {
+		return nil, err
+	}
 
-	err := httpClient.Get(
+	err = httpClient.Get(
 		c,
 		common.DeviceIPv4Path,
 		&ipv4Address,
@@ -122,3 +135,23 @@ func (a *Adaptor) FetchIPv4Addresses(c context.Context) ([]IPAddress, error) {
 
 	return ipv4Address, nil
 }
+
+func (a *Adaptor) FetchIPv6Addresses(c context.Context, server model.Server) ([]IPAddress, error) {
+	var ipv6Address []IPAddress
+
+	httpClient, err := a.mwpClients.GetClient(server)
+	if err != nil {
+		return nil, err
+	}
+
+	err = httpClient.Get(
+		c,
+		common.DeviceIPv6Path,
+		&ipv6Address,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return ipv6Address, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/dataservice/model"
 )
 
 type Interface struct {
@@ -18,12 +19,35 @@ type Interface struct {
 	Type      string `json:"type,omitempty"`
 }
 
-func (a *Adaptor) FetchInterface(c context.Context, interfaceID string) (*Interface, error) {
+func (a *Adaptor) FetchInterfaces(c context.Context, server model.Server) ([]Interface, error) {
+	var ifaces []Interface
+
+	httpClient, err := a.mwpClients.GetClient(server)
+	if err != nil {
+		return nil, err
+	}
+
+	err = httpClient.Get(
+		c,
+		common.InterfacePath,
+		&ifaces,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return ifaces, nil
+}
+
+func (a *Adaptor) FetchInterface(c context.Context, server model.Server, interfaceID string) (*Interface, error) {
 	var iface Interface
 
-	httpClient := a.mwpClients.GetClient(nil)
+	httpClient, err := a.mwpClients.GetClient(server)
+	if err != nil {
+		return nil, err
+	}
 
-	err := httpClient.Get(
+	err = httpClient.Get(
 		c,
 		common.InterfacePath+"/"+interfaceID,
 		&iface,
@@ -0,0 +1,158 @@
+package mikrotiktest
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/maahdima/mwp/api/common"
+)
+
+// PeerCounterWrap is where RouterOS wraps the rx/tx counters of WireGuard peers (32-bit counters)
+const PeerCounterWrap = 1 << 32
+
+// Fault describes a misbehaviour of the fake router for the requests it matches
+type Fault struct {
+	Method string        // empty matches any method
+	Path   string        // prefix of the REST path, e.g. /interface/wireguard/peers, empty matches any path
+	Delay  time.Duration // wait before answering, longer than the client timeout to simulate a timeout
+	Status int           // answer with this status instead of the real response, 0 answers normally after Delay
+	Detail string        // detail of the RouterOS error body
+	Times  int           // number of requests affected, 0 until ClearFaults
+
+	hits int
+}
+
+// Timeout makes the matching requests hang until the client gives up
+func Timeout(method, path string) Fault {
+	return Fault{Method: method, Path: path, Delay: time.Hour}
+}
+
+// Error makes the matching requests fail with the given status
+func Error(method, path string, status int) Fault {
+	return Fault{Method: method, Path: path, Status: status}
+}
+
+// InjectFault registers a fault, faults are matched in the order they were injected
+func (s *Server) InjectFault(fault Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fault.hits = 0
+	s.faults = append(s.faults, &fault)
+}
+
+// ClearFaults removes all the injected faults
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = nil
+}
+
+func (s *Server) matchFault(method, path string) *Fault {
+	for _, fault := range s.faults {
+		if fault.Method != "" && fault.Method != method {
+			continue
+		}
+		if fault.Path != "" && !strings.HasPrefix(path, fault.Path) {
+			continue
+		}
+		if fault.Times > 0 && fault.hits >= fault.Times {
+			continue
+		}
+
+		fault.hits++
+		matched := *fault
+		return &matched
+	}
+
+	return nil
+}
+
+// apply reports whether the fault already answered the request
+func (f *Fault) apply(w http.ResponseWriter, r *http.Request, closed <-chan struct{}) bool {
+	if f.Delay > 0 {
+		timer := time.NewTimer(f.Delay)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			return true
+		case <-closed:
+			return true
+		}
+	}
+
+	if f.Status == 0 {
+		return false
+	}
+
+	detail := f.Detail
+	if detail == "" {
+		detail = fmt.Sprintf("injected fault (%d)", f.Status)
+	}
+	writeError(w, f.Status, detail)
+
+	return true
+}
+
+// AddPeerTraffic adds traffic to a peer and its interface, peer counters wrap at PeerCounterWrap like on RouterOS
+func (s *Server) AddPeerTraffic(peerID string, rx, tx uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, peer := s.find(common.WGPeerPath, peerID)
+	if peer == nil {
+		return fmt.Errorf("peer %s: no such item", peerID)
+	}
+
+	peer["rx"] = strconv.FormatUint((parseCounter(peer["rx"])+rx)%PeerCounterWrap, 10)
+	peer["tx"] = strconv.FormatUint((parseCounter(peer["tx"])+tx)%PeerCounterWrap, 10)
+
+	for _, wg := range s.menus[common.WGInterfacePath] {
+		if wg["name"] == peer["interface"] {
+			counters := s.counters[wg[".id"]]
+			counters.rx += rx
+			counters.tx += tx
+		}
+	}
+
+	return nil
+}
+
+// SetPeerTraffic overwrites the rx/tx counters of a peer, e.g. to put them right before a wrap
+func (s *Server) SetPeerTraffic(peerID string, rx, tx uint64) error {
+	return s.Set(common.WGPeerPath, peerID, Record{
+		"rx": strconv.FormatUint(rx, 10),
+		"tx": strconv.FormatUint(tx, 10),
+	})
+}
+
+// SetLastHandshake sets how long ago the peer completed its last handshake, e.g. "1m30s"
+func (s *Server) SetLastHandshake(peerID, ago string) error {
+	return s.Set(common.WGPeerPath, peerID, Record{"last-handshake": ago})
+}
+
+// ResetCounters zeroes every peer and interface counter, like a router reboot does
+func (s *Server) ResetCounters() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, peer := range s.menus[common.WGPeerPath] {
+		peer["rx"] = "0"
+		peer["tx"] = "0"
+	}
+	for _, counters := range s.counters {
+		counters.rx = 0
+		counters.tx = 0
+	}
+}
+
+func parseCounter(value string) uint64 {
+	counter, _ := strconv.ParseUint(value, 10, 64)
+	return counter
+}
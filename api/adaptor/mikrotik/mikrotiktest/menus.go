@@ -0,0 +1,229 @@
+package mikrotiktest
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+
+	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/utils/wireguard"
+)
+
+// create validates a new record, fills the properties RouterOS computes itself and appends it to the menu
+func (s *Server) create(path string, body Record) (Record, error) {
+	if _, ok := s.menus[path]; !ok {
+		return nil, fmt.Errorf("no such command or directory (%s)", path)
+	}
+
+	record := body.clone()
+	if record == nil {
+		record = Record{}
+	}
+	delete(record, ".id")
+
+	if err := s.validateCreate(path, record); err != nil {
+		return nil, err
+	}
+
+	switch path {
+	case common.WGInterfacePath:
+		if record["private-key"] == "" {
+			_, privateKey, err := wireguard.GeneratePrivateKey()
+			if err != nil {
+				return nil, err
+			}
+			record["private-key"] = privateKey
+		}
+		setDefault(record, "listen-port", "13231")
+		setDefault(record, "mtu", "1420")
+	case common.WGPeerPath:
+		setDefault(record, "name", fmt.Sprintf("peer%d", len(s.menus[path])+1))
+		setDefault(record, "rx", "0")
+		setDefault(record, "tx", "0")
+	case common.QueuePath:
+		setDefault(record, "max-limit", "0/0")
+		setDefault(record, "bytes", "0/0")
+	case common.SchedulerPath:
+		setDefault(record, "run-count", "0")
+		setDefault(record, "interval", "0s")
+	case common.DeviceIPv4Path:
+		setDefault(record, "dynamic", "false")
+		setDefault(record, "invalid", "false")
+	}
+	setDefault(record, "disabled", "false")
+
+	record[".id"] = s.allocateID()
+	applyDerived(path, record)
+
+	s.menus[path] = append(s.menus[path], record)
+	if path == common.WGInterfacePath {
+		s.counters[record[".id"]] = &interfaceCounters{}
+	}
+
+	return record, nil
+}
+
+func (s *Server) validateCreate(path string, record Record) error {
+	switch path {
+	case common.WGInterfacePath, common.QueuePath, common.SchedulerPath:
+		if record["name"] == "" {
+			return fmt.Errorf("missing value(s) of argument(s) name")
+		}
+		if s.nameTaken(path, record["name"], "") {
+			return fmt.Errorf("failure: already have %s with such name", itemKind(path))
+		}
+	}
+
+	switch path {
+	case common.WGPeerPath:
+		if record["interface"] == "" || record["public-key"] == "" {
+			return fmt.Errorf("missing value(s) of argument(s) interface, public-key")
+		}
+		if !s.nameTaken(common.WGInterfacePath, record["interface"], "") {
+			return fmt.Errorf("input does not match any value of interface")
+		}
+		for _, peer := range s.menus[common.WGPeerPath] {
+			if peer["interface"] == record["interface"] && peer["public-key"] == record["public-key"] {
+				return fmt.Errorf("failure: peer with such public key already exists on this interface")
+			}
+		}
+	case common.QueuePath:
+		if record["target"] == "" {
+			return fmt.Errorf("missing value(s) of argument(s) target")
+		}
+	case common.DeviceIPv4Path, common.DeviceIPv6Path:
+		if record["address"] == "" || record["interface"] == "" {
+			return fmt.Errorf("missing value(s) of argument(s) address, interface")
+		}
+		if _, _, err := net.ParseCIDR(record["address"]); err != nil {
+			return fmt.Errorf("invalid value for argument address")
+		}
+	}
+
+	return nil
+}
+
+func (s *Server) validateUpdate(path string, record, body Record) error {
+	name, ok := body["name"]
+	if !ok {
+		return nil
+	}
+
+	switch path {
+	case common.WGInterfacePath, common.QueuePath, common.SchedulerPath:
+		if name == "" {
+			return fmt.Errorf("invalid value for argument name")
+		}
+		if s.nameTaken(path, name, record[".id"]) {
+			return fmt.Errorf("failure: already have %s with such name", itemKind(path))
+		}
+	}
+
+	return nil
+}
+
+func (s *Server) nameTaken(path, name, exceptID string) bool {
+	for _, record := range s.menus[path] {
+		if record["name"] == name && record[".id"] != exceptID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// applyDerived refreshes the read-only properties that depend on the writable ones
+func applyDerived(path string, record Record) {
+	switch path {
+	case common.WGInterfacePath:
+		if key := record["private-key"]; key != "" {
+			if publicKey, err := publicKeyOf(key); err == nil {
+				record["public-key"] = publicKey
+			}
+		}
+		record["running"] = fmt.Sprint(record["disabled"] != "true")
+	case common.DeviceIPv4Path, common.DeviceIPv6Path:
+		if _, network, err := net.ParseCIDR(record["address"]); err == nil {
+			record["network"] = network.IP.String()
+		}
+		record["actual-interface"] = record["interface"]
+	}
+}
+
+func publicKeyOf(privateKey string) (string, error) {
+	key, err := decodeKey(privateKey)
+	if err != nil {
+		return "", err
+	}
+
+	return wireguard.GeneratePublicKey(key)
+}
+
+func decodeKey(key string) ([]byte, error) {
+	decoded, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(decoded) != 32 {
+		return nil, fmt.Errorf("invalid key")
+	}
+
+	return decoded, nil
+}
+
+func itemKind(path string) string {
+	switch path {
+	case common.WGInterfacePath:
+		return "interface"
+	case common.QueuePath:
+		return "queue"
+	case common.SchedulerPath:
+		return "scheduler"
+	default:
+		return "item"
+	}
+}
+
+func setDefault(record Record, key, value string) {
+	if _, ok := record[key]; !ok {
+		record[key] = value
+	}
+}
+
+func defaultResource() Record {
+	return Record{
+		"architecture-name":       "arm64",
+		"bad-blocks":              "0",
+		"board-name":              "CHR",
+		"build-time":              "2025-01-01 00:00:00",
+		"cpu":                     "ARM64",
+		"cpu-count":               "4",
+		"cpu-frequency":           "1800",
+		"cpu-load":                "3",
+		"factory-software":        "7.1",
+		"free-hdd-space":          "104857600",
+		"free-memory":             "805306368",
+		"platform":                "MikroTik",
+		"total-hdd-space":         "134217728",
+		"total-memory":            "1073741824",
+		"uptime":                  "1w2d3h4m5s",
+		"version":                 "7.18 (stable)",
+		"write-sect-since-reboot": "100",
+		"write-sect-total":        "1000",
+	}
+}
+
+func defaultDNS() Record {
+	return Record{
+		"allow-remote-requests":       "false",
+		"cache-max-ttl":               "1w",
+		"cache-size":                  "2048KiB",
+		"cache-used":                  "32KiB",
+		"doh-timeout":                 "5s",
+		"dynamic-servers":             "",
+		"max-concurrent-queries":      "100",
+		"max-concurrent-tcp-sessions": "20",
+		"max-udp-packet-size":         "4096",
+		"query-server-timeout":        "2s",
+		"query-total-timeout":         "10s",
+		"servers":                     "1.1.1.1,8.8.8.8",
+		"use-doh-server":              "",
+	}
+}
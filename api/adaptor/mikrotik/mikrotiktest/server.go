@@ -0,0 +1,488 @@
+// Package mikrotiktest provides an in-process fake of the RouterOS REST API, in the spirit of net/http/httptest.
+//
+// It keeps the WireGuard interfaces, peers, simple queues, schedulers, IP addresses, DNS, identity and resource
+// menus in memory, allocates RouterOS style ".id" values and follows the PUT (create), PATCH (update) and DELETE
+// semantics of the real API. Faults such as timeouts, 4xx/5xx answers and counter wraps can be injected to
+// exercise the error paths of the services and jobs without a live router.
+package mikrotiktest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/dataservice/model"
+)
+
+const (
+	DefaultUsername = "admin"
+	DefaultPassword = "admin"
+	DefaultIdentity = "MikroTik"
+
+	restPrefix = "/rest"
+)
+
+// Record is a single RouterOS item, every property is a string just like on the real REST API
+type Record map[string]string
+
+// Call is a request received by the fake router
+type Call struct {
+	Method string
+	Path   string
+	Body   Record
+}
+
+// Server is a fake RouterOS REST API listening on a local port
+type Server struct {
+	// URL is the base URL of the REST API, e.g. http://127.0.0.1:38121/rest
+	URL      string
+	Username string
+	Password string
+
+	httpServer *httptest.Server
+	closed     chan struct{}
+
+	mu         sync.Mutex
+	nextID     uint64
+	menus      map[string][]Record
+	singletons map[string]Record
+	counters   map[string]*interfaceCounters
+	faults     []*Fault
+	calls      []Call
+}
+
+type interfaceCounters struct {
+	rx uint64
+	tx uint64
+}
+
+// NewServer starts a fake router with an ether1 interface, a default identity and resource info
+func NewServer() *Server {
+	s := &Server{
+		Username: DefaultUsername,
+		Password: DefaultPassword,
+		// *1 is taken by ether1
+		nextID: 1,
+		menus: map[string][]Record{
+			common.WGInterfacePath: {},
+			common.WGPeerPath:      {},
+			common.QueuePath:       {},
+			common.SchedulerPath:   {},
+			common.DeviceIPv4Path:  {},
+			common.DeviceIPv6Path:  {},
+		},
+		singletons: map[string]Record{
+			common.DeviceIdentityPath: {"name": DefaultIdentity},
+			common.DeviceInfoPath:     defaultResource(),
+			common.DeviceDnsPath:      defaultDNS(),
+		},
+		counters: make(map[string]*interfaceCounters),
+		closed:   make(chan struct{}),
+	}
+
+	s.httpServer = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.httpServer.URL + restPrefix
+
+	return s
+}
+
+// Close shuts the fake router down
+func (s *Server) Close() {
+	close(s.closed)
+	s.httpServer.Close()
+}
+
+// Host returns the address the fake router listens on
+func (s *Server) Host() string {
+	host, _, _ := net.SplitHostPort(s.httpServer.Listener.Addr().String())
+	return host
+}
+
+// Port returns the port the fake router listens on
+func (s *Server) Port() int {
+	_, port, _ := net.SplitHostPort(s.httpServer.Listener.Addr().String())
+	p, _ := strconv.Atoi(port)
+	return p
+}
+
+// ModelServer returns a model.Server pointing at the fake router, ready to be stored in the database
+func (s *Server) ModelServer(name string) model.Server {
+	return model.Server{
+		Name:      name,
+		IPAddress: s.Host(),
+		APIPort:   s.Port(),
+		Username:  s.Username,
+		Password:  s.Password,
+		IsActive:  true,
+	}
+}
+
+// Seed stores a record in the given menu as if it had been created on the router and returns its ".id"
+func (s *Server) Seed(path string, record Record) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	created, err := s.create(path, record)
+	if err != nil {
+		return "", err
+	}
+
+	return created[".id"], nil
+}
+
+// Records returns a copy of all the records of a menu
+func (s *Server) Records(path string) []Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := make([]Record, 0, len(s.menus[path]))
+	for _, record := range s.list(path) {
+		records = append(records, record.clone())
+	}
+
+	return records
+}
+
+// Record returns a copy of a single record, or nil if it does not exist
+func (s *Server) Record(path, id string) Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, record := s.find(path, id); record != nil {
+		return record.clone()
+	}
+
+	return nil
+}
+
+// Set overwrites properties of an existing record or singleton (e.g. /ip/dns) without going through the API
+func (s *Server) Set(path, id string, props Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if singleton, ok := s.singletons[path]; ok {
+		singleton.merge(props)
+		return nil
+	}
+
+	_, record := s.find(path, id)
+	if record == nil {
+		return fmt.Errorf("%s %s: no such item", path, id)
+	}
+	record.merge(props)
+
+	return nil
+}
+
+// Calls returns every request received so far
+func (s *Server) Calls() []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	calls := make([]Call, len(s.calls))
+	copy(calls, s.calls)
+
+	return calls
+}
+
+// CallCount returns how many requests matched the method and path, an empty method matches any method
+func (s *Server) CallCount(method, path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for _, call := range s.calls {
+		if (method == "" || call.Method == method) && call.Path == path {
+			count++
+		}
+	}
+
+	return count
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, restPrefix)
+
+	var body Record
+	if r.Body != nil && (r.Method == http.MethodPut || r.Method == http.MethodPatch || r.Method == http.MethodPost) {
+		decoded, err := decodeRecord(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "JSON parsing error: "+err.Error())
+			return
+		}
+		body = decoded
+	}
+
+	s.mu.Lock()
+	s.calls = append(s.calls, Call{Method: r.Method, Path: path, Body: body.clone()})
+	fault := s.matchFault(r.Method, path)
+	s.mu.Unlock()
+
+	if fault != nil && fault.apply(w, r, s.closed) {
+		return
+	}
+
+	username, password, ok := r.BasicAuth()
+	if !ok || username != s.Username || password != s.Password {
+		writeError(w, http.StatusUnauthorized, "")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if singleton, ok := s.singletons[path]; ok {
+		s.serveSingleton(w, r, singleton, body)
+		return
+	}
+	if strings.HasSuffix(path, "/set") {
+		if singleton, ok := s.singletons[strings.TrimSuffix(path, "/set")]; ok && r.Method == http.MethodPost {
+			singleton.merge(body)
+			writeJSON(w, http.StatusOK, []Record{})
+			return
+		}
+	}
+
+	if s.isMenu(path) {
+		s.serveMenu(w, r, path, body)
+		return
+	}
+
+	idx := strings.LastIndex(path, "/")
+	if idx > 0 && s.isMenu(path[:idx]) {
+		s.serveItem(w, r, path[:idx], path[idx+1:], body)
+		return
+	}
+
+	writeError(w, http.StatusBadRequest, "no such command or directory ("+strings.TrimPrefix(path, "/")+")")
+}
+
+func (s *Server) serveSingleton(w http.ResponseWriter, r *http.Request, singleton, body Record) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, singleton)
+	case http.MethodPatch:
+		singleton.merge(body)
+		writeJSON(w, http.StatusOK, singleton)
+	default:
+		writeError(w, http.StatusBadRequest, "no such command")
+	}
+}
+
+func (s *Server) serveMenu(w http.ResponseWriter, r *http.Request, path string, body Record) {
+	switch r.Method {
+	case http.MethodGet:
+		records := []Record{}
+		for _, record := range s.list(path) {
+			if record.matches(r.URL.Query()) {
+				records = append(records, record)
+			}
+		}
+		writeJSON(w, http.StatusOK, records)
+	case http.MethodPut:
+		if path == common.InterfacePath {
+			writeError(w, http.StatusBadRequest, "no such command")
+			return
+		}
+		created, err := s.create(path, body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusCreated, created)
+	default:
+		writeError(w, http.StatusBadRequest, "no such command")
+	}
+}
+
+func (s *Server) serveItem(w http.ResponseWriter, r *http.Request, path, id string, body Record) {
+	idx, record := s.find(path, id)
+	if record == nil {
+		writeError(w, http.StatusNotFound, "no such item")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, record)
+	case http.MethodPatch:
+		if path == common.InterfacePath {
+			writeError(w, http.StatusBadRequest, "no such command")
+			return
+		}
+		if err := s.validateUpdate(path, record, body); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		delete(body, ".id")
+		record.merge(body)
+		applyDerived(path, record)
+		writeJSON(w, http.StatusOK, record)
+	case http.MethodDelete:
+		if path == common.InterfacePath {
+			writeError(w, http.StatusBadRequest, "no such command")
+			return
+		}
+		s.menus[path] = append(s.menus[path][:idx], s.menus[path][idx+1:]...)
+		if path == common.WGInterfacePath {
+			delete(s.counters, id)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusBadRequest, "no such command")
+	}
+}
+
+func (s *Server) isMenu(path string) bool {
+	if path == common.InterfacePath {
+		return true
+	}
+	_, ok := s.menus[path]
+	return ok
+}
+
+// list returns the records of a menu, /interface is a view over ether1 and the WireGuard interfaces
+func (s *Server) list(path string) []Record {
+	if path != common.InterfacePath {
+		return s.menus[path]
+	}
+
+	records := []Record{s.etherInterface()}
+	for _, wg := range s.menus[common.WGInterfacePath] {
+		records = append(records, s.interfaceView(wg))
+	}
+
+	return records
+}
+
+func (s *Server) find(path, id string) (int, Record) {
+	for i, record := range s.list(path) {
+		if record[".id"] == id {
+			return i, record
+		}
+	}
+
+	return -1, nil
+}
+
+func (s *Server) allocateID() string {
+	s.nextID++
+	return fmt.Sprintf("*%X", s.nextID)
+}
+
+func (s *Server) etherInterface() Record {
+	return Record{
+		".id":        "*1",
+		"name":       common.IPv4DefaultInterface,
+		"type":       "ether",
+		"mtu":        "1500",
+		"actual-mtu": "1500",
+		"disabled":   "false",
+		"running":    "true",
+		"rx-byte":    "0",
+		"tx-byte":    "0",
+	}
+}
+
+func (s *Server) interfaceView(wg Record) Record {
+	counters := s.counters[wg[".id"]]
+	if counters == nil {
+		counters = &interfaceCounters{}
+	}
+
+	return Record{
+		".id":        wg[".id"],
+		"name":       wg["name"],
+		"type":       "wg",
+		"mtu":        wg["mtu"],
+		"actual-mtu": wg["mtu"],
+		"disabled":   wg["disabled"],
+		"running":    wg["running"],
+		"rx-byte":    strconv.FormatUint(counters.rx, 10),
+		"tx-byte":    strconv.FormatUint(counters.tx, 10),
+	}
+}
+
+func decodeRecord(r *http.Request) (Record, error) {
+	var raw map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+		if errors.Is(err, io.EOF) {
+			return Record{}, nil
+		}
+		return nil, err
+	}
+
+	record := make(Record, len(raw))
+	for key, value := range raw {
+		switch v := value.(type) {
+		case nil:
+			continue
+		case string:
+			record[key] = v
+		default:
+			record[key] = fmt.Sprint(v)
+		}
+	}
+
+	return record, nil
+}
+
+func (r Record) clone() Record {
+	if r == nil {
+		return nil
+	}
+
+	cloned := make(Record, len(r))
+	for key, value := range r {
+		cloned[key] = value
+	}
+
+	return cloned
+}
+
+func (r Record) merge(props Record) {
+	for key, value := range props {
+		r[key] = value
+	}
+}
+
+// matches applies RouterOS style equality filters, e.g. ?interface=wg1, ".proplist" is ignored
+func (r Record) matches(query map[string][]string) bool {
+	for key, values := range query {
+		if key == ".proplist" || len(values) == 0 {
+			continue
+		}
+		if r[key] != values[0] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// writeError answers with the error body RouterOS uses, e.g. {"error":404,"message":"Not Found","detail":"no such item"}
+func writeError(w http.ResponseWriter, status int, detail string) {
+	body := map[string]any{
+		"error":   status,
+		"message": http.StatusText(status),
+	}
+	if detail != "" {
+		body["detail"] = detail
+	}
+
+	writeJSON(w, status, body)
+}
@@ -0,0 +1,147 @@
+package mikrotiktest_test
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/maahdima/mwp/api/adaptor/mikrotik"
+	"github.com/maahdima/mwp/api/adaptor/mikrotik/mikrotiktest"
+	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/utils"
+	"github.com/maahdima/mwp/api/utils/wireguard"
+)
+
+// newRouter starts a fake router with a wg0 interface, the server it returns is not stored so no database is needed
+func newRouter(t *testing.T) (*mikrotiktest.Server, *mikrotik.Adaptor) {
+	t.Helper()
+
+	router := mikrotiktest.NewServer()
+	t.Cleanup(router.Close)
+	if _, err := router.Seed(common.WGInterfacePath, mikrotiktest.Record{"name": "wg0"}); err != nil {
+		t.Fatal(err)
+	}
+
+	return router, mikrotik.NewAdaptor(common.NewMwpClients(nil))
+}
+
+func publicKey(t *testing.T) string {
+	t.Helper()
+
+	raw, _, err := wireguard.GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := wireguard.GeneratePublicKey(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestPeerLifecycle(t *testing.T) {
+	router, adaptor := newRouter(t)
+	server := router.ModelServer("r1")
+	ctx := context.Background()
+
+	created, err := adaptor.CreateWgPeer(ctx, server, mikrotik.WireGuardPeer{
+		Interface:      "wg0",
+		Name:           "alice",
+		PublicKey:      publicKey(t),
+		AllowedAddress: "10.0.0.2/32",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.ID == "" || created.Disabled != "false" {
+		t.Fatalf("created peer %+v lacks the properties the router fills in", created)
+	}
+
+	if _, err := adaptor.UpdateWgPeer(ctx, server, created.ID, mikrotik.WireGuardPeer{Comment: utils.Ptr("vip")}); err != nil {
+		t.Fatal(err)
+	}
+	peer, err := adaptor.FetchWgPeer(ctx, server, created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if utils.DerefString(peer.Comment) != "vip" || peer.Name != "alice" {
+		t.Fatalf("update replaced the peer instead of patching it: %+v", peer)
+	}
+
+	if err := adaptor.DeleteWgPeer(ctx, server, created.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := adaptor.FetchWgPeer(ctx, server, created.ID); err == nil {
+		t.Fatal("deleted peer is still returned")
+	}
+	if router.CallCount(http.MethodDelete, common.WGPeerPath+"/"+created.ID) != 1 {
+		t.Fatal("delete was not recorded")
+	}
+}
+
+func TestCreateValidatesLikeRouterOS(t *testing.T) {
+	router, adaptor := newRouter(t)
+	server := router.ModelServer("r1")
+	ctx := context.Background()
+
+	key := publicKey(t)
+	peer := mikrotik.WireGuardPeer{Interface: "wg0", PublicKey: key, AllowedAddress: "10.0.0.2/32"}
+	if _, err := adaptor.CreateWgPeer(ctx, server, peer); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := adaptor.CreateWgPeer(ctx, server, peer); err == nil {
+		t.Fatal("a second peer with the same public key on the interface was accepted")
+	}
+
+	peer.PublicKey = publicKey(t)
+	peer.Interface = "wg9"
+	if _, err := adaptor.CreateWgPeer(ctx, server, peer); err == nil {
+		t.Fatal("a peer on a missing interface was accepted")
+	}
+}
+
+func TestInjectedError(t *testing.T) {
+	router, adaptor := newRouter(t)
+	server := router.ModelServer("r1")
+	ctx := context.Background()
+
+	fault := mikrotiktest.Error(http.MethodPut, common.QueuePath, http.StatusInternalServerError)
+	fault.Times = 1
+	router.InjectFault(fault)
+
+	queue := mikrotik.Queue{Name: "q1", Target: utils.Ptr("10.0.0.2/32"), MaxLimit: utils.Ptr("0/0")}
+	if _, err := adaptor.CreateSimpleQueue(ctx, server, queue); err == nil {
+		t.Fatal("injected error was not returned")
+	}
+	if _, err := adaptor.CreateSimpleQueue(ctx, server, queue); err != nil {
+		t.Fatalf("fault limited to one request still applies: %v", err)
+	}
+	if records := router.Records(common.QueuePath); len(records) != 1 {
+		t.Fatalf("router has %d queues, want the one created after the fault", len(records))
+	}
+}
+
+func TestPeerCountersWrap(t *testing.T) {
+	router, adaptor := newRouter(t)
+	server := router.ModelServer("r1")
+
+	id, err := router.Seed(common.WGPeerPath, mikrotiktest.Record{"interface": "wg0", "public-key": publicKey(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := router.SetPeerTraffic(id, 0, mikrotiktest.PeerCounterWrap-10); err != nil {
+		t.Fatal(err)
+	}
+	if err := router.AddPeerTraffic(id, 5, 25); err != nil {
+		t.Fatal(err)
+	}
+
+	peer, err := adaptor.FetchWgPeer(context.Background(), server, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if peer.TransferTx != strconv.Itoa(15) || peer.TransferRx != "5" {
+		t.Fatalf("counters tx %s rx %s, want the tx counter wrapped to 15", peer.TransferTx, peer.TransferRx)
+	}
+}
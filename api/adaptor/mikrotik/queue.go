@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/dataservice/model"
 )
 
 type Queue struct {
@@ -15,12 +16,35 @@ type Queue struct {
 	MaxLimit *string `json:"max-limit,omitempty"`
 }
 
-func (a *Adaptor) CreateSimpleQueue(c context.Context, queue Queue) (*Queue, error) {
+func (a *Adaptor) FetchSimpleQueues(c context.Context, server model.Server) ([]Queue, error) {
+	var queues []Queue
+
+	httpClient, err := a.mwpClients.GetClient(server)
+	if err != nil {
+		return nil, err
+	}
+
+	err = httpClient.Get(
+		c,
+		common.QueuePath,
+		&queues,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return queues, nil
+}
+
+func (a *Adaptor) CreateSimpleQueue(c context.Context, server model.Server, queue Queue) (*Queue, error) {
 	var createdQueue Queue
 
-	httpClient := a.mwpClients.GetClient(nil)
+	httpClient, err := a.mwpClients.GetClient(server)
+	if err != nil {
+		return nil, err
+	}
 
-	err := httpClient.Put(
+	err = httpClient.Put(
 		c,
 		common.QueuePath,
 		queue,
@@ -33,12 +57,15 @@ func (a *Adaptor) CreateSimpleQueue(c context.Context, queue Queue) (*Queue, err
 	return &createdQueue, nil
 }
 
-func (a *Adaptor) UpdateSimpleQueue(c context.Context, queueID string, queue Queue) (*Queue, error) {
+func (a *Adaptor) UpdateSimpleQueue(c context.Context, server model.Server, queueID string, queue Queue) (*Queue, error) {
 	var updatedQueue Queue
 
-	httpClient := a.mwpClients.GetClient(nil)
+	httpClient, err := a.mwpClients.GetClient(server)
+	if err != nil {
+		return nil, err
+	}
 
-	err := httpClient.Patch(
+	err = httpClient.Patch(
 		c,
 		common.QueuePath+"/"+queueID,
 		queue,
@@ -51,10 +78,13 @@ func (a *Adaptor) UpdateSimpleQueue(c context.Context, queueID string, queue Que
 	return &updatedQueue, nil
 }
 
-func (a *Adaptor) DeleteSimpleQueue(c context.Context, queueID string) error {
-	httpClient := a.mwpClients.GetClient(nil)
+func (a *Adaptor) DeleteSimpleQueue(c context.Context, server model.Server, queueID string) error {
+	httpClient, err := a.mwpClients.GetClient(server)
+	if err != nil {
+		return err
+	}
 
-	err := httpClient.Delete(
+	err = httpClient.Delete(
 		c,
 		common.QueuePath+"/"+queueID,
 		nil,
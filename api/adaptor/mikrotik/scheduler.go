@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/dataservice/model"
 )
 
 type Scheduler struct {
@@ -18,12 +19,35 @@ type Scheduler struct {
 	OnEvent   *string `json:"on-event,omitempty"`
 }
 
-func (a *Adaptor) CreateScheduler(c context.Context, scheduler Scheduler) (*Scheduler, error) {
+func (a *Adaptor) FetchSchedulers(c context.Context, server model.Server) ([]Scheduler, error) {
+	var schedulers []Scheduler
+
+	httpClient, err := a.mwpClients.GetClient(server)
+	if err != nil {
+		return nil, err
+	}
+
+	err = httpClient.Get(
+		c,
+		common.SchedulerPath,
+		&schedulers,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return schedulers, nil
+}
+
+func (a *Adaptor) CreateScheduler(c context.Context, server model.Server, scheduler Scheduler) (*Scheduler, error) {
 	var createdScheduler Scheduler
 
-	httpClient := a.mwpClients.GetClient(nil)
+	httpClient, err := a.mwpClients.GetClient(server)
+	if err != nil {
+		return nil, err
+	}
 
-	err := httpClient.Put(
+	err = httpClient.Put(
 		c,
 		common.SchedulerPath,
 		scheduler,
@@ -36,12 +60,15 @@ func (a *Adaptor) CreateScheduler(c context.Context, scheduler Scheduler) (*Sche
 	return &createdScheduler, nil
 }
 
-func (a *Adaptor) UpdateScheduler(c context.Context, schedulerID string, scheduler Scheduler) (*Scheduler, error) {
+func (a *Adaptor) UpdateScheduler(c context.Context, server model.Server, schedulerID string, scheduler Scheduler) (*Scheduler, error) {
 	var updatedScheduler Scheduler
 
-	httpClient := a.mwpClients.GetClient(nil)
+	httpClient, err := a.mwpClients.GetClient(server)
+	if err != nil {
+		return nil, err
+	}
 
-	err := httpClient.Patch(
+	err = httpClient.Patch(
 		c,
 		common.SchedulerPath+"/"+schedulerID,
 		scheduler,
@@ -54,10 +81,13 @@ func (a *Adaptor) UpdateScheduler(c context.Context, schedulerID string, schedul
 	return &updatedScheduler, nil
 }
 
-func (a *Adaptor) DeleteScheduler(c context.Context, schedulerID string) error {
-	httpClient := a.mwpClients.GetClient(nil)
+func (a *Adaptor) DeleteScheduler(c context.Context, server model.Server, schedulerID string) error {
+	httpClient, err := a.mwpClients.GetClient(server)
+	if err != nil {
+		return err
+	}
 
-	err := httpClient.Delete(
+	err = httpClient.Delete(
 		c,
 		common.SchedulerPath+"/"+schedulerID,
 		nil,
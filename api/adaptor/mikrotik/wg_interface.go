@@ -6,6 +6,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/dataservice/model"
 )
 
 type WireGuardInterface struct {
@@ -20,12 +21,15 @@ type WireGuardInterface struct {
 	Running    *string `json:"running,omitempty"`
 }
 
-func (a *Adaptor) FetchWgInterfaces(c context.Context) ([]WireGuardInterface, error) {
+func (a *Adaptor) FetchWgInterfaces(c context.Context, server model.Server) ([]WireGuardInterface, error) {
 	var wgInterfaces []WireGuardInterface
 
-	httpClient := a.mwpClients.GetClient(nil)
+	httpClient, err := a.mwpClients.GetClient(server)
+	if err != nil {
+		return nil, err
+	}
 
-	err := httpClient.Get(
+	err = httpClient.Get(
 		c,
 		common.WGInterfacePath,
 		&wgInterfaces,
@@ -37,12 +41,15 @@ func (a *Adaptor) FetchWgInterfaces(c context.Context) ([]WireGuardInterface, er
 	return wgInterfaces, nil
 }
 
-func (a *Adaptor) FetchWgInterface(c context.Context, interfaceID string) (*WireGuardInterface, error) {
+func (a *Adaptor) FetchWgInterface(c context.Context, server model.Server, interfaceID string) (*WireGuardInterface, error) {
 	var wgInterface WireGuardInterface
 
-	httpClient := a.mwpClients.GetClient(nil)
+	httpClient, err := a.mwpClients.GetClient(server)
+	if err != nil {
+		return nil, err
+	}
 
-	err := httpClient.Get(
+	err = httpClient.Get(
 		c,
 		common.WGInterfacePath+"/"+interfaceID,
 		&wgInterface,
@@ -54,12 +61,15 @@ func (a *Adaptor) FetchWgInterface(c context.Context, interfaceID string) (*Wire
 	return &wgInterface, nil
 }
 
-func (a *Adaptor) CreateWgInterface(c context.Context, wgInterface WireGuardInterface) (*WireGuardInterface, error) {
+func (a *Adaptor) CreateWgInterface(c context.Context, server model.Server, wgInterface WireGuardInterface) (*WireGuardInterface, error) {
 	var createdInterface WireGuardInterface
 
-	httpClient := a.mwpClients.GetClient(nil)
+	httpClient, err := a.mwpClients.GetClient(server)
+	if err != nil {
+		return nil, err
+	}
 
-	err := httpClient.Put(
+	err = httpClient.Put(
 		c,
 		common.WGInterfacePath,
 		wgInterface,
@@ -72,12 +82,15 @@ func (a *Adaptor) CreateWgInterface(c context.Context, wgInterface WireGuardInte
 	return &createdInterface, nil
 }
 
-func (a *Adaptor) UpdateWgInterface(c context.Context, interfaceID string, wgInterface WireGuardInterface) (*WireGuardInterface, error) {
+func (a *Adaptor) UpdateWgInterface(c context.Context, server model.Server, interfaceID string, wgInterface WireGuardInterface) (*WireGuardInterface, error) {
 	var updatedInterface WireGuardInterface
 
-	httpClient := a.mwpClients.GetClient(nil)
+	httpClient, err := a.mwpClients.GetClient(server)
+	if err != nil {
+		return nil, err
+	}
 
-	err := httpClient.Patch(
+	err = httpClient.Patch(
 		c,
 		common.WGInterfacePath+"/"+interfaceID,
 		wgInterface,
@@ -90,10 +103,13 @@ func (a *Adaptor) UpdateWgInterface(c context.Context, interfaceID string, wgInt
 	return &updatedInterface, nil
 }
 
-func (a *Adaptor) DeleteWgInterface(c context.Context, interfaceID string) error {
-	httpClient := a.mwpClients.GetClient(nil)
+func (a *Adaptor) DeleteWgInterface(c context.Context, server model.Server, interfaceID string) error {
+	httpClient, err := a.mwpClients.GetClient(server)
+	if err != nil {
+		return err
+	}
 
-	err := httpClient.Delete(
+	err = httpClient.Delete(
 		c,
 		common.WGInterfacePath+"/"+interfaceID,
 		nil,
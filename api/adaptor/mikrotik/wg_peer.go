@@ -6,6 +6,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/dataservice/model"
 )
 
 type WireGuardPeer struct {
@@ -27,12 +28,15 @@ type WireGuardPeer struct {
 	TransferTx             string  `json:"tx,omitempty"`
 }
 
-func (a *Adaptor) FetchWgPeers(c context.Context) ([]WireGuardPeer, error) {
+func (a *Adaptor) FetchWgPeers(c context.Context, server model.Server) ([]WireGuardPeer, error) {
 	var wgPeers []WireGuardPeer
 
-	httpClient := a.mwpClients.GetClient(nil)
+	httpClient, err := a.mwpClients.GetClient(server)
+	if err != nil {
+		return nil, err
+	}
 
-	err := httpClient.Get(
+	err = httpClient.Get(
 		c,
 		common.WGPeerPath,
 		&wgPeers,
@@ -45,12 +49,15 @@ func (a *Adaptor) FetchWgPeers(c context.Context) ([]WireGuardPeer, error) {
 	return wgPeers, nil
 }
 
-func (a *Adaptor) FetchWgPeer(c context.Context, peerID string) (*WireGuardPeer, error) {
+func (a *Adaptor) FetchWgPeer(c context.Context, server model.Server, peerID string) (*WireGuardPeer, error) {
 	var wgPeer WireGuardPeer
 
-	httpClient := a.mwpClients.GetClient(nil)
+	httpClient, err := a.mwpClients.GetClient(server)
+	if err != nil {
+		return nil, err
+	}
 
-	err := httpClient.Get(
+	err = httpClient.Get(
 		c,
 		common.WGPeerPath+"/"+peerID,
 		&wgPeer,
@@ -63,12 +70,15 @@ func (a *Adaptor) FetchWgPeer(c context.Context, peerID string) (*WireGuardPeer,
 	return &wgPeer, nil
 }
 
-func (a *Adaptor) CreateWgPeer(c context.Context, wgPeer WireGuardPeer) (*WireGuardPeer, error) {
+func (a *Adaptor) CreateWgPeer(c context.Context, server model.Server, wgPeer WireGuardPeer) (*WireGuardPeer, error) {
 	var createdPeer WireGuardPeer
 
-	httpClient := a.mwpClients.GetClient(nil)
+	httpClient, err := a.mwpClients.GetClient(server)
+	if err != nil {
+		return nil, err
+	}
 
-	err := httpClient.Put(
+	err = httpClient.Put(
 		c,
 		common.WGPeerPath,
 		wgPeer,
@@ -81,12 +91,15 @@ func (a *Adaptor) CreateWgPeer(c context.Context, wgPeer WireGuardPeer) (*WireGu
 	return &createdPeer, nil
 }
 
-func (a *Adaptor) UpdateWgPeer(c context.Context, peerID string, wgPeer WireGuardPeer) (*WireGuardPeer, error) {
+func (a *Adaptor) UpdateWgPeer(c context.Context, server model.Server, peerID string, wgPeer WireGuardPeer) (*WireGuardPeer, error) {
 	var updatedPeer WireGuardPeer
 
-	httpClient := a.mwpClients.GetClient(nil)
+	httpClient, err := a.mwpClients.GetClient(server)
+	if err != nil {
+		return nil, err
+	}
 
-	err := httpClient.Patch(
+	err = httpClient.Patch(
 		c,
 		common.WGPeerPath+"/"+peerID,
 		wgPeer,
@@ -99,10 +112,13 @@ func (a *Adaptor) UpdateWgPeer(c context.Context, peerID string, wgPeer WireGuar
 	return &updatedPeer, nil
 }
 
-func (a *Adaptor) DeleteWgPeer(c context.Context, peerID string) error {
-	httpClient := a.mwpClients.GetClient(nil)
+func (a *Adaptor) DeleteWgPeer(c context.Context, server model.Server, peerID string) error {
+	httpClient, err := a.mwpClients.GetClient(server)
+	if err != nil {
+		return err
+	}
 
-	err := httpClient.Delete(
+	err = httpClient.Delete(
 		c,
 		common.WGPeerPath+"/"+peerID,
 		nil,
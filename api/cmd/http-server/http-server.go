@@ -19,42 +19,56 @@ import (
 	"gorm.io/gorm"
 )
 
-func StartHttpServer(db *gorm.DB, mwpClients *common.MwpClients, mikrotikAdaptor *mikrotik.Adaptor, trafficCalculator *traffic.Calculator) error {
+func StartHttpServer(db *gorm.DB, mwpClients *common.MwpClients, mikrotikAdaptor *mikrotik.Adaptor, trafficCalculator *traffic.Calculator, reconciler *traffic.Reconciler, renderer *service.PeerRenderer) error {
 	appCfg := config.GetAppConfig()
 
 	authenticationService := service.NewAuthentication(db)
+	adminService := service.NewAdmin(db)
 	schedulerService := service.NewScheduler(mikrotikAdaptor)
 	queueService := service.NewQueue(mikrotikAdaptor)
-	configGenerator := service.NewConfigGenerator(db)
-	qrCodeGenerator := service.NewQRCodeGenerator(db)
+	configGenerator := service.NewConfigGenerator(db, renderer)
+	qrCodeGenerator := service.NewQRCodeGenerator(db, renderer)
 	excelGenerator := service.NewExcelGenerator(db)
 	serverService := service.NewServerService(db, mwpClients, mikrotikAdaptor)
+	ipPoolService := service.NewIPPool(db, mikrotikAdaptor)
+	peerService := service.NewWGPeer(db, mikrotikAdaptor, schedulerService, queueService, renderer)
 	interfaceService := service.NewWgInterface(db, mikrotikAdaptor)
-	ipPoolService := service.NewIPPool(db)
-	peerService := service.NewWGPeer(db, mikrotikAdaptor, schedulerService, queueService, configGenerator, qrCodeGenerator)
+	peerPlanService := service.NewPeerPlan(db, peerService)
 	deviceDataService := service.NewDeviceData(db, mikrotikAdaptor, serverService, interfaceService, peerService)
-	syncService := service.NewSyncService(db, mikrotikAdaptor, configGenerator, qrCodeGenerator)
+	syncService := service.NewSyncService(db, mikrotikAdaptor, schedulerService, queueService, renderer)
 
 	e := echo.New()
 	e.Use(middleware.Logger())
 	e.Use(middleware.CORS())
 	e.Validator = &validate.CustomValidator{Validator: validator.New()}
+	// the client IP keys the login rate limits, forwarded headers are only honored behind a known proxy
+	if appCfg.TrustProxyHeaders {
+		e.IPExtractor = echo.ExtractIPFromXFFHeader()
+	} else {
+		e.IPExtractor = echo.ExtractIPDirect()
+	}
 
+	http.SetupMwpMetrics(e, config.GetMetricsConfig(), service.NewMetricsCollector(db, peerService))
 	http.SetupMwpUI(e, appCfg.UIAssetsFs)
 	http.SetupMwpAPI(
 		e,
 		mwpClients,
 		authenticationService,
+		adminService,
 		serverService,
 		interfaceService,
 		ipPoolService,
 		peerService,
+		peerPlanService,
 		configGenerator,
 		qrCodeGenerator,
 		excelGenerator,
 		deviceDataService,
 		trafficCalculator,
 		syncService,
+		reconciler,
+		service.NewRateLimiter(db, config.GetRateLimitConfig(), service.NewTelegramNotifier(config.GetTelegramConfig())),
+		service.NewAudit(db),
 	)
 
 	if appCfg.Port == "443" || appCfg.Port == "8443" {
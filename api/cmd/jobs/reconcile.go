@@ -0,0 +1,208 @@
+package traffic
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/maahdima/mwp/api/dataservice/model"
+	"github.com/maahdima/mwp/api/http/schema"
+	"github.com/maahdima/mwp/api/metrics"
+	"github.com/maahdima/mwp/api/service"
+)
+
+// Auto-heal categories accepted in SYNC_AUTO_HEAL
+const (
+	AutoHealDisabled  = "disabled"
+	AutoHealQueue     = "queue"
+	AutoHealScheduler = "scheduler"
+)
+
+// Reconciler periodically detects drift on every active server, fixes the safe subset selected by autoHeal and
+// leaves the rest pending for an admin
+type Reconciler struct {
+	db          *gorm.DB
+	syncService *service.SyncService
+	autoHeal    map[string]bool
+	mu          sync.Mutex
+	lastRun     *schema.ReconcileRunResponse
+	logger      *zap.Logger
+}
+
+func NewReconciler(db *gorm.DB, syncService *service.SyncService, autoHeal []string) *Reconciler {
+	heal := make(map[string]bool, len(autoHeal))
+	for _, category := range autoHeal {
+		heal[category] = true
+	}
+
+	return &Reconciler{
+		db:          db,
+		syncService: syncService,
+		autoHeal:    heal,
+		logger:      zap.L().Named("ReconcileJob"),
+	}
+}
+
+func (r *Reconciler) Reconcile() {
+	run := schema.ReconcileRunResponse{
+		StartedAt: time.Now().Format(time.RFC3339),
+		AutoHeal:  r.autoHealList(),
+		Servers:   []schema.ReconcileServerResult{},
+	}
+
+	var servers []model.Server
+	if err := r.db.Where("is_active = ?", true).Find(&servers).Error; err != nil {
+		r.logger.Error("Failed to fetch active servers", zap.Error(err))
+		return
+	}
+
+	for _, server := range servers {
+		result := r.reconcileServer(server)
+		recordDriftMetrics(result)
+		run.Servers = append(run.Servers, result)
+	}
+
+	run.FinishedAt = time.Now().Format(time.RFC3339)
+
+	r.mu.Lock()
+	r.lastRun = &run
+	r.mu.Unlock()
+
+	r.logger.Info("Reconciliation job completed", zap.Int("servers", len(servers)))
+}
+
+// LastRun returns the result of the last completed run, nil if the job has not run yet
+func (r *Reconciler) LastRun() *schema.ReconcileRunResponse {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.lastRun
+}
+
+func (r *Reconciler) reconcileServer(server model.Server) schema.ReconcileServerResult {
+	result := schema.ReconcileServerResult{
+		ServerId:   server.ID,
+		ServerName: server.Name,
+		Healed:     []schema.DriftItem{},
+		Pending:    []schema.DriftItem{},
+		Failed:     []schema.DriftHealFailure{},
+	}
+
+	snapshot, err := r.syncService.SnapshotDrift(server)
+	if err != nil {
+		r.logger.Error("Failed to detect drift", zap.String("server", server.Name), zap.Error(err))
+		result.Error = err.Error()
+		return result
+	}
+
+	for _, item := range snapshot.Items {
+		resolution, ok := r.autoHealResolution(item)
+		if !ok {
+			r.logger.Warn("Pending drift",
+				zap.String("server", server.Name),
+				zap.String("object", string(item.Object)),
+				zap.String("kind", string(item.Kind)),
+				zap.String("name", item.Name),
+				zap.Any("fields", item.Fields),
+			)
+			result.Pending = append(result.Pending, item)
+			continue
+		}
+
+		if err := r.syncService.ResolveDriftItem(server, snapshot, item, resolution); err != nil {
+			r.logger.Error("Failed to auto-heal drift", zap.String("server", server.Name), zap.String("name", item.Name), zap.Error(err))
+			result.Failed = append(result.Failed, schema.DriftHealFailure{Item: item, Error: err.Error()})
+			continue
+		}
+
+		r.logger.Info("Auto-healed drift", zap.String("server", server.Name), zap.String("object", string(item.Object)), zap.String("name", item.Name))
+		result.Healed = append(result.Healed, item)
+	}
+
+	return result
+}
+
+// autoHealResolution reports whether a drift item is safe to fix without an admin and in which direction. A queue or
+// scheduler the panel created that has been removed from the router is created again, and an interface disabled
+// flag flipped on the router is pushed back. A peer is only ever disabled automatically: the panel disabling it wins,
+// and an expired peer the router scheduler disabled is recorded as disabled. Re-enabling a peer is left to an admin.
+func (r *Reconciler) autoHealResolution(item schema.DriftItem) (schema.DriftResolution, bool) {
+	switch item.Object {
+	case schema.DriftObjectInterface:
+		if r.autoHeal[AutoHealDisabled] && onlyDisabledChanged(item) {
+			return schema.DriftPanelWins, true
+		}
+	case schema.DriftObjectPeer:
+		if !r.autoHeal[AutoHealDisabled] || !onlyDisabledChanged(item) {
+			return "", false
+		}
+		field := item.Fields[0]
+		if field.Panel == "true" {
+			return schema.DriftPanelWins, true
+		}
+		if field.Router == "true" && item.Expired {
+			return schema.DriftRouterWins, true
+		}
+	case schema.DriftObjectQueue:
+		if r.autoHeal[AutoHealQueue] && item.Kind == schema.DriftMissingOnRouter {
+			return schema.DriftPanelWins, true
+		}
+	case schema.DriftObjectScheduler:
+		if r.autoHeal[AutoHealScheduler] && item.Kind == schema.DriftMissingOnRouter {
+			return schema.DriftPanelWins, true
+		}
+	}
+
+	return "", false
+}
+
+func onlyDisabledChanged(item schema.DriftItem) bool {
+	if item.Kind != schema.DriftChanged || len(item.Fields) == 0 {
+		return false
+	}
+	for _, field := range item.Fields {
+		if field.Field != "disabled" {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Reconciler) autoHealList() []string {
+	list := make([]string, 0, len(r.autoHeal))
+	for _, category := range []string{AutoHealDisabled, AutoHealQueue, AutoHealScheduler} {
+		if r.autoHeal[category] {
+			list = append(list, category)
+		}
+	}
+	return list
+}
+
+// recordDriftMetrics replaces the drift series of a server with the outcome of its last reconciliation, a server
+// whose drift could not be detected keeps the previous values
+func recordDriftMetrics(result schema.ReconcileServerResult) {
+	if result.Error != "" {
+		return
+	}
+
+	metrics.SyncDriftItems.DeletePartialMatch(prometheus.Labels{"server": result.ServerName})
+
+	for _, object := range []schema.DriftObject{schema.DriftObjectInterface, schema.DriftObjectPeer, schema.DriftObjectQueue, schema.DriftObjectScheduler} {
+		for _, state := range []string{"healed", "pending", "failed"} {
+			metrics.SyncDriftItems.WithLabelValues(result.ServerName, string(object), state).Set(0)
+		}
+	}
+
+	for _, item := range result.Healed {
+		metrics.SyncDriftItems.WithLabelValues(result.ServerName, string(item.Object), "healed").Inc()
+	}
+	for _, item := range result.Pending {
+		metrics.SyncDriftItems.WithLabelValues(result.ServerName, string(item.Object), "pending").Inc()
+	}
+	for _, failure := range result.Failed {
+		metrics.SyncDriftItems.WithLabelValues(result.ServerName, string(failure.Item.Object), "failed").Inc()
+	}
+}
@@ -0,0 +1,136 @@
+package traffic
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/maahdima/mwp/api/adaptor/mikrotik"
+	"github.com/maahdima/mwp/api/adaptor/mikrotik/mikrotiktest"
+	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/config"
+	"github.com/maahdima/mwp/api/dataservice"
+	"github.com/maahdima/mwp/api/dataservice/model"
+	"github.com/maahdima/mwp/api/http/schema"
+	"github.com/maahdima/mwp/api/service"
+	"github.com/maahdima/mwp/api/utils"
+	"github.com/maahdima/mwp/api/utils/wireguard"
+)
+
+func TestReconcileHealsFromOneSnapshot(t *testing.T) {
+	t.Setenv("DATA_DIR", t.TempDir())
+	t.Setenv("DB_DIALECT", "sqlite")
+	if _, err := dataservice.LoadKeyring(config.GetEncryptionConfig()); err != nil {
+		t.Fatal(err)
+	}
+	db, err := dataservice.ConnectDB(config.GetDBConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if err := dataservice.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	router := mikrotiktest.NewServer()
+	t.Cleanup(router.Close)
+	server := router.ModelServer("r1")
+	if err := db.Create(&server).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	ifaceID, err := router.Seed(common.WGInterfacePath, mikrotiktest.Record{"name": "wg0", "listen-port": "51820"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := router.Record(common.WGInterfacePath, ifaceID)
+	iface := model.Interface{
+		ServerID:    server.ID,
+		InterfaceID: ifaceID,
+		Name:        "wg0",
+		PrivateKey:  record["private-key"],
+		PublicKey:   record["public-key"],
+		ListenPort:  record["listen-port"],
+	}
+	if err := db.Create(&iface).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	adaptor := mikrotik.NewAdaptor(common.NewMwpClients(db))
+	scheduler, queue := service.NewScheduler(adaptor), service.NewQueue(adaptor)
+	renderer := service.NewPeerRenderer(db, config.GetPeerRenderConfig())
+	peers := service.NewWGPeer(db, adaptor, scheduler, queue, renderer)
+
+	var created []model.Peer
+	for _, peer := range []struct{ name, address, expireTime string }{
+		{"alice", "10.0.0.2/32", "2020-01-01"},
+		{"bob", "10.0.0.3/32", "2030-01-01"},
+	} {
+		raw, privateKey, err := wireguard.GeneratePrivateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		publicKey, err := wireguard.GeneratePublicKey(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := peers.CreatePeer(&schema.CreatePeerRequest{
+			Name:           peer.name,
+			InterfaceId:    iface.ID,
+			PrivateKey:     privateKey,
+			PublicKey:      publicKey,
+			AllowedAddress: peer.address,
+			Endpoint:       "vpn.example.com",
+			ExpireTime:     utils.Ptr(peer.expireTime),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var dbPeer model.Peer
+		if err := db.First(&dbPeer, resp.Id).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := adaptor.DeleteSimpleQueue(context.Background(), server, *dbPeer.QueueID); err != nil {
+			t.Fatal(err)
+		}
+		created = append(created, dbPeer)
+	}
+
+	// the scheduler of alice fired on the router
+	alice := created[0]
+	if err := router.Set(common.WGPeerPath, alice.PeerID, mikrotiktest.Record{"disabled": "true"}); err != nil {
+		t.Fatal(err)
+	}
+
+	syncService := service.NewSyncService(db, adaptor, scheduler, queue, renderer)
+	reconciler := NewReconciler(db, syncService, []string{AutoHealDisabled, AutoHealQueue, AutoHealScheduler})
+	before := router.CallCount(http.MethodGet, common.WGPeerPath)
+	reconciler.Reconcile()
+
+	run := reconciler.LastRun()
+	if run == nil || len(run.Servers) != 1 {
+		t.Fatalf("unexpected run %+v", run)
+	}
+	result := run.Servers[0]
+	if len(result.Healed) != 3 || len(result.Pending) != 0 || len(result.Failed) != 0 {
+		t.Fatalf("healed %d, pending %d, failed %d, want the two queues and the expired peer healed",
+			len(result.Healed), len(result.Pending), len(result.Failed))
+	}
+	if calls := router.CallCount(http.MethodGet, common.WGPeerPath) - before; calls != 1 {
+		t.Fatalf("peers were fetched %d times, want once per run", calls)
+	}
+
+	if records := router.Records(common.QueuePath); len(records) != 2 {
+		t.Fatalf("router has %d queues, want both recreated", len(records))
+	}
+	if err := db.First(&alice, alice.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !alice.Disabled {
+		t.Fatal("expired peer the router disabled was not recorded as disabled")
+	}
+}
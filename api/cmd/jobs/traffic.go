@@ -6,9 +6,12 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/maahdima/mwp/api/adaptor/mikrotik"
+	"github.com/maahdima/mwp/api/common"
 	"github.com/maahdima/mwp/api/dataservice/model"
+	"github.com/maahdima/mwp/api/metrics"
 	"github.com/maahdima/mwp/api/utils"
 
 	"go.uber.org/zap"
@@ -41,6 +44,11 @@ func (c *Calculator) CalculatePeerTraffic() {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	start := time.Now()
+	defer func() {
+		metrics.TrafficJobDuration.Observe(time.Since(start).Seconds())
+	}()
+
 	peers, err := c.fetchPeers()
 	if err != nil {
 		return
@@ -52,10 +60,24 @@ func (c *Calculator) CalculatePeerTraffic() {
 
 	const maxCounter = 4294967296 // mikrotik 32-bit counter bug in wg peers (2^32)
 
+	// a server counts as collected once a peer of it was read and as failed once one could not be
+	collected := make(map[uint]bool)
+	failed := make(map[uint]bool)
 	for _, peer := range peers {
-		c.processPeerTraffic(peer, maxCounter)
+		if err := c.processPeerTraffic(peer, maxCounter); err != nil {
+			failed[peer.ServerID] = true
+			continue
+		}
+		collected[peer.ServerID] = true
 	}
 
+	if len(failed) > 0 {
+		c.logger.Warn("Peer Traffic calculation job completed with router errors", zap.Int("failedServers", len(failed)))
+		return
+	}
+	if len(collected) > 0 {
+		metrics.TrafficJobLastSuccess.SetToCurrentTime()
+	}
 	c.logger.Info("Peer Traffic calculation job completed")
 }
 
@@ -64,7 +86,7 @@ func (c *Calculator) CalculateDailyTraffic() {
 	defer c.mu.Unlock()
 
 	var interfaces []model.Interface
-	if err := c.db.Find(&interfaces).Error; err != nil {
+	if err := c.db.Preload("Server").Find(&interfaces).Error; err != nil {
 		c.logger.Error("Failed to fetch interfaces from database", zap.Error(err))
 		return
 	}
@@ -75,7 +97,7 @@ func (c *Calculator) CalculateDailyTraffic() {
 	}
 
 	for _, iface := range interfaces {
-		wgInterface, err := c.mikrotikAdaptor.FetchInterface(context.Background(), iface.InterfaceID)
+		wgInterface, err := c.mikrotikAdaptor.FetchInterface(context.Background(), iface.Server, iface.InterfaceID)
 		if err != nil {
 			c.logger.Error("Failed to fetch WireGuard interface", zap.String("interfaceID", iface.InterfaceID), zap.Error(err))
 			continue
@@ -126,12 +148,12 @@ func (c *Calculator) ResetPeerUsage(id uint) error {
 	defer c.mu.Unlock()
 
 	var peer model.Peer
-	if err := c.db.Where("id = ?", id).First(&peer).Error; err != nil {
+	if err := c.db.Preload("Server").Where("id = ?", id).First(&peer).Error; err != nil {
 		c.logger.Error("Failed to find peer in DB", zap.Uint("id", id), zap.Error(err))
 		return err
 	}
 
-	wgPeer, err := c.mikrotikAdaptor.FetchWgPeer(context.Background(), peer.PeerID)
+	wgPeer, err := c.mikrotikAdaptor.FetchWgPeer(context.Background(), peer.Server, peer.PeerID)
 	if err != nil {
 		c.logger.Error("Failed to fetch peer from Mikrotik", zap.String("peerID", peer.PeerID), zap.Error(err))
 		return err
@@ -171,24 +193,33 @@ func (c *Calculator) ResetPeerUsages() error {
 	defer c.mu.Unlock()
 
 	var peers []model.Peer
-	if err := c.db.Find(&peers).Error; err != nil {
+	if err := c.db.Preload("Server").Find(&peers).Error; err != nil {
 		c.logger.Error("Failed to find peers in DB", zap.Error(err))
 		return err
 	}
 
-	wgPeers, err := c.mikrotikAdaptor.FetchWgPeers(context.Background())
-	if err != nil {
-		c.logger.Error("Failed to fetch peers from Mikrotik", zap.Error(err))
-		return err
-	}
+	// peer IDs are only unique per router, so fetch and index each server's peers separately
+	wgPeerMaps := make(map[uint]map[string]mikrotik.WireGuardPeer)
+	for _, peer := range peers {
+		if _, fetched := wgPeerMaps[peer.ServerID]; fetched {
+			continue
+		}
+
+		wgPeers, err := c.mikrotikAdaptor.FetchWgPeers(context.Background(), peer.Server)
+		if err != nil {
+			c.logger.Error("Failed to fetch peers from Mikrotik", zap.String("server", peer.Server.Name), zap.Error(err))
+			return err
+		}
 
-	wgPeerMap := make(map[string]mikrotik.WireGuardPeer)
-	for _, wgPeer := range wgPeers {
-		wgPeerMap[wgPeer.ID] = wgPeer
+		wgPeerMap := make(map[string]mikrotik.WireGuardPeer)
+		for _, wgPeer := range wgPeers {
+			wgPeerMap[wgPeer.ID] = wgPeer
+		}
+		wgPeerMaps[peer.ServerID] = wgPeerMap
 	}
 
 	for _, peer := range peers {
-		wgPeer, found := wgPeerMap[peer.PeerID]
+		wgPeer, found := wgPeerMaps[peer.ServerID][peer.PeerID]
 		if !found {
 			continue
 		}
@@ -216,18 +247,18 @@ func (c *Calculator) ResetPeerUsages() error {
 
 func (c *Calculator) fetchPeers() ([]model.Peer, error) {
 	var peers []model.Peer
-	if err := c.db.Find(&peers).Error; err != nil {
+	if err := c.db.Preload("Server").Find(&peers).Error; err != nil {
 		c.logger.Error("Failed to fetch peers from database", zap.Error(err))
 		return nil, err
 	}
 	return peers, nil
 }
 
-func (c *Calculator) processPeerTraffic(peer model.Peer, maxCounter int64) {
-	wgPeer, err := c.mikrotikAdaptor.FetchWgPeer(context.Background(), peer.PeerID)
+func (c *Calculator) processPeerTraffic(peer model.Peer, maxCounter int64) error {
+	wgPeer, err := c.mikrotikAdaptor.FetchWgPeer(context.Background(), peer.Server, peer.PeerID)
 	if err != nil {
 		c.logger.Error("Failed to fetch wireguard peer", zap.String("peerID", peer.PeerID), zap.Error(err))
-		return
+		return err
 	}
 
 	currentTx := utils.ParseStringToInt(wgPeer.TransferTx)
@@ -262,6 +293,8 @@ func (c *Calculator) processPeerTraffic(peer model.Peer, maxCounter int64) {
 	c.applyPeerTrafficNotifications(&peer, updates)
 	c.applyPeerTrafficLimit(&peer, updates)
 	c.persistPeerTraffic(peer, updates)
+	c.recordPeerTrafficSample(peer, deltaTx, deltaRx, time.Now())
+	return nil
 }
 
 func (c *Calculator) calculatePeerDeltas(peer model.Peer, currentTx, currentRx, maxCounter int64) (int64, int64, bool) {
@@ -273,10 +306,13 @@ func (c *Calculator) calculatePeerDeltas(peer model.Peer, currentTx, currentRx,
 func (c *Calculator) applyPeerTrafficLimit(peer *model.Peer, updates map[string]interface{}) {
 	if peer.TrafficLimit != nil && (peer.DownloadUsage+peer.UploadUsage) > *peer.TrafficLimit {
 		c.logger.Warn("Peer traffic limit exceeded", zap.String("peerID", peer.PeerID))
+		if !peer.Disabled {
+			updates["disabled_reason"] = common.PeerDisabledByTrafficLimit
+		}
 		peer.Disabled = true
 		updates["disabled"] = true
 
-		_, err := c.mikrotikAdaptor.UpdateWgPeer(context.Background(), peer.PeerID, mikrotik.WireGuardPeer{
+		_, err := c.mikrotikAdaptor.UpdateWgPeer(context.Background(), peer.Server, peer.PeerID, mikrotik.WireGuardPeer{
 			Disabled: strconv.FormatBool(true),
 		})
 		if err != nil {
@@ -0,0 +1,82 @@
+package traffic
+
+import (
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/maahdima/mwp/api/config"
+	"github.com/maahdima/mwp/api/dataservice/model"
+)
+
+// recordPeerTrafficSample stores the traffic of one job run and adds it to the hour and day buckets it falls in
+func (c *Calculator) recordPeerTrafficSample(peer model.Peer, deltaTx, deltaRx int64, at time.Time) {
+	if deltaTx == 0 && deltaRx == 0 {
+		return
+	}
+
+	buckets := map[string]time.Time{
+		model.TrafficGranularityRaw:  at,
+		model.TrafficGranularityHour: at.Truncate(time.Hour),
+		model.TrafficGranularityDay:  time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, at.Location()),
+	}
+
+	err := c.db.Transaction(func(tx *gorm.DB) error {
+		for granularity, start := range buckets {
+			sample := model.PeerTrafficSample{
+				PeerID:        peer.ID,
+				Granularity:   granularity,
+				BucketStart:   start.Unix(),
+				DownloadUsage: deltaTx,
+				UploadUsage:   deltaRx,
+			}
+
+			err := tx.Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "peer_id"}, {Name: "granularity"}, {Name: "bucket_start"}},
+				DoUpdates: clause.Assignments(map[string]interface{}{
+					"download_usage": gorm.Expr("peer_traffic_samples.download_usage + ?", deltaTx),
+					"upload_usage":   gorm.Expr("peer_traffic_samples.upload_usage + ?", deltaRx),
+					"updated_at":     at.Unix(),
+				}),
+			}).Create(&sample).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.logger.Error("Failed to record peer traffic sample", zap.String("peerID", peer.PeerID), zap.Error(err))
+	}
+}
+
+// PrunePeerTrafficSamples removes the peer traffic samples older than the retention of their granularity
+func (c *Calculator) PrunePeerTrafficSamples(cfg config.TrafficHistoryConfig) {
+	retentions := map[string]string{
+		model.TrafficGranularityRaw:  cfg.RawRetention,
+		model.TrafficGranularityHour: cfg.HourlyRetention,
+		model.TrafficGranularityDay:  cfg.DailyRetention,
+	}
+
+	for granularity, retention := range retentions {
+		days, err := strconv.Atoi(retention)
+		if err != nil || days <= 0 {
+			c.logger.Warn("Invalid traffic retention, skipping", zap.String("granularity", granularity), zap.String("days", retention))
+			continue
+		}
+
+		cutoff := time.Now().AddDate(0, 0, -days).Unix()
+		result := c.db.Unscoped().
+			Where("granularity = ? AND bucket_start < ?", granularity, cutoff).
+			Delete(&model.PeerTrafficSample{})
+		if result.Error != nil {
+			c.logger.Error("Failed to prune peer traffic samples", zap.String("granularity", granularity), zap.Error(result.Error))
+			continue
+		}
+
+		c.logger.Info("Pruned peer traffic samples", zap.String("granularity", granularity), zap.Int64("rows", result.RowsAffected))
+	}
+}
@@ -0,0 +1,228 @@
+package traffic
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gorm.io/gorm"
+
+	"github.com/maahdima/mwp/api/adaptor/mikrotik"
+	"github.com/maahdima/mwp/api/adaptor/mikrotik/mikrotiktest"
+	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/config"
+	"github.com/maahdima/mwp/api/dataservice"
+	"github.com/maahdima/mwp/api/dataservice/model"
+	"github.com/maahdima/mwp/api/metrics"
+	"github.com/maahdima/mwp/api/utils"
+	"github.com/maahdima/mwp/api/utils/wireguard"
+)
+
+// newTestCalculator returns a calculator over a migrated database with one peer on a fake router
+func newTestCalculator(t *testing.T) (*Calculator, *gorm.DB, *mikrotiktest.Server, model.Peer) {
+	t.Helper()
+
+	t.Setenv("DATA_DIR", t.TempDir())
+	t.Setenv("DB_DIALECT", "sqlite")
+	if _, err := dataservice.LoadKeyring(config.GetEncryptionConfig()); err != nil {
+		t.Fatal(err)
+	}
+	db, err := dataservice.ConnectDB(config.GetDBConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if err := dataservice.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	router := mikrotiktest.NewServer()
+	t.Cleanup(router.Close)
+	server := router.ModelServer("r1")
+	if err := db.Create(&server).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := router.Seed(common.WGInterfacePath, mikrotiktest.Record{"name": "wg0"}); err != nil {
+		t.Fatal(err)
+	}
+	raw, privateKey, err := wireguard.GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, err := wireguard.GeneratePublicKey(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	peerID, err := router.Seed(common.WGPeerPath, mikrotiktest.Record{
+		"interface":       "wg0",
+		"name":            "alice",
+		"public-key":      publicKey,
+		"allowed-address": "10.0.0.2/32",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	peer := model.Peer{
+		ServerID:       server.ID,
+		UUID:           "alice",
+		PeerID:         peerID,
+		Name:           "alice",
+		Interface:      "wg0",
+		PrivateKey:     privateKey,
+		PublicKey:      publicKey,
+		AllowedAddress: "10.0.0.2/32",
+	}
+	if err := db.Create(&peer).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	adaptor := mikrotik.NewAdaptor(common.NewMwpClients(db))
+	return NewTrafficCalculator(db, adaptor, nil), db, router, peer
+}
+
+func assertUsage(t *testing.T, db *gorm.DB, id uint, download, upload int64) {
+	t.Helper()
+
+	var peer model.Peer
+	if err := db.First(&peer, id).Error; err != nil {
+		t.Fatal(err)
+	}
+	if peer.DownloadUsage != download || peer.UploadUsage != upload {
+		t.Fatalf("usage %d/%d, want %d/%d", peer.DownloadUsage, peer.UploadUsage, download, upload)
+	}
+}
+
+func TestCalculatePeerTrafficCounterReset(t *testing.T) {
+	calculator, db, router, peer := newTestCalculator(t)
+
+	if err := router.SetPeerTraffic(peer.PeerID, 500, 1000); err != nil {
+		t.Fatal(err)
+	}
+	calculator.CalculatePeerTraffic()
+	assertUsage(t, db, peer.ID, 1000, 500)
+
+	// a router reboot starts the counters over, only what was counted since is added
+	router.ResetCounters()
+	if err := router.AddPeerTraffic(peer.PeerID, 200, 300); err != nil {
+		t.Fatal(err)
+	}
+	calculator.CalculatePeerTraffic()
+	assertUsage(t, db, peer.ID, 1300, 700)
+
+	calculator.CalculatePeerTraffic()
+	assertUsage(t, db, peer.ID, 1300, 700)
+}
+
+func TestCalculatePeerTrafficCounterWrap(t *testing.T) {
+	calculator, db, router, peer := newTestCalculator(t)
+
+	if err := router.SetPeerTraffic(peer.PeerID, 0, mikrotiktest.PeerCounterWrap-100); err != nil {
+		t.Fatal(err)
+	}
+	calculator.CalculatePeerTraffic()
+	assertUsage(t, db, peer.ID, mikrotiktest.PeerCounterWrap-100, 0)
+
+	if err := router.AddPeerTraffic(peer.PeerID, 0, 300); err != nil {
+		t.Fatal(err)
+	}
+	calculator.CalculatePeerTraffic()
+	assertUsage(t, db, peer.ID, mikrotiktest.PeerCounterWrap+200, 0)
+}
+
+func TestCalculatePeerTrafficDisablesPeerOverLimit(t *testing.T) {
+	calculator, db, router, peer := newTestCalculator(t)
+
+	if err := db.Model(&peer).Update("traffic_limit", 1000).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := router.SetPeerTraffic(peer.PeerID, 600, 600); err != nil {
+		t.Fatal(err)
+	}
+	calculator.CalculatePeerTraffic()
+
+	if err := db.First(&peer, peer.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !peer.Disabled || utils.DerefString(peer.DisabledReason) != common.PeerDisabledByTrafficLimit {
+		t.Fatalf("peer over its limit: disabled %t, reason %q", peer.Disabled, utils.DerefString(peer.DisabledReason))
+	}
+	if disabled := router.Record(common.WGPeerPath, peer.PeerID)["disabled"]; disabled != "true" {
+		t.Fatalf("peer over its limit is not disabled on the router: %q", disabled)
+	}
+}
+
+func TestCalculatePeerTrafficRecordsSamples(t *testing.T) {
+	calculator, db, router, peer := newTestCalculator(t)
+
+	if err := router.SetPeerTraffic(peer.PeerID, 500, 1000); err != nil {
+		t.Fatal(err)
+	}
+	calculator.CalculatePeerTraffic()
+	if err := router.AddPeerTraffic(peer.PeerID, 50, 100); err != nil {
+		t.Fatal(err)
+	}
+	calculator.CalculatePeerTraffic()
+
+	for granularity, rows := range map[string]int{model.TrafficGranularityHour: 1, model.TrafficGranularityDay: 1} {
+		var samples []model.PeerTrafficSample
+		if err := db.Where("peer_id = ? AND granularity = ?", peer.ID, granularity).Find(&samples).Error; err != nil {
+			t.Fatal(err)
+		}
+		if len(samples) != rows {
+			t.Fatalf("got %d %s samples, want %d", len(samples), granularity, rows)
+		}
+		if samples[0].DownloadUsage != 1100 || samples[0].UploadUsage != 550 {
+			t.Fatalf("%s sample %d/%d, want 1100/550", granularity, samples[0].DownloadUsage, samples[0].UploadUsage)
+		}
+	}
+}
+
+func TestPrunePeerTrafficSamples(t *testing.T) {
+	calculator, db, _, peer := newTestCalculator(t)
+
+	old := time.Now().AddDate(0, 0, -3)
+	samples := []model.PeerTrafficSample{
+		{PeerID: peer.ID, Granularity: model.TrafficGranularityRaw, BucketStart: old.Unix(), DownloadUsage: 1},
+		{PeerID: peer.ID, Granularity: model.TrafficGranularityRaw, BucketStart: time.Now().Unix(), DownloadUsage: 1},
+		{PeerID: peer.ID, Granularity: model.TrafficGranularityHour, BucketStart: old.Truncate(time.Hour).Unix(), DownloadUsage: 1},
+	}
+	if err := db.Create(&samples).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	calculator.PrunePeerTrafficSamples(config.TrafficHistoryConfig{RawRetention: "2", HourlyRetention: "30", DailyRetention: "365"})
+
+	var kept []model.PeerTrafficSample
+	if err := db.Order("id").Find(&kept).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(kept) != 2 || kept[0].ID != samples[1].ID || kept[1].ID != samples[2].ID {
+		t.Fatalf("kept %+v, want the recent raw and the hourly sample", kept)
+	}
+}
+
+func TestCalculatePeerTrafficLastSuccessNeedsEveryRouter(t *testing.T) {
+	calculator, _, router, peer := newTestCalculator(t)
+	metrics.TrafficJobLastSuccess.Set(0)
+
+	router.InjectFault(mikrotiktest.Error(http.MethodGet, common.WGPeerPath, http.StatusInternalServerError))
+	calculator.CalculatePeerTraffic()
+	if last := testutil.ToFloat64(metrics.TrafficJobLastSuccess); last != 0 {
+		t.Fatalf("a run the router failed was marked successful at %v", last)
+	}
+
+	router.ClearFaults()
+	if err := router.SetPeerTraffic(peer.PeerID, 10, 10); err != nil {
+		t.Fatal(err)
+	}
+	calculator.CalculatePeerTraffic()
+	if last := testutil.ToFloat64(metrics.TrafficJobLastSuccess); last == 0 {
+		t.Fatal("a run that read every router was not marked successful")
+	}
+}
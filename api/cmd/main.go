@@ -2,10 +2,13 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"strconv"
+	"text/tabwriter"
 	"time"
 
 	"github.com/go-co-op/gocron/v2"
+	"gorm.io/gorm"
 
 	"github.com/maahdima/mwp/api/adaptor/mikrotik"
 	"github.com/maahdima/mwp/api/cmd/http-server"
@@ -29,16 +32,23 @@ func main() {
 
 	appCfg := config.GetAppConfig()
 
+	if _, err := dataservice.LoadKeyring(config.GetEncryptionConfig()); err != nil {
+		logger.Panic("Failed to load encryption key", zap.Error(err))
+	}
+
 	db, err := dataservice.ConnectDB(config.GetDBConfig())
 	if err != nil {
 		logger.Panic("Failed to connect to database", zap.Error(err))
 	}
 
-	if err := dataservice.AutoMigrate(db); err != nil {
-		logger.Panic("Failed to auto-migrate database", zap.Error(err))
+	if len(os.Args) > 1 {
+		runCommand(db, os.Args[1:])
+		return
 	}
 
-	// TODO: add db migration (gorm)
+	if err := dataservice.Migrate(db); err != nil {
+		logger.Panic("Failed to migrate database", zap.Error(err))
+	}
 
 	err = seeds.AdminSeed(db)
 	if err != nil {
@@ -53,6 +63,7 @@ func main() {
 	mikrotikAdaptor := mikrotik.NewAdaptor(mwpClients)
 	telegramNotifier := service.NewTelegramNotifier(config.GetTelegramConfig())
 	trafficCalculator := traffic.NewTrafficCalculator(db, mikrotikAdaptor, telegramNotifier)
+	peerRenderer := service.NewPeerRenderer(db, config.GetPeerRenderConfig())
 
 	// Start the traffic calculation job
 	scheduler, err := gocron.NewScheduler()
@@ -79,10 +90,147 @@ func main() {
 		logger.Panic("Failed to create daily traffic calculation job", zap.Error(err))
 	}
 
+	_, err = scheduler.NewJob(
+		gocron.DurationJob(time.Hour),
+		gocron.NewTask(trafficCalculator.PrunePeerTrafficSamples, config.GetTrafficHistoryConfig()))
+	if err != nil {
+		logger.Panic("Failed to create traffic history pruning job", zap.Error(err))
+	}
+
+	syncService := service.NewSyncService(
+		db,
+		mikrotikAdaptor,
+		service.NewScheduler(mikrotikAdaptor),
+		service.NewQueue(mikrotikAdaptor),
+		peerRenderer,
+	)
+	reconciler := traffic.NewReconciler(db, syncService, config.GetSyncConfig().AutoHeal)
+
+	syncJobInterval, _ := strconv.Atoi(appCfg.SyncJobInterval)
+	if syncJobInterval > 0 {
+		_, err = scheduler.NewJob(
+			gocron.DurationJob(
+				time.Duration(syncJobInterval)*time.Second),
+			gocron.NewTask(reconciler.Reconcile),
+			gocron.WithSingletonMode(gocron.LimitModeReschedule))
+		if err != nil {
+			logger.Panic("Failed to create reconciliation job", zap.Error(err))
+		}
+	}
+
 	scheduler.Start()
 
 	// Start the HTTP server
-	if err := httpserver.StartHttpServer(db, mwpClients, mikrotikAdaptor, trafficCalculator); err != nil {
+	if err := httpserver.StartHttpServer(db, mwpClients, mikrotikAdaptor, trafficCalculator, reconciler, peerRenderer); err != nil {
 		logger.Panic("Failed to start HTTP server", zap.Error(err))
 	}
 }
+
+// runCommand runs a maintenance command instead of the server
+func runCommand(db *gorm.DB, args []string) {
+	switch args[0] {
+	case "migrate":
+		migrateDatabase(db, args[1:])
+	case "rotate-key":
+		if err := dataservice.Migrate(db); err != nil {
+			zap.L().Panic("Failed to migrate database", zap.Error(err))
+		}
+		rotateEncryptionKey(db)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q, available commands: migrate, rotate-key\n", args[0])
+		os.Exit(2)
+	}
+}
+
+// migrateDatabase runs "migrate up [steps]", "migrate down [steps]" or "migrate status". Up applies every pending
+// migration unless steps is given, down reverts the latest one and never goes below the baseline (version 1).
+func migrateDatabase(db *gorm.DB, args []string) {
+	logger := zap.L()
+
+	if len(args) == 0 || len(args) > 2 {
+		fmt.Fprintln(os.Stderr, "usage: mwp migrate up|down|status [steps]")
+		os.Exit(2)
+	}
+
+	steps := 0
+	if args[0] == "down" {
+		steps = 1
+	}
+	if len(args) == 2 {
+		var err error
+		if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+			fmt.Fprintf(os.Stderr, "invalid number of steps %q\n", args[1])
+			os.Exit(2)
+		}
+	}
+
+	switch args[0] {
+	case "up":
+		done, err := dataservice.MigrateUp(db, steps)
+		for _, migration := range done {
+			fmt.Printf("applied %d %s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			logger.Panic("Failed to migrate database", zap.Error(err))
+		}
+		if len(done) == 0 {
+			fmt.Println("the database is up to date")
+		}
+	case "down":
+		done, err := dataservice.MigrateDown(db, steps)
+		for _, migration := range done {
+			fmt.Printf("reverted %d %s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			logger.Panic("Failed to revert migration", zap.Error(err))
+		}
+	case "status":
+		statuses, err := dataservice.GetMigrationStatus(db)
+		if err != nil {
+			logger.Panic("Failed to get migration status", zap.Error(err))
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = time.Unix(*status.AppliedAt, 0).Format(time.RFC3339)
+			}
+			if status.Up == nil {
+				appliedAt += " (unknown to this version)"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
+		_ = w.Flush()
+	default:
+		fmt.Fprintf(os.Stderr, "unknown migrate action %q, expected up, down or status\n", args[0])
+		os.Exit(2)
+	}
+}
+
+// rotateEncryptionKey moves every secret to a new master key, only the data keys are re-encrypted. The server must be
+// stopped as it keeps the old key in memory.
+func rotateEncryptionKey(db *gorm.DB) {
+	logger := zap.L()
+	cfg := config.GetEncryptionConfig()
+
+	keyring, err := dataservice.RotateKeyring(cfg)
+	if err != nil {
+		logger.Panic("Failed to create new encryption key", zap.Error(err))
+	}
+
+	rows, err := dataservice.SealSecrets(db)
+	if err != nil {
+		logger.Panic("Failed to rewrap secrets", zap.Error(err))
+	}
+
+	if err := dataservice.ForgetPreviousKeys(cfg); err != nil {
+		logger.Panic("Failed to remove previous encryption keys", zap.Error(err))
+	}
+
+	fmt.Printf("rewrapped %d secrets under key %s\n", rows, keyring.KeyID())
+	if cfg.Key != "" {
+		fmt.Println("ENCRYPTION_PREVIOUS_KEYS can be cleared now")
+	}
+}
@@ -2,9 +2,10 @@ package common
 
 import (
 	"context"
-	"crypto/tls"
+	"errors"
 	"fmt"
-	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -12,148 +13,188 @@ import (
 	"gorm.io/gorm"
 
 	"github.com/maahdima/mwp/api/dataservice/model"
-	"github.com/maahdima/mwp/api/http/schema"
-	"github.com/maahdima/mwp/api/utils"
 	"github.com/maahdima/mwp/api/utils/httphelper"
 )
 
+// connectionCheckTTL is how long the outcome of a connection check is reused before the router is asked again
+const connectionCheckTTL = 15 * time.Second
+
 type MwpClients struct {
-	db      *gorm.DB
-	mu      sync.RWMutex
-	clients map[string]*httphelper.Client
-	logger  *zap.Logger
+	db          *gorm.DB
+	mu          sync.RWMutex
+	clients     map[uint]*httphelper.Client
+	connections map[uint]connectionState
+	logger      *zap.Logger
+}
+
+type connectionState struct {
+	connected bool
+	checkedAt time.Time
 }
 
 func NewMwpClients(db *gorm.DB) *MwpClients {
 	return &MwpClients{
-		db:      db,
-		mu:      sync.RWMutex{},
-		clients: make(map[string]*httphelper.Client),
-		logger:  zap.L().Named("mwpClients"),
+		db:          db,
+		mu:          sync.RWMutex{},
+		clients:     make(map[uint]*httphelper.Client),
+		connections: make(map[uint]connectionState),
+		logger:      zap.L().Named("mwpClients"),
 	}
 }
 
-// IsConnected Check if the specified client is connected (not nil)
-func (c *MwpClients) IsConnected(serverName *string) bool {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-
-	var server model.Server
-	var name string
-
-	if serverName == nil {
-		if err := c.db.First(&server).Error; err != nil {
-			c.logger.Error("Failed to fetch server from database", zap.Error(err))
-			return false
-		}
-		name = server.Name
-	} else {
-		name = utils.DerefString(serverName)
-
-		if err := c.db.Where("name = ?", name).First(&server).Error; err != nil {
-			c.logger.Error("Failed to fetch server by name", zap.String("serverName", name), zap.Error(err))
-			return false
+// IsConnected Check if the specified server answers on its REST API, the outcome is cached for connectionCheckTTL
+func (c *MwpClients) IsConnected(server model.Server) bool {
+	if server.ID != 0 {
+		c.mu.RLock()
+		state, ok := c.connections[server.ID]
+		c.mu.RUnlock()
+		if ok && time.Since(state.checkedAt) < connectionCheckTTL {
+			return state.connected
 		}
 	}
 
-	client, ok := c.clients[name]
-	if !ok || client == nil {
-		c.logger.Error("Client not found in mwp clients", zap.String("serverName", name))
-		return false
+	connected := c.checkConnection(server)
+	if server.ID != 0 {
+		c.mu.Lock()
+		c.connections[server.ID] = connectionState{connected: connected, checkedAt: time.Now()}
+		c.mu.Unlock()
 	}
 
-	transport := http.DefaultTransport.(*http.Transport).Clone()
-	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	return connected
+}
 
-	httpClient := &http.Client{
-		Transport: transport,
-		Timeout:   5 * time.Second,
+func (c *MwpClients) checkConnection(server model.Server) bool {
+	client, err := c.GetClient(server)
+	if err != nil {
+		c.logger.Error("Client not found in mwp clients", zap.String("serverName", server.Name), zap.Error(err))
+		return false
 	}
 
-	uri := fmt.Sprintf("http://%s:%d/rest/system/identity", server.IPAddress, server.APIPort)
-
-	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, uri, nil)
-	if err != nil {
-		c.logger.Error("Failed to create request to mikrotik REST API", zap.String("serverName", name), zap.Error(err))
+	if err := client.Get(context.Background(), DeviceIdentityPath, nil); err != nil {
+		c.logger.Error("HTTP request to mikrotik REST API failed", zap.String("serverName", server.Name), zap.Error(err))
 		return false
 	}
 
-	req.SetBasicAuth(server.Username, server.Password)
+	return true
+}
+
+// ResolveServer Find an active server by name or ID, an empty ref falls back to the only active server
+func (c *MwpClients) ResolveServer(ref string) (*model.Server, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return c.soleActiveServer()
+	}
 
-	resp, err := httpClient.Do(req)
+	var server model.Server
+	err := c.db.Where("name = ?", ref).First(&server).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		id, parseErr := strconv.ParseUint(ref, 10, 64)
+		if parseErr != nil {
+			return nil, ErrServerNotFound
+		}
+		err = c.db.First(&server, id).Error
+	}
 	if err != nil {
-		c.logger.Error("HTTP request to mikrotik REST API failed", zap.String("serverName", name), zap.Error(err))
-		return false
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrServerNotFound
+		}
+		c.logger.Error("Failed to fetch server from database", zap.String("server", ref), zap.Error(err))
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		c.logger.Error("Unexpected status code from mikrotik REST API",
-			zap.String("serverName", name),
-			zap.Int("status", resp.StatusCode))
-		return false
+	if !server.IsActive {
+		return nil, ErrServerInactive
 	}
 
-	return true
+	return &server, nil
 }
 
-// GetClient Get client for a specific server
-func (c *MwpClients) GetClient(serverName *string) *httphelper.Client {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-
-	if serverName == nil {
-		// get the first item in the client map
-		for _, client := range c.clients {
-			if client != nil {
-				return client
-			}
+// ResolvePeerServer Find the active server a peer belongs to
+func (c *MwpClients) ResolvePeerServer(peerID uint) (*model.Server, error) {
+	var peer model.Peer
+	if err := c.db.Select("server_id").First(&peer, peerID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrServerNotFound
+		}
+		c.logger.Error("Failed to fetch peer from database", zap.Uint("peerId", peerID), zap.Error(err))
+		return nil, err
+	}
 
-			return nil
+	var server model.Server
+	if err := c.db.First(&server, peer.ServerID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrServerNotFound
 		}
+		c.logger.Error("Failed to fetch server from database", zap.Uint("serverId", peer.ServerID), zap.Error(err))
+		return nil, err
 	}
 
-	if client, ok := c.clients[utils.DerefString(serverName)]; ok {
-		return client
+	if !server.IsActive {
+		return nil, ErrServerInactive
 	}
 
-	zap.L().Error("client not found in mwp clients", zap.String("serverName", utils.DerefString(serverName)))
-	return nil
+	return &server, nil
 }
 
-// SetClient Set or update the client for a specific server
-func (c *MwpClients) SetClient(serverData *schema.CreateServerRequest) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+func (c *MwpClients) soleActiveServer() (*model.Server, error) {
+	var servers []model.Server
+	if err := c.db.Where("is_active = ?", true).Order("id ASC").Limit(2).Find(&servers).Error; err != nil {
+		c.logger.Error("Failed to fetch servers from database", zap.Error(err))
+		return nil, err
+	}
 
-	apiPort := utils.ParseStringToInt(serverData.APIPort)
-	isSSL := *serverData.IsSSL
+	switch len(servers) {
+	case 0:
+		return nil, ErrNoActiveServer
+	case 1:
+		return &servers[0], nil
+	default:
+		return nil, ErrServerNotSpecified
+	}
+}
 
-	var protocol string
-	if isSSL {
-		protocol = "https"
-	} else {
-		protocol = "http"
+// GetClient Get client for a specific server, creating it on first use
+func (c *MwpClients) GetClient(server model.Server) (*httphelper.Client, error) {
+	if server.ID == 0 {
+		// not persisted yet (e.g. connection probe before creating the server), don't cache it
+		return newServerClient(server)
 	}
 
-	client, err := httphelper.NewClient(httphelper.Config{
-		BaseURL:            fmt.Sprintf("%s://%s:%d/rest", protocol, serverData.IPAddress, apiPort),
-		Username:           serverData.Username,
-		Password:           serverData.Password,
-		InsecureSkipVerify: !isSSL,
-	})
-	if err != nil {
-		c.logger.Panic("Failed to create HTTP client", zap.Error(err))
+	c.mu.RLock()
+	client, ok := c.clients[server.ID]
+	c.mu.RUnlock()
+	if ok && client != nil {
+		return client, nil
+	}
+
+	if err := c.SetClient(server); err != nil {
+		return nil, err
 	}
 
-	c.clients[serverData.Name] = client
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.clients[server.ID], nil
 }
 
-// InitClient Set or update the client for a specific server
-func (c *MwpClients) InitClient() {
+// SetClient Set or update the client for a specific server
+func (c *MwpClients) SetClient(server model.Server) error {
+	client, err := newServerClient(server)
+	if err != nil {
+		c.logger.Error("Failed to create HTTP client", zap.String("serverName", server.Name), zap.Error(err))
+		return err
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	c.clients[server.ID] = client
+	delete(c.connections, server.ID)
+	return nil
+}
+
+// InitClient Create clients for all the servers stored in database
+func (c *MwpClients) InitClient() {
 	var servers []model.Server
 	if err := c.db.Find(&servers).Error; err != nil {
 		c.logger.Error("Failed to fetch servers from database", zap.Error(err))
@@ -161,24 +202,32 @@ func (c *MwpClients) InitClient() {
 	}
 
 	for _, server := range servers {
-		client, err := httphelper.NewClient(httphelper.Config{
-			BaseURL:            fmt.Sprintf("%s://%s:%d/rest", "http", server.IPAddress, server.APIPort),
-			Username:           server.Username,
-			Password:           server.Password,
-			InsecureSkipVerify: true,
-		})
-		if err != nil {
+		if err := c.SetClient(server); err != nil {
 			c.logger.Panic("Failed to create HTTP client", zap.Error(err))
 		}
-
-		c.clients[server.Name] = client
 	}
 }
 
 // DeleteClient Remove the client for a specific server
-func (c *MwpClients) DeleteClient(serverName string) {
+func (c *MwpClients) DeleteClient(serverID uint) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	delete(c.clients, serverName)
+	delete(c.clients, serverID)
+	delete(c.connections, serverID)
+}
+
+func newServerClient(server model.Server) (*httphelper.Client, error) {
+	protocol := "http"
+	if server.IsSSL {
+		protocol = "https"
+	}
+
+	return httphelper.NewClient(httphelper.Config{
+		BaseURL:            fmt.Sprintf("%s://%s:%d/rest", protocol, server.IPAddress, server.APIPort),
+		Username:           server.Username,
+		Password:           server.Password,
+		InsecureSkipVerify: !server.IsSSL,
+		Name:               server.Name,
+	})
 }
@@ -0,0 +1,59 @@
+package common
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/maahdima/mwp/api/dataservice/model"
+)
+
+func testServer(t *testing.T, srv *httptest.Server, isSSL bool) model.Server {
+	t.Helper()
+
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, port, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	apiPort, _ := strconv.Atoi(port)
+
+	return model.Server{Name: "r1", IPAddress: host, APIPort: apiPort, IsSSL: isSSL}
+}
+
+func TestServerClientVerifiesTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	client, err := newServerClient(testServer(t, srv, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Get(context.Background(), DeviceIdentityPath, nil); err == nil {
+		t.Fatal("a self-signed certificate was accepted")
+	}
+}
+
+func TestServerClientPlainHTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	client, err := newServerClient(testServer(t, srv, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Get(context.Background(), DeviceIdentityPath, nil); err != nil {
+		t.Fatal(err)
+	}
+}
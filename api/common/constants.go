@@ -5,6 +5,7 @@ var (
 	DeviceIdentityPath = "/system/identity"
 	DeviceDnsPath      = "/ip/dns"
 	DeviceIPv4Path     = "/ip/address"
+	DeviceIPv6Path     = "/ipv6/address"
 	InterfacePath      = "/interface"
 	WGInterfacePath    = "/interface/wireguard"
 	WGPeerPath         = "/interface/wireguard/peers"
@@ -25,15 +26,42 @@ var (
 	SchedulerEvent     = "/interface/wireguard/peers/disable"
 )
 
+// Reasons a peer is disabled, kept so a renewal only enables the peers its expiry or quota disabled
+var (
+	PeerDisabledByAdmin        = "admin"
+	PeerDisabledByExpiry       = "expiry"
+	PeerDisabledByTrafficLimit = "traffic_limit"
+)
+
 var (
 	QueueComment     = "Wireguard Bandwidth Queue: "
 	QueueName        = "Bandwidth Limit: "
 	DefaultKeepalive = "25"
 )
 
-// TODO : Make these configurable
 var (
-	AllowedIpsExcludeLocal = ""
+	// BulkPeerConcurrency bounds the peers a bulk operation changes on the router at the same time
+	BulkPeerConcurrency = 4
+	// ImportMaxFileSize bounds the request body of a peer import, larger uploads are refused before they are parsed
+	ImportMaxFileSize int64 = 10 << 20
+)
+
+// Client config defaults, used when neither the peer nor its interface sets its own
+var (
+	// AllowedIpsExcludeLocal routes everything but the private ranges (10/8, 172.16/12, 192.168/16) and multicast
+	AllowedIpsExcludeLocal = "0.0.0.0/5, 8.0.0.0/7, 11.0.0.0/8, 12.0.0.0/6, 16.0.0.0/4, 32.0.0.0/3, 64.0.0.0/2, " +
+		"128.0.0.0/3, 160.0.0.0/5, 168.0.0.0/6, 172.0.0.0/12, 172.32.0.0/11, 172.64.0.0/10, 172.128.0.0/9, " +
+		"173.0.0.0/8, 174.0.0.0/7, 176.0.0.0/4, 192.0.0.0/9, 192.128.0.0/11, 192.160.0.0/13, 192.169.0.0/16, " +
+		"192.170.0.0/15, 192.172.0.0/14, 192.176.0.0/12, 192.192.0.0/10, 193.0.0.0/8, 194.0.0.0/7, 196.0.0.0/6, " +
+		"200.0.0.0/5, 208.0.0.0/4, ::/0"
 	AllowedIpsIncludeLocal = "0.0.0.0/0, ::/0"
 	DefaultDns             = "8.8.8.8, 1.1.1.1"
 )
+
+// Allowed-IPs modes of a peer config
+var (
+	AllowedIpsModeFull           = "full"
+	AllowedIpsModeExcludePrivate = "exclude_private"
+	// AllowedIpsModeSplit routes only the listed networks through the tunnel
+	AllowedIpsModeSplit = "split"
+)
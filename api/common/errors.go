@@ -3,5 +3,28 @@ package common
 import "errors"
 
 var (
-	ErrPeerNotShared = errors.New("peer is not shared")
+	ErrPeerNotShared        = errors.New("peer is not shared")
+	ErrNoActiveServer       = errors.New("no active server found")
+	ErrServerNotFound       = errors.New("server not found")
+	ErrServerInactive       = errors.New("server is not active")
+	ErrServerNotSpecified   = errors.New("multiple servers are active, the target server must be specified")
+	ErrDriftNotFound        = errors.New("drift item not found")
+	ErrDriftNotResolvable   = errors.New("drift item cannot be resolved this way")
+	ErrServerInUse          = errors.New("server still has interfaces")
+	ErrInvalidBulkRequest   = errors.New("invalid bulk request")
+	ErrInvalidPeerPlan      = errors.New("invalid peer plan")
+	ErrInvalidRenewal       = errors.New("invalid renewal")
+	ErrInvalidTrafficQuery  = errors.New("invalid traffic query")
+	ErrInvalidAdmin         = errors.New("invalid admin")
+	ErrLastOwner            = errors.New("at least one active owner must remain")
+	ErrInvalidToken         = errors.New("invalid or expired token")
+	ErrInvalidAPIToken      = errors.New("invalid api token")
+	ErrTwoFactorState       = errors.New("two-factor authentication is not in the expected state")
+	ErrInvalidOTP           = errors.New("invalid two-factor code")
+	ErrInvalidCredentials   = errors.New("invalid username or password")
+	ErrInvalidAuditQuery    = errors.New("invalid audit query")
+	ErrInvalidImportFile    = errors.New("invalid import file")
+	ErrInvalidIPPool        = errors.New("invalid IP pool")
+	ErrInvalidIPReservation = errors.New("invalid IP reservation")
+	ErrInvalidClientConfig  = errors.New("invalid client config settings")
 )
@@ -20,8 +20,10 @@ type AppConfig struct {
 	ConsoleLogFormat   string
 	DataDirPath        string
 	UIAssetsFs         fs.FS
-	PeerFilesDir       string
+	PeerFilesDir       string // where older versions stored peer configs and QR codes, only read to remove them
 	TrafficJobInterval string
+	SyncJobInterval    string
+	TrustProxyHeaders  bool
 }
 
 type DBConfig struct {
@@ -41,12 +43,54 @@ type AdminConfig struct {
 type AuthConfig struct {
 	AccessTokenTTL  string
 	RefreshTokenTTL string
+	SigningKey      string
+	SigningKeyFile  string
+}
+
+// EncryptionConfig holds the master key secrets are sealed with at rest, PreviousKeys only open values until they are
+// rewrapped under the current key
+type EncryptionConfig struct {
+	Key          string
+	KeyFile      string
+	PreviousKeys []string
+}
+
+type SyncConfig struct {
+	AutoHeal []string
+}
+
+// TrafficHistoryConfig holds how long peer traffic samples are kept per granularity, in days
+type TrafficHistoryConfig struct {
+	RawRetention    string
+	HourlyRetention string
+	DailyRetention  string
+}
+
+// PeerRenderConfig holds how many rendered QR codes are kept in memory, 0 renders every request
+type PeerRenderConfig struct {
+	QRCodeCacheSize string
+}
+
+type MetricsConfig struct {
+	Enabled bool
+	Token   string
 }
 
 type TelegramConfig struct {
-	Enabled    bool
-	BotToken   string
-	ApiBaseURL string
+	Enabled     bool
+	BotToken    string
+	ApiBaseURL  string
+	AdminChatID string
+}
+
+// RateLimitConfig holds the brute-force protection of the login and the public share links, durations in seconds
+type RateLimitConfig struct {
+	Enabled          bool
+	LoginMaxAttempts string
+	ShareMaxAttempts string
+	AttemptWindow    string
+	LockoutBase      string
+	LockoutMax       string
 }
 
 func init() {
@@ -77,6 +121,8 @@ func GetAppConfig() AppConfig {
 		PeerFilesDir:       getEnv("PEER_FILES_DIR", filepath.Join(dataDir, "peer-files")),
 		DataDirPath:        dataDir,
 		TrafficJobInterval: getEnv("TRAFFIC_JOB_INTERVAL", "300"),
+		SyncJobInterval:    getEnv("SYNC_JOB_INTERVAL", "900"),
+		TrustProxyHeaders:  getEnvBool("TRUST_PROXY_HEADERS", false),
 	}
 }
 
@@ -110,14 +156,78 @@ func GetAuthConfig() AuthConfig {
 	return AuthConfig{
 		AccessTokenTTL:  getEnv("AUTH_ACCESS_TOKEN_TTL", "900"),
 		RefreshTokenTTL: getEnv("AUTH_REFRESH_TOKEN_TTL", "86400"),
+		SigningKey:      getEnv("AUTH_SIGNING_KEY", ""),
+		SigningKeyFile:  getEnv("AUTH_SIGNING_KEY_FILE", ""),
+	}
+}
+
+func GetEncryptionConfig() EncryptionConfig {
+	var previousKeys []string
+	for _, key := range strings.Split(getEnv("ENCRYPTION_PREVIOUS_KEYS", ""), ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			previousKeys = append(previousKeys, key)
+		}
+	}
+
+	return EncryptionConfig{
+		Key:          getEnv("ENCRYPTION_KEY", ""),
+		KeyFile:      getEnv("ENCRYPTION_KEY_FILE", ""),
+		PreviousKeys: previousKeys,
+	}
+}
+
+func GetSyncConfig() SyncConfig {
+	var autoHeal []string
+	for _, item := range strings.Split(getEnv("SYNC_AUTO_HEAL", "disabled,queue,scheduler"), ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item != "" && item != "none" {
+			autoHeal = append(autoHeal, item)
+		}
+	}
+
+	return SyncConfig{
+		AutoHeal: autoHeal,
+	}
+}
+
+func GetTrafficHistoryConfig() TrafficHistoryConfig {
+	return TrafficHistoryConfig{
+		RawRetention:    getEnv("TRAFFIC_RAW_RETENTION_DAYS", "2"),
+		HourlyRetention: getEnv("TRAFFIC_HOURLY_RETENTION_DAYS", "30"),
+		DailyRetention:  getEnv("TRAFFIC_DAILY_RETENTION_DAYS", "365"),
+	}
+}
+
+func GetPeerRenderConfig() PeerRenderConfig {
+	return PeerRenderConfig{
+		QRCodeCacheSize: getEnv("QR_CODE_CACHE_SIZE", "1000"),
+	}
+}
+
+func GetMetricsConfig() MetricsConfig {
+	return MetricsConfig{
+		Enabled: getEnvBool("METRICS_ENABLED", true),
+		Token:   getEnv("METRICS_TOKEN", ""),
 	}
 }
 
 func GetTelegramConfig() TelegramConfig {
 	return TelegramConfig{
-		Enabled:    getEnvBool("TELEGRAM_BOT_ENABLED", false),
-		BotToken:   getEnv("TELEGRAM_BOT_TOKEN", ""),
-		ApiBaseURL: getEnv("TELEGRAM_BOT_API_BASE_URL", "https://api.telegram.org"),
+		Enabled:     getEnvBool("TELEGRAM_BOT_ENABLED", false),
+		BotToken:    getEnv("TELEGRAM_BOT_TOKEN", ""),
+		ApiBaseURL:  getEnv("TELEGRAM_BOT_API_BASE_URL", "https://api.telegram.org"),
+		AdminChatID: getEnv("TELEGRAM_ADMIN_CHAT_ID", ""),
+	}
+}
+
+func GetRateLimitConfig() RateLimitConfig {
+	return RateLimitConfig{
+		Enabled:          getEnvBool("RATE_LIMIT_ENABLED", true),
+		LoginMaxAttempts: getEnv("LOGIN_MAX_ATTEMPTS", "5"),
+		ShareMaxAttempts: getEnv("SHARE_MAX_ATTEMPTS", "20"),
+		AttemptWindow:    getEnv("RATE_LIMIT_WINDOW", "900"),
+		LockoutBase:      getEnv("RATE_LIMIT_LOCKOUT_BASE", "60"),
+		LockoutMax:       getEnv("RATE_LIMIT_LOCKOUT_MAX", "3600"),
 	}
 }
 
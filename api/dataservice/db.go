@@ -8,7 +8,6 @@ import (
 	"time"
 
 	"github.com/maahdima/mwp/api/config"
-	"github.com/maahdima/mwp/api/dataservice/model"
 
 	"github.com/glebarez/sqlite"
 	"gorm.io/driver/postgres"
@@ -35,7 +34,8 @@ func ConnectDB(config config.DBConfig) (db *gorm.DB, err error) {
 	var dialect gorm.Dialector
 	if config.Dialect == "sqlite" {
 		dialect = sqlite.Open(
-			fmt.Sprintf("file:%s?_pragma=foreign_keys(1)", config.Database),
+			// concurrent writers (jobs, bulk operations) wait for the lock instead of failing with SQLITE_BUSY
+			fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)", config.Database),
 		)
 	} else if config.Dialect == "postgres" {
 		var dsn string
@@ -56,20 +56,3 @@ func ConnectDB(config config.DBConfig) (db *gorm.DB, err error) {
 
 	return
 }
-
-func AutoMigrate(db *gorm.DB) error {
-	err := db.Migrator().AutoMigrate(
-		&model.Interface{},
-		&model.IPPool{},
-		&model.Peer{},
-		&model.Traffic{},
-		&model.TotalTrafficUsage{},
-		&model.Server{},
-		&model.Admin{},
-	)
-	if err != nil {
-		log.Panic("failed to auto migrate db: ", err)
-		return err
-	}
-	return nil
-}
@@ -0,0 +1,218 @@
+package dataservice
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/maahdima/mwp/api/config"
+	"github.com/maahdima/mwp/api/utils/envelope"
+)
+
+// encryptedColumns lists the columns tagged serializer:encrypted, by table
+var encryptedColumns = map[string][]string{
+	"admins":     {"totp_secret"},
+	"servers":    {"password"},
+	"interfaces": {"private_key"},
+	"peers":      {"private_key", "preshared_key"},
+}
+
+// LoadKeyring loads the master key and makes it the default keyring: ENCRYPTION_KEY when set, otherwise the key file,
+// which is generated on first start. The key file holds one key per line, the first one is current and the others
+// only open values that were not rewrapped yet.
+func LoadKeyring(cfg config.EncryptionConfig) (*envelope.Keyring, error) {
+	var keys []string
+	if cfg.Key != "" {
+		keys = []string{cfg.Key}
+	} else {
+		var err error
+		if keys, err = readKeyFile(encryptionKeyFile(cfg)); errors.Is(err, os.ErrNotExist) {
+			key, err := envelope.GenerateKey()
+			if err != nil {
+				return nil, err
+			}
+			if err := writeKeyFile(encryptionKeyFile(cfg), []string{key}); err != nil {
+				return nil, err
+			}
+			keys = []string{key}
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	keyring, err := envelope.NewKeyring(keys[0], append(keys[1:], cfg.PreviousKeys...)...)
+	if err != nil {
+		return nil, err
+	}
+	envelope.SetDefault(keyring)
+
+	return keyring, nil
+}
+
+// RotateKeyring switches to a new master key. With a key file a key is generated and put first in the file, the old
+// ones stay behind it until ForgetPreviousKeys. With ENCRYPTION_KEY the new key is expected there already and the old
+// one in ENCRYPTION_PREVIOUS_KEYS.
+func RotateKeyring(cfg config.EncryptionConfig) (*envelope.Keyring, error) {
+	if cfg.Key != "" {
+		if len(cfg.PreviousKeys) == 0 {
+			return nil, errors.New("set the new key in ENCRYPTION_KEY and the old one in ENCRYPTION_PREVIOUS_KEYS")
+		}
+		return LoadKeyring(cfg)
+	}
+
+	keyFile := encryptionKeyFile(cfg)
+	keys, err := readKeyFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := envelope.GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	if err := writeKeyFile(keyFile, append([]string{key}, keys...)); err != nil {
+		return nil, err
+	}
+
+	return LoadKeyring(cfg)
+}
+
+// ForgetPreviousKeys drops the old keys from the key file once every value is sealed under the current one
+func ForgetPreviousKeys(cfg config.EncryptionConfig) error {
+	if cfg.Key != "" {
+		return nil
+	}
+
+	keyFile := encryptionKeyFile(cfg)
+	keys, err := readKeyFile(keyFile)
+	if err != nil {
+		return err
+	}
+
+	return writeKeyFile(keyFile, keys[:1])
+}
+
+// SealSecrets brings every encrypted column under the current master key: plaintext rows written before encryption are
+// sealed and rows sealed under a previous key are rewrapped. It returns how many values changed.
+func SealSecrets(db *gorm.DB) (int, error) {
+	changed := 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		changed, err = rewrapSecrets(tx)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return changed, nil
+}
+
+func rewrapSecrets(tx *gorm.DB) (int, error) {
+	keyring := envelope.Default()
+	if keyring == nil {
+		return 0, errors.New("encryption master key is not loaded")
+	}
+
+	return updateSecrets(tx, func(value string) (string, bool, error) {
+		return keyring.Rewrap(value)
+	})
+}
+
+// openSecrets writes the encrypted columns back in plaintext, for going back to a version without encryption
+func openSecrets(tx *gorm.DB) error {
+	keyring := envelope.Default()
+	if keyring == nil {
+		return errors.New("encryption master key is not loaded")
+	}
+
+	_, err := updateSecrets(tx, func(value string) (string, bool, error) {
+		if !envelope.IsSealed(value) {
+			return value, false, nil
+		}
+		plaintext, err := keyring.Open(value)
+		return string(plaintext), err == nil, err
+	})
+	return err
+}
+
+// updateSecrets passes every non empty encrypted column through update and stores the values it changed
+func updateSecrets(tx *gorm.DB, update func(value string) (string, bool, error)) (int, error) {
+	changed := 0
+	for table, columns := range encryptedColumns {
+		for _, column := range columns {
+			// a column added by a later migration is not there yet while the earlier ones run
+			if !tx.Migrator().HasColumn(table, column) {
+				continue
+			}
+			var rows []struct {
+				ID    uint
+				Value string
+			}
+			// the table name skips the serializer and the soft delete scope, deleted rows are updated as well
+			err := tx.Table(table).Select("id", column+" AS value").Where(column+" <> ?", "").Scan(&rows).Error
+			if err != nil {
+				return 0, err
+			}
+
+			for _, row := range rows {
+				value, ok, err := update(row.Value)
+				if err != nil {
+					return 0, fmt.Errorf("failed to update %s.%s of row %d: %w", table, column, row.ID, err)
+				}
+				if !ok {
+					continue
+				}
+				if err := tx.Table(table).Where("id = ?", row.ID).UpdateColumn(column, value).Error; err != nil {
+					return 0, err
+				}
+				changed++
+			}
+		}
+	}
+
+	return changed, nil
+}
+
+func encryptionKeyFile(cfg config.EncryptionConfig) string {
+	if cfg.KeyFile != "" {
+		return cfg.KeyFile
+	}
+	return filepath.Join(config.GetAppConfig().DataDirPath, "encryption.key")
+}
+
+func readKeyFile(keyFile string) ([]string, error) {
+	content, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for _, line := range strings.Split(string(content), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			keys = append(keys, line)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no master key in %s", keyFile)
+	}
+
+	return keys, nil
+}
+
+// writeKeyFile replaces the key file through a rename so a crash never leaves it half written
+func writeKeyFile(keyFile string, keys []string) error {
+	tmp := keyFile + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strings.Join(keys, "\n")+"\n"), 0600); err != nil {
+		return fmt.Errorf("failed to write master key: %w", err)
+	}
+	if err := os.Rename(tmp, keyFile); err != nil {
+		return fmt.Errorf("failed to write master key: %w", err)
+	}
+
+	return nil
+}
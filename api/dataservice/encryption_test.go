@@ -0,0 +1,91 @@
+package dataservice
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+
+	"github.com/maahdima/mwp/api/config"
+	"github.com/maahdima/mwp/api/dataservice/model"
+	"github.com/maahdima/mwp/api/utils/envelope"
+)
+
+func newEncryptedDB(t *testing.T) (*gorm.DB, config.EncryptionConfig) {
+	t.Helper()
+
+	t.Setenv("DATA_DIR", t.TempDir())
+	t.Setenv("DB_DIALECT", "sqlite")
+	cfg := config.GetEncryptionConfig()
+	if _, err := LoadKeyring(cfg); err != nil {
+		t.Fatal(err)
+	}
+	db, err := ConnectDB(config.GetDBConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	return db, cfg
+}
+
+func rawColumn(t *testing.T, db *gorm.DB, table, column string, id uint) string {
+	t.Helper()
+
+	var value string
+	if err := db.Table(table).Select(column).Where("id = ?", id).Scan(&value).Error; err != nil {
+		t.Fatal(err)
+	}
+	return value
+}
+
+func TestSealSecretsSealsPlaintextAndRewraps(t *testing.T) {
+	db, cfg := newEncryptedDB(t)
+
+	server := model.Server{Name: "r1", IPAddress: "192.0.2.1", APIPort: 443, Username: "admin", Password: "router-pass"}
+	if err := db.Create(&server).Error; err != nil {
+		t.Fatal(err)
+	}
+	if raw := rawColumn(t, db, "servers", "password", server.ID); !envelope.IsSealed(raw) {
+		t.Fatalf("password was written in plaintext: %q", raw)
+	}
+
+	// a row left from before encryption
+	if err := db.Table("servers").Where("id = ?", server.ID).UpdateColumn("password", "router-pass").Error; err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := SealSecrets(db); err != nil || changed != 1 {
+		t.Fatalf("sealing plaintext changed %d values: %v", changed, err)
+	}
+	sealed := rawColumn(t, db, "servers", "password", server.ID)
+
+	if _, err := RotateKeyring(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := SealSecrets(db); err != nil || changed != 1 {
+		t.Fatalf("rewrapping under the new key changed %d values: %v", changed, err)
+	}
+	if rewrapped := rawColumn(t, db, "servers", "password", server.ID); rewrapped == sealed {
+		t.Fatal("password was not rewrapped under the new key")
+	}
+
+	if err := ForgetPreviousKeys(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadKeyring(cfg); err != nil {
+		t.Fatal(err)
+	}
+	var loaded model.Server
+	if err := db.First(&loaded, server.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Password != "router-pass" {
+		t.Fatalf("password reads back as %q", loaded.Password)
+	}
+}
@@ -0,0 +1,181 @@
+package dataservice
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/maahdima/mwp/api/dataservice/model"
+)
+
+var (
+	ErrSchemaTooNew = errors.New("database schema is newer than this version of mwp supports")
+	ErrIrreversible = errors.New("migration cannot be reverted")
+)
+
+// Migration is one versioned step of the schema. Up and Down run in a transaction together with the bookkeeping in
+// schema_migrations, a nil Down makes the step irreversible. Later steps must not use the models as they change over
+// time, they declare the tables as they need them.
+type Migration struct {
+	Version uint
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// MigrationStatus is a migration with the time it was applied, nil when it is pending. Applied migrations unknown to
+// this version of mwp have no Up and Down.
+type MigrationStatus struct {
+	Migration
+	AppliedAt *int64
+}
+
+// Migrate applies every pending migration, it refuses to touch a schema newer than this version of mwp
+func Migrate(db *gorm.DB) error {
+	_, err := MigrateUp(db, 0)
+	return err
+}
+
+// MigrateUp applies up to steps pending migrations in order, all of them when steps is 0
+func MigrateUp(db *gorm.DB, steps int) ([]Migration, error) {
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSchemaVersion(applied); err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, migration := range migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		if steps > 0 && len(done) == steps {
+			break
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := migration.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&model.SchemaMigration{
+				Version:   migration.Version,
+				Name:      migration.Name,
+				AppliedAt: time.Now().Unix(),
+			}).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("migration %d %s failed: %w", migration.Version, migration.Name, err)
+		}
+
+		zap.L().Info("applied migration", zap.Uint("version", migration.Version), zap.String("name", migration.Name))
+		done = append(done, migration)
+	}
+
+	return done, nil
+}
+
+// MigrateDown reverts the steps most recently applied migrations. It stops with ErrIrreversible at the baseline
+// (version 1), the schema from before versioned migrations cannot be restored.
+func MigrateDown(db *gorm.DB, steps int) ([]Migration, error) {
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSchemaVersion(applied); err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, migration := range slices.Backward(migrations) {
+		if len(done) == steps {
+			break
+		}
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		if migration.Down == nil {
+			return done, fmt.Errorf("%w: %d %s", ErrIrreversible, migration.Version, migration.Name)
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := migration.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&model.SchemaMigration{}, migration.Version).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("reverting migration %d %s failed: %w", migration.Version, migration.Name, err)
+		}
+
+		zap.L().Info("reverted migration", zap.Uint("version", migration.Version), zap.String("name", migration.Name))
+		done = append(done, migration)
+	}
+
+	return done, nil
+}
+
+// GetMigrationStatus lists the known migrations in order, followed by the applied ones this version does not know
+func GetMigrationStatus(db *gorm.DB) ([]MigrationStatus, error) {
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, migration := range migrations {
+		status := MigrationStatus{Migration: migration}
+		if row, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &row.AppliedAt
+			delete(applied, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+
+	for _, version := range slices.Sorted(maps.Keys(applied)) {
+		row := applied[version]
+		statuses = append(statuses, MigrationStatus{
+			Migration: Migration{Version: row.Version, Name: row.Name},
+			AppliedAt: &row.AppliedAt,
+		})
+	}
+
+	return statuses, nil
+}
+
+func appliedMigrations(db *gorm.DB) (map[uint]model.SchemaMigration, error) {
+	if err := db.Migrator().AutoMigrate(&model.SchemaMigration{}); err != nil {
+		return nil, err
+	}
+
+	var rows []model.SchemaMigration
+	if err := db.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	applied := make(map[uint]model.SchemaMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+
+	return applied, nil
+}
+
+// checkSchemaVersion fails when the database holds migrations this version does not know, a newer mwp applied them
+// and running against that schema could corrupt it
+func checkSchemaVersion(applied map[uint]model.SchemaMigration) error {
+	latest := migrations[len(migrations)-1].Version
+	for version := range applied {
+		if version > latest {
+			return fmt.Errorf("%w: it has migration %d, the latest known is %d", ErrSchemaTooNew, version, latest)
+		}
+	}
+
+	return nil
+}
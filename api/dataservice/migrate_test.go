@@ -0,0 +1,139 @@
+package dataservice
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gorm.io/gorm"
+
+	"github.com/maahdima/mwp/api/config"
+	"github.com/maahdima/mwp/api/dataservice/model"
+	"github.com/maahdima/mwp/api/utils/envelope"
+)
+
+func TestBaselineCoversModels(t *testing.T) {
+	db, _ := newEncryptedDB(t)
+
+	models := []interface{}{
+		&model.Interface{},
+		&model.IPPool{},
+		&model.Peer{},
+		&model.Traffic{},
+		&model.TotalTrafficUsage{},
+		&model.Server{},
+		&model.Admin{},
+		&model.PeerPlan{},
+		&model.PeerRenewal{},
+		&model.PeerTrafficSample{},
+		&model.AdminSession{},
+		&model.AdminScope{},
+		&model.APIToken{},
+		&model.AdminRecoveryCode{},
+		&model.LoginThrottle{},
+		&model.AuditEvent{},
+	}
+	for _, m := range models {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(m); err != nil {
+			t.Fatal(err)
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName != "" && !db.Migrator().HasColumn(m, field.DBName) {
+				t.Errorf("migrations do not create %s.%s", stmt.Schema.Table, field.DBName)
+			}
+		}
+	}
+}
+
+func TestMigrateDownAndUp(t *testing.T) {
+	db, _ := newEncryptedDB(t)
+
+	server := model.Server{Name: "r1", IPAddress: "192.0.2.1", APIPort: 443, Username: "admin", Password: "router-pass"}
+	if err := db.Create(&server).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	reverted, err := MigrateDown(db, len(migrations)-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reverted) != len(migrations)-1 || reverted[len(reverted)-1].Name != "encrypt_secrets" {
+		t.Fatalf("reverted %+v, want every migration down to encrypt_secrets", reverted)
+	}
+	if raw := rawColumn(t, db, "servers", "password", server.ID); raw != "router-pass" {
+		t.Fatalf("reverting encrypt_secrets left the password as %q", raw)
+	}
+
+	statuses, err := GetMigrationStatus(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statuses[0].AppliedAt == nil || statuses[len(statuses)-1].AppliedAt != nil {
+		t.Fatalf("status %+v, want only the baseline applied", statuses)
+	}
+
+	// the baseline is where versioned migrations start, there is nothing before it to go back to
+	if _, err := MigrateDown(db, 1); !errors.Is(err, ErrIrreversible) {
+		t.Fatalf("reverting the baseline got %v, want ErrIrreversible", err)
+	}
+
+	applied, err := MigrateUp(db, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != len(migrations)-1 || !envelope.IsSealed(rawColumn(t, db, "servers", "password", server.ID)) {
+		t.Fatalf("applied %+v, want encrypt_secrets to seal the password again", applied)
+	}
+}
+
+func TestMigrateRefusesNewerSchema(t *testing.T) {
+	db, _ := newEncryptedDB(t)
+
+	if err := db.Create(&model.SchemaMigration{Version: 999, Name: "from_the_future"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := Migrate(db); !errors.Is(err, ErrSchemaTooNew) {
+		t.Fatalf("migrating a newer schema got %v, want ErrSchemaTooNew", err)
+	}
+	if _, err := MigrateDown(db, 1); !errors.Is(err, ErrSchemaTooNew) {
+		t.Fatalf("reverting a newer schema got %v, want ErrSchemaTooNew", err)
+	}
+}
+
+func TestRemovePeerFilesKeepsForeignFiles(t *testing.T) {
+	t.Setenv("DATA_DIR", t.TempDir())
+	dir := config.GetAppConfig().PeerFilesDir
+
+	for _, path := range []string{"config/alice.conf", "qrcode/alice.jpg", "notes.txt"} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, path)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, path), []byte("x"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := removePeerFiles(nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, sub := range []string{"config", "qrcode"} {
+		if _, err := os.Stat(filepath.Join(dir, sub)); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("%s was kept: %v", sub, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Fatalf("a file the migration does not own was removed: %v", err)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := removePeerFiles(nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dir); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("the empty peer files directory was kept: %v", err)
+	}
+}
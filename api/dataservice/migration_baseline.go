@@ -0,0 +1,309 @@
+package dataservice
+
+import (
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// The baseline creates the schema as it was when versioned migrations were introduced. Databases created by
+// AutoMigrate in older versions are brought to the same point, as AutoMigrate only adds what is missing. The tables
+// are frozen copies of the models so the baseline does not change as the models move on.
+
+type baselineModel struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt uint64
+	UpdatedAt uint64
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+type baselineInterface struct {
+	Model       baselineModel `gorm:"embedded"`
+	ServerID    uint          `gorm:"not null;default:0;uniqueIndex:idx_interfaces_server_interface_id;uniqueIndex:idx_interfaces_server_name"`
+	InterfaceID string        `gorm:"type:varchar(255);not null;uniqueIndex:idx_interfaces_server_interface_id"`
+	Disabled    bool          `gorm:"type:boolean;not null;default:false"`
+	Comment     *string       `gorm:"type:varchar(255)"`
+	Name        string        `gorm:"type:varchar(255);not null;uniqueIndex:idx_interfaces_server_name"`
+	PrivateKey  string        `gorm:"type:text;not null"`
+	PublicKey   string        `gorm:"type:varchar(255);not null"`
+	ListenPort  string        `gorm:"type:varchar(10);not null"`
+
+	IPPool *baselineIPPool `gorm:"foreignKey:InterfaceID"`
+}
+
+func (baselineInterface) TableName() string { return "interfaces" }
+
+type baselineIPPool struct {
+	Model       baselineModel `gorm:"embedded"`
+	Name        string        `gorm:"type:varchar(255);not null;uniqueIndex"`
+	StartIP     string        `gorm:"type:varchar(15);not null;uniqueIndex"`
+	EndIP       string        `gorm:"type:varchar(15);not null;uniqueIndex"`
+	InterfaceID uint          `gorm:"not null;uniqueIndex"`
+}
+
+func (baselineIPPool) TableName() string { return "ip_pools" }
+
+type baselinePeer struct {
+	Model               baselineModel `gorm:"embedded"`
+	ServerID            uint          `gorm:"not null;default:0;uniqueIndex:idx_peers_server_peer_id;uniqueIndex:idx_peers_server_allowed_address"`
+	UUID                string        `gorm:"type:varchar(36);uniqueIndex:idx_peers_uuid;not null"`
+	PeerID              string        `gorm:"type:varchar(255);not null;uniqueIndex:idx_peers_server_peer_id"`
+	Disabled            bool          `gorm:"type:boolean;not null;default:false"`
+	DisabledReason      *string       `gorm:"type:varchar(32)"`
+	Comment             *string       `gorm:"type:text"`
+	Name                string        `gorm:"type:varchar(255);not null"`
+	PrivateKey          string        `gorm:"type:text;not null"`
+	PublicKey           string        `gorm:"type:varchar(255);not null"`
+	Interface           string        `gorm:"type:varchar(255);not null"`
+	AllowedAddress      string        `gorm:"type:varchar(255);not null;uniqueIndex:idx_peers_server_allowed_address"`
+	Endpoint            string        `gorm:"type:varchar(255);not null"`
+	EndpointPort        string        `gorm:"type:varchar(10);not null"`
+	PersistentKeepalive string        `gorm:"type:varchar(10)"`
+	SchedulerID         *string       `gorm:"type:varchar(255)"`
+	QueueID             *string       `gorm:"type:varchar(255)"`
+	ExpireTime          *string       `gorm:"type:varchar(255)"`
+	TrafficLimit        *int64        `gorm:"type:bigint"`
+	TelegramUsername    *string       `gorm:"type:varchar(255)"`
+	FirstNotify         bool          `gorm:"type:boolean;not null;default:false;column:first_notify"`
+	SecondNotify        bool          `gorm:"type:boolean;not null;default:false;column:second_notify"`
+	ThirdNotify         bool          `gorm:"type:boolean;not null;default:false;column:third_notify"`
+	DownloadBandwidth   *string       `gorm:"type:varchar(255)"`
+	UploadBandwidth     *string       `gorm:"type:varchar(255)"`
+	DownloadUsage       int64         `gorm:"type:bigint;not null;default:0"`
+	UploadUsage         int64         `gorm:"type:bigint;not null;default:0"`
+	LastTx              int64         `gorm:"type:bigint;not null;default:0"`
+	LastRx              int64         `gorm:"type:bigint;not null;default:0"`
+	IsShared            bool          `gorm:"type:boolean;not null;default:false"`
+	ShareExpireTime     *string       `gorm:"type:varchar(255)"`
+	DNS                 *string       `gorm:"type:varchar(255)"`
+	AllowedIPsMode      *string       `gorm:"type:varchar(32)"`
+	PlanID              *uint         `gorm:"index:idx_peers_plan_id"`
+}
+
+func (baselinePeer) TableName() string { return "peers" }
+
+type baselineTraffic struct {
+	Model         baselineModel `gorm:"embedded"`
+	InterfaceID   uint          `gorm:"index:idx_traffics_interface_id;not null"`
+	DownloadUsage int64         `gorm:"type:bigint;not null;default:0"`
+	UploadUsage   int64         `gorm:"type:bigint;not null;default:0"`
+	TotalUsage    int64         `gorm:"type:bigint;not null;default:0"`
+}
+
+func (baselineTraffic) TableName() string { return "traffics" }
+
+type baselineTotalTrafficUsage struct {
+	Model      baselineModel `gorm:"embedded"`
+	TotalUsage int64         `gorm:"type:bigint;not null;default:0"`
+}
+
+func (baselineTotalTrafficUsage) TableName() string { return "total_traffic_usages" }
+
+type baselineServer struct {
+	Model     baselineModel `gorm:"embedded"`
+	Comment   *string       `gorm:"type:varchar(255)"`
+	Name      string        `gorm:"type:varchar(64);uniqueIndex:idx_servers_name;not null;"`
+	IPAddress string        `gorm:"type:varchar(64);uniqueIndex:idx_servers_ip_address;not null;"`
+	APIPort   int           `gorm:"not null;default:80;"`
+	IsSSL     bool          `gorm:"not null;default:false;"`
+	Username  string        `gorm:"type:varchar(64);not null;"`
+	Password  string        `gorm:"type:text;not null;"`
+	IsActive  bool          `gorm:"not null;default:true;"`
+}
+
+func (baselineServer) TableName() string { return "servers" }
+
+type baselineAdmin struct {
+	Model            baselineModel `gorm:"embedded"`
+	Username         string        `gorm:"type:varchar(64);uniqueIndex:idx_admins_username;not null;"`
+	Password         string        `gorm:"type:varchar(128);not null;"`
+	IsActive         bool          `gorm:"not null;default:true;"`
+	Role             string        `gorm:"type:varchar(16);not null;default:owner;"`
+	TOTPSecret       string        `gorm:"type:text;"`
+	TOTPEnabled      bool          `gorm:"not null;default:false;"`
+	TOTPLastStep     int64         `gorm:"not null;default:0;"`
+	RecoveryCodeSalt string        `gorm:"type:varchar(64);"`
+}
+
+func (baselineAdmin) TableName() string { return "admins" }
+
+type baselinePeerPlan struct {
+	Model               baselineModel `gorm:"embedded"`
+	Name                string        `gorm:"type:varchar(255);not null;uniqueIndex:idx_peer_plans_name"`
+	Comment             *string       `gorm:"type:varchar(255)"`
+	TrafficLimit        *int64        `gorm:"type:bigint"`
+	DownloadBandwidth   *string       `gorm:"type:varchar(255)"`
+	UploadBandwidth     *string       `gorm:"type:varchar(255)"`
+	ValidityDays        *int          `gorm:"type:integer"`
+	PersistentKeepalive *string       `gorm:"type:varchar(10)"`
+	DNS                 *string       `gorm:"type:varchar(255)"`
+	AllowedIPsMode      *string       `gorm:"type:varchar(32)"`
+}
+
+func (baselinePeerPlan) TableName() string { return "peer_plans" }
+
+type baselinePeerRenewal struct {
+	Model              baselineModel `gorm:"embedded"`
+	PeerID             uint          `gorm:"index:idx_peer_renewals_peer_id;not null"`
+	ServerID           uint          `gorm:"index:idx_peer_renewals_server_id;not null"`
+	PeerName           string        `gorm:"type:varchar(255);not null"`
+	PlanID             *uint         `gorm:"index:idx_peer_renewals_plan_id"`
+	Days               int           `gorm:"not null"`
+	PreviousExpireTime *string       `gorm:"type:varchar(255)"`
+	NewExpireTime      string        `gorm:"type:varchar(255);not null"`
+	UsageReset         bool          `gorm:"type:boolean;not null;default:false"`
+	PreviousUsage      int64         `gorm:"type:bigint;not null;default:0"`
+	ReEnabled          bool          `gorm:"type:boolean;not null;default:false"`
+}
+
+func (baselinePeerRenewal) TableName() string { return "peer_renewals" }
+
+type baselinePeerTrafficSample struct {
+	Model         baselineModel `gorm:"embedded"`
+	PeerID        uint          `gorm:"not null;uniqueIndex:idx_peer_traffic_sample"`
+	Granularity   string        `gorm:"type:varchar(16);not null;uniqueIndex:idx_peer_traffic_sample"`
+	BucketStart   int64         `gorm:"not null;uniqueIndex:idx_peer_traffic_sample;index:idx_peer_traffic_samples_bucket_start"`
+	DownloadUsage int64         `gorm:"type:bigint;not null;default:0"`
+	UploadUsage   int64         `gorm:"type:bigint;not null;default:0"`
+}
+
+func (baselinePeerTrafficSample) TableName() string { return "peer_traffic_samples" }
+
+type baselineAdminSession struct {
+	Model          baselineModel `gorm:"embedded"`
+	SessionID      string        `gorm:"type:varchar(36);uniqueIndex:idx_admin_sessions_session_id;not null"`
+	AdminID        uint          `gorm:"index:idx_admin_sessions_admin_id;not null"`
+	RefreshTokenID string        `gorm:"type:varchar(36);not null"`
+	ExpiresAt      int64         `gorm:"not null;index:idx_admin_sessions_expires_at"`
+	RevokedAt      *int64
+	UserAgent      string `gorm:"type:varchar(255)"`
+	IPAddress      string `gorm:"type:varchar(64)"`
+}
+
+func (baselineAdminSession) TableName() string { return "admin_sessions" }
+
+type baselineAdminScope struct {
+	Model       baselineModel `gorm:"embedded"`
+	AdminID     uint          `gorm:"index:idx_admin_scopes_admin_id;not null"`
+	ServerID    uint          `gorm:"not null"`
+	InterfaceID *uint         `gorm:"index:idx_admin_scopes_interface_id"`
+}
+
+func (baselineAdminScope) TableName() string { return "admin_scopes" }
+
+type baselineAPIToken struct {
+	Model      baselineModel `gorm:"embedded"`
+	AdminID    uint          `gorm:"index:idx_api_tokens_admin_id;not null"`
+	Name       string        `gorm:"type:varchar(64);not null"`
+	Prefix     string        `gorm:"type:varchar(16);not null"`
+	TokenHash  string        `gorm:"type:varchar(64);uniqueIndex:idx_api_tokens_token_hash;not null"`
+	Scopes     string        `gorm:"type:varchar(255);not null"`
+	ExpiresAt  *int64
+	LastUsedAt *int64
+	RevokedAt  *int64
+}
+
+func (baselineAPIToken) TableName() string { return "api_tokens" }
+
+type baselineAdminRecoveryCode struct {
+	Model    baselineModel `gorm:"embedded"`
+	AdminID  uint          `gorm:"index:idx_admin_recovery_codes_admin_id;not null"`
+	CodeHash string        `gorm:"type:varchar(64);not null"`
+	UsedAt   *int64
+}
+
+func (baselineAdminRecoveryCode) TableName() string { return "admin_recovery_codes" }
+
+type baselineLoginThrottle struct {
+	Model         baselineModel `gorm:"embedded"`
+	ClientKey     string        `gorm:"type:varchar(191);uniqueIndex:idx_login_throttles_client_key;not null"`
+	Failures      int           `gorm:"not null;default:0"`
+	Lockouts      int           `gorm:"not null;default:0"`
+	LastFailureAt int64         `gorm:"not null;default:0"`
+	LockedUntil   int64         `gorm:"not null;default:0"`
+}
+
+func (baselineLoginThrottle) TableName() string { return "login_throttles" }
+
+type baselineAuditEvent struct {
+	ID         uint   `gorm:"primarykey"`
+	CreatedAt  uint64 `gorm:"not null;index:idx_audit_events_created_at"`
+	Actor      string `gorm:"type:varchar(64);not null;index:idx_audit_events_actor"`
+	Action     string `gorm:"type:varchar(64);not null;index:idx_audit_events_action"`
+	TargetType string `gorm:"type:varchar(32);not null;index:idx_audit_events_target"`
+	TargetID   string `gorm:"type:varchar(64);index:idx_audit_events_target"`
+	Before     string `gorm:"type:text"`
+	After      string `gorm:"type:text"`
+	SourceIP   string `gorm:"type:varchar(64)"`
+	StatusCode int    `gorm:"not null"`
+}
+
+func (baselineAuditEvent) TableName() string { return "audit_events" }
+
+func migrateBaseline(tx *gorm.DB) error {
+	dropLegacyIndexes(tx)
+
+	err := tx.Migrator().AutoMigrate(
+		&baselineInterface{},
+		&baselineIPPool{},
+		&baselinePeer{},
+		&baselineTraffic{},
+		&baselineTotalTrafficUsage{},
+		&baselineServer{},
+		&baselineAdmin{},
+		&baselinePeerPlan{},
+		&baselinePeerRenewal{},
+		&baselinePeerTrafficSample{},
+		&baselineAdminSession{},
+		&baselineAdminScope{},
+		&baselineAPIToken{},
+		&baselineAdminRecoveryCode{},
+		&baselineLoginThrottle{},
+		&baselineAuditEvent{},
+	)
+	if err != nil {
+		return err
+	}
+
+	return backfillServerIDs(tx)
+}
+
+// dropLegacyIndexes removes the single column unique indexes that were replaced
+// by the per-server ones, router IDs and names are only unique within a router
+func dropLegacyIndexes(tx *gorm.DB) {
+	legacyIndexes := map[string][]string{
+		"interfaces": {"idx_interfaces_interface_id", "idx_interfaces_name"},
+		"peers":      {"idx_peers_peer_id", "idx_peers_allowed_address"},
+	}
+
+	for table, indexes := range legacyIndexes {
+		if !tx.Migrator().HasTable(table) {
+			continue
+		}
+		for _, index := range indexes {
+			if tx.Migrator().HasIndex(table, index) {
+				if err := tx.Migrator().DropIndex(table, index); err != nil {
+					zap.L().Warn("failed to drop legacy index", zap.String("index", index), zap.Error(err))
+				}
+			}
+		}
+	}
+}
+
+// backfillServerIDs assigns interfaces and peers created before multi-server
+// support to the first server, which was the only one used back then
+func backfillServerIDs(tx *gorm.DB) error {
+	var serverID uint
+	err := tx.Table("servers").Select("id").Where("deleted_at IS NULL").Order("id ASC").Limit(1).Scan(&serverID).Error
+	if err != nil {
+		return err
+	}
+	if serverID == 0 {
+		return nil
+	}
+
+	if err := tx.Table("interfaces").Where("server_id = ?", 0).Update("server_id", serverID).Error; err != nil {
+		return err
+	}
+
+	return tx.Table("peers").Where("server_id = ?", 0).Update("server_id", serverID).Error
+}
@@ -0,0 +1,86 @@
+package dataservice
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// clientConfigInterface is interfaces once it holds the client config defaults of its peers
+type clientConfigInterface struct {
+	baselineInterface
+	ClientDNS                 *string `gorm:"type:varchar(255)"`
+	ClientAllowedIPsMode      *string `gorm:"type:varchar(32)"`
+	ClientAllowedIPs          *string `gorm:"type:text"`
+	ClientMTU                 *int    `gorm:"column:client_mtu"`
+	ClientPersistentKeepalive *string `gorm:"type:varchar(10)"`
+	GeneratePresharedKey      bool    `gorm:"type:boolean;not null;default:false"`
+	OmitPresharedKey          bool    `gorm:"type:boolean;not null;default:false"`
+}
+
+func (clientConfigInterface) TableName() string { return "interfaces" }
+
+// clientConfigPeer is peers once it holds its own client config settings and preshared key
+type clientConfigPeer struct {
+	baselinePeer
+	AllowedIPs          *string `gorm:"type:text"`
+	MTU                 *int    `gorm:"column:mtu"`
+	PresharedKey        string  `gorm:"type:text"`
+	IncludePresharedKey *bool   `gorm:"type:boolean"`
+}
+
+func (clientConfigPeer) TableName() string { return "peers" }
+
+var (
+	clientConfigInterfaceFields = []string{"ClientDNS", "ClientAllowedIPsMode", "ClientAllowedIPs", "ClientMTU", "ClientPersistentKeepalive", "GeneratePresharedKey", "OmitPresharedKey"}
+	clientConfigPeerFields      = []string{"AllowedIPs", "MTU", "PresharedKey", "IncludePresharedKey"}
+)
+
+// migrateClientConfig adds the client config defaults of interfaces and the client config settings of peers, the
+// preshared keys of existing peers are filled in by the next sync with the router
+func migrateClientConfig(tx *gorm.DB) error {
+	migrator := tx.Migrator()
+
+	for _, field := range clientConfigInterfaceFields {
+		if migrator.HasColumn(&clientConfigInterface{}, field) {
+			continue
+		}
+		if err := migrator.AddColumn(&clientConfigInterface{}, field); err != nil {
+			return err
+		}
+	}
+	for _, field := range clientConfigPeerFields {
+		if migrator.HasColumn(&clientConfigPeer{}, field) {
+			continue
+		}
+		if err := migrator.AddColumn(&clientConfigPeer{}, field); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// revertClientConfig drops the client config settings, the preshared keys stay on the router
+func revertClientConfig(tx *gorm.DB) error {
+	err := dropColumns(tx, "interfaces", "client_dns", "client_allowed_ips_mode", "client_allowed_ips", "client_mtu",
+		"client_persistent_keepalive", "generate_preshared_key", "omit_preshared_key")
+	if err != nil {
+		return err
+	}
+	return dropColumns(tx, "peers", "allowed_ips", "mtu", "preshared_key", "include_preshared_key")
+}
+
+// dropColumns drops the columns in place, the SQLite migrator rebuilds the table instead and that breaks the foreign
+// keys pointing at it
+func dropColumns(tx *gorm.DB, table string, columns ...string) error {
+	for _, column := range columns {
+		if !tx.Migrator().HasColumn(table, column) {
+			continue
+		}
+		if err := tx.Exec("ALTER TABLE ? DROP COLUMN ?", clause.Table{Name: table}, clause.Column{Name: column}).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
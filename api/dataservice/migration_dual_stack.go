@@ -0,0 +1,85 @@
+package dataservice
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// dualStackIPPool is ip_pools once an interface may have one IPv4 and one IPv6 pool
+type dualStackIPPool struct {
+	Model       baselineModel `gorm:"embedded"`
+	Name        string        `gorm:"type:varchar(255);not null;uniqueIndex"`
+	StartIP     string        `gorm:"type:varchar(64);not null;uniqueIndex"`
+	EndIP       string        `gorm:"type:varchar(64);not null;uniqueIndex"`
+	InterfaceID uint          `gorm:"not null;uniqueIndex:idx_ip_pools_interface_ip_version,priority:1"`
+	IPVersion   int           `gorm:"not null;default:4;uniqueIndex:idx_ip_pools_interface_ip_version,priority:2"`
+}
+
+func (dualStackIPPool) TableName() string { return "ip_pools" }
+
+// migrateDualStackIPPools widens the pool addresses for IPv6 and replaces the one pool per interface index by one pool
+// per interface and IP version
+func migrateDualStackIPPools(tx *gorm.DB) error {
+	migrator := tx.Migrator()
+
+	for _, field := range []string{"StartIP", "EndIP"} {
+		if err := migrator.AlterColumn(&dualStackIPPool{}, field); err != nil {
+			return err
+		}
+	}
+	if !migrator.HasColumn(&dualStackIPPool{}, "IPVersion") {
+		if err := migrator.AddColumn(&dualStackIPPool{}, "IPVersion"); err != nil {
+			return err
+		}
+	}
+	if migrator.HasIndex(&dualStackIPPool{}, "idx_ip_pools_interface_id") {
+		if err := migrator.DropIndex(&dualStackIPPool{}, "idx_ip_pools_interface_id"); err != nil {
+			return err
+		}
+	}
+
+	return ensureIPPoolIndexes(tx, &dualStackIPPool{}, "idx_ip_pools_interface_ip_version")
+}
+
+// revertDualStackIPPools goes back to IPv4 only pools, it refuses while IPv6 pools exist
+func revertDualStackIPPools(tx *gorm.DB) error {
+	var ipv6Pools int64
+	if err := tx.Table("ip_pools").Where("ip_version <> ?", 4).Count(&ipv6Pools).Error; err != nil {
+		return err
+	}
+	if ipv6Pools > 0 {
+		return errors.New("delete the IPv6 pools before reverting dual stack support")
+	}
+
+	migrator := tx.Migrator()
+	if err := migrator.DropIndex(&dualStackIPPool{}, "idx_ip_pools_interface_ip_version"); err != nil {
+		return err
+	}
+	if err := migrator.DropColumn(&dualStackIPPool{}, "IPVersion"); err != nil {
+		return err
+	}
+	for _, field := range []string{"StartIP", "EndIP"} {
+		if err := migrator.AlterColumn(&baselineIPPool{}, field); err != nil {
+			return err
+		}
+	}
+
+	return ensureIPPoolIndexes(tx, &baselineIPPool{}, "idx_ip_pools_interface_id")
+}
+
+// ensureIPPoolIndexes creates the ip_pools indexes that are missing, SQLite rebuilds the table to alter a column and
+// loses them on the way
+func ensureIPPoolIndexes(tx *gorm.DB, pool interface{}, interfaceIndex string) error {
+	indexes := []string{"idx_ip_pools_deleted_at", "idx_ip_pools_name", "idx_ip_pools_start_ip", "idx_ip_pools_end_ip", interfaceIndex}
+	for _, index := range indexes {
+		if tx.Migrator().HasIndex(pool, index) {
+			continue
+		}
+		if err := tx.Migrator().CreateIndex(pool, index); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
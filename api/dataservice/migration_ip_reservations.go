@@ -0,0 +1,24 @@
+package dataservice
+
+import (
+	"gorm.io/gorm"
+)
+
+type ipReservation struct {
+	Model    baselineModel `gorm:"embedded"`
+	PoolID   uint          `gorm:"not null;index;uniqueIndex:idx_ip_reservations_pool_peer_name"`
+	StartIP  string        `gorm:"type:varchar(64);not null"`
+	EndIP    string        `gorm:"type:varchar(64);not null"`
+	PeerName *string       `gorm:"type:varchar(255);uniqueIndex:idx_ip_reservations_pool_peer_name"`
+	Comment  *string       `gorm:"type:varchar(255)"`
+}
+
+func (ipReservation) TableName() string { return "ip_reservations" }
+
+func createIPReservations(tx *gorm.DB) error {
+	return tx.Migrator().CreateTable(&ipReservation{})
+}
+
+func dropIPReservations(tx *gorm.DB) error {
+	return tx.Migrator().DropTable(&ipReservation{})
+}
@@ -0,0 +1,70 @@
+package dataservice
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// multipleIPPool is ip_pools once an interface may have several pools of a family, tried in order of priority
+type multipleIPPool struct {
+	Model       baselineModel `gorm:"embedded"`
+	Name        string        `gorm:"type:varchar(255);not null;uniqueIndex"`
+	CIDR        *string       `gorm:"column:cidr;type:varchar(64)"`
+	StartIP     string        `gorm:"type:varchar(64);not null;uniqueIndex"`
+	EndIP       string        `gorm:"type:varchar(64);not null;uniqueIndex"`
+	InterfaceID uint          `gorm:"not null;index:idx_ip_pools_interface_priority,priority:1"`
+	IPVersion   int           `gorm:"not null;default:4"`
+	Priority    int           `gorm:"not null;default:0;index:idx_ip_pools_interface_priority,priority:2"`
+}
+
+func (multipleIPPool) TableName() string { return "ip_pools" }
+
+// migrateMultipleIPPools adds the CIDR and priority of pools and lifts the one pool per interface and family limit
+func migrateMultipleIPPools(tx *gorm.DB) error {
+	migrator := tx.Migrator()
+
+	for _, field := range []string{"CIDR", "Priority"} {
+		if migrator.HasColumn(&multipleIPPool{}, field) {
+			continue
+		}
+		if err := migrator.AddColumn(&multipleIPPool{}, field); err != nil {
+			return err
+		}
+	}
+	if migrator.HasIndex(&multipleIPPool{}, "idx_ip_pools_interface_ip_version") {
+		if err := migrator.DropIndex(&multipleIPPool{}, "idx_ip_pools_interface_ip_version"); err != nil {
+			return err
+		}
+	}
+
+	return ensureIPPoolIndexes(tx, &multipleIPPool{}, "idx_ip_pools_interface_priority")
+}
+
+// revertMultipleIPPools goes back to one pool per interface and family, it refuses while an interface has more
+func revertMultipleIPPools(tx *gorm.DB) error {
+	var crowded int64
+	err := tx.Table("ip_pools").
+		Select("interface_id").
+		Group("interface_id, ip_version").
+		Having("COUNT(*) > 1").
+		Count(&crowded).Error
+	if err != nil {
+		return err
+	}
+	if crowded > 0 {
+		return errors.New("keep a single pool per interface and IP version before reverting multiple pools")
+	}
+
+	migrator := tx.Migrator()
+	if err := migrator.DropIndex(&multipleIPPool{}, "idx_ip_pools_interface_priority"); err != nil {
+		return err
+	}
+	for _, field := range []string{"CIDR", "Priority"} {
+		if err := migrator.DropColumn(&multipleIPPool{}, field); err != nil {
+			return err
+		}
+	}
+
+	return ensureIPPoolIndexes(tx, &dualStackIPPool{}, "idx_ip_pools_interface_ip_version")
+}
@@ -0,0 +1,35 @@
+package dataservice
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"gorm.io/gorm"
+
+	"github.com/maahdima/mwp/api/config"
+)
+
+// removePeerFiles deletes the peer configs and QR codes older versions stored on disk, they are rendered from the
+// database now. The directory itself goes as well unless something else was put there.
+func removePeerFiles(*gorm.DB) error {
+	dir := config.GetAppConfig().PeerFilesDir
+
+	for _, sub := range []string{"config", "qrcode"} {
+		if err := os.RemoveAll(filepath.Join(dir, sub)); err != nil {
+			return err
+		}
+	}
+
+	err := os.Remove(dir)
+	if err == nil || errors.Is(err, os.ErrNotExist) || errors.Is(err, syscall.ENOTEMPTY) || errors.Is(err, syscall.EEXIST) {
+		return nil
+	}
+	return err
+}
+
+// restorePeerFiles leaves the files to the older version, its next sync with the router writes them again
+func restorePeerFiles(*gorm.DB) error {
+	return nil
+}
@@ -0,0 +1,21 @@
+package dataservice
+
+import (
+	"gorm.io/gorm"
+)
+
+// migrations are applied in order of Version, append new steps at the end and never change the released ones
+var migrations = []Migration{
+	{Version: 1, Name: "baseline", Up: migrateBaseline},
+	{Version: 2, Name: "encrypt_secrets", Up: sealSecrets, Down: openSecrets},
+	{Version: 3, Name: "dual_stack_ip_pools", Up: migrateDualStackIPPools, Down: revertDualStackIPPools},
+	{Version: 4, Name: "ip_reservations", Up: createIPReservations, Down: dropIPReservations},
+	{Version: 5, Name: "multiple_ip_pools", Up: migrateMultipleIPPools, Down: revertMultipleIPPools},
+	{Version: 6, Name: "client_config", Up: migrateClientConfig, Down: revertClientConfig},
+	{Version: 7, Name: "remove_peer_files", Up: removePeerFiles, Down: restorePeerFiles},
+}
+
+func sealSecrets(tx *gorm.DB) error {
+	_, err := rewrapSecrets(tx)
+	return err
+}
@@ -1,8 +1,26 @@
 package model
 
+// Roles of an admin account
+const (
+	AdminRoleOwner    = "owner"    // everything, including admin accounts and servers
+	AdminRoleOperator = "operator" // manages peers, reads everything else
+	AdminRoleViewer   = "viewer"   // read only
+	AdminRoleReseller = "reseller" // manages the peers of the servers and interfaces in its scope
+)
+
 type Admin struct {
 	Model
 	Username string `gorm:"type:varchar(64);uniqueIndex;not null;"`
 	Password string `gorm:"type:varchar(128);not null;"`
 	IsActive bool   `gorm:"not null;default:true;"`
+	Role     string `gorm:"type:varchar(16);not null;default:owner;"`
+
+	// TOTPSecret is set on enrolment, the second factor is only asked for once TOTPEnabled is confirmed with a code
+	TOTPSecret   string `gorm:"type:text;serializer:encrypted;"`
+	TOTPEnabled  bool   `gorm:"not null;default:false;"`
+	TOTPLastStep int64  `gorm:"not null;default:0;"` // last accepted time step, a code is never accepted twice
+	// RecoveryCodeSalt keys the HMAC the recovery codes are stored with, it is replaced with every set of codes
+	RecoveryCodeSalt string `gorm:"type:varchar(64);"`
+
+	Scopes []AdminScope `gorm:"foreignKey:AdminID;constraint:-"`
 }
@@ -0,0 +1,10 @@
+package model
+
+// AdminRecoveryCode is a single use code that stands in for the TOTP code of an admin, only its HMAC keyed by
+// the RecoveryCodeSalt of the admin is kept
+type AdminRecoveryCode struct {
+	Model
+	AdminID  uint   `gorm:"index;not null"`
+	CodeHash string `gorm:"type:varchar(64);not null"`
+	UsedAt   *int64
+}
@@ -0,0 +1,9 @@
+package model
+
+// AdminScope grants a reseller a whole server, or a single interface of it when InterfaceID is set
+type AdminScope struct {
+	Model
+	AdminID     uint  `gorm:"index;not null"`
+	ServerID    uint  `gorm:"not null"`
+	InterfaceID *uint `gorm:"index"`
+}
@@ -0,0 +1,14 @@
+package model
+
+// AdminSession is one login of an admin. Access tokens carry its SessionID and stop working once it is revoked, the
+// refresh token is rotated on every use and only the latest one (RefreshTokenID) is accepted.
+type AdminSession struct {
+	Model
+	SessionID      string `gorm:"type:varchar(36);uniqueIndex;not null"`
+	AdminID        uint   `gorm:"index;not null"`
+	RefreshTokenID string `gorm:"type:varchar(36);not null"`
+	ExpiresAt      int64  `gorm:"not null;index"` // unix seconds, moved forward on every refresh
+	RevokedAt      *int64
+	UserAgent      string `gorm:"type:varchar(255)"`
+	IPAddress      string `gorm:"type:varchar(64)"`
+}
@@ -0,0 +1,22 @@
+package model
+
+// API token scopes, a token is further limited by the role of its admin
+const (
+	APITokenScopeRead       = "read"
+	APITokenScopePeersWrite = "peers:write"
+	APITokenScopeWrite      = "write"
+)
+
+// APIToken is a long-lived personal access token of an admin. Only the SHA-256 of the secret is kept, Prefix is the
+// start of it so the owner can tell the tokens apart.
+type APIToken struct {
+	Model
+	AdminID    uint   `gorm:"index;not null"`
+	Name       string `gorm:"type:varchar(64);not null"`
+	Prefix     string `gorm:"type:varchar(16);not null"`
+	TokenHash  string `gorm:"type:varchar(64);uniqueIndex;not null"`
+	Scopes     string `gorm:"type:varchar(255);not null"` // comma separated
+	ExpiresAt  *int64 // unix seconds, nil never expires
+	LastUsedAt *int64
+	RevokedAt  *int64
+}
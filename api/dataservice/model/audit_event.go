@@ -0,0 +1,16 @@
+package model
+
+// AuditEvent records one administrative request. Events are never updated or soft deleted, so it does not embed
+// Model; Before and After hold JSON snapshots of the target with secrets redacted.
+type AuditEvent struct {
+	ID         uint   `gorm:"primarykey"`
+	CreatedAt  uint64 `gorm:"not null;index"`
+	Actor      string `gorm:"type:varchar(64);not null;index"`
+	Action     string `gorm:"type:varchar(64);not null;index"`
+	TargetType string `gorm:"type:varchar(32);not null;index:idx_audit_events_target"`
+	TargetID   string `gorm:"type:varchar(64);index:idx_audit_events_target"`
+	Before     string `gorm:"type:text"`
+	After      string `gorm:"type:text"`
+	SourceIP   string `gorm:"type:varchar(64)"`
+	StatusCode int    `gorm:"not null"`
+}
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm/schema"
+
+	"github.com/maahdima/mwp/api/utils/envelope"
+)
+
+var errNoKeyring = errors.New("encryption master key is not loaded")
+
+func init() {
+	schema.RegisterSerializer("encrypted", EncryptedSerializer{})
+}
+
+// EncryptedSerializer keeps string columns tagged serializer:encrypted sealed in the database with the default
+// envelope keyring. Plaintext left from before encryption is still read, the encrypt_secrets migration seals it.
+type EncryptedSerializer struct{}
+
+func (EncryptedSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var value string
+	switch v := dbValue.(type) {
+	case nil:
+	case string:
+		value = v
+	case []byte:
+		value = string(v)
+	default:
+		return fmt.Errorf("unsupported value %T for encrypted field %s", dbValue, field.Name)
+	}
+
+	if envelope.IsSealed(value) {
+		keyring := envelope.Default()
+		if keyring == nil {
+			return errNoKeyring
+		}
+		plaintext, err := keyring.Open(value)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt %s: %w", field.Name, err)
+		}
+		value = string(plaintext)
+	}
+
+	return field.Set(ctx, dst, value)
+}
+
+func (EncryptedSerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	value, ok := fieldValue.(string)
+	if !ok {
+		return nil, fmt.Errorf("unsupported value %T for encrypted field %s", fieldValue, field.Name)
+	}
+	if value == "" {
+		return "", nil
+	}
+
+	keyring := envelope.Default()
+	if keyring == nil {
+		return nil, errNoKeyring
+	}
+
+	return keyring.Seal([]byte(value))
+}
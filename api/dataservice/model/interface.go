@@ -2,13 +2,24 @@ package model
 
 type Interface struct {
 	Model
-	InterfaceID string  `gorm:"type:varchar(255);uniqueIndex;not null"`
+	ServerID    uint    `gorm:"not null;default:0;uniqueIndex:idx_interfaces_server_interface_id;uniqueIndex:idx_interfaces_server_name"`
+	InterfaceID string  `gorm:"type:varchar(255);not null;uniqueIndex:idx_interfaces_server_interface_id"`
 	Disabled    bool    `gorm:"type:boolean;not null;default:false"`
 	Comment     *string `gorm:"type:varchar(255)"`
-	Name        string  `gorm:"type:varchar(255);uniqueIndex;not null"`
-	PrivateKey  string  `gorm:"type:varchar(255);not null"`
+	Name        string  `gorm:"type:varchar(255);not null;uniqueIndex:idx_interfaces_server_name"`
+	PrivateKey  string  `gorm:"type:text;not null;serializer:encrypted"`
 	PublicKey   string  `gorm:"type:varchar(255);not null"`
 	ListenPort  string  `gorm:"type:varchar(10);not null"`
 
-	IPPool *IPPool `gorm:"foreignKey:InterfaceID"`
+	// client config defaults of the peers of the interface, a peer setting its own wins
+	ClientDNS                 *string `gorm:"type:varchar(255)"`
+	ClientAllowedIPsMode      *string `gorm:"type:varchar(32)"`
+	ClientAllowedIPs          *string `gorm:"type:text"`
+	ClientMTU                 *int    `gorm:"column:client_mtu"`
+	ClientPersistentKeepalive *string `gorm:"type:varchar(10)"`
+	GeneratePresharedKey      bool    `gorm:"type:boolean;not null;default:false"`
+	OmitPresharedKey          bool    `gorm:"type:boolean;not null;default:false"`
+
+	Server  Server   `gorm:"foreignKey:ServerID;constraint:-"`
+	IPPools []IPPool `gorm:"foreignKey:InterfaceID"`
 }
@@ -2,9 +2,12 @@ package model
 
 type IPPool struct {
 	Model
-	Name    string `gorm:"type:varchar(255);not null;uniqueIndex"`
-	StartIP string `gorm:"type:varchar(15);not null;uniqueIndex"`
-	EndIP   string `gorm:"type:varchar(15);not null;uniqueIndex"`
+	Name    string  `gorm:"type:varchar(255);not null;uniqueIndex"`
+	CIDR    *string `gorm:"column:cidr;type:varchar(64)"`
+	StartIP string  `gorm:"type:varchar(64);not null;uniqueIndex"`
+	EndIP   string  `gorm:"type:varchar(64);not null;uniqueIndex"`
 
-	InterfaceID uint `gorm:"not null;uniqueIndex"`
+	InterfaceID uint `gorm:"not null;index:idx_ip_pools_interface_priority,priority:1"`
+	IPVersion   int  `gorm:"not null;default:4"`
+	Priority    int  `gorm:"not null;default:0;index:idx_ip_pools_interface_priority,priority:2"`
 }
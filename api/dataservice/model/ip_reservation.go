@@ -0,0 +1,11 @@
+package model
+
+// IPReservation keeps a range of a pool away from the allocator, or holds a single address for the peer named PeerName
+type IPReservation struct {
+	Model
+	PoolID   uint    `gorm:"not null;index;uniqueIndex:idx_ip_reservations_pool_peer_name"`
+	StartIP  string  `gorm:"type:varchar(64);not null"`
+	EndIP    string  `gorm:"type:varchar(64);not null"`
+	PeerName *string `gorm:"type:varchar(255);uniqueIndex:idx_ip_reservations_pool_peer_name"`
+	Comment  *string `gorm:"type:varchar(255)"`
+}
@@ -0,0 +1,12 @@
+package model
+
+// LoginThrottle counts the recent failures of one client key, such as an IP address or a username, and the lockout
+// they caused
+type LoginThrottle struct {
+	Model
+	ClientKey     string `gorm:"type:varchar(191);uniqueIndex;not null"`
+	Failures      int    `gorm:"not null;default:0"`
+	Lockouts      int    `gorm:"not null;default:0"` // lockouts in a row, each one lasts twice as long as the previous
+	LastFailureAt int64  `gorm:"not null;default:0"`
+	LockedUntil   int64  `gorm:"not null;default:0"`
+}
@@ -2,15 +2,17 @@ package model
 
 type Peer struct {
 	Model
+	ServerID            uint    `gorm:"not null;default:0;uniqueIndex:idx_peers_server_peer_id;uniqueIndex:idx_peers_server_allowed_address"`
 	UUID                string  `gorm:"type:varchar(36);uniqueIndex;not null"`
-	PeerID              string  `gorm:"type:varchar(255);uniqueIndex;not null"`
+	PeerID              string  `gorm:"type:varchar(255);not null;uniqueIndex:idx_peers_server_peer_id"`
 	Disabled            bool    `gorm:"type:boolean;not null;default:false"`
+	DisabledReason      *string `gorm:"type:varchar(32)"` // common.PeerDisabledBy*, nil while enabled
 	Comment             *string `gorm:"type:text"`
 	Name                string  `gorm:"type:varchar(255);not null"`
-	PrivateKey          string  `gorm:"type:varchar(255);not null"`
+	PrivateKey          string  `gorm:"type:text;not null;serializer:encrypted"`
 	PublicKey           string  `gorm:"type:varchar(255);not null"`
 	Interface           string  `gorm:"type:varchar(255);not null"`
-	AllowedAddress      string  `gorm:"type:varchar(255);uniqueIndex;not null"`
+	AllowedAddress      string  `gorm:"type:varchar(255);not null;uniqueIndex:idx_peers_server_allowed_address"`
 	Endpoint            string  `gorm:"type:varchar(255);not null"`
 	EndpointPort        string  `gorm:"type:varchar(10);not null"`
 	PersistentKeepalive string  `gorm:"type:varchar(10)"`
@@ -30,4 +32,14 @@ type Peer struct {
 	LastRx              int64   `gorm:"type:bigint;not null;default:0"` // in bytes
 	IsShared            bool    `gorm:"type:boolean;not null;default:false"`
 	ShareExpireTime     *string `gorm:"type:varchar(255)"`
+	DNS                 *string `gorm:"type:varchar(255)"`
+	AllowedIPsMode      *string `gorm:"type:varchar(32)"`
+	AllowedIPs          *string `gorm:"type:text"`
+	MTU                 *int    `gorm:"column:mtu"`
+	PresharedKey        string  `gorm:"type:text;serializer:encrypted"`
+	IncludePresharedKey *bool   `gorm:"type:boolean"`
+	PlanID              *uint   `gorm:"index"`
+
+	Server Server    `gorm:"foreignKey:ServerID;constraint:-"`
+	Plan   *PeerPlan `gorm:"foreignKey:PlanID;constraint:-"`
 }
@@ -0,0 +1,14 @@
+package model
+
+type PeerPlan struct {
+	Model
+	Name                string  `gorm:"type:varchar(255);not null;uniqueIndex"`
+	Comment             *string `gorm:"type:varchar(255)"`
+	TrafficLimit        *int64  `gorm:"type:bigint"` // in bytes
+	DownloadBandwidth   *string `gorm:"type:varchar(255)"`
+	UploadBandwidth     *string `gorm:"type:varchar(255)"`
+	ValidityDays        *int    `gorm:"type:integer"`
+	PersistentKeepalive *string `gorm:"type:varchar(10)"`
+	DNS                 *string `gorm:"type:varchar(255)"`
+	AllowedIPsMode      *string `gorm:"type:varchar(32)"`
+}
@@ -0,0 +1,16 @@
+package model
+
+// PeerRenewal records one renewal of a peer, rows outlive the peer so they can be used for billing
+type PeerRenewal struct {
+	Model
+	PeerID             uint    `gorm:"index;not null"`
+	ServerID           uint    `gorm:"index;not null"`
+	PeerName           string  `gorm:"type:varchar(255);not null"`
+	PlanID             *uint   `gorm:"index"`
+	Days               int     `gorm:"not null"`
+	PreviousExpireTime *string `gorm:"type:varchar(255)"`
+	NewExpireTime      string  `gorm:"type:varchar(255);not null"`
+	UsageReset         bool    `gorm:"type:boolean;not null;default:false"`
+	PreviousUsage      int64   `gorm:"type:bigint;not null;default:0"` // in bytes
+	ReEnabled          bool    `gorm:"type:boolean;not null;default:false"`
+}
@@ -0,0 +1,19 @@
+package model
+
+// Granularities of PeerTrafficSample rows
+const (
+	TrafficGranularityRaw  = "raw"
+	TrafficGranularityHour = "hour"
+	TrafficGranularityDay  = "day"
+)
+
+// PeerTrafficSample holds the traffic of a peer over one bucket. Raw rows are written by every traffic job run, the
+// hour and day rows are rolled up as the samples come in.
+type PeerTrafficSample struct {
+	Model
+	PeerID        uint   `gorm:"not null;uniqueIndex:idx_peer_traffic_sample"`
+	Granularity   string `gorm:"type:varchar(16);not null;uniqueIndex:idx_peer_traffic_sample"`
+	BucketStart   int64  `gorm:"not null;uniqueIndex:idx_peer_traffic_sample;index"` // unix seconds
+	DownloadUsage int64  `gorm:"type:bigint;not null;default:0"`                     // in bytes
+	UploadUsage   int64  `gorm:"type:bigint;not null;default:0"`                     // in bytes
+}
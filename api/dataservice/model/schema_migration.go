@@ -0,0 +1,8 @@
+package model
+
+// SchemaMigration records a migration applied to the database, the highest Version is the version of the schema
+type SchemaMigration struct {
+	Version   uint   `gorm:"primarykey;autoIncrement:false"`
+	Name      string `gorm:"type:varchar(255);not null"`
+	AppliedAt int64  `gorm:"not null"` // unix seconds
+}
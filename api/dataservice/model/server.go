@@ -6,7 +6,8 @@ type Server struct {
 	Name      string  `gorm:"type:varchar(64);uniqueIndex;not null;"`
 	IPAddress string  `gorm:"type:varchar(64);uniqueIndex;not null;"`
 	APIPort   int     `gorm:"not null;default:80;"`
+	IsSSL     bool    `gorm:"not null;default:false;"`
 	Username  string  `gorm:"type:varchar(64);not null;"`
-	Password  string  `gorm:"type:varchar(64);not null;"`
+	Password  string  `gorm:"type:text;not null;serializer:encrypted"`
 	IsActive  bool    `gorm:"not null;default:true;"`
 }
@@ -34,6 +34,7 @@ func AdminSeed(db *gorm.DB) error {
 		{
 			Username: adminConfig.Username,
 			Password: string(hashedPassword),
+			Role:     model.AdminRoleOwner,
 		},
 	}
 
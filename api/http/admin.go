@@ -0,0 +1,175 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/http/middleware"
+	"github.com/maahdima/mwp/api/http/schema"
+	"github.com/maahdima/mwp/api/service"
+)
+
+type AdminController struct {
+	adminService *service.Admin
+	logger       *zap.Logger
+}
+
+func NewAdminController(adminService *service.Admin) *AdminController {
+	return &AdminController{
+		adminService: adminService,
+		logger:       zap.L().Named("AdminController"),
+	}
+}
+
+func (c *AdminController) GetAdmins(ctx echo.Context) error {
+	admins, err := c.adminService.GetAdmins()
+	if err != nil {
+		c.logger.Error("failed to get admins", zap.Error(err))
+		return ctx.JSON(http.StatusInternalServerError, schema.ErrorResponse{
+			StatusCode: http.StatusInternalServerError,
+			Status:     "error",
+			Message:    "failed to get admins: " + err.Error(),
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, schema.BasicResponseData[[]schema.AdminResponse]{
+		BasicResponse: schema.OkBasicResponse,
+		Data:          *admins,
+	})
+}
+
+func (c *AdminController) CreateAdmin(ctx echo.Context) error {
+	var req schema.CreateAdminRequest
+
+	if err := ctx.Bind(&req); err != nil {
+		c.logger.Warn("failed to bind request", zap.Error(err))
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	if err := ctx.Validate(&req); err != nil {
+		c.logger.Warn("failed to validate request", zap.Error(err))
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	admin, err := c.adminService.CreateAdmin(&req)
+	if err != nil {
+		if errors.Is(err, common.ErrInvalidAdmin) {
+			return ctx.JSON(http.StatusBadRequest, schema.ErrorResponse{
+				StatusCode: http.StatusBadRequest,
+				Status:     "error",
+				Message:    err.Error(),
+			})
+		}
+
+		c.logger.Error("failed to create admin", zap.Error(err))
+		return ctx.JSON(http.StatusInternalServerError, schema.ErrorResponse{
+			StatusCode: http.StatusInternalServerError,
+			Status:     "error",
+			Message:    "failed to create admin: " + err.Error(),
+		})
+	}
+
+	return ctx.JSON(http.StatusCreated, schema.BasicResponseData[schema.AdminResponse]{
+		BasicResponse: schema.OkBasicResponse,
+		Data:          *admin,
+	})
+}
+
+func (c *AdminController) UpdateAdmin(ctx echo.Context) error {
+	id := ctx.Param("id")
+	if id == "" {
+		c.logger.Error("Admin ID is required")
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	adminId, err := strconv.Atoi(id)
+	if err != nil {
+		c.logger.Error("Invalid admin ID", zap.Error(err))
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	var req schema.UpdateAdminRequest
+	if err := ctx.Bind(&req); err != nil {
+		c.logger.Warn("failed to bind request", zap.Error(err))
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	if err := ctx.Validate(&req); err != nil {
+		c.logger.Warn("failed to validate request", zap.Error(err))
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	admin, err := c.adminService.UpdateAdmin(uint(adminId), &req)
+	if err != nil {
+		return c.adminErrorResponse(ctx, err, "failed to update admin")
+	}
+
+	return ctx.JSON(http.StatusOK, schema.BasicResponseData[schema.AdminResponse]{
+		BasicResponse: schema.OkBasicResponse,
+		Data:          *admin,
+	})
+}
+
+func (c *AdminController) DeleteAdmin(ctx echo.Context) error {
+	id := ctx.Param("id")
+	if id == "" {
+		c.logger.Error("Admin ID is required")
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	adminId, err := strconv.Atoi(id)
+	if err != nil {
+		c.logger.Error("Invalid admin ID", zap.Error(err))
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	var caller string
+	if claims := middleware.GetClaims(ctx); claims != nil {
+		caller = claims.Subject
+	}
+
+	if err := c.adminService.DeleteAdmin(uint(adminId), caller); err != nil {
+		return c.adminErrorResponse(ctx, err, "failed to delete admin")
+	}
+
+	return ctx.JSON(http.StatusNoContent, schema.BasicResponse{
+		StatusCode: http.StatusNoContent,
+		Status:     "success",
+	})
+}
+
+func (c *AdminController) adminErrorResponse(ctx echo.Context, err error, message string) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ctx.JSON(http.StatusNotFound, schema.ErrorResponse{
+			StatusCode: http.StatusNotFound,
+			Status:     "error",
+			Message:    "admin not found",
+		})
+	case errors.Is(err, common.ErrInvalidAdmin):
+		return ctx.JSON(http.StatusBadRequest, schema.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Status:     "error",
+			Message:    err.Error(),
+		})
+	case errors.Is(err, common.ErrLastOwner):
+		return ctx.JSON(http.StatusConflict, schema.ErrorResponse{
+			StatusCode: http.StatusConflict,
+			Status:     "error",
+			Message:    err.Error(),
+		})
+	}
+
+	c.logger.Error(message, zap.Error(err))
+	return ctx.JSON(http.StatusInternalServerError, schema.ErrorResponse{
+		StatusCode: http.StatusInternalServerError,
+		Status:     "error",
+		Message:    message + ": " + err.Error(),
+	})
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/maahdima/mwp/api/cmd/jobs"
 	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/dataservice/model"
 	"github.com/maahdima/mwp/api/http/middleware"
 	"github.com/maahdima/mwp/api/service"
 
@@ -16,27 +17,34 @@ func SetupMwpAPI(
 	app *echo.Echo,
 	mwpClients *common.MwpClients,
 	authenticationService *service.Authentication,
+	adminService *service.Admin,
 	serverService *service.Server,
 	interfaceService *service.WgInterface,
 	ipPoolService *service.IPPool,
 	peerService *service.WgPeer,
+	peerPlanService *service.PeerPlan,
 	configGeneratorService *service.ConfigGenerator,
 	qrCodeGeneratorService *service.QRCodeGenerator,
 	excelGeneratorService *service.ExcelGenerator,
 	deviceDataService *service.DeviceData,
 	trafficCalculator *traffic.Calculator,
 	syncService *service.SyncService,
+	reconciler *traffic.Reconciler,
+	rateLimiter *service.RateLimiter,
+	auditService *service.Audit,
 ) {
 	router := app.Group("/api")
 
 	jwtConfig := echojwt.Config{
-		SigningKey: authenticationService.AccessSecret,
+		ParseTokenFunc: authenticationService.ParseAccessToken,
 		ErrorHandler: func(c echo.Context, err error) error {
 			return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
 		},
 	}
 
 	authController := NewAuthController(authenticationService)
+	adminController := NewAdminController(adminService)
+	auditController := NewAuditController(auditService)
 	serverController := NewServerController(serverService)
 	wgInterfaceController := NewWgInterfaceController(interfaceService)
 	ipPoolController := NewIPPoolController(ipPoolService)
@@ -47,32 +55,80 @@ func SetupMwpAPI(
 		excelGeneratorService,
 		trafficCalculator,
 	)
+	peerPlanController := NewPeerPlanController(peerPlanService)
 	deviceInfoController := NewDeviceDataController(deviceDataService, trafficCalculator)
-	syncController := NewSyncController(syncService)
+	syncController := NewSyncController(syncService, reconciler)
 	userController := NewUserController(peerService, configGeneratorService, qrCodeGeneratorService)
 
-	setupAuthenticationRoutes(router, jwtConfig, authController)
-	setupServerRoutes(router, jwtConfig, serverController)
-	setupInterfaceRoutes(router, mwpClients, jwtConfig, wgInterfaceController)
-	setupIPPoolRoutes(router, jwtConfig, ipPoolController)
-	setupPeerRoutes(router, mwpClients, jwtConfig, wgPeerController)
-	setupDeviceInfoRoutes(router, mwpClients, jwtConfig, deviceInfoController)
-	setupSyncRoutes(router, mwpClients, jwtConfig, syncController)
-	setupUserRoutes(router, userController)
+	setupAuthenticationRoutes(router, jwtConfig, rateLimiter, auditService, authController)
+	setupAdminRoutes(router, jwtConfig, auditService, adminController)
+	setupAuditRoutes(router, jwtConfig, auditController)
+	setupServerRoutes(router, jwtConfig, auditService, serverController)
+	setupInterfaceRoutes(router, mwpClients, jwtConfig, auditService, wgInterfaceController)
+	setupIPPoolRoutes(router, jwtConfig, auditService, ipPoolController)
+	setupPeerRoutes(router, mwpClients, jwtConfig, auditService, peerService, wgPeerController)
+	setupPeerPlanRoutes(router, jwtConfig, auditService, peerPlanController)
+	setupDeviceInfoRoutes(router, mwpClients, jwtConfig, auditService, deviceInfoController)
+	setupSyncRoutes(router, mwpClients, jwtConfig, auditService, syncController)
+	setupUserRoutes(router, rateLimiter, userController)
 }
 
-func setupAuthenticationRoutes(router *echo.Group, jwtConfig echojwt.Config, authController *AuthController) {
+func setupAuthenticationRoutes(router *echo.Group, jwtConfig echojwt.Config, rateLimiter *service.RateLimiter, auditService *service.Audit, authController *AuthController) {
 	authGroup := router.Group("/auth")
-	authGroup.POST("/login", authController.Login)
+	auditAuth := middleware.Audit(auditService, "auth")
+	auditTokens := middleware.Audit(auditService, "api-token")
+
+	loginThrottle := middleware.Throttle(middleware.ThrottleConfig{
+		Throttler:       rateLimiter,
+		MaxAttempts:     rateLimiter.LoginMaxAttempts,
+		Keys:            middleware.LoginThrottleKeys,
+		FailureStatuses: []int{http.StatusUnauthorized},
+		ResetOnSuccess:  true,
+	})
+	authGroup.POST("/login", authController.Login, auditAuth, loginThrottle)
+	authGroup.POST("/login/2fa", authController.LoginTwoFactor, auditAuth, loginThrottle)
+	authGroup.POST("/refresh", authController.Refresh)
 
 	authProtected := authGroup.Group("")
 	authProtected.Use(echojwt.WithConfig(jwtConfig))
-	authProtected.PUT("/profile", authController.UpdateProfile)
+	authProtected.Use(middleware.RequireSession())
+	authProtected.POST("/logout", authController.Logout, auditAuth)
+	authProtected.PUT("/profile", authController.UpdateProfile, auditAuth)
+	authProtected.GET("/tokens", authController.GetAPITokens)
+	authProtected.POST("/tokens", authController.CreateAPIToken, auditTokens)
+	authProtected.DELETE("/tokens/:id", authController.RevokeAPIToken, auditTokens)
+	authProtected.POST("/2fa/setup", authController.SetupTwoFactor, auditAuth)
+	authProtected.POST("/2fa/enable", authController.EnableTwoFactor, auditAuth)
+	authProtected.POST("/2fa/disable", authController.DisableTwoFactor, auditAuth)
+	authProtected.POST("/2fa/recovery-codes", authController.RegenerateRecoveryCodes, auditAuth)
+}
+
+func setupAdminRoutes(router *echo.Group, jwtConfig echojwt.Config, auditService *service.Audit, adminController *AdminController) {
+	adminGroup := router.Group("/admin")
+	adminGroup.Use(echojwt.WithConfig(jwtConfig))
+	adminGroup.Use(middleware.Audit(auditService, "admin"))
+	adminGroup.Use(middleware.Authorize(middleware.OwnerRoles, middleware.OwnerRoles))
+
+	adminGroup.GET("", adminController.GetAdmins)
+	adminGroup.POST("", adminController.CreateAdmin)
+	adminGroup.PUT("/:id", adminController.UpdateAdmin)
+	adminGroup.DELETE("/:id", adminController.DeleteAdmin)
+}
+
+func setupAuditRoutes(router *echo.Group, jwtConfig echojwt.Config, auditController *AuditController) {
+	auditGroup := router.Group("/audit")
+	auditGroup.Use(echojwt.WithConfig(jwtConfig))
+	auditGroup.Use(middleware.Authorize(middleware.OwnerRoles, middleware.OwnerRoles))
+
+	auditGroup.GET("", auditController.GetAuditEvents)
+	auditGroup.GET("/export", auditController.ExportAuditEvents)
 }
 
-func setupServerRoutes(router *echo.Group, jwtConfig echojwt.Config, serverController *ServerController) {
+func setupServerRoutes(router *echo.Group, jwtConfig echojwt.Config, auditService *service.Audit, serverController *ServerController) {
 	serverGroup := router.Group("/server")
 	serverGroup.Use(echojwt.WithConfig(jwtConfig))
+	serverGroup.Use(middleware.Audit(auditService, "server"))
+	serverGroup.Use(middleware.Authorize(middleware.AllRoles, middleware.OwnerRoles))
 
 	serverGroup.GET("", serverController.GetServers)
 	serverGroup.POST("", serverController.CreateServer)
@@ -81,12 +137,15 @@ func setupServerRoutes(router *echo.Group, jwtConfig echojwt.Config, serverContr
 	serverGroup.DELETE("/:id", serverController.DeleteServer)
 }
 
-func setupInterfaceRoutes(router *echo.Group, mwpClients *common.MwpClients, jwtConfig echojwt.Config, wgInterfaceController *WgInterfaceController) {
+func setupInterfaceRoutes(router *echo.Group, mwpClients *common.MwpClients, jwtConfig echojwt.Config, auditService *service.Audit, wgInterfaceController *WgInterfaceController) {
 	interfaceGroup := router.Group("/interface")
 	interfaceGroup.Use(echojwt.WithConfig(jwtConfig))
+	interfaceGroup.Use(middleware.Audit(auditService, "interface"))
+	interfaceGroup.Use(middleware.Authorize(middleware.AllRoles, middleware.OwnerRoles))
 
 	secured := interfaceGroup.Group("")
 	secured.Use(middleware.ClientConnectionMiddleware(mwpClients))
+	secured.Use(middleware.ServerScopeMiddleware())
 
 	secured.GET("", wgInterfaceController.GetInterfaces)
 	secured.POST("", wgInterfaceController.CreateInterface)
@@ -95,19 +154,27 @@ func setupInterfaceRoutes(router *echo.Group, mwpClients *common.MwpClients, jwt
 	secured.DELETE("/:id", wgInterfaceController.DeleteInterface)
 }
 
-func setupIPPoolRoutes(router *echo.Group, jwtConfig echojwt.Config, ipPpolController *IPPoolController) {
+func setupIPPoolRoutes(router *echo.Group, jwtConfig echojwt.Config, auditService *service.Audit, ipPpolController *IPPoolController) {
 	ipPoolGroup := router.Group("/ip-pool")
 	ipPoolGroup.Use(echojwt.WithConfig(jwtConfig))
+	ipPoolGroup.Use(middleware.Audit(auditService, "ip-pool"))
+	ipPoolGroup.Use(middleware.Authorize(middleware.StaffRoles, middleware.OwnerRoles))
 
 	ipPoolGroup.GET("", ipPpolController.GetIPPools)
 	ipPoolGroup.POST("", ipPpolController.CreateIPPool)
 	ipPoolGroup.PUT("/:id", ipPpolController.UpdateIPPool)
 	ipPoolGroup.DELETE("/:id", ipPpolController.DeleteIPPool)
+	ipPoolGroup.GET("/:id/reservations", ipPpolController.GetIPReservations)
+	ipPoolGroup.POST("/:id/reservations", ipPpolController.CreateIPReservation)
+	ipPoolGroup.DELETE("/:id/reservations/:reservationId", ipPpolController.DeleteIPReservation)
 }
 
-func setupPeerRoutes(router *echo.Group, mwpClients *common.MwpClients, jwtConfig echojwt.Config, wgPeerController *WgPeerController) {
+func setupPeerRoutes(router *echo.Group, mwpClients *common.MwpClients, jwtConfig echojwt.Config, auditService *service.Audit, peerService *service.WgPeer, wgPeerController *WgPeerController) {
 	peerGroup := router.Group("/peer")
 	peerGroup.Use(echojwt.WithConfig(jwtConfig))
+	peerGroup.Use(middleware.Audit(auditService, "peer"))
+	peerGroup.Use(middleware.Authorize(middleware.AllRoles, middleware.PeerManagers, model.APITokenScopePeersWrite))
+	peerGroup.Use(middleware.PeerScopeMiddleware(peerService.PeerInScope))
 
 	peerGroup.POST("/allowed-address", wgPeerController.GetNewPeerAllowedAddress)
 	peerGroup.GET("/credentials", wgPeerController.GetPeerCredentials)
@@ -116,23 +183,43 @@ func setupPeerRoutes(router *echo.Group, mwpClients *common.MwpClients, jwtConfi
 	peerGroup.PATCH("/:id/share/expire", wgPeerController.UpdatePeerShareExpire)
 	peerGroup.GET("/:id/config", wgPeerController.GetPeerConfig)
 	peerGroup.GET("/:id/qrcode", wgPeerController.GetPeerQRCode)
+	peerGroup.GET("/:id/renewals", wgPeerController.GetPeerRenewals)
+	peerGroup.GET("/:id/traffic", wgPeerController.GetPeerTrafficHistory)
 
 	peerSecured := peerGroup.Group("")
-	peerSecured.Use(middleware.ClientConnectionMiddleware(mwpClients))
+	peerSecured.Use(middleware.PeerClientConnectionMiddleware(mwpClients))
+	peerSecured.Use(middleware.ServerScopeMiddleware())
 
 	peerSecured.GET("", wgPeerController.GetPeers)
 	peerSecured.POST("", wgPeerController.CreatePeer)
+	peerSecured.POST("/import", wgPeerController.ImportPeers)
+	peerSecured.POST("/bulk", wgPeerController.BulkUpdatePeers)
 	peerSecured.PATCH("/:id/status", wgPeerController.UpdatePeerStatus)
 	peerSecured.PATCH("/:id/reset-usage", wgPeerController.ResetPeerUsage)
-	peerSecured.PATCH("/reset-usage", wgPeerController.ResetPeerUsages)
+	peerSecured.PATCH("/reset-usage", wgPeerController.ResetPeerUsages, middleware.RequireRoles(middleware.OperatorRoles...))
+	peerSecured.POST("/:id/renew", wgPeerController.RenewPeer)
 	peerSecured.PUT("/:id", wgPeerController.UpdatePeer)
 	peerSecured.DELETE("/:id", wgPeerController.DeletePeer)
-	peerSecured.POST("/traffic/export", wgPeerController.ExportPeersTrafficData)
+	peerSecured.POST("/traffic/export", wgPeerController.ExportPeersTrafficData, middleware.RequireRoles(middleware.OperatorRoles...))
+}
+
+func setupPeerPlanRoutes(router *echo.Group, jwtConfig echojwt.Config, auditService *service.Audit, peerPlanController *PeerPlanController) {
+	planGroup := router.Group("/plan")
+	planGroup.Use(echojwt.WithConfig(jwtConfig))
+	planGroup.Use(middleware.Audit(auditService, "plan"))
+	planGroup.Use(middleware.Authorize(middleware.AllRoles, middleware.OwnerRoles))
+
+	planGroup.GET("", peerPlanController.GetPeerPlans)
+	planGroup.POST("", peerPlanController.CreatePeerPlan)
+	planGroup.PUT("/:id", peerPlanController.UpdatePeerPlan)
+	planGroup.DELETE("/:id", peerPlanController.DeletePeerPlan)
 }
 
-func setupDeviceInfoRoutes(router *echo.Group, mwpClients *common.MwpClients, jwtConfig echojwt.Config, deviceInfoController *DeviceDataController) {
+func setupDeviceInfoRoutes(router *echo.Group, mwpClients *common.MwpClients, jwtConfig echojwt.Config, auditService *service.Audit, deviceInfoController *DeviceDataController) {
 	deviceGroup := router.Group("/device")
 	deviceGroup.Use(echojwt.WithConfig(jwtConfig))
+	deviceGroup.Use(middleware.Audit(auditService, "device"))
+	deviceGroup.Use(middleware.Authorize(middleware.StaffRoles, middleware.OwnerRoles))
 
 	deviceSecured := deviceGroup.Group("")
 	deviceSecured.Use(middleware.ClientConnectionMiddleware(mwpClients))
@@ -142,9 +229,11 @@ func setupDeviceInfoRoutes(router *echo.Group, mwpClients *common.MwpClients, jw
 	deviceSecured.PATCH("/traffic/reset", deviceInfoController.ResetTotalTrafficUsage)
 }
 
-func setupSyncRoutes(router *echo.Group, mwpClients *common.MwpClients, jwtConfig echojwt.Config, syncController *SyncController) {
+func setupSyncRoutes(router *echo.Group, mwpClients *common.MwpClients, jwtConfig echojwt.Config, auditService *service.Audit, syncController *SyncController) {
 	syncGroup := router.Group("/sync")
 	syncGroup.Use(echojwt.WithConfig(jwtConfig))
+	syncGroup.Use(middleware.Audit(auditService, "sync"))
+	syncGroup.Use(middleware.Authorize(middleware.StaffRoles, middleware.OwnerRoles))
 
 	syncSecured := syncGroup.Group("")
 	syncSecured.Use(middleware.ClientConnectionMiddleware(mwpClients))
@@ -155,11 +244,24 @@ func setupSyncRoutes(router *echo.Group, mwpClients *common.MwpClients, jwtConfi
 	syncSecured.POST("/peers/selected", syncController.SyncSelectedPeers)
 	syncSecured.POST("/peers", syncController.SyncPeers)
 	syncSecured.POST("/interfaces", syncController.SyncInterfaces)
+	syncSecured.GET("/drift", syncController.GetDrift)
+	syncSecured.POST("/drift/resolve", syncController.ResolveDrift)
+
+	// the last run covers every server, it does not need a router connection
+	syncGroup.GET("/reconcile", syncController.GetLastReconcileRun)
 }
 
-func setupUserRoutes(router *echo.Group, userController *UserController) {
+func setupUserRoutes(router *echo.Group, rateLimiter *service.RateLimiter, userController *UserController) {
 	userGroup := router.Group("/user")
 
+	// share links are bearer secrets, clients asking for unknown ones are guessing
+	userGroup.Use(middleware.Throttle(middleware.ThrottleConfig{
+		Throttler:       rateLimiter,
+		MaxAttempts:     rateLimiter.ShareMaxAttempts,
+		Keys:            middleware.ShareThrottleKeys,
+		FailureStatuses: []int{http.StatusBadRequest, http.StatusNotFound},
+	}))
+
 	userGroup.GET("/:uuid/config", userController.GetUserConfig)
 	userGroup.GET("/:uuid/qrcode", userController.GetUserQRCode)
 	userGroup.GET("/:uuid/details", userController.GetUserDetails)
@@ -0,0 +1,90 @@
+package http
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+
+	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/http/schema"
+	"github.com/maahdima/mwp/api/service"
+)
+
+type AuditController struct {
+	auditService *service.Audit
+	logger       *zap.Logger
+}
+
+func NewAuditController(auditService *service.Audit) *AuditController {
+	return &AuditController{
+		auditService: auditService,
+		logger:       zap.L().Named("AuditController"),
+	}
+}
+
+func (c *AuditController) GetAuditEvents(ctx echo.Context) error {
+	var query schema.AuditQuery
+	if err := ctx.Bind(&query); err != nil {
+		c.logger.Warn("failed to bind request", zap.Error(err))
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	if err := ctx.Validate(&query); err != nil {
+		c.logger.Warn("failed to validate request", zap.Error(err))
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	events, err := c.auditService.GetAuditEvents(&query)
+	if err != nil {
+		return c.auditErrorResponse(ctx, err)
+	}
+
+	return ctx.JSON(http.StatusOK, schema.BasicResponseData[schema.AuditEventsResponse]{
+		BasicResponse: schema.OkBasicResponse,
+		Data:          *events,
+	})
+}
+
+func (c *AuditController) ExportAuditEvents(ctx echo.Context) error {
+	var query schema.AuditQuery
+	if err := ctx.Bind(&query); err != nil {
+		c.logger.Warn("failed to bind request", zap.Error(err))
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	if err := ctx.Validate(&query); err != nil {
+		c.logger.Warn("failed to validate request", zap.Error(err))
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	var buf bytes.Buffer
+	if err := c.auditService.ExportAuditEvents(&query, &buf); err != nil {
+		return c.auditErrorResponse(ctx, err)
+	}
+
+	fileName := fmt.Sprintf("audit-%s.csv", time.Now().Format("20060102-150405"))
+	ctx.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", fileName))
+	return ctx.Blob(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+}
+
+func (c *AuditController) auditErrorResponse(ctx echo.Context, err error) error {
+	if errors.Is(err, common.ErrInvalidAuditQuery) {
+		return ctx.JSON(http.StatusBadRequest, schema.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Status:     "error",
+			Message:    err.Error(),
+		})
+	}
+
+	c.logger.Error("failed to get audit events", zap.Error(err))
+	return ctx.JSON(http.StatusInternalServerError, schema.ErrorResponse{
+		StatusCode: http.StatusInternalServerError,
+		Status:     "error",
+		Message:    "failed to get audit events: " + err.Error(),
+	})
+}
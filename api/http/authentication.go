@@ -1,13 +1,18 @@
 package http
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
+	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/http/middleware"
 	"github.com/maahdima/mwp/api/http/schema"
 	"github.com/maahdima/mwp/api/service"
 
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 type AuthController struct {
@@ -35,8 +40,20 @@ func (a *AuthController) Login(ctx echo.Context) error {
 		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
 	}
 
-	admin, err := a.authService.Login(req.Username, req.Password)
+	admin, err := a.authService.Login(req.Username, req.Password, service.SessionClient{
+		UserAgent: ctx.Request().UserAgent(),
+		IPAddress: ctx.RealIP(),
+	})
 	if err != nil {
+		if errors.Is(err, common.ErrInvalidCredentials) {
+			a.logger.Warn("failed to login", zap.Error(err))
+			return ctx.JSON(http.StatusUnauthorized, schema.ErrorResponse{
+				StatusCode: http.StatusUnauthorized,
+				Status:     "error",
+				Message:    "failed to login: " + err.Error(),
+			})
+		}
+
 		a.logger.Error("failed to login", zap.Error(err))
 		return ctx.JSON(http.StatusNotFound, schema.ErrorResponse{
 			StatusCode: http.StatusNotFound,
@@ -51,6 +68,64 @@ func (a *AuthController) Login(ctx echo.Context) error {
 	})
 }
 
+func (a *AuthController) Refresh(ctx echo.Context) error {
+	var req schema.RefreshTokenRequest
+	if err := ctx.Bind(&req); err != nil {
+		a.logger.Warn("failed to bind request", zap.Error(err))
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	if err := ctx.Validate(&req); err != nil {
+		a.logger.Warn("failed to validate request", zap.Error(err))
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	tokens, err := a.authService.Refresh(req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, common.ErrInvalidToken) {
+			a.logger.Warn("refresh token rejected", zap.Error(err))
+			return ctx.JSON(http.StatusUnauthorized, schema.ErrorResponse{
+				StatusCode: http.StatusUnauthorized,
+				Status:     "error",
+				Message:    err.Error(),
+			})
+		}
+
+		a.logger.Error("failed to refresh token", zap.Error(err))
+		return ctx.JSON(http.StatusInternalServerError, schema.ErrorResponse{
+			StatusCode: http.StatusInternalServerError,
+			Status:     "error",
+			Message:    "failed to refresh token: " + err.Error(),
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, schema.BasicResponseData[schema.LoginResponse]{
+		BasicResponse: schema.OkBasicResponse,
+		Data:          *tokens,
+	})
+}
+
+func (a *AuthController) Logout(ctx echo.Context) error {
+	claims := middleware.GetClaims(ctx)
+	if claims == nil {
+		return ctx.JSON(http.StatusUnauthorized, schema.ErrorResponse{
+			StatusCode: http.StatusUnauthorized,
+			Status:     "error",
+			Message:    "Unauthorized",
+		})
+	}
+
+	if err := a.authService.Logout(claims.SessionID); err != nil {
+		return ctx.JSON(http.StatusInternalServerError, schema.ErrorResponse{
+			StatusCode: http.StatusInternalServerError,
+			Status:     "error",
+			Message:    "failed to logout: " + err.Error(),
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, schema.OkBasicResponse)
+}
+
 func (a *AuthController) UpdateProfile(ctx echo.Context) error {
 	var req schema.UpdateProfileRequest
 	if err := ctx.Bind(&req); err != nil {
@@ -63,6 +138,11 @@ func (a *AuthController) UpdateProfile(ctx echo.Context) error {
 		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
 	}
 
+	// admins can only change their own credentials here, the others are managed under /api/admin
+	if claims := middleware.GetClaims(ctx); claims == nil || claims.Subject != req.OldUsername {
+		return ctx.JSON(http.StatusForbidden, schema.ForbiddenErrorResponse)
+	}
+
 	err := a.authService.UpdateProfile(req.OldUsername, req.OldPassword, req.NewUsername, req.NewPassword)
 	if err != nil {
 		a.logger.Error("failed to update profile", zap.Error(err))
@@ -75,3 +155,210 @@ func (a *AuthController) UpdateProfile(ctx echo.Context) error {
 
 	return ctx.JSON(http.StatusOK, schema.OkBasicResponse)
 }
+
+func (a *AuthController) GetAPITokens(ctx echo.Context) error {
+	tokens, err := a.authService.GetAPITokens(middleware.GetClaims(ctx).Subject)
+	if err != nil {
+		a.logger.Error("failed to get api tokens", zap.Error(err))
+		return ctx.JSON(http.StatusInternalServerError, schema.ErrorResponse{
+			StatusCode: http.StatusInternalServerError,
+			Status:     "error",
+			Message:    "failed to get api tokens: " + err.Error(),
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, schema.BasicResponseData[[]schema.APITokenResponse]{
+		BasicResponse: schema.OkBasicResponse,
+		Data:          *tokens,
+	})
+}
+
+func (a *AuthController) CreateAPIToken(ctx echo.Context) error {
+	var req schema.CreateAPITokenRequest
+	if err := ctx.Bind(&req); err != nil {
+		a.logger.Warn("failed to bind request", zap.Error(err))
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	if err := ctx.Validate(&req); err != nil {
+		a.logger.Warn("failed to validate request", zap.Error(err))
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	token, err := a.authService.CreateAPIToken(middleware.GetClaims(ctx).Subject, &req)
+	if err != nil {
+		a.logger.Error("failed to create api token", zap.Error(err))
+		return ctx.JSON(http.StatusInternalServerError, schema.ErrorResponse{
+			StatusCode: http.StatusInternalServerError,
+			Status:     "error",
+			Message:    "failed to create api token: " + err.Error(),
+		})
+	}
+
+	return ctx.JSON(http.StatusCreated, schema.BasicResponseData[schema.APITokenResponse]{
+		BasicResponse: schema.OkBasicResponse,
+		Data:          *token,
+	})
+}
+
+func (a *AuthController) RevokeAPIToken(ctx echo.Context) error {
+	tokenId, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		a.logger.Error("Invalid api token ID", zap.Error(err))
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	if err := a.authService.RevokeAPIToken(middleware.GetClaims(ctx).Subject, uint(tokenId)); err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return ctx.JSON(http.StatusNotFound, schema.ErrorResponse{
+				StatusCode: http.StatusNotFound,
+				Status:     "error",
+				Message:    "api token not found",
+			})
+		case errors.Is(err, common.ErrInvalidAPIToken):
+			return ctx.JSON(http.StatusConflict, schema.ErrorResponse{
+				StatusCode: http.StatusConflict,
+				Status:     "error",
+				Message:    err.Error(),
+			})
+		}
+
+		a.logger.Error("failed to revoke api token", zap.Error(err))
+		return ctx.JSON(http.StatusInternalServerError, schema.ErrorResponse{
+			StatusCode: http.StatusInternalServerError,
+			Status:     "error",
+			Message:    "failed to revoke api token: " + err.Error(),
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, schema.OkBasicResponse)
+}
+
+func (a *AuthController) LoginTwoFactor(ctx echo.Context) error {
+	var req schema.TwoFactorLoginRequest
+	if err := ctx.Bind(&req); err != nil {
+		a.logger.Warn("failed to bind request", zap.Error(err))
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	if err := ctx.Validate(&req); err != nil {
+		a.logger.Warn("failed to validate request", zap.Error(err))
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	tokens, err := a.authService.LoginTwoFactor(req.ChallengeToken, req.Code, service.SessionClient{
+		UserAgent: ctx.Request().UserAgent(),
+		IPAddress: ctx.RealIP(),
+	})
+	if err != nil {
+		return a.twoFactorErrorResponse(ctx, err, "failed to login")
+	}
+
+	return ctx.JSON(http.StatusOK, schema.BasicResponseData[schema.LoginResponse]{
+		BasicResponse: schema.OkBasicResponse,
+		Data:          *tokens,
+	})
+}
+
+func (a *AuthController) SetupTwoFactor(ctx echo.Context) error {
+	setup, err := a.authService.SetupTwoFactor(middleware.GetClaims(ctx).Subject)
+	if err != nil {
+		return a.twoFactorErrorResponse(ctx, err, "failed to set up two-factor authentication")
+	}
+
+	return ctx.JSON(http.StatusOK, schema.BasicResponseData[schema.TwoFactorSetupResponse]{
+		BasicResponse: schema.OkBasicResponse,
+		Data:          *setup,
+	})
+}
+
+func (a *AuthController) EnableTwoFactor(ctx echo.Context) error {
+	var req schema.TwoFactorCodeRequest
+	if err := ctx.Bind(&req); err != nil {
+		a.logger.Warn("failed to bind request", zap.Error(err))
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	if err := ctx.Validate(&req); err != nil {
+		a.logger.Warn("failed to validate request", zap.Error(err))
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	codes, err := a.authService.EnableTwoFactor(middleware.GetClaims(ctx).Subject, req.Code)
+	if err != nil {
+		return a.twoFactorErrorResponse(ctx, err, "failed to enable two-factor authentication")
+	}
+
+	return ctx.JSON(http.StatusOK, schema.BasicResponseData[schema.RecoveryCodesResponse]{
+		BasicResponse: schema.OkBasicResponse,
+		Data:          *codes,
+	})
+}
+
+func (a *AuthController) DisableTwoFactor(ctx echo.Context) error {
+	var req schema.DisableTwoFactorRequest
+	if err := ctx.Bind(&req); err != nil {
+		a.logger.Warn("failed to bind request", zap.Error(err))
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	if err := ctx.Validate(&req); err != nil {
+		a.logger.Warn("failed to validate request", zap.Error(err))
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	if err := a.authService.DisableTwoFactor(middleware.GetClaims(ctx).Subject, req.Password, req.Code); err != nil {
+		return a.twoFactorErrorResponse(ctx, err, "failed to disable two-factor authentication")
+	}
+
+	return ctx.JSON(http.StatusOK, schema.OkBasicResponse)
+}
+
+func (a *AuthController) RegenerateRecoveryCodes(ctx echo.Context) error {
+	var req schema.TwoFactorCodeRequest
+	if err := ctx.Bind(&req); err != nil {
+		a.logger.Warn("failed to bind request", zap.Error(err))
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	if err := ctx.Validate(&req); err != nil {
+		a.logger.Warn("failed to validate request", zap.Error(err))
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	codes, err := a.authService.RegenerateRecoveryCodes(middleware.GetClaims(ctx).Subject, req.Code)
+	if err != nil {
+		return a.twoFactorErrorResponse(ctx, err, "failed to regenerate recovery codes")
+	}
+
+	return ctx.JSON(http.StatusOK, schema.BasicResponseData[schema.RecoveryCodesResponse]{
+		BasicResponse: schema.OkBasicResponse,
+		Data:          *codes,
+	})
+}
+
+func (a *AuthController) twoFactorErrorResponse(ctx echo.Context, err error, message string) error {
+	switch {
+	case errors.Is(err, common.ErrInvalidToken), errors.Is(err, common.ErrInvalidOTP), errors.Is(err, common.ErrInvalidCredentials):
+		a.logger.Warn(message, zap.Error(err))
+		return ctx.JSON(http.StatusUnauthorized, schema.ErrorResponse{
+			StatusCode: http.StatusUnauthorized,
+			Status:     "error",
+			Message:    err.Error(),
+		})
+	case errors.Is(err, common.ErrTwoFactorState):
+		return ctx.JSON(http.StatusConflict, schema.ErrorResponse{
+			StatusCode: http.StatusConflict,
+			Status:     "error",
+			Message:    err.Error(),
+		})
+	}
+
+	a.logger.Error(message, zap.Error(err))
+	return ctx.JSON(http.StatusInternalServerError, schema.ErrorResponse{
+		StatusCode: http.StatusInternalServerError,
+		Status:     "error",
+		Message:    message + ": " + err.Error(),
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/maahdima/mwp/api/cmd/jobs"
+	"github.com/maahdima/mwp/api/http/middleware"
 	"github.com/maahdima/mwp/api/http/schema"
 	"github.com/maahdima/mwp/api/service"
 
@@ -53,7 +54,7 @@ func (c *DeviceDataController) GetDailyTrafficUsage(ctx echo.Context) error {
 }
 
 func (c *DeviceDataController) GetDeviceInfo(ctx echo.Context) error {
-	info, err := c.deviceDataService.GetDeviceData()
+	info, err := c.deviceDataService.GetDeviceData(middleware.GetServer(ctx))
 	if err != nil {
 		c.logger.Error("failed to fetch device info", zap.Error(err))
 		return ctx.JSON(http.StatusInternalServerError, schema.ErrorResponse{
@@ -9,6 +9,7 @@ import (
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 
+	"github.com/maahdima/mwp/api/common"
 	"github.com/maahdima/mwp/api/http/schema"
 	"github.com/maahdima/mwp/api/service"
 )
@@ -57,6 +58,14 @@ func (c *IPPoolController) CreateIPPool(ctx echo.Context) error {
 
 	ipPool, err := c.ipPoolService.CreateIPPool(&req)
 	if err != nil {
+		if errors.Is(err, common.ErrInvalidIPPool) {
+			return ctx.JSON(http.StatusBadRequest, schema.ErrorResponse{
+				StatusCode: http.StatusBadRequest,
+				Status:     "error",
+				Message:    err.Error(),
+			})
+		}
+
 		c.logger.Error("failed to create IP pool", zap.Error(err))
 		return ctx.JSON(http.StatusInternalServerError, schema.ErrorResponse{
 			StatusCode: http.StatusInternalServerError,
@@ -105,6 +114,13 @@ func (c *IPPoolController) UpdateIPPool(ctx echo.Context) error {
 				Message:    "IP Pool not found",
 			})
 		}
+		if errors.Is(err, common.ErrInvalidIPPool) {
+			return ctx.JSON(http.StatusBadRequest, schema.ErrorResponse{
+				StatusCode: http.StatusBadRequest,
+				Status:     "error",
+				Message:    err.Error(),
+			})
+		}
 
 		c.logger.Error("failed to update IP pool", zap.Error(err))
 		return ctx.JSON(http.StatusInternalServerError, schema.ErrorResponse{
@@ -156,3 +172,119 @@ func (c *IPPoolController) DeleteIPPool(ctx echo.Context) error {
 		Status:     "success",
 	})
 }
+
+func (c *IPPoolController) GetIPReservations(ctx echo.Context) error {
+	poolId, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		c.logger.Error("Invalid IP Pool ID", zap.Error(err))
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	reservations, err := c.ipPoolService.GetIPReservations(uint(poolId))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ctx.JSON(http.StatusNotFound, schema.ErrorResponse{
+				StatusCode: http.StatusNotFound,
+				Status:     "error",
+				Message:    "IP Pool not found",
+			})
+		}
+
+		c.logger.Error("failed to get IP reservations", zap.Error(err))
+		return ctx.JSON(http.StatusInternalServerError, schema.ErrorResponse{
+			StatusCode: http.StatusInternalServerError,
+			Status:     "error",
+			Message:    "failed to get IP reservations: " + err.Error(),
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, schema.BasicResponseData[[]schema.IPReservationResponse]{
+		BasicResponse: schema.OkBasicResponse,
+		Data:          *reservations,
+	})
+}
+
+func (c *IPPoolController) CreateIPReservation(ctx echo.Context) error {
+	poolId, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		c.logger.Error("Invalid IP Pool ID", zap.Error(err))
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	var req schema.CreateIPReservationRequest
+	if err := ctx.Bind(&req); err != nil {
+		c.logger.Warn("failed to bind request", zap.Error(err))
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	if err := ctx.Validate(&req); err != nil {
+		c.logger.Warn("failed to validate request", zap.Error(err))
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	reservation, err := c.ipPoolService.CreateIPReservation(uint(poolId), &req)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ctx.JSON(http.StatusNotFound, schema.ErrorResponse{
+				StatusCode: http.StatusNotFound,
+				Status:     "error",
+				Message:    "IP Pool not found",
+			})
+		}
+		if errors.Is(err, common.ErrInvalidIPReservation) {
+			return ctx.JSON(http.StatusBadRequest, schema.ErrorResponse{
+				StatusCode: http.StatusBadRequest,
+				Status:     "error",
+				Message:    err.Error(),
+			})
+		}
+
+		c.logger.Error("failed to create IP reservation", zap.Error(err))
+		return ctx.JSON(http.StatusInternalServerError, schema.ErrorResponse{
+			StatusCode: http.StatusInternalServerError,
+			Status:     "error",
+			Message:    "failed to create IP reservation: " + err.Error(),
+		})
+	}
+
+	return ctx.JSON(http.StatusCreated, schema.BasicResponseData[schema.IPReservationResponse]{
+		BasicResponse: schema.OkBasicResponse,
+		Data:          *reservation,
+	})
+}
+
+func (c *IPPoolController) DeleteIPReservation(ctx echo.Context) error {
+	poolId, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		c.logger.Error("Invalid IP Pool ID", zap.Error(err))
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	reservationId, err := strconv.Atoi(ctx.Param("reservationId"))
+	if err != nil {
+		c.logger.Error("Invalid IP reservation ID", zap.Error(err))
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	if err := c.ipPoolService.DeleteIPReservation(uint(poolId), uint(reservationId)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ctx.JSON(http.StatusNotFound, schema.ErrorResponse{
+				StatusCode: http.StatusNotFound,
+				Status:     "error",
+				Message:    "IP reservation not found",
+			})
+		}
+
+		c.logger.Error("failed to delete IP reservation", zap.Error(err))
+		return ctx.JSON(http.StatusInternalServerError, schema.ErrorResponse{
+			StatusCode: http.StatusInternalServerError,
+			Status:     "error",
+			Message:    "failed to delete IP reservation: " + err.Error(),
+		})
+	}
+
+	return ctx.JSON(http.StatusNoContent, schema.BasicResponse{
+		StatusCode: http.StatusNoContent,
+		Status:     "success",
+	})
+}
@@ -0,0 +1,43 @@
+package http
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/maahdima/mwp/api/config"
+	"github.com/maahdima/mwp/api/metrics"
+	"github.com/maahdima/mwp/api/service"
+)
+
+// SetupMwpMetrics serves the Prometheus metrics on /metrics, behind a bearer token when one is configured
+func SetupMwpMetrics(app *echo.Echo, cfg config.MetricsConfig, collector *service.MetricsCollector) {
+	if !cfg.Enabled {
+		return
+	}
+
+	metrics.Registry.MustRegister(collector)
+
+	handler := echo.WrapHandler(promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{}))
+	app.GET("/metrics", handler, metricsTokenMiddleware(cfg.Token))
+}
+
+func metricsTokenMiddleware(token string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if token == "" {
+				return next(c)
+			}
+
+			given, found := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+			if !found || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+				return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized")
+			}
+
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestMetricsTokenMiddleware(t *testing.T) {
+	handler := metricsTokenMiddleware("secret")(func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	for header, allowed := range map[string]bool{
+		"":              false,
+		"secret":        false,
+		"Bearer wrong":  false,
+		"Bearer secret": true,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if header != "" {
+			req.Header.Set(echo.HeaderAuthorization, header)
+		}
+		rec := httptest.NewRecorder()
+
+		err := handler(echo.New().NewContext(req, rec))
+		var httpErr *echo.HTTPError
+		switch {
+		case allowed && (err != nil || rec.Code != http.StatusOK):
+			t.Fatalf("authorization %q was refused: %v", header, err)
+		case !allowed && (!errors.As(err, &httpErr) || httpErr.Code != http.StatusUnauthorized):
+			t.Fatalf("authorization %q got %v, want 401", header, err)
+		}
+	}
+}
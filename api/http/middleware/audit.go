@@ -0,0 +1,163 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/maahdima/mwp/api/dataservice/model"
+)
+
+// maxAuditResponseBody bounds how much of a response is kept to describe a target without :id
+const maxAuditResponseBody = 64 << 10
+
+// AuditRecorder stores the events produced by Audit
+type AuditRecorder interface {
+	Snapshot(targetType, targetID string) any
+	Record(event model.AuditEvent, before, after any)
+}
+
+// actions caches the audit action of each route, named after its handler
+var actions sync.Map
+
+// Audit records every request but reads with its actor, outcome and source IP. Routes with an :id snapshot the
+// target before and after the handler, the others keep the data of the response as the after state.
+func Audit(recorder AuditRecorder, targetType string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if isReadRequest(c) {
+				return next(c)
+			}
+
+			actor := auditActor(c)
+			targetID := c.Param("id")
+			var before any
+			if targetID != "" {
+				before = recorder.Snapshot(targetType, targetID)
+			}
+
+			writer := &auditResponseWriter{ResponseWriter: c.Response().Writer}
+			c.Response().Writer = writer
+			if err := next(c); err != nil {
+				c.Error(err)
+			}
+			c.Response().Writer = writer.ResponseWriter
+
+			var after any
+			if targetID != "" {
+				after = recorder.Snapshot(targetType, targetID)
+			} else if data := writer.data(); data != nil {
+				after = data
+				if object, ok := data.(map[string]interface{}); ok {
+					if id, ok := object["id"].(float64); ok {
+						targetID = strconv.FormatFloat(id, 'f', -1, 64)
+					}
+				}
+			}
+
+			recorder.Record(model.AuditEvent{
+				Actor:      actor,
+				Action:     auditAction(c),
+				TargetType: targetType,
+				TargetID:   targetID,
+				SourceIP:   c.RealIP(),
+				StatusCode: c.Response().Status,
+			}, before, after)
+
+			return nil
+		}
+	}
+}
+
+// auditActor names the admin behind a request, the username being tried for logins
+func auditActor(c echo.Context) string {
+	if claims := GetClaims(c); claims != nil {
+		return claims.Subject
+	}
+	if username := loginUsername(c); username != "" {
+		return username
+	}
+	return "anonymous"
+}
+
+// auditAction turns the handler of the route into a snake_case action, UpdatePeerStatus becomes update_peer_status
+func auditAction(c echo.Context) string {
+	key := c.Request().Method + " " + c.Path()
+	if action, ok := actions.Load(key); ok {
+		return action.(string)
+	}
+
+	action := key
+	for _, route := range c.Echo().Routes() {
+		if route.Method != c.Request().Method || route.Path != c.Path() {
+			continue
+		}
+		name := strings.TrimSuffix(route.Name, "-fm")
+		name = name[strings.LastIndex(name, ".")+1:]
+
+		var snake strings.Builder
+		for i, r := range name {
+			if unicode.IsUpper(r) && i > 0 {
+				snake.WriteByte('_')
+			}
+			snake.WriteRune(unicode.ToLower(r))
+		}
+		action = snake.String()
+		break
+	}
+
+	actions.Store(key, action)
+	return action
+}
+
+// auditResponseWriter keeps the start of JSON responses while passing them through
+type auditResponseWriter struct {
+	http.ResponseWriter
+	body      bytes.Buffer
+	truncated bool
+}
+
+func (w *auditResponseWriter) Write(b []byte) (int, error) {
+	if !w.truncated && strings.HasPrefix(w.Header().Get(echo.HeaderContentType), echo.MIMEApplicationJSON) {
+		if w.body.Len()+len(b) > maxAuditResponseBody {
+			w.truncated = true
+		} else {
+			w.body.Write(b)
+		}
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *auditResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// data returns the data field of a JSON response, or its message for errors
+func (w *auditResponseWriter) data() any {
+	if w.truncated || w.body.Len() == 0 {
+		return nil
+	}
+
+	var resp struct {
+		Data    any    `json:"data"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(w.body.Bytes(), &resp); err != nil {
+		return nil
+	}
+	if resp.Data != nil {
+		return resp.Data
+	}
+	if resp.Message != "" {
+		return map[string]interface{}{"message": resp.Message}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+
+	"github.com/maahdima/mwp/api/dataservice/model"
+	"github.com/maahdima/mwp/api/http/schema"
+)
+
+type stubAuditRecorder struct {
+	names  map[string]string
+	events []model.AuditEvent
+	before []any
+	after  []any
+}
+
+func (s *stubAuditRecorder) Snapshot(_, targetID string) any {
+	return s.names[targetID]
+}
+
+func (s *stubAuditRecorder) Record(event model.AuditEvent, before, after any) {
+	s.events = append(s.events, event)
+	s.before = append(s.before, before)
+	s.after = append(s.after, after)
+}
+
+type peerHandlers struct {
+	recorder *stubAuditRecorder
+}
+
+func (h peerHandlers) UpdatePeerStatus(c echo.Context) error {
+	h.recorder.names[c.Param("id")] = "disabled"
+	return c.NoContent(http.StatusOK)
+}
+
+func (h peerHandlers) GetPeer(c echo.Context) error {
+	return c.NoContent(http.StatusOK)
+}
+
+func (h peerHandlers) CreatePeer(c echo.Context) error {
+	return c.JSON(http.StatusCreated, map[string]any{"data": map[string]any{"id": 7}})
+}
+
+func TestAuditRecordsWrites(t *testing.T) {
+	recorder := &stubAuditRecorder{names: map[string]string{"3": "enabled"}}
+	handlers := peerHandlers{recorder: recorder}
+
+	app := echo.New()
+	app.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set(TokenContextKey, &jwt.Token{Claims: &schema.TokenClaims{RegisteredClaims: jwt.RegisteredClaims{Subject: "alice"}}})
+			return next(c)
+		}
+	})
+	group := app.Group("/api/peer", Audit(recorder, "peer"))
+	group.GET("/:id", handlers.GetPeer)
+	group.PATCH("/:id/status", handlers.UpdatePeerStatus)
+	group.POST("", handlers.CreatePeer)
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/api/peer/3", nil),
+		httptest.NewRequest(http.MethodPatch, "/api/peer/3/status", nil),
+		httptest.NewRequest(http.MethodPost, "/api/peer", nil),
+	} {
+		app.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if len(recorder.events) != 2 {
+		t.Fatalf("recorded %d events, want the two writes", len(recorder.events))
+	}
+
+	update := recorder.events[0]
+	if update.Actor != "alice" || update.Action != "update_peer_status" || update.TargetID != "3" {
+		t.Fatalf("update event %+v", update)
+	}
+	if recorder.before[0] != "enabled" || recorder.after[0] != "disabled" {
+		t.Fatalf("update snapshots %v -> %v, want enabled -> disabled", recorder.before[0], recorder.after[0])
+	}
+
+	create := recorder.events[1]
+	if create.Action != "create_peer" || create.TargetID != "7" || create.StatusCode != http.StatusCreated {
+		t.Fatalf("create event %+v, want the id of the response", create)
+	}
+}
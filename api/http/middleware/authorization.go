@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	"github.com/maahdima/mwp/api/dataservice/model"
+	"github.com/maahdima/mwp/api/http/schema"
+)
+
+// TokenContextKey holds the *jwt.Token set by the JWT middleware
+const TokenContextKey = "user"
+
+// Role sets guarding the route groups
+var (
+	AllRoles      = []string{model.AdminRoleOwner, model.AdminRoleOperator, model.AdminRoleViewer, model.AdminRoleReseller}
+	StaffRoles    = []string{model.AdminRoleOwner, model.AdminRoleOperator, model.AdminRoleViewer}
+	PeerManagers  = []string{model.AdminRoleOwner, model.AdminRoleOperator, model.AdminRoleReseller}
+	OperatorRoles = []string{model.AdminRoleOwner, model.AdminRoleOperator}
+	OwnerRoles    = []string{model.AdminRoleOwner}
+)
+
+// GetClaims returns the claims of the access token validated by the JWT middleware
+func GetClaims(c echo.Context) *schema.TokenClaims {
+	token, ok := c.Get(TokenContextKey).(*jwt.Token)
+	if !ok {
+		return nil
+	}
+	claims, _ := token.Claims.(*schema.TokenClaims)
+	return claims
+}
+
+// GetAccessScope returns the scope of a reseller, nil for the other roles as they are not restricted
+func GetAccessScope(c echo.Context) *schema.AccessScope {
+	claims := GetClaims(c)
+	if claims == nil || claims.Role != model.AdminRoleReseller {
+		return nil
+	}
+	return &claims.Scope
+}
+
+// Authorize lets GET and HEAD requests through for readRoles and every other method for writeRoles. API tokens
+// additionally need the write scope, or one of writeScopes, for anything but reads.
+func Authorize(readRoles, writeRoles []string, writeScopes ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			read := isReadRequest(c)
+			roles := writeRoles
+			if read {
+				roles = readRoles
+			}
+
+			claims := GetClaims(c)
+			if claims == nil || !slices.Contains(roles, claims.Role) {
+				return c.JSON(http.StatusForbidden, schema.ForbiddenErrorResponse)
+			}
+
+			if claims.IsAPIToken() && !read && !slices.ContainsFunc(claims.TokenScopes, func(scope string) bool {
+				return scope == model.APITokenScopeWrite || slices.Contains(writeScopes, scope)
+			}) {
+				return c.JSON(http.StatusForbidden, schema.ForbiddenErrorResponse)
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// RequireRoles narrows a single route down to the given roles, token scopes are left to the group's Authorize
+func RequireRoles(roles ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			claims := GetClaims(c)
+			if claims == nil || !slices.Contains(roles, claims.Role) {
+				return c.JSON(http.StatusForbidden, schema.ForbiddenErrorResponse)
+			}
+			return next(c)
+		}
+	}
+}
+
+// RequireSession rejects API tokens from routes that manage the login itself
+func RequireSession() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if claims := GetClaims(c); claims == nil || claims.IsAPIToken() {
+				return c.JSON(http.StatusForbidden, schema.ForbiddenErrorResponse)
+			}
+			return next(c)
+		}
+	}
+}
+
+func isReadRequest(c echo.Context) bool {
+	method := c.Request().Method
+	return method == http.MethodGet || method == http.MethodHead
+}
+
+// ServerScopeMiddleware rejects resellers from servers outside their scope, it runs after ClientConnectionMiddleware
+func ServerScopeMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if scope := GetAccessScope(c); scope != nil && !scope.AllowsServer(GetServer(c).ID) {
+				return c.JSON(http.StatusForbidden, schema.ForbiddenErrorResponse)
+			}
+			return next(c)
+		}
+	}
+}
+
+// PeerScopeMiddleware rejects resellers from routes whose :id names a peer outside their scope. Unknown peers are
+// let through so the handler reports them as not found.
+func PeerScopeMiddleware(inScope func(peerID uint, scope schema.AccessScope) (bool, error)) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			scope := GetAccessScope(c)
+			if scope == nil {
+				return next(c)
+			}
+
+			peerID, err := strconv.Atoi(c.Param("id"))
+			if err != nil {
+				return next(c)
+			}
+
+			allowed, err := inScope(uint(peerID), *scope)
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return c.JSON(http.StatusInternalServerError, schema.InternalServerErrorResponse)
+			}
+			if err == nil && !allowed {
+				return c.JSON(http.StatusForbidden, schema.ForbiddenErrorResponse)
+			}
+
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+
+	"github.com/maahdima/mwp/api/dataservice/model"
+	"github.com/maahdima/mwp/api/http/schema"
+)
+
+func TestAuthorize(t *testing.T) {
+	handler := Authorize(StaffRoles, OperatorRoles)(func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	cases := []struct {
+		role   string
+		method string
+		want   int
+	}{
+		{model.AdminRoleViewer, http.MethodGet, http.StatusOK},
+		{model.AdminRoleViewer, http.MethodPost, http.StatusForbidden},
+		{model.AdminRoleOperator, http.MethodPost, http.StatusOK},
+		{model.AdminRoleReseller, http.MethodGet, http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(httptest.NewRequest(tc.method, "/", nil), rec)
+		c.Set(TokenContextKey, &jwt.Token{Claims: &schema.TokenClaims{Role: tc.role}})
+
+		if err := handler(c); err != nil {
+			t.Fatal(err)
+		}
+		if rec.Code != tc.want {
+			t.Fatalf("%s %s: status %d, want %d", tc.role, tc.method, rec.Code, tc.want)
+		}
+	}
+}
+
+func TestAuthorizeNeedsWriteScopeForAPITokens(t *testing.T) {
+	handler := Authorize(StaffRoles, PeerManagers, model.APITokenScopePeersWrite)(func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	cases := []struct {
+		scopes []string
+		method string
+		want   int
+	}{
+		{[]string{model.APITokenScopeRead}, http.MethodGet, http.StatusOK},
+		{[]string{model.APITokenScopeRead}, http.MethodPost, http.StatusForbidden},
+		{[]string{model.APITokenScopePeersWrite}, http.MethodPost, http.StatusOK},
+		{[]string{model.APITokenScopeWrite}, http.MethodPost, http.StatusOK},
+	}
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(httptest.NewRequest(tc.method, "/", nil), rec)
+		c.Set(TokenContextKey, &jwt.Token{Claims: &schema.TokenClaims{Role: model.AdminRoleOwner, TokenScopes: tc.scopes}})
+
+		if err := handler(c); err != nil {
+			t.Fatal(err)
+		}
+		if rec.Code != tc.want {
+			t.Fatalf("token scopes %v %s: status %d, want %d", tc.scopes, tc.method, rec.Code, tc.want)
+		}
+	}
+}
@@ -1,33 +1,123 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/labstack/echo/v4"
 
 	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/dataservice/model"
 	"github.com/maahdima/mwp/api/http/schema"
+	"github.com/maahdima/mwp/api/utils/httphelper"
 )
 
+const (
+	// ServerContextKey holds the model.Server the current request operates on
+	ServerContextKey = "mwp_server"
+	// ClientContextKey holds the REST client of the resolved server
+	ClientContextKey = "mwp_client"
+
+	ServerHeader     = "X-Server-Name"
+	ServerQueryParam = "server"
+	ServerPathParam  = "server"
+)
+
+// ClientConnectionMiddleware resolves the target server from the X-Server-Name header, the ?server= query param
+// or a :server path param (name or ID). When none is given, the only active server is used.
 func ClientConnectionMiddleware(mwpClients *common.MwpClients) echo.MiddlewareFunc {
+	return clientConnection(mwpClients, false)
+}
+
+// PeerClientConnectionMiddleware is ClientConnectionMiddleware for routes taking a peer :id, when no server is given
+// the server of that peer is used
+func PeerClientConnectionMiddleware(mwpClients *common.MwpClients) echo.MiddlewareFunc {
+	return clientConnection(mwpClients, true)
+}
+
+func clientConnection(mwpClients *common.MwpClients, byPeer bool) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			// TODO : multiple server support
-			//serverName := c.Request().Header.Get("X-Server-Name")
-			//if serverName == "" {
-			//	serverName = c.QueryParam("server")
-			//}
-			//serverName = strings.TrimSpace(serverName)
-
-			if !mwpClients.IsConnected(nil) {
+			serverRef := c.Request().Header.Get(ServerHeader)
+			if serverRef == "" {
+				serverRef = c.QueryParam(ServerQueryParam)
+			}
+			if serverRef == "" {
+				serverRef = c.Param(ServerPathParam)
+			}
+
+			server, err := resolveServer(c, mwpClients, serverRef, byPeer)
+			if err != nil {
+				return c.JSON(serverErrorStatus(err), schema.ErrorResponse{
+					StatusCode: serverErrorStatus(err),
+					Status:     "error",
+					Message:    serverErrorMessage(err),
+				})
+			}
+
+			client, err := mwpClients.GetClient(*server)
+			if err != nil || !mwpClients.IsConnected(*server) {
 				return c.JSON(http.StatusServiceUnavailable, schema.ErrorResponse{
 					StatusCode: http.StatusServiceUnavailable,
 					Status:     "error",
-					Message:    "Client is not connected to the server",
+					Message:    "Client is not connected to the server " + server.Name,
 				})
 			}
 
+			c.Set(ServerContextKey, *server)
+			c.Set(ClientContextKey, client)
+
 			return next(c)
 		}
 	}
 }
+
+// resolveServer resolves the given server, or the server of the peer in :id when byPeer is set and none is given. An
+// unknown peer falls back to the only active server and is left to the handler to report.
+func resolveServer(c echo.Context, mwpClients *common.MwpClients, serverRef string, byPeer bool) (*model.Server, error) {
+	if byPeer && serverRef == "" {
+		if peerID, err := strconv.ParseUint(c.Param("id"), 10, 64); err == nil {
+			server, err := mwpClients.ResolvePeerServer(uint(peerID))
+			if !errors.Is(err, common.ErrServerNotFound) {
+				return server, err
+			}
+		}
+	}
+
+	return mwpClients.ResolveServer(serverRef)
+}
+
+// GetServer returns the server resolved by ClientConnectionMiddleware
+func GetServer(c echo.Context) model.Server {
+	server, _ := c.Get(ServerContextKey).(model.Server)
+	return server
+}
+
+// GetClient returns the REST client of the server resolved by ClientConnectionMiddleware
+func GetClient(c echo.Context) *httphelper.Client {
+	client, _ := c.Get(ClientContextKey).(*httphelper.Client)
+	return client
+}
+
+func serverErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, common.ErrServerNotSpecified), errors.Is(err, common.ErrServerInactive):
+		return http.StatusBadRequest
+	case errors.Is(err, common.ErrServerNotFound), errors.Is(err, common.ErrNoActiveServer):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func serverErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, common.ErrServerNotSpecified):
+		return "server is not specified, set the " + ServerHeader + " header or the ?" + ServerQueryParam + "= query param"
+	case errors.Is(err, common.ErrServerInactive), errors.Is(err, common.ErrServerNotFound), errors.Is(err, common.ErrNoActiveServer):
+		return err.Error()
+	default:
+		return "failed to resolve server"
+	}
+}
@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+
+	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/config"
+	"github.com/maahdima/mwp/api/dataservice"
+	"github.com/maahdima/mwp/api/dataservice/model"
+)
+
+// newRouterDB returns a database with two active servers answering on the same stub router, and the number of
+// connection checks the router received
+func newRouterDB(t *testing.T) (*gorm.DB, []model.Server, *atomic.Int32) {
+	t.Helper()
+
+	var checks atomic.Int32
+	router := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/rest"+common.DeviceIdentityPath {
+			checks.Add(1)
+		}
+		w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(router.Close)
+	u, _ := url.Parse(router.URL)
+	host, port, _ := net.SplitHostPort(u.Host)
+	apiPort, _ := strconv.Atoi(port)
+
+	t.Setenv("DATA_DIR", t.TempDir())
+	t.Setenv("DB_DIALECT", "sqlite")
+	if _, err := dataservice.LoadKeyring(config.GetEncryptionConfig()); err != nil {
+		t.Fatal(err)
+	}
+	db, err := dataservice.ConnectDB(config.GetDBConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dataservice.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	servers := []model.Server{
+		{Name: "r1", IPAddress: host, APIPort: apiPort, IsActive: true},
+		{Name: "r2", IPAddress: "localhost", APIPort: apiPort, IsActive: true},
+	}
+	if err := db.Create(&servers).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return db, servers, &checks
+}
+
+// serve runs a request through the middleware on the route path and returns the status and the resolved server
+func serve(mw echo.MiddlewareFunc, path, target string, header http.Header) (int, model.Server) {
+	e := echo.New()
+	var server model.Server
+	e.GET(path, func(c echo.Context) error {
+		server = GetServer(c)
+		return c.NoContent(http.StatusOK)
+	}, mw)
+
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	return rec.Code, server
+}
+
+func TestClientConnectionRequiresServerWhenSeveralAreActive(t *testing.T) {
+	db, servers, _ := newRouterDB(t)
+	mw := ClientConnectionMiddleware(common.NewMwpClients(db))
+
+	if code, _ := serve(mw, "/peer", "/peer", nil); code != http.StatusBadRequest {
+		t.Fatalf("got %d without a server, want %d", code, http.StatusBadRequest)
+	}
+
+	code, server := serve(mw, "/peer", "/peer", http.Header{ServerHeader: {"r2"}})
+	if code != http.StatusOK || server.ID != servers[1].ID {
+		t.Fatalf("got %d and server %d, want %d and server %d", code, server.ID, http.StatusOK, servers[1].ID)
+	}
+	if code, _ := serve(mw, "/peer", "/peer?server=missing", nil); code != http.StatusNotFound {
+		t.Fatalf("got %d for an unknown server, want %d", code, http.StatusNotFound)
+	}
+}
+
+func TestPeerClientConnectionUsesServerOfPeer(t *testing.T) {
+	db, servers, _ := newRouterDB(t)
+	peer := model.Peer{ServerID: servers[1].ID, UUID: "p1", PeerID: "*1", Name: "p1", PrivateKey: "k", PublicKey: "k",
+		Interface: "wg0", AllowedAddress: "10.0.0.2/32", Endpoint: "e", EndpointPort: "51820"}
+	if err := db.Create(&peer).Error; err != nil {
+		t.Fatal(err)
+	}
+	mw := PeerClientConnectionMiddleware(common.NewMwpClients(db))
+
+	code, server := serve(mw, "/peer/:id", "/peer/"+strconv.Itoa(int(peer.ID)), nil)
+	if code != http.StatusOK || server.ID != servers[1].ID {
+		t.Fatalf("got %d and server %d, want %d and the server of the peer %d", code, server.ID, http.StatusOK, servers[1].ID)
+	}
+	if code, _ := serve(mw, "/peer/:id", "/peer/999", nil); code != http.StatusBadRequest {
+		t.Fatalf("got %d for an unknown peer, want %d", code, http.StatusBadRequest)
+	}
+}
+
+func TestClientConnectionCachesConnectionState(t *testing.T) {
+	db, _, checks := newRouterDB(t)
+	mw := ClientConnectionMiddleware(common.NewMwpClients(db))
+
+	for range 3 {
+		if code, _ := serve(mw, "/peer", "/peer?server=r1", nil); code != http.StatusOK {
+			t.Fatalf("got %d, want %d", code, http.StatusOK)
+		}
+	}
+	if got := checks.Load(); got != 1 {
+		t.Fatalf("router was checked %d times, want once", got)
+	}
+}
@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+
+	"github.com/maahdima/mwp/api/http/schema"
+)
+
+// Throttler is the brute-force protection consulted by Throttle
+type Throttler interface {
+	LockedFor(keys ...string) (time.Duration, error)
+	Fail(maxAttempts int, keys ...string) error
+	Reset(keys ...string) error
+}
+
+type ThrottleConfig struct {
+	Throttler   Throttler
+	MaxAttempts int
+	// Keys names the client of a request, every key is counted and locked out on its own
+	Keys func(c echo.Context) []string
+	// FailureStatuses are the response codes counted as failed attempts
+	FailureStatuses []int
+	// ResetOnSuccess forgets the failures of the keys once a request succeeds
+	ResetOnSuccess bool
+}
+
+// Throttle answers 429 while any key of the request is locked out, and records the outcome of the others
+func Throttle(cfg ThrottleConfig) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			keys := cfg.Keys(c)
+
+			lockedFor, err := cfg.Throttler.LockedFor(keys...)
+			if err != nil {
+				return c.JSON(http.StatusInternalServerError, schema.InternalServerErrorResponse)
+			}
+			if lockedFor > 0 {
+				retryAfter := int(math.Ceil(lockedFor.Seconds()))
+				c.Response().Header().Set("Retry-After", strconv.Itoa(retryAfter))
+				return c.JSON(http.StatusTooManyRequests, schema.ErrorResponse{
+					StatusCode: http.StatusTooManyRequests,
+					Status:     "error",
+					Message:    fmt.Sprintf("too many failed attempts, retry in %d seconds", retryAfter),
+				})
+			}
+
+			if err := next(c); err != nil {
+				c.Error(err)
+			}
+
+			status := c.Response().Status
+			switch {
+			case slices.Contains(cfg.FailureStatuses, status):
+				_ = cfg.Throttler.Fail(cfg.MaxAttempts, keys...)
+			case cfg.ResetOnSuccess && status < http.StatusBadRequest:
+				_ = cfg.Throttler.Reset(keys...)
+			}
+
+			return nil
+		}
+	}
+}
+
+// LoginThrottleKeys names a login attempt by client IP and by the username it is for
+func LoginThrottleKeys(c echo.Context) []string {
+	keys := []string{"login-ip:" + c.RealIP()}
+
+	if username := strings.ToLower(loginUsername(c)); username != "" {
+		keys = append(keys, "login-user:"+username)
+	}
+
+	return keys
+}
+
+// loginUsername reads the username of a login request from the body, or from the challenge token of the second
+// step, and puts the body back for the handler
+func loginUsername(c echo.Context) string {
+	body, err := io.ReadAll(io.LimitReader(c.Request().Body, 64<<10))
+	if err != nil {
+		return ""
+	}
+	c.Request().Body = io.NopCloser(bytes.NewReader(body))
+
+	var req struct {
+		Username       string `json:"username"`
+		ChallengeToken string `json:"challenge_token"`
+	}
+	_ = json.Unmarshal(body, &req)
+
+	username := req.Username
+	if req.ChallengeToken != "" {
+		// the signature is checked by the handler, the subject is only used to name the client
+		claims := jwt.MapClaims{}
+		if _, _, err := jwt.NewParser().ParseUnverified(req.ChallengeToken, claims); err == nil {
+			username, _ = claims["sub"].(string)
+		}
+	}
+
+	username = strings.TrimSpace(username)
+	if len(username) > 64 {
+		username = username[:64]
+	}
+	return username
+}
+
+// ShareThrottleKeys names a request to the public share links by client IP
+func ShareThrottleKeys(c echo.Context) []string {
+	return []string{"share-ip:" + c.RealIP()}
+}
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+// stubThrottler locks a key out once it failed maxAttempts times
+type stubThrottler struct {
+	failures map[string]int
+}
+
+func (s *stubThrottler) LockedFor(keys ...string) (time.Duration, error) {
+	for _, key := range keys {
+		if s.failures[key] >= 2 {
+			return 90 * time.Second, nil
+		}
+	}
+	return 0, nil
+}
+
+func (s *stubThrottler) Fail(_ int, keys ...string) error {
+	for _, key := range keys {
+		s.failures[key]++
+	}
+	return nil
+}
+
+func (s *stubThrottler) Reset(keys ...string) error {
+	for _, key := range keys {
+		delete(s.failures, key)
+	}
+	return nil
+}
+
+func TestThrottleLocksOutAfterFailures(t *testing.T) {
+	throttler := &stubThrottler{failures: map[string]int{}}
+	handler := Throttle(ThrottleConfig{
+		Throttler:       throttler,
+		MaxAttempts:     2,
+		Keys:            func(c echo.Context) []string { return []string{"login-ip:" + c.RealIP()} },
+		FailureStatuses: []int{http.StatusUnauthorized},
+	})(func(c echo.Context) error {
+		return c.NoContent(http.StatusUnauthorized)
+	})
+
+	for i, want := range []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests} {
+		rec := httptest.NewRecorder()
+		if err := handler(echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/", nil), rec)); err != nil {
+			t.Fatal(err)
+		}
+		if rec.Code != want {
+			t.Fatalf("attempt %d: status %d, want %d", i+1, rec.Code, want)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	if err := handler(echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/", nil), rec)); err != nil {
+		t.Fatal(err)
+	}
+	if retryAfter := rec.Header().Get("Retry-After"); retryAfter != "90" {
+		t.Fatalf("Retry-After %q, want 90", retryAfter)
+	}
+}
+
+func TestLoginThrottleKeysNameUserOfChallenge(t *testing.T) {
+	challenge, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "Admin"}).SignedString([]byte("key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"challenge_token":"`+challenge+`"}`))
+	req.RemoteAddr = "192.0.2.1:1234"
+	c := echo.New().NewContext(req, httptest.NewRecorder())
+
+	keys := LoginThrottleKeys(c)
+	if len(keys) != 2 || keys[0] != "login-ip:192.0.2.1" || keys[1] != "login-user:admin" {
+		t.Fatalf("keys %v, want the client IP and the lower-cased user", keys)
+	}
+}
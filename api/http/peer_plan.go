@@ -0,0 +1,172 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/http/schema"
+	"github.com/maahdima/mwp/api/service"
+)
+
+type PeerPlanController struct {
+	peerPlanService *service.PeerPlan
+	logger          *zap.Logger
+}
+
+func NewPeerPlanController(peerPlanService *service.PeerPlan) *PeerPlanController {
+	return &PeerPlanController{
+		peerPlanService: peerPlanService,
+		logger:          zap.L().Named("PeerPlanController"),
+	}
+}
+
+func (c *PeerPlanController) GetPeerPlans(ctx echo.Context) error {
+	plans, err := c.peerPlanService.GetPeerPlans()
+	if err != nil {
+		c.logger.Error("failed to get peer plans", zap.Error(err))
+		return ctx.JSON(http.StatusInternalServerError, schema.ErrorResponse{
+			StatusCode: http.StatusInternalServerError,
+			Status:     "error",
+			Message:    "failed to get peer plans: " + err.Error(),
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, schema.BasicResponseData[[]schema.PeerPlanResponse]{
+		BasicResponse: schema.OkBasicResponse,
+		Data:          *plans,
+	})
+}
+
+func (c *PeerPlanController) CreatePeerPlan(ctx echo.Context) error {
+	var req schema.CreatePeerPlanRequest
+
+	if err := ctx.Bind(&req); err != nil {
+		c.logger.Warn("failed to bind request", zap.Error(err))
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	if err := ctx.Validate(&req); err != nil {
+		c.logger.Warn("failed to validate request", zap.Error(err))
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	plan, err := c.peerPlanService.CreatePeerPlan(&req)
+	if err != nil {
+		if errors.Is(err, common.ErrInvalidPeerPlan) {
+			return ctx.JSON(http.StatusBadRequest, schema.ErrorResponse{
+				StatusCode: http.StatusBadRequest,
+				Status:     "error",
+				Message:    err.Error(),
+			})
+		}
+
+		c.logger.Error("failed to create peer plan", zap.Error(err))
+		return ctx.JSON(http.StatusInternalServerError, schema.ErrorResponse{
+			StatusCode: http.StatusInternalServerError,
+			Status:     "error",
+			Message:    "failed to create peer plan: " + err.Error(),
+		})
+	}
+
+	return ctx.JSON(http.StatusCreated, schema.BasicResponseData[schema.PeerPlanResponse]{
+		BasicResponse: schema.OkBasicResponse,
+		Data:          *plan,
+	})
+}
+
+func (c *PeerPlanController) UpdatePeerPlan(ctx echo.Context) error {
+	id := ctx.Param("id")
+	if id == "" {
+		c.logger.Error("Peer plan ID is required")
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	planId, err := strconv.Atoi(id)
+	if err != nil {
+		c.logger.Error("Invalid peer plan ID", zap.Error(err))
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	var req schema.UpdatePeerPlanRequest
+	if err := ctx.Bind(&req); err != nil {
+		c.logger.Warn("failed to bind request", zap.Error(err))
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	if err := ctx.Validate(&req); err != nil {
+		c.logger.Warn("failed to validate request", zap.Error(err))
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	result, err := c.peerPlanService.UpdatePeerPlan(uint(planId), &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return ctx.JSON(http.StatusNotFound, schema.ErrorResponse{
+				StatusCode: http.StatusNotFound,
+				Status:     "error",
+				Message:    "peer plan not found",
+			})
+		case errors.Is(err, common.ErrInvalidPeerPlan):
+			return ctx.JSON(http.StatusBadRequest, schema.ErrorResponse{
+				StatusCode: http.StatusBadRequest,
+				Status:     "error",
+				Message:    err.Error(),
+			})
+		}
+
+		c.logger.Error("failed to update peer plan", zap.Error(err))
+		return ctx.JSON(http.StatusInternalServerError, schema.ErrorResponse{
+			StatusCode: http.StatusInternalServerError,
+			Status:     "error",
+			Message:    "failed to update peer plan: " + err.Error(),
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, schema.BasicResponseData[schema.UpdatePeerPlanResponse]{
+		BasicResponse: schema.OkBasicResponse,
+		Data:          *result,
+	})
+}
+
+func (c *PeerPlanController) DeletePeerPlan(ctx echo.Context) error {
+	id := ctx.Param("id")
+	if id == "" {
+		c.logger.Error("Peer plan ID is required")
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	planId, err := strconv.Atoi(id)
+	if err != nil {
+		c.logger.Error("Invalid peer plan ID", zap.Error(err))
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	if err := c.peerPlanService.DeletePeerPlan(uint(planId)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ctx.JSON(http.StatusNotFound, schema.ErrorResponse{
+				StatusCode: http.StatusNotFound,
+				Status:     "error",
+				Message:    "peer plan not found",
+			})
+		}
+
+		c.logger.Error("failed to delete peer plan", zap.Error(err))
+		return ctx.JSON(http.StatusInternalServerError, schema.ErrorResponse{
+			StatusCode: http.StatusInternalServerError,
+			Status:     "error",
+			Message:    "failed to delete peer plan: " + err.Error(),
+		})
+	}
+
+	return ctx.JSON(http.StatusNoContent, schema.BasicResponse{
+		StatusCode: http.StatusNoContent,
+		Status:     "success",
+	})
+}
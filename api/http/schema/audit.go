@@ -0,0 +1,36 @@
+package schema
+
+import "encoding/json"
+
+// AuditQuery filters the audit log, from and to take RFC3339 or YYYY-MM-DD
+type AuditQuery struct {
+	Actor      string `query:"actor"`
+	Action     string `query:"action"`
+	TargetType string `query:"target_type"`
+	TargetId   string `query:"target_id"`
+	Outcome    string `query:"outcome" validate:"omitempty,oneof=success failure"`
+	From       string `query:"from"`
+	To         string `query:"to"`
+	Page       int    `query:"page" validate:"omitempty,min=1"`
+	PageSize   int    `query:"page_size" validate:"omitempty,min=1,max=500"`
+}
+
+type AuditEventResponse struct {
+	Id         uint            `json:"id"`
+	Time       string          `json:"time"`
+	Actor      string          `json:"actor"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetId   string          `json:"target_id,omitempty"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	SourceIP   string          `json:"source_ip"`
+	StatusCode int             `json:"status_code"`
+}
+
+type AuditEventsResponse struct {
+	Events   []AuditEventResponse `json:"events"`
+	Total    int64                `json:"total"`
+	Page     int                  `json:"page"`
+	PageSize int                  `json:"page_size"`
+}
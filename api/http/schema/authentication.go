@@ -1,16 +1,47 @@
 package schema
 
+import "github.com/golang-jwt/jwt/v5"
+
 type LoginRequest struct {
 	Username string `json:"username" validate:"required"`
 	Password string `json:"password" validate:"required"`
 }
 
+// LoginResponse carries the tokens, or only a challenge token when the admin still has to pass the second factor
 type LoginResponse struct {
-	UserID       uint   `json:"user_id"`
-	Username     string `json:"username"`
-	AccessToken  string `json:"access_token"`
-	RefreshToken string `json:"refresh_token"`
-	ExpiresIn    int64  `json:"expires_in"`
+	UserID            uint   `json:"user_id"`
+	Username          string `json:"username"`
+	Role              string `json:"role"`
+	AccessToken       string `json:"access_token,omitempty"`
+	RefreshToken      string `json:"refresh_token,omitempty"`
+	ExpiresIn         int64  `json:"expires_in,omitempty"`
+	TwoFactorRequired bool   `json:"two_factor_required,omitempty"`
+	ChallengeToken    string `json:"challenge_token,omitempty"`
+}
+
+// TwoFactorLoginRequest completes a login with a TOTP code or one of the recovery codes
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	Code           string `json:"code" validate:"required,max=32"`
+}
+
+type TwoFactorSetupResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+	QRCode string `json:"qr_code"` // PNG data URI of the URI
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" validate:"required,max=32"`
+}
+
+type DisableTwoFactorRequest struct {
+	Password string `json:"password" validate:"required"`
+	Code     string `json:"code" validate:"required,max=32"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
 }
 
 type UpdateProfileRequest struct {
@@ -19,3 +50,98 @@ type UpdateProfileRequest struct {
 	NewUsername *string `json:"new_username"`
 	NewPassword *string `json:"new_password"`
 }
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// AdminScopeEntry grants a whole server, or a single interface of it when InterfaceId is set
+type AdminScopeEntry struct {
+	ServerId    uint  `json:"server_id" validate:"required"`
+	InterfaceId *uint `json:"interface_id,omitempty"`
+}
+
+// AccessScope is what a reseller may reach
+type AccessScope []AdminScopeEntry
+
+func (s AccessScope) AllowsServer(serverId uint) bool {
+	for _, entry := range s {
+		if entry.ServerId == serverId {
+			return true
+		}
+	}
+	return false
+}
+
+func (s AccessScope) AllowsInterface(serverId, interfaceId uint) bool {
+	for _, entry := range s {
+		if entry.ServerId == serverId && (entry.InterfaceId == nil || *entry.InterfaceId == interfaceId) {
+			return true
+		}
+	}
+	return false
+}
+
+// TokenClaims is the payload of an access token
+type TokenClaims struct {
+	jwt.RegisteredClaims
+	SessionID string      `json:"sid"`
+	Role      string      `json:"role"`
+	Scope     AccessScope `json:"scope,omitempty"`
+	// TokenScopes is only set for API tokens, which are not JWTs and never carry a session
+	TokenScopes []string `json:"-"`
+}
+
+// IsAPIToken reports whether the request was authenticated with an API token
+func (c *TokenClaims) IsAPIToken() bool {
+	return c.TokenScopes != nil
+}
+
+// APITokenResponse describes a token, Token holds the secret and is only returned once on creation
+type APITokenResponse struct {
+	Id         uint     `json:"id"`
+	Name       string   `json:"name"`
+	Prefix     string   `json:"prefix"`
+	Scopes     []string `json:"scopes"`
+	ExpiresAt  *string  `json:"expires_at"`
+	LastUsedAt *string  `json:"last_used_at"`
+	RevokedAt  *string  `json:"revoked_at"`
+	CreatedAt  string   `json:"created_at"`
+	Token      string   `json:"token,omitempty"`
+}
+
+// CreateAPITokenRequest creates a token for the signed-in admin, ExpiresInDays left out never expires
+type CreateAPITokenRequest struct {
+	Name          string   `json:"name" validate:"required,max=64"`
+	Scopes        []string `json:"scopes" validate:"required,min=1,dive,oneof=read peers:write write"`
+	ExpiresInDays *int     `json:"expires_in_days" validate:"omitempty,min=1,max=3650"`
+}
+
+type AdminResponse struct {
+	Id        uint        `json:"id"`
+	Username  string      `json:"username"`
+	Role      string      `json:"role"`
+	IsActive  bool        `json:"is_active"`
+	TwoFactor bool        `json:"two_factor_enabled"`
+	Scope     AccessScope `json:"scope"`
+	CreatedAt string      `json:"created_at"`
+}
+
+type CreateAdminRequest struct {
+	Username string      `json:"username" validate:"required,max=64"`
+	Password string      `json:"password" validate:"required,min=6"`
+	Role     string      `json:"role" validate:"required,oneof=owner operator viewer reseller"`
+	IsActive *bool       `json:"is_active"`
+	Scope    AccessScope `json:"scope" validate:"dive"`
+}
+
+// UpdateAdminRequest changes the given fields only, a scope replaces the previous one
+type UpdateAdminRequest struct {
+	Username *string     `json:"username" validate:"omitempty,max=64"`
+	Password *string     `json:"password" validate:"omitempty,min=6"`
+	Role     *string     `json:"role" validate:"omitempty,oneof=owner operator viewer reseller"`
+	IsActive *bool       `json:"is_active"`
+	Scope    AccessScope `json:"scope" validate:"omitempty,dive"`
+	// ResetTwoFactor turns off the second factor of an admin that lost both the authenticator and the recovery codes
+	ResetTwoFactor bool `json:"reset_two_factor"`
+}
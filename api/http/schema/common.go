@@ -27,6 +27,12 @@ var BadParamsErrorResponse = ErrorResponse{
 	Message:    "bad parameters",
 }
 
+var ForbiddenErrorResponse = ErrorResponse{
+	StatusCode: http.StatusForbidden,
+	Status:     "error",
+	Message:    "forbidden",
+}
+
 var InternalServerErrorResponse = ErrorResponse{
 	StatusCode: http.StatusInternalServerError,
 	Status:     "error",
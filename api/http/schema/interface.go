@@ -2,6 +2,7 @@ package schema
 
 type InterfaceResponse struct {
 	Id          uint    `json:"id"`
+	ServerId    uint    `json:"server_id"`
 	InterfaceID string  `json:"interface_id"`
 	Disabled    bool    `json:"disabled"`
 	Comment     *string `json:"comment"`
@@ -9,18 +10,33 @@ type InterfaceResponse struct {
 	ListenPort  string  `json:"listen_port"`
 	MTU         string  `json:"mtu"`
 	IsRunning   bool    `json:"is_running"`
+	ClientInterfaceSettings
+}
+
+// ClientInterfaceSettings are the client config defaults of the peers of an interface
+type ClientInterfaceSettings struct {
+	ClientDNS                 *string `json:"client_dns,omitempty"`
+	ClientAllowedIPsMode      *string `json:"client_allowed_ips_mode,omitempty" validate:"omitempty,oneof=full exclude_private split"`
+	ClientAllowedIPs          *string `json:"client_allowed_ips,omitempty"`
+	ClientMTU                 *int    `json:"client_mtu,omitempty"`
+	ClientPersistentKeepalive *string `json:"client_persistent_keepalive,omitempty"`
+	GeneratePresharedKey      *bool   `json:"generate_preshared_key,omitempty"`
+	OmitPresharedKey          *bool   `json:"omit_preshared_key,omitempty"`
 }
 
 type CreateInterfaceRequest struct {
 	Comment    *string `json:"comment,omitempty"`
 	Name       string  `json:"name" validate:"required"`
 	ListenPort string  `json:"listen_port" validate:"required"`
+	ClientInterfaceSettings
 }
 
 type UpdateInterfaceRequest struct {
 	Disabled *bool   `json:"disabled,omitempty"`
 	Comment  *string `json:"comment,omitempty"`
 	Name     string  `json:"name,omitempty"`
+	// the client config defaults are kept when left out, an empty value clears them
+	ClientInterfaceSettings
 }
 
 type InterfaceStatsResponse struct {
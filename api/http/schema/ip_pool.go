@@ -1,24 +1,49 @@
 package schema
 
 type IPPoolResponse struct {
-	Id          uint   `json:"id"`
-	Name        string `json:"name"`
-	StartIP     string `json:"start_ip"`
-	EndIP       string `json:"end_ip"`
-	TotalIP     int    `json:"total_ip"`
-	UsedIP      int    `json:"used_ip"`
-	RemainingIP int    `json:"remaining_ip"`
+	Id          uint    `json:"id"`
+	Name        string  `json:"name"`
+	InterfaceID uint    `json:"interface_id"`
+	IPVersion   int     `json:"ip_version"`
+	Priority    int     `json:"priority"`
+	CIDR        *string `json:"cidr"`
+	StartIP     string  `json:"start_ip"`
+	EndIP       string  `json:"end_ip"`
+	TotalIP     int     `json:"total_ip"`
+	UsedIP      int     `json:"used_ip"`
+	ReservedIP  int     `json:"reserved_ip"`
+	RemainingIP int     `json:"remaining_ip"`
 }
 
+// CreateIPPoolRequest defines a pool by CIDR or by a start and end IP
 type CreateIPPoolRequest struct {
 	Name        string `json:"name" validate:"required"`
 	InterfaceID uint   `json:"interface_id" validate:"required"`
-	StartIP     string `json:"start_ip" validate:"required"`
-	EndIP       string `json:"end_ip" validate:"required"`
+	CIDR        string `json:"cidr,omitempty"`
+	StartIP     string `json:"start_ip,omitempty"`
+	EndIP       string `json:"end_ip,omitempty"`
+	Priority    *int   `json:"priority,omitempty"`
 }
 
 type UpdateIPPoolRequest struct {
-	Name    string `json:"name,omitempty"`
-	StartIP string `json:"start_ip,omitempty"`
-	EndIP   string `json:"end_ip,omitempty"`
+	Name     string `json:"name,omitempty"`
+	CIDR     string `json:"cidr,omitempty"`
+	StartIP  string `json:"start_ip,omitempty"`
+	EndIP    string `json:"end_ip,omitempty"`
+	Priority *int   `json:"priority,omitempty"`
+}
+
+type IPReservationResponse struct {
+	Id       uint    `json:"id"`
+	StartIP  string  `json:"start_ip"`
+	EndIP    string  `json:"end_ip"`
+	PeerName *string `json:"peer_name"`
+	Comment  *string `json:"comment"`
+}
+
+type CreateIPReservationRequest struct {
+	StartIP  string  `json:"start_ip" validate:"required"`
+	EndIP    string  `json:"end_ip,omitempty"`
+	PeerName *string `json:"peer_name,omitempty"`
+	Comment  *string `json:"comment,omitempty"`
 }
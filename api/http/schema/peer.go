@@ -10,7 +10,8 @@ var (
 )
 
 type NewPeerAllowedAddressRequest struct {
-	InterfaceId uint `json:"interface_id" validate:"required"`
+	InterfaceId uint   `json:"interface_id" validate:"required"`
+	Name        string `json:"name,omitempty"`
 }
 
 type NewPeerAllowedAddressResponse struct {
@@ -24,7 +25,7 @@ type CreatePeerRequest struct {
 	InterfaceId         uint    `json:"interface_id" validate:"required"`
 	PrivateKey          string  `json:"private_key" validate:"required"`
 	PublicKey           string  `json:"public_key" validate:"required"`
-	AllowedAddress      string  `json:"allowed_address" validate:"required"`
+	AllowedAddress      string  `json:"allowed_address,omitempty"`
 	PresharedKey        *string `json:"preshared_key,omitempty"`
 	PersistentKeepAlive *string `json:"persistent_keepalive"`
 	Endpoint            string  `json:"endpoint" validate:"required"`
@@ -32,6 +33,12 @@ type CreatePeerRequest struct {
 	TrafficLimit        *string `json:"traffic_limit,omitempty"`
 	DownloadBandwidth   *string `json:"download_bandwidth,omitempty"`
 	UploadBandwidth     *string `json:"upload_bandwidth,omitempty"`
+	PlanId              *uint   `json:"plan_id,omitempty"`
+	DNS                 *string `json:"dns,omitempty"`
+	AllowedIPsMode      *string `json:"allowed_ips_mode,omitempty" validate:"omitempty,oneof=full exclude_private split"`
+	AllowedIPs          *string `json:"allowed_ips,omitempty"`
+	MTU                 *int    `json:"mtu,omitempty"`
+	IncludePresharedKey *bool   `json:"include_preshared_key,omitempty"`
 }
 
 type UpdatePeerRequest struct {
@@ -46,6 +53,12 @@ type UpdatePeerRequest struct {
 	TrafficLimit        *string `json:"traffic_limit,omitempty"`
 	DownloadBandwidth   *string `json:"download_bandwidth,omitempty"`
 	UploadBandwidth     *string `json:"upload_bandwidth,omitempty"`
+	// the client config settings are kept when left out, an empty value falls back to the interface default
+	DNS                 *string `json:"dns,omitempty"`
+	AllowedIPsMode      *string `json:"allowed_ips_mode,omitempty" validate:"omitempty,oneof=full exclude_private split"`
+	AllowedIPs          *string `json:"allowed_ips,omitempty"`
+	MTU                 *int    `json:"mtu,omitempty"`
+	IncludePresharedKey *bool   `json:"include_preshared_key,omitempty"`
 }
 
 type UpdatePeerShareExpireRequest struct {
@@ -75,22 +88,31 @@ type PeerShareStatusResponse struct {
 }
 
 type PeerResponse struct {
-	Id                uint         `json:"id"`
-	UUID              string       `json:"uuid"`
-	Disabled          bool         `json:"disabled"`
-	Comment           *string      `json:"comment"`
-	TelegramUsername  *string      `json:"telegram_username"`
-	Name              string       `json:"name"`
-	Interface         string       `json:"interface"`
-	AllowedAddress    string       `json:"allowed_address"`
-	TrafficLimit      *string      `json:"traffic_limit"`
-	ExpireTime        *string      `json:"expire_time"`
-	DownloadBandwidth *string      `json:"download_bandwidth"`
-	UploadBandwidth   *string      `json:"upload_bandwidth"`
-	TotalUsage        string       `json:"total_usage"`
-	Status            []PeerStatus `json:"status"`
-	IsOnline          bool         `json:"is_online"`
-	IsShared          bool         `json:"is_shared"`
+	Id                  uint         `json:"id"`
+	ServerId            uint         `json:"server_id"`
+	UUID                string       `json:"uuid"`
+	Disabled            bool         `json:"disabled"`
+	DisabledReason      *string      `json:"disabled_reason"`
+	Comment             *string      `json:"comment"`
+	TelegramUsername    *string      `json:"telegram_username"`
+	Name                string       `json:"name"`
+	Interface           string       `json:"interface"`
+	AllowedAddress      string       `json:"allowed_address"`
+	TrafficLimit        *string      `json:"traffic_limit"`
+	ExpireTime          *string      `json:"expire_time"`
+	DownloadBandwidth   *string      `json:"download_bandwidth"`
+	UploadBandwidth     *string      `json:"upload_bandwidth"`
+	TotalUsage          string       `json:"total_usage"`
+	Status              []PeerStatus `json:"status"`
+	IsOnline            bool         `json:"is_online"`
+	IsShared            bool         `json:"is_shared"`
+	PlanId              *uint        `json:"plan_id"`
+	DNS                 *string      `json:"dns"`
+	AllowedIPsMode      *string      `json:"allowed_ips_mode"`
+	AllowedIPs          *string      `json:"allowed_ips"`
+	MTU                 *int         `json:"mtu"`
+	IncludePresharedKey *bool        `json:"include_preshared_key"`
+	HasPresharedKey     bool         `json:"has_preshared_key"`
 }
 
 type PeerStatsResponse struct {
@@ -105,3 +127,118 @@ type RecentOnlinePeers struct {
 	Name     string `json:"name"`
 	LastSeen string `json:"last_seen"`
 }
+
+type ImportRowStatus string
+
+var (
+	ImportRowValid   ImportRowStatus = "valid"
+	ImportRowInvalid ImportRowStatus = "invalid"
+	ImportRowCreated ImportRowStatus = "created"
+	ImportRowFailed  ImportRowStatus = "failed"
+)
+
+type ImportPeerRowResult struct {
+	Row            int             `json:"row"`
+	Name           string          `json:"name"`
+	Interface      string          `json:"interface"`
+	AllowedAddress string          `json:"allowed_address,omitempty"`
+	Status         ImportRowStatus `json:"status"`
+	Errors         []string        `json:"errors,omitempty"`
+	PeerId         *uint           `json:"peer_id,omitempty"`
+}
+
+type ImportPeersResponse struct {
+	DryRun  bool                  `json:"dry_run"`
+	Total   int                   `json:"total"`
+	Valid   int                   `json:"valid"`
+	Invalid int                   `json:"invalid"`
+	Created int                   `json:"created"`
+	Failed  int                   `json:"failed"`
+	Rows    []ImportPeerRowResult `json:"rows"`
+}
+
+type BulkPeerAction string
+
+var (
+	BulkEnablePeers     BulkPeerAction = "enable"
+	BulkDisablePeers    BulkPeerAction = "disable"
+	BulkDeletePeers     BulkPeerAction = "delete"
+	BulkExtendExpire    BulkPeerAction = "extend_expire"
+	BulkSetTrafficLimit BulkPeerAction = "set_traffic_limit"
+	BulkSetBandwidth    BulkPeerAction = "set_bandwidth"
+	BulkResetUsage      BulkPeerAction = "reset_usage"
+	BulkEnableSharing   BulkPeerAction = "enable_sharing"
+)
+
+type BulkPeerFilter struct {
+	Interface *string     `json:"interface,omitempty"`
+	Status    *PeerStatus `json:"status,omitempty" validate:"omitempty,oneof=active inactive expired suspended"`
+	Expired   *bool       `json:"expired,omitempty"`
+	OverLimit *bool       `json:"over_limit,omitempty"`
+}
+
+type BulkPeerRequest struct {
+	Ids               []uint          `json:"ids,omitempty"`
+	Filter            *BulkPeerFilter `json:"filter,omitempty"`
+	All               bool            `json:"all,omitempty"`
+	Action            BulkPeerAction  `json:"action" validate:"required,oneof=enable disable delete extend_expire set_traffic_limit set_bandwidth reset_usage enable_sharing"`
+	Days              *int            `json:"days,omitempty" validate:"omitempty,min=1"`
+	TrafficLimit      *string         `json:"traffic_limit,omitempty"`
+	DownloadBandwidth *string         `json:"download_bandwidth,omitempty"`
+	UploadBandwidth   *string         `json:"upload_bandwidth,omitempty"`
+}
+
+type BulkPeerResult struct {
+	Id      uint   `json:"id"`
+	Name    string `json:"name"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+type BulkPeerResponse struct {
+	Action    BulkPeerAction   `json:"action"`
+	Total     int              `json:"total"`
+	Succeeded int              `json:"succeeded"`
+	Failed    int              `json:"failed"`
+	Results   []BulkPeerResult `json:"results"`
+}
+
+type RenewPeerRequest struct {
+	Days       *int  `json:"days,omitempty" validate:"omitempty,min=1"`
+	PlanId     *uint `json:"plan_id,omitempty"`
+	ResetUsage bool  `json:"reset_usage"`
+}
+
+type PeerRenewalResponse struct {
+	Id                 uint    `json:"id"`
+	PeerId             uint    `json:"peer_id"`
+	PeerName           string  `json:"peer_name"`
+	PlanId             *uint   `json:"plan_id"`
+	Days               int     `json:"days"`
+	PreviousExpireTime *string `json:"previous_expire_time"`
+	NewExpireTime      string  `json:"new_expire_time"`
+	UsageReset         bool    `json:"usage_reset"`
+	PreviousUsage      string  `json:"previous_usage"`
+	ReEnabled          bool    `json:"re_enabled"`
+	RenewedAt          string  `json:"renewed_at"`
+}
+
+type PeerTrafficPoint struct {
+	Time          string `json:"time"`
+	DownloadUsage string `json:"download"`
+	UploadUsage   string `json:"upload"`
+	TotalUsage    string `json:"total"`
+	DownloadBytes int64  `json:"download_bytes"`
+	UploadBytes   int64  `json:"upload_bytes"`
+}
+
+type PeerTrafficHistoryResponse struct {
+	PeerId        uint               `json:"peer_id"`
+	Granularity   string             `json:"granularity"`
+	From          string             `json:"from"`
+	To            string             `json:"to"`
+	DownloadUsage string             `json:"download"`
+	UploadUsage   string             `json:"upload"`
+	TotalUsage    string             `json:"total"`
+	Points        []PeerTrafficPoint `json:"points"`
+}
@@ -0,0 +1,38 @@
+package schema
+
+type PeerPlanResponse struct {
+	Id                  uint    `json:"id"`
+	Name                string  `json:"name"`
+	Comment             *string `json:"comment"`
+	TrafficLimit        *string `json:"traffic_limit"`
+	DownloadBandwidth   *string `json:"download_bandwidth"`
+	UploadBandwidth     *string `json:"upload_bandwidth"`
+	ValidityDays        *int    `json:"validity_days"`
+	PersistentKeepalive *string `json:"persistent_keepalive"`
+	DNS                 *string `json:"dns"`
+	AllowedIPsMode      *string `json:"allowed_ips_mode"`
+	PeerCount           int64   `json:"peer_count"`
+}
+
+type CreatePeerPlanRequest struct {
+	Name                string  `json:"name" validate:"required"`
+	Comment             *string `json:"comment,omitempty"`
+	TrafficLimit        *string `json:"traffic_limit,omitempty"`
+	DownloadBandwidth   *string `json:"download_bandwidth,omitempty"`
+	UploadBandwidth     *string `json:"upload_bandwidth,omitempty"`
+	ValidityDays        *int    `json:"validity_days,omitempty" validate:"omitempty,min=1"`
+	PersistentKeepalive *string `json:"persistent_keepalive,omitempty"`
+	DNS                 *string `json:"dns,omitempty"`
+	AllowedIPsMode      *string `json:"allowed_ips_mode,omitempty" validate:"omitempty,oneof=full exclude_private"`
+}
+
+type UpdatePeerPlanRequest struct {
+	CreatePeerPlanRequest
+	// Propagate applies the new traffic limit, bandwidth, keepalive, DNS and allowed-IPs mode to every peer on the plan
+	Propagate bool `json:"propagate"`
+}
+
+type UpdatePeerPlanResponse struct {
+	Plan    PeerPlanResponse `json:"plan"`
+	Results []BulkPeerResult `json:"results"`
+}
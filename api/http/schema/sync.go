@@ -26,3 +26,79 @@ type SyncInterfacesRequest struct {
 type SyncPeersRequest struct {
 	PeerIDs []string `json:"peer_ids"`
 }
+
+type DriftObject string
+
+var (
+	DriftObjectInterface DriftObject = "interface"
+	DriftObjectPeer      DriftObject = "peer"
+	DriftObjectScheduler DriftObject = "scheduler"
+	DriftObjectQueue     DriftObject = "queue"
+)
+
+type DriftKind string
+
+var (
+	DriftMissingOnRouter DriftKind = "missing_on_router"
+	DriftMissingInDB     DriftKind = "missing_in_db"
+	DriftChanged         DriftKind = "changed"
+)
+
+type DriftResolution string
+
+var (
+	DriftRouterWins DriftResolution = "router"
+	DriftPanelWins  DriftResolution = "panel"
+)
+
+type DriftField struct {
+	Field  string `json:"field"`
+	Panel  string `json:"panel"`
+	Router string `json:"router"`
+}
+
+type DriftItem struct {
+	Object   DriftObject  `json:"object"`
+	Kind     DriftKind    `json:"kind"`
+	RouterID string       `json:"router_id,omitempty"`
+	PanelID  uint         `json:"panel_id,omitempty"`
+	Name     string       `json:"name"`
+	Fields   []DriftField `json:"fields,omitempty"`
+	// Expired is set on peers whose expire time has passed, the router scheduler disables them
+	Expired bool `json:"expired,omitempty"`
+}
+
+type DriftReportResponse struct {
+	ServerId  uint        `json:"server_id"`
+	CheckedAt string      `json:"checked_at"`
+	Total     int         `json:"total"`
+	Items     []DriftItem `json:"items"`
+}
+
+type ResolveDriftRequest struct {
+	Object     DriftObject     `json:"object" validate:"required,oneof=interface peer scheduler queue"`
+	RouterID   string          `json:"router_id,omitempty"`
+	PanelID    uint            `json:"panel_id,omitempty"`
+	Resolution DriftResolution `json:"resolution" validate:"required,oneof=router panel"`
+}
+
+type DriftHealFailure struct {
+	Item  DriftItem `json:"item"`
+	Error string    `json:"error"`
+}
+
+type ReconcileServerResult struct {
+	ServerId   uint               `json:"server_id"`
+	ServerName string             `json:"server_name"`
+	Error      string             `json:"error,omitempty"`
+	Healed     []DriftItem        `json:"healed"`
+	Pending    []DriftItem        `json:"pending"`
+	Failed     []DriftHealFailure `json:"failed"`
+}
+
+type ReconcileRunResponse struct {
+	StartedAt  string                  `json:"started_at"`
+	FinishedAt string                  `json:"finished_at"`
+	AutoHeal   []string                `json:"auto_heal"`
+	Servers    []ReconcileServerResult `json:"servers"`
+}
@@ -1,9 +1,12 @@
 package http
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
+	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/http/middleware"
 	"github.com/maahdima/mwp/api/http/schema"
 	"github.com/maahdima/mwp/api/service"
 
@@ -34,6 +37,16 @@ func (c *ServerController) GetServers(ctx echo.Context) error {
 		})
 	}
 
+	if scope := middleware.GetAccessScope(ctx); scope != nil {
+		filtered := make([]schema.ServerResponse, 0, len(*servers))
+		for _, server := range *servers {
+			if scope.AllowsServer(server.Id) {
+				filtered = append(filtered, server)
+			}
+		}
+		servers = &filtered
+	}
+
 	return ctx.JSON(http.StatusOK, schema.BasicResponseData[[]schema.ServerResponse]{
 		BasicResponse: schema.OkBasicResponse,
 		Data:          *servers,
@@ -152,6 +165,14 @@ func (c *ServerController) DeleteServer(ctx echo.Context) error {
 	}
 
 	if err := c.serverService.DeleteServer(uint(serverId)); err != nil {
+		if errors.Is(err, common.ErrServerInUse) {
+			return ctx.JSON(http.StatusConflict, schema.ErrorResponse{
+				StatusCode: http.StatusConflict,
+				Status:     "error",
+				Message:    err.Error(),
+			})
+		}
+
 		c.logger.Error("failed to delete server", zap.Error(err))
 		return ctx.JSON(http.StatusInternalServerError, schema.ErrorResponse{
 			StatusCode: http.StatusInternalServerError,
@@ -1,30 +1,36 @@
 package http
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
 
+	"github.com/maahdima/mwp/api/cmd/jobs"
+	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/http/middleware"
 	"github.com/maahdima/mwp/api/http/schema"
 	"github.com/maahdima/mwp/api/service"
 )
 
 type SyncController struct {
 	syncService *service.SyncService
+	reconciler  *traffic.Reconciler
 	logger      *zap.Logger
 }
 
-func NewSyncController(syncService *service.SyncService) *SyncController {
+func NewSyncController(syncService *service.SyncService, reconciler *traffic.Reconciler) *SyncController {
 	return &SyncController{
 		syncService: syncService,
+		reconciler:  reconciler,
 		logger:      zap.L().Named("SyncController"),
 	}
 }
 
 func (c *SyncController) GetSyncInterfaces(ctx echo.Context) error {
-	ifaces, err := c.syncService.GetSyncInterfaces()
+	ifaces, err := c.syncService.GetSyncInterfaces(middleware.GetServer(ctx))
 	if err != nil {
 		c.logger.Error("failed to fetch sync interfaces", zap.Error(err))
 		return ctx.JSON(http.StatusInternalServerError, schema.ErrorResponse{StatusCode: http.StatusInternalServerError, Status: "error", Message: "failed to fetch interfaces for sync: " + err.Error()})
@@ -35,7 +41,7 @@ func (c *SyncController) GetSyncInterfaces(ctx echo.Context) error {
 
 func (c *SyncController) GetSyncPeers(ctx echo.Context) error {
 	interfaceName := strings.TrimSpace(ctx.QueryParam("interface"))
-	peers, err := c.syncService.GetSyncPeers(interfaceName)
+	peers, err := c.syncService.GetSyncPeers(middleware.GetServer(ctx), interfaceName)
 	if err != nil {
 		c.logger.Error("failed to fetch sync peers", zap.Error(err))
 		return ctx.JSON(http.StatusInternalServerError, schema.ErrorResponse{StatusCode: http.StatusInternalServerError, Status: "error", Message: "failed to fetch peers for sync: " + err.Error()})
@@ -49,7 +55,7 @@ func (c *SyncController) SyncSelectedInterfaces(ctx echo.Context) error {
 	if err := ctx.Bind(&req); err != nil {
 		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
 	}
-	if err := c.syncService.SyncSelectedInterfaces(req.InterfaceIDs); err != nil {
+	if err := c.syncService.SyncSelectedInterfaces(middleware.GetServer(ctx), req.InterfaceIDs); err != nil {
 		c.logger.Error("failed to sync selected interfaces", zap.Error(err))
 		return ctx.JSON(http.StatusInternalServerError, schema.ErrorResponse{StatusCode: http.StatusInternalServerError, Status: "error", Message: "failed to sync selected interfaces: " + err.Error()})
 	}
@@ -61,7 +67,7 @@ func (c *SyncController) SyncSelectedPeers(ctx echo.Context) error {
 	if err := ctx.Bind(&req); err != nil {
 		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
 	}
-	if err := c.syncService.SyncSelectedPeers(req.PeerIDs); err != nil {
+	if err := c.syncService.SyncSelectedPeers(middleware.GetServer(ctx), req.PeerIDs); err != nil {
 		c.logger.Error("failed to sync selected peers", zap.Error(err))
 		return ctx.JSON(http.StatusInternalServerError, schema.ErrorResponse{StatusCode: http.StatusInternalServerError, Status: "error", Message: "failed to sync selected peers: " + err.Error()})
 	}
@@ -69,7 +75,7 @@ func (c *SyncController) SyncSelectedPeers(ctx echo.Context) error {
 }
 
 func (c *SyncController) SyncPeers(ctx echo.Context) error {
-	if err := c.syncService.SyncPeers(); err != nil {
+	if err := c.syncService.SyncPeers(middleware.GetServer(ctx)); err != nil {
 		c.logger.Error("failed to sync peers", zap.Error(err))
 		return ctx.JSON(http.StatusInternalServerError, schema.ErrorResponse{
 			StatusCode: http.StatusInternalServerError,
@@ -82,7 +88,7 @@ func (c *SyncController) SyncPeers(ctx echo.Context) error {
 }
 
 func (c *SyncController) SyncInterfaces(ctx echo.Context) error {
-	if err := c.syncService.SyncInterfaces(); err != nil {
+	if err := c.syncService.SyncInterfaces(middleware.GetServer(ctx)); err != nil {
 		c.logger.Error("failed to sync interfaces", zap.Error(err))
 		return ctx.JSON(http.StatusInternalServerError, schema.ErrorResponse{
 			StatusCode: http.StatusInternalServerError,
@@ -93,3 +99,48 @@ func (c *SyncController) SyncInterfaces(ctx echo.Context) error {
 
 	return ctx.JSON(http.StatusOK, schema.OkBasicResponse)
 }
+
+func (c *SyncController) GetDrift(ctx echo.Context) error {
+	report, err := c.syncService.DetectDrift(middleware.GetServer(ctx))
+	if err != nil {
+		c.logger.Error("failed to detect drift", zap.Error(err))
+		return ctx.JSON(http.StatusInternalServerError, schema.ErrorResponse{StatusCode: http.StatusInternalServerError, Status: "error", Message: "failed to detect drift: " + err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, schema.BasicResponseData[schema.DriftReportResponse]{BasicResponse: schema.OkBasicResponse, Data: *report})
+}
+
+func (c *SyncController) ResolveDrift(ctx echo.Context) error {
+	var req schema.ResolveDriftRequest
+	if err := ctx.Bind(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+	if err := ctx.Validate(&req); err != nil {
+		return ctx.JSON(http.StatusBadRequest, schema.ErrorResponse{StatusCode: http.StatusBadRequest, Status: "error", Message: err.Error()})
+	}
+	if req.RouterID == "" && req.PanelID == 0 {
+		return ctx.JSON(http.StatusBadRequest, schema.ErrorResponse{StatusCode: http.StatusBadRequest, Status: "error", Message: "router_id or panel_id is required"})
+	}
+
+	if err := c.syncService.ResolveDrift(middleware.GetServer(ctx), &req); err != nil {
+		c.logger.Error("failed to resolve drift", zap.Error(err))
+		switch {
+		case errors.Is(err, common.ErrDriftNotFound):
+			return ctx.JSON(http.StatusNotFound, schema.ErrorResponse{StatusCode: http.StatusNotFound, Status: "error", Message: err.Error()})
+		case errors.Is(err, common.ErrDriftNotResolvable):
+			return ctx.JSON(http.StatusConflict, schema.ErrorResponse{StatusCode: http.StatusConflict, Status: "error", Message: err.Error()})
+		}
+		return ctx.JSON(http.StatusInternalServerError, schema.ErrorResponse{StatusCode: http.StatusInternalServerError, Status: "error", Message: "failed to resolve drift: " + err.Error()})
+	}
+
+	return ctx.JSON(http.StatusOK, schema.OkBasicResponse)
+}
+
+func (c *SyncController) GetLastReconcileRun(ctx echo.Context) error {
+	run := c.reconciler.LastRun()
+	if run == nil {
+		return ctx.JSON(http.StatusNotFound, schema.ErrorResponse{StatusCode: http.StatusNotFound, Status: "error", Message: "reconciliation has not run yet"})
+	}
+
+	return ctx.JSON(http.StatusOK, schema.BasicResponseData[schema.ReconcileRunResponse]{BasicResponse: schema.OkBasicResponse, Data: *run})
+}
@@ -83,7 +83,7 @@ func (u *UserController) GetUserConfig(ctx echo.Context) error {
 		})
 	}
 
-	return ctx.File(config)
+	return ctx.Blob(http.StatusOK, echo.MIMETextPlainCharsetUTF8, config)
 }
 
 func (u *UserController) GetUserQRCode(ctx echo.Context) error {
@@ -110,5 +110,5 @@ func (u *UserController) GetUserQRCode(ctx echo.Context) error {
 		})
 	}
 
-	return ctx.File(qrCode)
+	return ctx.Blob(http.StatusOK, "image/jpeg", qrCode)
 }
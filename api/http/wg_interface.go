@@ -1,9 +1,12 @@
 package http
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
+	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/http/middleware"
 	"github.com/maahdima/mwp/api/http/schema"
 	"github.com/maahdima/mwp/api/service"
 
@@ -24,7 +27,7 @@ func NewWgInterfaceController(interfaceService *service.WgInterface) *WgInterfac
 }
 
 func (c *WgInterfaceController) GetInterfaces(ctx echo.Context) error {
-	interfaces, err := c.interfaceService.GetInterfaces()
+	interfaces, err := c.interfaceService.GetInterfaces(middleware.GetServer(ctx))
 	if err != nil {
 		c.logger.Error("failed to get wireguard interfaces", zap.Error(err))
 		return ctx.JSON(http.StatusInternalServerError, schema.ErrorResponse{
@@ -34,6 +37,16 @@ func (c *WgInterfaceController) GetInterfaces(ctx echo.Context) error {
 		})
 	}
 
+	if scope := middleware.GetAccessScope(ctx); scope != nil {
+		filtered := make([]schema.InterfaceResponse, 0, len(*interfaces))
+		for _, iface := range *interfaces {
+			if scope.AllowsInterface(iface.ServerId, iface.Id) {
+				filtered = append(filtered, iface)
+			}
+		}
+		interfaces = &filtered
+	}
+
 	return ctx.JSON(http.StatusOK, schema.BasicResponseData[[]schema.InterfaceResponse]{
 		BasicResponse: schema.OkBasicResponse,
 		Data:          *interfaces,
@@ -51,8 +64,16 @@ func (c *WgInterfaceController) CreateInterface(ctx echo.Context) error {
 		})
 	}
 
-	iface, err := c.interfaceService.CreateInterface(&req)
+	iface, err := c.interfaceService.CreateInterface(middleware.GetServer(ctx), &req)
 	if err != nil {
+		if errors.Is(err, common.ErrInvalidClientConfig) {
+			return ctx.JSON(http.StatusBadRequest, schema.ErrorResponse{
+				StatusCode: http.StatusBadRequest,
+				Status:     "error",
+				Message:    err.Error(),
+			})
+		}
+
 		c.logger.Error("failed to create wireguard interface", zap.Error(err))
 		return ctx.JSON(http.StatusInternalServerError, schema.ErrorResponse{
 			StatusCode: http.StatusInternalServerError,
@@ -119,6 +140,14 @@ func (c *WgInterfaceController) UpdateInterface(ctx echo.Context) error {
 
 	iface, err := c.interfaceService.UpdateInterface(uint(interfaceId), &req)
 	if err != nil {
+		if errors.Is(err, common.ErrInvalidClientConfig) {
+			return ctx.JSON(http.StatusBadRequest, schema.ErrorResponse{
+				StatusCode: http.StatusBadRequest,
+				Status:     "error",
+				Message:    err.Error(),
+			})
+		}
+
 		c.logger.Error("failed to update wireguard interface", zap.Error(err))
 		return ctx.JSON(http.StatusInternalServerError, schema.ErrorResponse{
 			StatusCode: http.StatusInternalServerError,
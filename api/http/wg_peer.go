@@ -2,10 +2,14 @@ package http
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/maahdima/mwp/api/cmd/jobs"
+	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/http/middleware"
 	"github.com/maahdima/mwp/api/http/schema"
 	"github.com/maahdima/mwp/api/service"
 
@@ -64,7 +68,14 @@ func (c *WgPeerController) GetNewPeerAllowedAddress(ctx echo.Context) error {
 		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
 	}
 
-	allowedAddress, err := c.peerService.GetNewPeerAllowedAddress(req.InterfaceId)
+	if forbidden, err := c.interfaceOutOfScope(ctx, req.InterfaceId); forbidden || err != nil {
+		if err != nil {
+			return ctx.JSON(http.StatusInternalServerError, schema.InternalServerErrorResponse)
+		}
+		return ctx.JSON(http.StatusForbidden, schema.ForbiddenErrorResponse)
+	}
+
+	allowedAddress, err := c.peerService.GetNewPeerAllowedAddress(req.InterfaceId, req.Name)
 	if err != nil {
 		c.logger.Error("failed to get wireguard peer allowed addresses", zap.Error(err))
 		return ctx.JSON(http.StatusInternalServerError, schema.ErrorResponse{
@@ -81,7 +92,15 @@ func (c *WgPeerController) GetNewPeerAllowedAddress(ctx echo.Context) error {
 }
 
 func (c *WgPeerController) GetPeers(ctx echo.Context) error {
-	peers, err := c.peerService.GetPeers()
+	server := middleware.GetServer(ctx)
+	peers, err := c.peerService.GetPeers(server)
+	if err == nil {
+		if scope := middleware.GetAccessScope(ctx); scope != nil {
+			var filtered []schema.PeerResponse
+			filtered, err = c.peerService.FilterPeersInScope(server.ID, *peers, *scope)
+			peers = &filtered
+		}
+	}
 	if err != nil {
 		c.logger.Error("failed to get wireguard peers", zap.Error(err))
 		return ctx.JSON(http.StatusInternalServerError, schema.ErrorResponse{
@@ -115,8 +134,23 @@ func (c *WgPeerController) CreatePeer(ctx echo.Context) error {
 		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
 	}
 
+	if forbidden, err := c.interfaceOutOfScope(ctx, req.InterfaceId); forbidden || err != nil {
+		if err != nil {
+			return ctx.JSON(http.StatusInternalServerError, schema.InternalServerErrorResponse)
+		}
+		return ctx.JSON(http.StatusForbidden, schema.ForbiddenErrorResponse)
+	}
+
 	peer, err := c.peerService.CreatePeer(&req)
 	if err != nil {
+		if errors.Is(err, common.ErrInvalidPeerPlan) || errors.Is(err, common.ErrInvalidClientConfig) {
+			return ctx.JSON(http.StatusBadRequest, schema.ErrorResponse{
+				StatusCode: http.StatusBadRequest,
+				Status:     "error",
+				Message:    err.Error(),
+			})
+		}
+
 		c.logger.Error("failed to create wireguard peer", zap.Error(err))
 		return ctx.JSON(http.StatusInternalServerError, schema.ErrorResponse{
 			StatusCode: http.StatusInternalServerError,
@@ -131,6 +165,43 @@ func (c *WgPeerController) CreatePeer(ctx echo.Context) error {
 	})
 }
 
+func (c *WgPeerController) BulkUpdatePeers(ctx echo.Context) error {
+	var req schema.BulkPeerRequest
+
+	if err := ctx.Bind(&req); err != nil {
+		c.logger.Warn("failed to bind request", zap.Error(err))
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	if err := ctx.Validate(&req); err != nil {
+		c.logger.Warn("failed to validate request", zap.Error(err))
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	result, err := c.peerService.BulkUpdatePeers(middleware.GetServer(ctx), &req, c.trafficCalculator, middleware.GetAccessScope(ctx))
+	if err != nil {
+		if errors.Is(err, common.ErrInvalidBulkRequest) {
+			return ctx.JSON(http.StatusBadRequest, schema.ErrorResponse{
+				StatusCode: http.StatusBadRequest,
+				Status:     "error",
+				Message:    err.Error(),
+			})
+		}
+
+		c.logger.Error("failed to run bulk peer action", zap.Error(err))
+		return ctx.JSON(http.StatusInternalServerError, schema.ErrorResponse{
+			StatusCode: http.StatusInternalServerError,
+			Status:     "error",
+			Message:    "failed to run bulk peer action: " + err.Error(),
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, schema.BasicResponseData[schema.BulkPeerResponse]{
+		BasicResponse: schema.OkBasicResponse,
+		Data:          *result,
+	})
+}
+
 func (c *WgPeerController) UpdatePeerStatus(ctx echo.Context) error {
 	id := ctx.Param("id")
 	if id == "" {
@@ -183,6 +254,14 @@ func (c *WgPeerController) UpdatePeer(ctx echo.Context) error {
 
 	peer, err := c.peerService.UpdatePeer(uint(peerId), &req)
 	if err != nil {
+		if errors.Is(err, common.ErrInvalidClientConfig) {
+			return ctx.JSON(http.StatusBadRequest, schema.ErrorResponse{
+				StatusCode: http.StatusBadRequest,
+				Status:     "error",
+				Message:    err.Error(),
+			})
+		}
+
 		c.logger.Error("failed to update wireguard peer", zap.Error(err))
 		return ctx.JSON(http.StatusInternalServerError, schema.ErrorResponse{
 			StatusCode: http.StatusInternalServerError,
@@ -256,7 +335,7 @@ func (c *WgPeerController) GetPeerConfig(ctx echo.Context) error {
 		})
 	}
 
-	return ctx.File(config)
+	return ctx.Blob(http.StatusOK, echo.MIMETextPlainCharsetUTF8, config)
 }
 
 func (c *WgPeerController) GetPeerQRCode(ctx echo.Context) error {
@@ -289,7 +368,7 @@ func (c *WgPeerController) GetPeerQRCode(ctx echo.Context) error {
 		})
 	}
 
-	return ctx.File(qrCode)
+	return ctx.Blob(http.StatusOK, "image/jpeg", qrCode)
 }
 
 func (c *WgPeerController) GetPeerShareStatus(ctx echo.Context) error {
@@ -417,6 +496,134 @@ func (c *WgPeerController) ResetPeerUsage(ctx echo.Context) error {
 	return ctx.JSON(http.StatusOK, schema.OkBasicResponse)
 }
 
+func (c *WgPeerController) RenewPeer(ctx echo.Context) error {
+	id := ctx.Param("id")
+	if id == "" {
+		c.logger.Error("Peer ID is required")
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	peerId, err := strconv.Atoi(id)
+	if err != nil {
+		c.logger.Error("Invalid peer ID", zap.Error(err))
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	var req schema.RenewPeerRequest
+	if err := ctx.Bind(&req); err != nil {
+		c.logger.Warn("failed to bind request", zap.Error(err))
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	if err := ctx.Validate(&req); err != nil {
+		c.logger.Warn("failed to validate request", zap.Error(err))
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	renewal, err := c.peerService.RenewPeer(uint(peerId), &req, c.trafficCalculator)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return ctx.JSON(http.StatusNotFound, schema.ErrorResponse{
+				StatusCode: http.StatusNotFound,
+				Status:     "error",
+				Message:    "peer not found",
+			})
+		case errors.Is(err, common.ErrInvalidRenewal):
+			return ctx.JSON(http.StatusBadRequest, schema.ErrorResponse{
+				StatusCode: http.StatusBadRequest,
+				Status:     "error",
+				Message:    err.Error(),
+			})
+		}
+
+		c.logger.Error("failed to renew wireguard peer", zap.Error(err))
+		return ctx.JSON(http.StatusInternalServerError, schema.ErrorResponse{
+			StatusCode: http.StatusInternalServerError,
+			Status:     "error",
+			Message:    "failed to renew wireguard peer: " + err.Error(),
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, schema.BasicResponseData[schema.PeerRenewalResponse]{
+		BasicResponse: schema.OkBasicResponse,
+		Data:          *renewal,
+	})
+}
+
+func (c *WgPeerController) GetPeerRenewals(ctx echo.Context) error {
+	id := ctx.Param("id")
+	if id == "" {
+		c.logger.Error("Peer ID is required")
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	peerId, err := strconv.Atoi(id)
+	if err != nil {
+		c.logger.Error("Invalid peer ID", zap.Error(err))
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	renewals, err := c.peerService.GetPeerRenewals(uint(peerId))
+	if err != nil {
+		c.logger.Error("failed to get wireguard peer renewals", zap.Error(err))
+		return ctx.JSON(http.StatusInternalServerError, schema.ErrorResponse{
+			StatusCode: http.StatusInternalServerError,
+			Status:     "error",
+			Message:    "failed to retrieve wireguard peer renewals: " + err.Error(),
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, schema.BasicResponseData[[]schema.PeerRenewalResponse]{
+		BasicResponse: schema.OkBasicResponse,
+		Data:          *renewals,
+	})
+}
+
+func (c *WgPeerController) GetPeerTrafficHistory(ctx echo.Context) error {
+	id := ctx.Param("id")
+	if id == "" {
+		c.logger.Error("Peer ID is required")
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	peerId, err := strconv.Atoi(id)
+	if err != nil {
+		c.logger.Error("Invalid peer ID", zap.Error(err))
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	history, err := c.peerService.GetPeerTrafficHistory(uint(peerId), ctx.QueryParam("from"), ctx.QueryParam("to"), ctx.QueryParam("granularity"))
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return ctx.JSON(http.StatusNotFound, schema.ErrorResponse{
+				StatusCode: http.StatusNotFound,
+				Status:     "error",
+				Message:    "peer not found",
+			})
+		case errors.Is(err, common.ErrInvalidTrafficQuery):
+			return ctx.JSON(http.StatusBadRequest, schema.ErrorResponse{
+				StatusCode: http.StatusBadRequest,
+				Status:     "error",
+				Message:    err.Error(),
+			})
+		}
+
+		c.logger.Error("failed to get wireguard peer traffic history", zap.Error(err))
+		return ctx.JSON(http.StatusInternalServerError, schema.ErrorResponse{
+			StatusCode: http.StatusInternalServerError,
+			Status:     "error",
+			Message:    "failed to retrieve wireguard peer traffic history: " + err.Error(),
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, schema.BasicResponseData[schema.PeerTrafficHistoryResponse]{
+		BasicResponse: schema.OkBasicResponse,
+		Data:          *history,
+	})
+}
+
 func (c *WgPeerController) ResetPeerUsages(ctx echo.Context) error {
 	err := c.trafficCalculator.ResetPeerUsages()
 	if err != nil {
@@ -444,3 +651,93 @@ func (c *WgPeerController) ExportPeersTrafficData(ctx echo.Context) error {
 
 	return ctx.File(filePath)
 }
+
+func (c *WgPeerController) ImportPeers(ctx echo.Context) error {
+	ctx.Request().Body = http.MaxBytesReader(ctx.Response(), ctx.Request().Body, common.ImportMaxFileSize)
+
+	fileHeader, err := ctx.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.logger.Warn("import file is too large", zap.Int64("limit", tooLarge.Limit))
+			return ctx.JSON(http.StatusRequestEntityTooLarge, schema.ErrorResponse{
+				StatusCode: http.StatusRequestEntityTooLarge,
+				Status:     "error",
+				Message:    fmt.Sprintf("import file is larger than %d MB", common.ImportMaxFileSize>>20),
+			})
+		}
+		c.logger.Warn("import file is missing", zap.Error(err))
+		return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+	}
+
+	dryRun := false
+	if value := ctx.FormValue("dry_run"); value != "" {
+		dryRun, err = strconv.ParseBool(value)
+		if err != nil {
+			c.logger.Warn("invalid dry_run value", zap.String("dry_run", value))
+			return ctx.JSON(http.StatusBadRequest, schema.BadParamsErrorResponse)
+		}
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.logger.Error("failed to open import file", zap.Error(err))
+		return ctx.JSON(http.StatusInternalServerError, schema.ErrorResponse{
+			StatusCode: http.StatusInternalServerError,
+			Status:     "error",
+			Message:    "failed to read import file: " + err.Error(),
+		})
+	}
+	defer file.Close()
+
+	rows, err := c.excelGeneratorService.ReadRows(fileHeader.Filename, file)
+	if err != nil {
+		return c.importPeersError(ctx, fileHeader.Filename, err)
+	}
+
+	result, err := c.peerService.ImportPeers(middleware.GetServer(ctx), rows, strings.TrimSpace(ctx.FormValue("endpoint")), dryRun, middleware.GetAccessScope(ctx))
+	if err != nil {
+		return c.importPeersError(ctx, fileHeader.Filename, err)
+	}
+
+	return ctx.JSON(http.StatusOK, schema.BasicResponseData[schema.ImportPeersResponse]{
+		BasicResponse: schema.OkBasicResponse,
+		Data:          *result,
+	})
+}
+
+func (c *WgPeerController) importPeersError(ctx echo.Context, fileName string, err error) error {
+	if errors.Is(err, common.ErrInvalidImportFile) {
+		c.logger.Warn("invalid import file", zap.String("file", fileName), zap.Error(err))
+		return ctx.JSON(http.StatusBadRequest, schema.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Status:     "error",
+			Message:    err.Error(),
+		})
+	}
+
+	c.logger.Error("failed to import wireguard peers", zap.Error(err))
+	return ctx.JSON(http.StatusInternalServerError, schema.ErrorResponse{
+		StatusCode: http.StatusInternalServerError,
+		Status:     "error",
+		Message:    "failed to import wireguard peers: " + err.Error(),
+	})
+}
+
+// interfaceOutOfScope reports whether a reseller targets an interface outside its scope
+func (c *WgPeerController) interfaceOutOfScope(ctx echo.Context, interfaceID uint) (bool, error) {
+	scope := middleware.GetAccessScope(ctx)
+	if scope == nil {
+		return false, nil
+	}
+
+	allowed, err := c.peerService.InterfaceInScope(interfaceID, *scope)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return !allowed, nil
+}
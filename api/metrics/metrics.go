@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+const namespace = "mwp"
+
+// Outcomes of a router API request
+const (
+	OutcomeSuccess = "success"
+	OutcomeError   = "error"
+)
+
+// Registry holds every series exposed on /metrics
+var Registry = prometheus.NewRegistry()
+
+var (
+	RouterRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "router_request_duration_seconds",
+		Help:      "Latency of Mikrotik REST API requests, labeled by outcome so failed requests are counted as well.",
+		Buckets:   []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	}, []string{"router", "method", "endpoint", "outcome"})
+
+	TrafficJobDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "traffic_job_duration_seconds",
+		Help:      "Duration of the peer traffic calculation job.",
+		Buckets:   []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+	})
+
+	TrafficJobLastSuccess = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "traffic_job_last_success_timestamp_seconds",
+		Help:      "Unix time of the last peer traffic calculation that read every router without an error.",
+	})
+
+	SyncDriftItems = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "sync_drift_items",
+		Help:      "Drift found by the last reconciliation per server, object and state (healed, pending or failed).",
+	}, []string{"server", "object", "state"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		RouterRequestDuration,
+		TrafficJobDuration,
+		TrafficJobLastSuccess,
+		SyncDriftItems,
+	)
+}
+
+// ObserveRouterRequest records one router API request. Item IDs ("*1A") in the path are replaced by ":id" to keep
+// the number of series bounded.
+func ObserveRouterRequest(router, method, path string, duration time.Duration, err error) {
+	outcome := OutcomeSuccess
+	if err != nil {
+		outcome = OutcomeError
+	}
+
+	RouterRequestDuration.WithLabelValues(router, method, endpointLabel(path), outcome).Observe(duration.Seconds())
+}
+
+func endpointLabel(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, "*") {
+			segments[i] = ":id"
+		}
+	}
+	return strings.Join(segments, "/")
+}
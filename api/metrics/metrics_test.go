@@ -0,0 +1,16 @@
+package metrics
+
+import "testing"
+
+func TestEndpointLabel(t *testing.T) {
+	cases := map[string]string{
+		"/interface/wireguard/peers":     "/interface/wireguard/peers",
+		"/interface/wireguard/peers/*1A": "/interface/wireguard/peers/:id",
+		"/queue/simple/*2B/extra":        "/queue/simple/:id/extra",
+	}
+	for path, want := range cases {
+		if got := endpointLabel(path); got != want {
+			t.Fatalf("endpointLabel(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/dataservice/model"
+	"github.com/maahdima/mwp/api/http/schema"
+)
+
+type Admin struct {
+	db     *gorm.DB
+	logger *zap.Logger
+}
+
+func NewAdmin(db *gorm.DB) *Admin {
+	return &Admin{
+		db:     db,
+		logger: zap.L().Named("AdminService"),
+	}
+}
+
+func (a *Admin) GetAdmins() (*[]schema.AdminResponse, error) {
+	var admins []model.Admin
+	if err := a.db.Preload("Scopes").Order("id").Find(&admins).Error; err != nil {
+		a.logger.Error("failed to get admins", zap.Error(err))
+		return nil, err
+	}
+
+	resp := make([]schema.AdminResponse, 0, len(admins))
+	for _, admin := range admins {
+		resp = append(resp, transformAdminToResponse(admin))
+	}
+
+	return &resp, nil
+}
+
+func (a *Admin) CreateAdmin(req *schema.CreateAdminRequest) (*schema.AdminResponse, error) {
+	if err := a.validateScope(req.Role, req.Scope); err != nil {
+		return nil, err
+	}
+
+	var taken int64
+	if err := a.db.Model(&model.Admin{}).Where("username = ?", req.Username).Count(&taken).Error; err != nil {
+		a.logger.Error("failed to check username", zap.Error(err))
+		return nil, err
+	}
+	if taken > 0 {
+		return nil, fmt.Errorf("%w: username %q is already in use", common.ErrInvalidAdmin, req.Username)
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		a.logger.Error("failed to hash password", zap.Error(err))
+		return nil, err
+	}
+
+	admin := model.Admin{
+		Username: req.Username,
+		Password: string(hashedPassword),
+		Role:     req.Role,
+		IsActive: req.IsActive == nil || *req.IsActive,
+		Scopes:   adminScopeRows(req.Role, req.Scope),
+	}
+
+	err = a.db.Transaction(func(tx *gorm.DB) error {
+		// IsActive defaults to true in the database, a zero value would be skipped on insert
+		if err := tx.Create(&admin).Error; err != nil {
+			return err
+		}
+		return tx.Model(&admin).Update("is_active", admin.IsActive).Error
+	})
+	if err != nil {
+		a.logger.Error("failed to create admin", zap.Error(err))
+		return nil, err
+	}
+
+	a.logger.Info("admin created", zap.String("username", admin.Username), zap.String("role", admin.Role))
+
+	resp := transformAdminToResponse(admin)
+	return &resp, nil
+}
+
+// UpdateAdmin changes an admin account. Any change to its credentials, role, scope or status ends its sessions so the
+// new rules apply from the next login.
+func (a *Admin) UpdateAdmin(id uint, req *schema.UpdateAdminRequest) (*schema.AdminResponse, error) {
+	var admin model.Admin
+	if err := a.db.Preload("Scopes").First(&admin, id).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			a.logger.Error("failed to find admin", zap.Uint("id", id), zap.Error(err))
+		}
+		return nil, err
+	}
+
+	role := admin.Role
+	if req.Role != nil {
+		role = *req.Role
+	}
+	scope := transformAdminScope(admin.Scopes)
+	if req.Scope != nil {
+		scope = req.Scope
+	}
+	if err := a.validateScope(role, scope); err != nil {
+		return nil, err
+	}
+
+	if req.Username != nil && *req.Username != admin.Username {
+		var taken int64
+		if err := a.db.Model(&model.Admin{}).Where("username = ? AND id <> ?", *req.Username, admin.ID).Count(&taken).Error; err != nil {
+			a.logger.Error("failed to check username", zap.Error(err))
+			return nil, err
+		}
+		if taken > 0 {
+			return nil, fmt.Errorf("%w: username %q is already in use", common.ErrInvalidAdmin, *req.Username)
+		}
+		admin.Username = *req.Username
+	}
+
+	if req.Password != nil {
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(*req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			a.logger.Error("failed to hash password", zap.Error(err))
+			return nil, err
+		}
+		admin.Password = string(hashedPassword)
+	}
+
+	isActive := admin.IsActive
+	if req.IsActive != nil {
+		isActive = *req.IsActive
+	}
+
+	if req.ResetTwoFactor {
+		admin.TOTPSecret = ""
+		admin.TOTPEnabled = false
+		admin.TOTPLastStep = 0
+	}
+
+	err := a.db.Transaction(func(tx *gorm.DB) error {
+		if admin.Role == model.AdminRoleOwner && admin.IsActive && (role != model.AdminRoleOwner || !isActive) {
+			if err := ensureAnotherOwner(tx, admin.ID); err != nil {
+				return err
+			}
+		}
+
+		admin.Role = role
+		admin.IsActive = isActive
+		err := tx.Model(&admin).
+			Select("username", "password", "role", "is_active", "totp_secret", "totp_enabled", "totp_last_step").
+			Omit(clause.Associations).
+			Updates(&admin).Error
+		if err != nil {
+			return err
+		}
+
+		if req.ResetTwoFactor {
+			if err := tx.Unscoped().Where("admin_id = ?", admin.ID).Delete(&model.AdminRecoveryCode{}).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Unscoped().Where("admin_id = ?", admin.ID).Delete(&model.AdminScope{}).Error; err != nil {
+			return err
+		}
+		admin.Scopes = adminScopeRows(role, scope)
+		for i := range admin.Scopes {
+			admin.Scopes[i].AdminID = admin.ID
+		}
+		if len(admin.Scopes) > 0 {
+			if err := tx.Create(&admin.Scopes).Error; err != nil {
+				return err
+			}
+		}
+
+		return revokeAdminSessions(tx, admin.ID)
+	})
+	if err != nil {
+		if !errors.Is(err, common.ErrLastOwner) {
+			a.logger.Error("failed to update admin", zap.Uint("id", id), zap.Error(err))
+		}
+		return nil, err
+	}
+
+	a.logger.Info("admin updated", zap.String("username", admin.Username), zap.String("role", admin.Role), zap.Bool("isActive", admin.IsActive))
+
+	resp := transformAdminToResponse(admin)
+	return &resp, nil
+}
+
+// DeleteAdmin removes an admin account, the caller cannot remove itself nor the last active owner
+func (a *Admin) DeleteAdmin(id uint, callerUsername string) error {
+	var admin model.Admin
+	if err := a.db.First(&admin, id).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			a.logger.Error("failed to find admin", zap.Uint("id", id), zap.Error(err))
+		}
+		return err
+	}
+
+	if admin.Username == callerUsername {
+		return fmt.Errorf("%w: you cannot delete your own account", common.ErrInvalidAdmin)
+	}
+
+	err := a.db.Transaction(func(tx *gorm.DB) error {
+		if admin.Role == model.AdminRoleOwner && admin.IsActive {
+			if err := ensureAnotherOwner(tx, admin.ID); err != nil {
+				return err
+			}
+		}
+		if err := tx.Unscoped().Where("admin_id = ?", admin.ID).Delete(&model.AdminScope{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("admin_id = ?", admin.ID).Delete(&model.AdminSession{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("admin_id = ?", admin.ID).Delete(&model.APIToken{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("admin_id = ?", admin.ID).Delete(&model.AdminRecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&admin).Error
+	})
+	if err != nil {
+		if !errors.Is(err, common.ErrLastOwner) {
+			a.logger.Error("failed to delete admin", zap.Uint("id", id), zap.Error(err))
+		}
+		return err
+	}
+
+	a.logger.Info("admin deleted", zap.String("username", admin.Username))
+
+	return nil
+}
+
+// validateScope checks that resellers get at least one existing server or interface and other roles get none
+func (a *Admin) validateScope(role string, scope schema.AccessScope) error {
+	if role != model.AdminRoleReseller {
+		if len(scope) > 0 {
+			return fmt.Errorf("%w: only resellers have a scope", common.ErrInvalidAdmin)
+		}
+		return nil
+	}
+
+	if len(scope) == 0 {
+		return fmt.Errorf("%w: a reseller needs at least one server or interface in its scope", common.ErrInvalidAdmin)
+	}
+
+	for _, entry := range scope {
+		var server model.Server
+		if err := a.db.First(&server, entry.ServerId).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: server %d not found", common.ErrInvalidAdmin, entry.ServerId)
+			}
+			return err
+		}
+
+		if entry.InterfaceId == nil {
+			continue
+		}
+
+		var iface model.Interface
+		if err := a.db.First(&iface, "id = ? AND server_id = ?", *entry.InterfaceId, entry.ServerId).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: interface %d not found on server %s", common.ErrInvalidAdmin, *entry.InterfaceId, server.Name)
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+func ensureAnotherOwner(db *gorm.DB, adminID uint) error {
+	var owners int64
+	err := db.Model(&model.Admin{}).
+		Where("role = ? AND is_active = ? AND id <> ?", model.AdminRoleOwner, true, adminID).
+		Count(&owners).Error
+	if err != nil {
+		return err
+	}
+	if owners == 0 {
+		return common.ErrLastOwner
+	}
+	return nil
+}
+
+func adminScopeRows(role string, scope schema.AccessScope) []model.AdminScope {
+	if role != model.AdminRoleReseller {
+		return nil
+	}
+
+	rows := make([]model.AdminScope, 0, len(scope))
+	for _, entry := range scope {
+		rows = append(rows, model.AdminScope{
+			ServerID:    entry.ServerId,
+			InterfaceID: entry.InterfaceId,
+		})
+	}
+	return rows
+}
+
+func transformAdminScope(scopes []model.AdminScope) schema.AccessScope {
+	scope := make(schema.AccessScope, 0, len(scopes))
+	for _, row := range scopes {
+		scope = append(scope, schema.AdminScopeEntry{
+			ServerId:    row.ServerID,
+			InterfaceId: row.InterfaceID,
+		})
+	}
+	return scope
+}
+
+func transformAdminToResponse(admin model.Admin) schema.AdminResponse {
+	return schema.AdminResponse{
+		Id:        admin.ID,
+		Username:  admin.Username,
+		Role:      admin.Role,
+		IsActive:  admin.IsActive,
+		TwoFactor: admin.TOTPEnabled,
+		Scope:     transformAdminScope(admin.Scopes),
+		CreatedAt: time.Unix(int64(admin.CreatedAt), 0).Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/dataservice/model"
+	"github.com/maahdima/mwp/api/http/schema"
+)
+
+func TestLastOwnerCannotBeRemoved(t *testing.T) {
+	env := newTestEnv(t)
+	admins := NewAdmin(env.db)
+
+	owner, err := admins.CreateAdmin(&schema.CreateAdminRequest{Username: "owner", Password: "secret", Role: model.AdminRoleOwner})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	operator := model.AdminRoleOperator
+	if _, err := admins.UpdateAdmin(owner.Id, &schema.UpdateAdminRequest{Role: &operator}); !errors.Is(err, common.ErrLastOwner) {
+		t.Fatalf("demoting the last owner got %v, want ErrLastOwner", err)
+	}
+	inactive := false
+	if _, err := admins.UpdateAdmin(owner.Id, &schema.UpdateAdminRequest{IsActive: &inactive}); !errors.Is(err, common.ErrLastOwner) {
+		t.Fatalf("deactivating the last owner got %v, want ErrLastOwner", err)
+	}
+	if err := admins.DeleteAdmin(owner.Id, "someone"); !errors.Is(err, common.ErrLastOwner) {
+		t.Fatalf("deleting the last owner got %v, want ErrLastOwner", err)
+	}
+
+	if _, err := admins.CreateAdmin(&schema.CreateAdminRequest{Username: "second", Password: "secret", Role: model.AdminRoleOwner}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := admins.UpdateAdmin(owner.Id, &schema.UpdateAdminRequest{Role: &operator}); err != nil {
+		t.Fatalf("demoting an owner while another one remains: %v", err)
+	}
+}
+
+func TestResellerNeedsScope(t *testing.T) {
+	env := newTestEnv(t)
+	admins := NewAdmin(env.db)
+
+	requests := []*schema.CreateAdminRequest{
+		{Username: "reseller", Password: "secret", Role: model.AdminRoleReseller},
+		{Username: "reseller", Password: "secret", Role: model.AdminRoleReseller, Scope: schema.AccessScope{{ServerId: env.server.ID + 1}}},
+		{Username: "operator", Password: "secret", Role: model.AdminRoleOperator, Scope: schema.AccessScope{{ServerId: env.server.ID}}},
+	}
+	for _, req := range requests {
+		if _, err := admins.CreateAdmin(req); !errors.Is(err, common.ErrInvalidAdmin) {
+			t.Fatalf("creating %s with scope %+v got %v, want ErrInvalidAdmin", req.Role, req.Scope, err)
+		}
+	}
+
+	resp, err := admins.CreateAdmin(&schema.CreateAdminRequest{
+		Username: "reseller",
+		Password: "secret",
+		Role:     model.AdminRoleReseller,
+		Scope:    schema.AccessScope{{ServerId: env.server.ID}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Scope) != 1 || resp.Scope[0].ServerId != env.server.ID {
+		t.Fatalf("reseller scope %+v, want the whole server", resp.Scope)
+	}
+}
+
+func TestBulkUpdatePeersStaysInScope(t *testing.T) {
+	env := newTestEnv(t)
+	wg0 := env.seedInterface(t, "wg0")
+	wg1 := env.seedInterface(t, "wg1")
+	peers := env.peerService()
+
+	inside, err := peers.CreatePeer(newCreatePeerRequest(t, wg0, "alice", "10.0.0.2/32"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := peers.CreatePeer(newCreatePeerRequest(t, wg1, "bob", "10.1.0.2/32")); err != nil {
+		t.Fatal(err)
+	}
+
+	scope := schema.AccessScope{{ServerId: env.server.ID, InterfaceId: &wg0.ID}}
+	resp, err := peers.BulkUpdatePeers(env.server, &schema.BulkPeerRequest{All: true, Action: schema.BulkDisablePeers}, nil, &scope)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Total != 1 || resp.Results[0].Id != inside.Id {
+		t.Fatalf("bulk response %+v, want only the peer of the scoped interface", resp)
+	}
+}
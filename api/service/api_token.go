@@ -0,0 +1,204 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/dataservice/model"
+	"github.com/maahdima/mwp/api/http/schema"
+)
+
+// APITokenPrefix starts every API token, it tells them apart from the JWT access tokens
+const APITokenPrefix = "mwp_"
+
+// lastUsedResolution bounds how often a busy token writes its last-used time
+const lastUsedResolution = time.Minute
+
+// GetAPITokens lists the tokens of an admin, revoked ones included
+func (a *Authentication) GetAPITokens(username string) (*[]schema.APITokenResponse, error) {
+	admin, err := a.findAdmin(username)
+	if err != nil {
+		return nil, err
+	}
+
+	var tokens []model.APIToken
+	if err := a.db.Where("admin_id = ?", admin.ID).Order("id").Find(&tokens).Error; err != nil {
+		a.logger.Error("failed to get api tokens", zap.Error(err))
+		return nil, err
+	}
+
+	resp := make([]schema.APITokenResponse, 0, len(tokens))
+	for _, token := range tokens {
+		resp = append(resp, transformAPITokenToResponse(token))
+	}
+
+	return &resp, nil
+}
+
+// CreateAPIToken issues a token for an admin. The secret is part of the response and cannot be retrieved later.
+func (a *Authentication) CreateAPIToken(username string, req *schema.CreateAPITokenRequest) (*schema.APITokenResponse, error) {
+	admin, err := a.findAdmin(username)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		a.logger.Error("failed to generate api token", zap.Error(err))
+		return nil, err
+	}
+	raw := APITokenPrefix + hex.EncodeToString(secret)
+
+	scopes := slices.Compact(slices.Sorted(slices.Values(req.Scopes)))
+	token := model.APIToken{
+		AdminID:   admin.ID,
+		Name:      req.Name,
+		Prefix:    raw[:len(APITokenPrefix)+8],
+		TokenHash: sha256Hex(raw),
+		Scopes:    strings.Join(scopes, ","),
+	}
+	if req.ExpiresInDays != nil {
+		expiresAt := time.Now().AddDate(0, 0, *req.ExpiresInDays).Unix()
+		token.ExpiresAt = &expiresAt
+	}
+
+	if err := a.db.Create(&token).Error; err != nil {
+		a.logger.Error("failed to create api token", zap.Error(err))
+		return nil, err
+	}
+
+	a.logger.Info("api token created", zap.String("username", admin.Username), zap.String("name", token.Name), zap.Strings("scopes", scopes))
+
+	resp := transformAPITokenToResponse(token)
+	resp.Token = raw
+	return &resp, nil
+}
+
+// RevokeAPIToken revokes one of the tokens of an admin
+func (a *Authentication) RevokeAPIToken(username string, id uint) error {
+	admin, err := a.findAdmin(username)
+	if err != nil {
+		return err
+	}
+
+	var token model.APIToken
+	if err := a.db.Where("id = ? AND admin_id = ?", id, admin.ID).First(&token).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			a.logger.Error("failed to find api token", zap.Uint("id", id), zap.Error(err))
+		}
+		return err
+	}
+	if token.RevokedAt != nil {
+		return fmt.Errorf("%w: token is already revoked", common.ErrInvalidAPIToken)
+	}
+
+	if err := a.db.Model(&token).Update("revoked_at", time.Now().Unix()).Error; err != nil {
+		a.logger.Error("failed to revoke api token", zap.Uint("id", id), zap.Error(err))
+		return err
+	}
+
+	a.logger.Info("api token revoked", zap.String("username", admin.Username), zap.String("name", token.Name))
+
+	return nil
+}
+
+// parseAPIToken resolves an mwp_ token to the claims of its admin, the token scopes narrow them further
+func (a *Authentication) parseAPIToken(raw string) (*jwt.Token, error) {
+	var token model.APIToken
+	err := a.db.Where("token_hash = ? AND revoked_at IS NULL", sha256Hex(raw)).First(&token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, common.ErrInvalidToken
+		}
+		a.logger.Error("failed to fetch api token", zap.Error(err))
+		return nil, err
+	}
+
+	now := time.Now()
+	if token.ExpiresAt != nil && *token.ExpiresAt <= now.Unix() {
+		return nil, common.ErrInvalidToken
+	}
+
+	var admin model.Admin
+	if err := a.db.First(&admin, token.AdminID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, common.ErrInvalidToken
+		}
+		return nil, err
+	}
+	if !admin.IsActive {
+		return nil, common.ErrInvalidToken
+	}
+
+	scope, err := a.adminAccessScope(admin)
+	if err != nil {
+		return nil, err
+	}
+
+	if token.LastUsedAt == nil || now.Sub(time.Unix(*token.LastUsedAt, 0)) >= lastUsedResolution {
+		if err := a.db.Model(&token).UpdateColumn("last_used_at", now.Unix()).Error; err != nil {
+			a.logger.Warn("failed to record api token use", zap.Uint("id", token.ID), zap.Error(err))
+		}
+	}
+
+	claims := &schema.TokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject: admin.Username,
+			ID:      fmt.Sprintf("token-%d", token.ID),
+		},
+		Role:        admin.Role,
+		Scope:       scope,
+		TokenScopes: strings.Split(token.Scopes, ","),
+	}
+
+	return &jwt.Token{Claims: claims, Valid: true}, nil
+}
+
+func (a *Authentication) findAdmin(username string) (model.Admin, error) {
+	var admin model.Admin
+	if err := a.db.Where("username = ?", username).First(&admin).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			a.logger.Error("failed to find admin", zap.String("username", username), zap.Error(err))
+		}
+		return admin, err
+	}
+
+	return admin, nil
+}
+
+func sha256Hex(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+func transformAPITokenToResponse(token model.APIToken) schema.APITokenResponse {
+	return schema.APITokenResponse{
+		Id:         token.ID,
+		Name:       token.Name,
+		Prefix:     token.Prefix,
+		Scopes:     strings.Split(token.Scopes, ","),
+		ExpiresAt:  formatOptionalUnix(token.ExpiresAt),
+		LastUsedAt: formatOptionalUnix(token.LastUsedAt),
+		RevokedAt:  formatOptionalUnix(token.RevokedAt),
+		CreatedAt:  time.Unix(int64(token.CreatedAt), 0).Format(time.RFC3339),
+	}
+}
+
+func formatOptionalUnix(ts *int64) *string {
+	if ts == nil {
+		return nil
+	}
+	formatted := time.Unix(*ts, 0).Format(time.RFC3339)
+	return &formatted
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/dataservice/model"
+	"github.com/maahdima/mwp/api/http/schema"
+)
+
+func TestAPITokenAuthenticatesUntilRevoked(t *testing.T) {
+	env := newTestEnv(t)
+	auth := env.authService(t)
+
+	created, err := auth.CreateAPIToken("admin", &schema.CreateAPITokenRequest{
+		Name:   "ci",
+		Scopes: []string{model.APITokenScopeRead, model.APITokenScopePeersWrite, model.APITokenScopeRead},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := auth.ParseAccessToken(nil, created.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := parsed.(*jwt.Token).Claims.(*schema.TokenClaims)
+	if claims.Subject != "admin" || !claims.IsAPIToken() || len(claims.TokenScopes) != 2 {
+		t.Fatalf("claims %+v, want the admin with the read and peers:write scopes", claims)
+	}
+
+	var token model.APIToken
+	if err := env.db.First(&token, created.Id).Error; err != nil {
+		t.Fatal(err)
+	}
+	if token.TokenHash == created.Token || token.LastUsedAt == nil {
+		t.Fatalf("token row %+v stores the secret or misses its last use", token)
+	}
+
+	if err := auth.RevokeAPIToken("admin", created.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.ParseAccessToken(nil, created.Token); !errors.Is(err, common.ErrInvalidToken) {
+		t.Fatalf("revoked token got %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestAPITokenRejectedWhenExpiredOrAdminInactive(t *testing.T) {
+	env := newTestEnv(t)
+	auth := env.authService(t)
+
+	expired, err := auth.CreateAPIToken("admin", &schema.CreateAPITokenRequest{Name: "old", Scopes: []string{model.APITokenScopeRead}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := env.db.Model(&model.APIToken{}).Where("id = ?", expired.Id).Update("expires_at", 1).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.ParseAccessToken(nil, expired.Token); !errors.Is(err, common.ErrInvalidToken) {
+		t.Fatalf("expired token got %v, want ErrInvalidToken", err)
+	}
+
+	active, err := auth.CreateAPIToken("admin", &schema.CreateAPITokenRequest{Name: "ci", Scopes: []string{model.APITokenScopeRead}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := env.db.Model(&model.Admin{}).Where("username = ?", "admin").Update("is_active", false).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.ParseAccessToken(nil, active.Token); !errors.Is(err, common.ErrInvalidToken) {
+		t.Fatalf("token of an inactive admin got %v, want ErrInvalidToken", err)
+	}
+}
@@ -0,0 +1,281 @@
+package service
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/dataservice/model"
+	"github.com/maahdima/mwp/api/http/schema"
+)
+
+const (
+	defaultAuditPageSize = 50
+	maxAuditExportRows   = 100000
+)
+
+// auditTargets maps the target types of the audit log to the table their snapshots are read from
+var auditTargets = map[string]interface{}{
+	"server":    &model.Server{},
+	"interface": &model.Interface{},
+	"ip-pool":   &model.IPPool{},
+	"peer":      &model.Peer{},
+	"plan":      &model.PeerPlan{},
+	"admin":     &model.Admin{},
+	"api-token": &model.APIToken{},
+}
+
+// redactedAuditKeys are never written to the audit log, keys are compared lowercased without '_' and '-'
+var redactedAuditKeys = map[string]bool{
+	"password":       true,
+	"oldpassword":    true,
+	"newpassword":    true,
+	"privatekey":     true,
+	"presharedkey":   true,
+	"totpsecret":     true,
+	"secret":         true,
+	"tokenhash":      true,
+	"token":          true,
+	"accesstoken":    true,
+	"refreshtoken":   true,
+	"challengetoken": true,
+	"code":           true,
+	"recoverycodes":  true,
+	"qrcode":         true,
+	"uri":            true,
+}
+
+type Audit struct {
+	db     *gorm.DB
+	logger *zap.Logger
+}
+
+func NewAudit(db *gorm.DB) *Audit {
+	return &Audit{
+		db:     db,
+		logger: zap.L().Named("AuditService"),
+	}
+}
+
+// Snapshot returns the columns of a target row, nil when the type is not backed by a table or the row is gone
+func (a *Audit) Snapshot(targetType, targetID string) any {
+	table, ok := auditTargets[targetType]
+	if !ok || targetID == "" {
+		return nil
+	}
+
+	row := map[string]interface{}{}
+	if err := a.db.Model(table).Where("id = ?", targetID).Take(&row).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			a.logger.Warn("failed to snapshot audit target", zap.String("type", targetType), zap.String("id", targetID), zap.Error(err))
+		}
+		return nil
+	}
+	delete(row, "deleted_at")
+
+	return row
+}
+
+// Record stores an event with the redacted before and after states of its target
+func (a *Audit) Record(event model.AuditEvent, before, after any) {
+	event.CreatedAt = uint64(time.Now().Unix())
+	event.Actor = truncate(event.Actor, 64)
+	event.TargetID = truncate(event.TargetID, 64)
+	event.Before = marshalAuditState(before)
+	event.After = marshalAuditState(after)
+
+	if err := a.db.Create(&event).Error; err != nil {
+		a.logger.Error("failed to record audit event", zap.String("action", event.Action), zap.Error(err))
+	}
+}
+
+func (a *Audit) GetAuditEvents(query *schema.AuditQuery) (*schema.AuditEventsResponse, error) {
+	tx, err := a.filterAuditEvents(query)
+	if err != nil {
+		return nil, err
+	}
+
+	var total int64
+	if err := tx.Count(&total).Error; err != nil {
+		a.logger.Error("failed to count audit events", zap.Error(err))
+		return nil, err
+	}
+
+	page := max(query.Page, 1)
+	pageSize := query.PageSize
+	if pageSize == 0 {
+		pageSize = defaultAuditPageSize
+	}
+
+	var events []model.AuditEvent
+	err = tx.Order("created_at DESC, id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&events).Error
+	if err != nil {
+		a.logger.Error("failed to get audit events", zap.Error(err))
+		return nil, err
+	}
+
+	resp := &schema.AuditEventsResponse{
+		Events:   make([]schema.AuditEventResponse, 0, len(events)),
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	}
+	for _, event := range events {
+		resp.Events = append(resp.Events, transformAuditEventToResponse(event))
+	}
+
+	return resp, nil
+}
+
+// ExportAuditEvents writes the events matching query as CSV, newest first
+func (a *Audit) ExportAuditEvents(query *schema.AuditQuery, w io.Writer) error {
+	tx, err := a.filterAuditEvents(query)
+	if err != nil {
+		return err
+	}
+
+	var events []model.AuditEvent
+	if err := tx.Order("created_at DESC, id DESC").Limit(maxAuditExportRows).Find(&events).Error; err != nil {
+		a.logger.Error("failed to get audit events", zap.Error(err))
+		return err
+	}
+
+	writer := csv.NewWriter(w)
+	_ = writer.Write([]string{"id", "time", "actor", "action", "target_type", "target_id", "status_code", "source_ip", "before", "after"})
+	for _, event := range events {
+		_ = writer.Write([]string{
+			strconv.FormatUint(uint64(event.ID), 10),
+			time.Unix(int64(event.CreatedAt), 0).Format(time.RFC3339),
+			event.Actor,
+			event.Action,
+			event.TargetType,
+			event.TargetID,
+			strconv.Itoa(event.StatusCode),
+			event.SourceIP,
+			event.Before,
+			event.After,
+		})
+	}
+	writer.Flush()
+
+	return writer.Error()
+}
+
+func (a *Audit) filterAuditEvents(query *schema.AuditQuery) (*gorm.DB, error) {
+	tx := a.db.Model(&model.AuditEvent{})
+
+	if query.Actor != "" {
+		tx = tx.Where("actor = ?", query.Actor)
+	}
+	if query.Action != "" {
+		tx = tx.Where("action = ?", query.Action)
+	}
+	if query.TargetType != "" {
+		tx = tx.Where("target_type = ?", query.TargetType)
+	}
+	if query.TargetId != "" {
+		tx = tx.Where("target_id = ?", query.TargetId)
+	}
+	switch query.Outcome {
+	case "success":
+		tx = tx.Where("status_code < ?", 400)
+	case "failure":
+		tx = tx.Where("status_code >= ?", 400)
+	}
+
+	if query.From != "" {
+		from, _, err := parseTrafficTime(query.From)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid from %q, expected RFC3339 or YYYY-MM-DD", common.ErrInvalidAuditQuery, query.From)
+		}
+		tx = tx.Where("created_at >= ?", from.Unix())
+	}
+	if query.To != "" {
+		to, isDate, err := parseTrafficTime(query.To)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid to %q, expected RFC3339 or YYYY-MM-DD", common.ErrInvalidAuditQuery, query.To)
+		}
+		if isDate {
+			to = to.AddDate(0, 0, 1)
+		}
+		tx = tx.Where("created_at < ?", to.Unix())
+	}
+
+	return tx, nil
+}
+
+func marshalAuditState(state any) string {
+	if state == nil {
+		return ""
+	}
+
+	// round trip through JSON so structs and maps are redacted alike
+	raw, err := json.Marshal(state)
+	if err != nil {
+		return ""
+	}
+	var generic interface{}
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return ""
+	}
+
+	redacted, err := json.Marshal(redactAuditValue(generic))
+	if err != nil {
+		return ""
+	}
+	return string(redacted)
+}
+
+func redactAuditValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			normalized := strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(key))
+			if redactedAuditKeys[normalized] {
+				if item != nil && item != "" {
+					v[key] = "[redacted]"
+				}
+				continue
+			}
+			v[key] = redactAuditValue(item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactAuditValue(item)
+		}
+		return v
+	default:
+		return value
+	}
+}
+
+func transformAuditEventToResponse(event model.AuditEvent) schema.AuditEventResponse {
+	resp := schema.AuditEventResponse{
+		Id:         event.ID,
+		Time:       time.Unix(int64(event.CreatedAt), 0).Format(time.RFC3339),
+		Actor:      event.Actor,
+		Action:     event.Action,
+		TargetType: event.TargetType,
+		TargetId:   event.TargetID,
+		SourceIP:   event.SourceIP,
+		StatusCode: event.StatusCode,
+	}
+	if event.Before != "" {
+		resp.Before = json.RawMessage(event.Before)
+	}
+	if event.After != "" {
+		resp.After = json.RawMessage(event.After)
+	}
+
+	return resp
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/dataservice/model"
+	"github.com/maahdima/mwp/api/http/schema"
+)
+
+func TestAuditRecordRedactsSecrets(t *testing.T) {
+	env := newTestEnv(t)
+	audit := NewAudit(env.db)
+
+	audit.Record(model.AuditEvent{Actor: "admin", Action: "update_admin", TargetType: "admin", StatusCode: 200},
+		map[string]interface{}{"username": "bob", "password": "old-hash"},
+		map[string]interface{}{"username": "bob", "Password": "new-hash", "scopes": []interface{}{map[string]interface{}{"private_key": "k"}}})
+
+	var event model.AuditEvent
+	if err := env.db.First(&event).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"old-hash", "new-hash", `"k"`} {
+		if strings.Contains(event.Before+event.After, secret) {
+			t.Fatalf("audit event kept %s: before %s, after %s", secret, event.Before, event.After)
+		}
+	}
+	if !strings.Contains(event.After, `"username":"bob"`) {
+		t.Fatalf("audit event lost the username: %s", event.After)
+	}
+}
+
+func TestAuditQueryAndExport(t *testing.T) {
+	env := newTestEnv(t)
+	audit := NewAudit(env.db)
+
+	audit.Record(model.AuditEvent{Actor: "alice", Action: "create_peer", TargetType: "peer", TargetID: "1", StatusCode: 201}, nil, nil)
+	audit.Record(model.AuditEvent{Actor: "alice", Action: "delete_peer", TargetType: "peer", TargetID: "1", StatusCode: 404}, nil, nil)
+	audit.Record(model.AuditEvent{Actor: "bob", Action: "create_peer", TargetType: "peer", TargetID: "2", StatusCode: 201}, nil, nil)
+
+	resp, err := audit.GetAuditEvents(&schema.AuditQuery{Actor: "alice", Outcome: "success"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Total != 1 || resp.Events[0].Action != "create_peer" {
+		t.Fatalf("got %+v, want the successful request of alice", resp)
+	}
+
+	if _, err := audit.GetAuditEvents(&schema.AuditQuery{From: "last week"}); !errors.Is(err, common.ErrInvalidAuditQuery) {
+		t.Fatalf("invalid from got %v, want ErrInvalidAuditQuery", err)
+	}
+
+	var out bytes.Buffer
+	if err := audit.ExportAuditEvents(&schema.AuditQuery{Action: "create_peer"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || rows[0][2] != "actor" || rows[1][2] != "bob" || rows[2][2] != "alice" {
+		t.Fatalf("exported rows %v, want the header and both create_peer events newest first", rows)
+	}
+}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/dataservice/model"
+	"github.com/maahdima/mwp/api/http/schema"
+)
+
+var errRefreshTokenReused = errors.New("refresh token reused")
+
+// SessionClient describes the client a session was opened from
+type SessionClient struct {
+	UserAgent string
+	IPAddress string
+}
+
+// ParseAccessToken is the ParseTokenFunc of the JWT middleware, on top of the signature and expiry it rejects tokens
+// whose session has been revoked or has expired. API tokens (mwp_...) are accepted as well.
+func (a *Authentication) ParseAccessToken(_ echo.Context, auth string) (interface{}, error) {
+	if strings.HasPrefix(auth, APITokenPrefix) {
+		return a.parseAPIToken(auth)
+	}
+
+	claims := &schema.TokenClaims{}
+	token, err := parseToken(auth, a.AccessSecret, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := a.activeSession(a.db, claims.SessionID); err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// Refresh exchanges a refresh token for a new token pair. The refresh token is single use: presenting one that was
+// already rotated means it leaked, and the whole session is revoked.
+func (a *Authentication) Refresh(refreshToken string) (*schema.LoginResponse, error) {
+	claims := jwt.MapClaims{}
+	if _, err := parseToken(refreshToken, a.RefreshSecret, claims); err != nil {
+		return nil, err
+	}
+	sessionID, _ := claims["sid"].(string)
+	tokenID, _ := claims["jti"].(string)
+
+	var session model.AdminSession
+	var admin model.Admin
+	err := a.db.Transaction(func(tx *gorm.DB) error {
+		current, err := a.activeSession(tx, sessionID)
+		if err != nil {
+			return err
+		}
+
+		if current.RefreshTokenID != tokenID {
+			return errRefreshTokenReused
+		}
+
+		result := tx.Model(&model.AdminSession{}).
+			Where("id = ? AND refresh_token_id = ?", current.ID, tokenID).
+			Updates(map[string]interface{}{
+				"refresh_token_id": uuid.NewString(),
+				"expires_at":       time.Now().Add(refreshTokenTTL).Unix(),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return common.ErrInvalidToken
+		}
+
+		if err := tx.First(&session, current.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&admin, session.AdminID).Error; err != nil {
+			return err
+		}
+		if !admin.IsActive {
+			return common.ErrInvalidToken
+		}
+		return nil
+	})
+	if errors.Is(err, errRefreshTokenReused) {
+		a.logger.Warn("refresh token reused, revoking session", zap.String("sessionId", sessionID))
+		revokeErr := a.db.Model(&model.AdminSession{}).
+			Where("session_id = ?", sessionID).
+			Update("revoked_at", time.Now().Unix()).Error
+		if revokeErr != nil {
+			a.logger.Error("failed to revoke session", zap.String("sessionId", sessionID), zap.Error(revokeErr))
+		}
+		return nil, common.ErrInvalidToken
+	}
+	if err != nil {
+		if !errors.Is(err, common.ErrInvalidToken) {
+			a.logger.Error("failed to rotate refresh token", zap.Error(err))
+		}
+		return nil, err
+	}
+
+	return a.issueTokens(admin, session)
+}
+
+// Logout revokes the session the access token belongs to
+func (a *Authentication) Logout(sessionID string) error {
+	err := a.db.Model(&model.AdminSession{}).
+		Where("session_id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", time.Now().Unix()).Error
+	if err != nil {
+		a.logger.Error("failed to revoke session", zap.String("sessionId", sessionID), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (a *Authentication) createSession(admin model.Admin, client SessionClient) (model.AdminSession, error) {
+	session := model.AdminSession{
+		SessionID:      uuid.NewString(),
+		AdminID:        admin.ID,
+		RefreshTokenID: uuid.NewString(),
+		ExpiresAt:      time.Now().Add(refreshTokenTTL).Unix(),
+		UserAgent:      truncate(client.UserAgent, 255),
+		IPAddress:      truncate(client.IPAddress, 64),
+	}
+
+	err := a.db.Transaction(func(tx *gorm.DB) error {
+		// sessions past their refresh window can never be used again
+		if err := tx.Unscoped().Where("expires_at < ?", time.Now().Unix()).Delete(&model.AdminSession{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&session).Error
+	})
+	if err != nil {
+		a.logger.Error("failed to create session", zap.Error(err))
+		return model.AdminSession{}, err
+	}
+
+	return session, nil
+}
+
+func (a *Authentication) activeSession(db *gorm.DB, sessionID string) (model.AdminSession, error) {
+	var session model.AdminSession
+	err := db.
+		Where("session_id = ? AND revoked_at IS NULL AND expires_at > ?", sessionID, time.Now().Unix()).
+		First(&session).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return session, common.ErrInvalidToken
+		}
+		a.logger.Error("failed to fetch session", zap.Error(err))
+		return session, err
+	}
+
+	return session, nil
+}
+
+func revokeAdminSessions(db *gorm.DB, adminID uint) error {
+	return db.Model(&model.AdminSession{}).
+		Where("admin_id = ? AND revoked_at IS NULL", adminID).
+		Update("revoked_at", time.Now().Unix()).Error
+}
+
+func parseToken(raw string, secret []byte, claims jwt.Claims) (*jwt.Token, error) {
+	token, err := jwt.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		return secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", common.ErrInvalidToken, err.Error())
+	}
+
+	return token, nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
@@ -1,7 +1,15 @@
 package service
 
 import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
 	"strconv"
 	"time"
 
@@ -10,10 +18,10 @@ import (
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 
+	"github.com/maahdima/mwp/api/common"
 	"github.com/maahdima/mwp/api/config"
 	"github.com/maahdima/mwp/api/dataservice/model"
 	"github.com/maahdima/mwp/api/http/schema"
-	"github.com/maahdima/mwp/api/utils"
 )
 
 var (
@@ -32,28 +40,38 @@ func init() {
 }
 
 type Authentication struct {
-	db            *gorm.DB
-	AccessSecret  []byte
-	RefreshSecret []byte
-	logger        *zap.Logger
+	db              *gorm.DB
+	AccessSecret    []byte
+	RefreshSecret   []byte
+	ChallengeSecret []byte
+	logger          *zap.Logger
 }
 
 func NewAuthentication(db *gorm.DB) *Authentication {
+	logger := zap.L().Named("AuthenticationService")
+
+	signingKey, err := loadSigningKey(config.GetAuthConfig())
+	if err != nil {
+		logger.Panic("failed to load token signing key", zap.Error(err))
+	}
+
 	return &Authentication{
-		db:            db,
-		AccessSecret:  []byte(utils.RandomString(24)),
-		RefreshSecret: []byte(utils.RandomString(24)),
-		logger:        zap.L().Named("AuthenticationService"),
+		db:              db,
+		AccessSecret:    deriveSigningKey(signingKey, "access"),
+		RefreshSecret:   deriveSigningKey(signingKey, "refresh"),
+		ChallengeSecret: deriveSigningKey(signingKey, "two-factor"),
+		logger:          logger,
 	}
 }
 
-func (a *Authentication) Login(username, password string) (*schema.LoginResponse, error) {
+// Login checks the credentials of an admin and opens a session for the client
+func (a *Authentication) Login(username, password string, client SessionClient) (*schema.LoginResponse, error) {
 	var admin model.Admin
 
 	if err := a.db.First(&admin, "username = ?", username).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			a.logger.Error("user not found", zap.String("username", username))
-			return nil, gorm.ErrRecordNotFound
+			return nil, common.ErrInvalidCredentials
 		}
 		a.logger.Error("failed to query user from database", zap.Error(err))
 		return nil, err
@@ -61,29 +79,24 @@ func (a *Authentication) Login(username, password string) (*schema.LoginResponse
 
 	if err := bcrypt.CompareHashAndPassword([]byte(admin.Password), []byte(password)); err != nil {
 		a.logger.Error("password mismatch", zap.String("username", username), zap.Error(err))
-		return nil, errors.New("password mismatch")
+		return nil, common.ErrInvalidCredentials
 	}
 
-	accessToken, err := a.generateAccessToken(username)
-	if err != nil {
-		a.logger.Error("failed to generate access token", zap.Error(err))
-		return nil, err
+	if !admin.IsActive {
+		a.logger.Warn("login of inactive admin", zap.String("username", username))
+		return nil, errors.New("account is disabled")
 	}
 
-	refreshToken, err := a.generateRefreshToken(username)
+	if admin.TOTPEnabled {
+		return a.twoFactorChallenge(admin)
+	}
+
+	session, err := a.createSession(admin, client)
 	if err != nil {
-		a.logger.Error("failed to generate refresh token", zap.Error(err))
 		return nil, err
 	}
 
-	expiresIn := int64(accessTokenTTL.Seconds())
-	return &schema.LoginResponse{
-		UserID:       admin.ID,
-		Username:     admin.Username,
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-		ExpiresIn:    expiresIn,
-	}, nil
+	return a.issueTokens(admin, session)
 }
 
 func (a *Authentication) UpdateProfile(oldUsername, oldPassword string, newUsername, newPassword *string) error {
@@ -116,7 +129,17 @@ func (a *Authentication) UpdateProfile(oldUsername, oldPassword string, newUsern
 		admin.Password = string(hashedPassword)
 	}
 
-	if err := a.db.Save(&admin).Error; err != nil {
+	// tokens name the admin and were granted for the old password, so every session ends with the change
+	err := a.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&admin).Error; err != nil {
+			return err
+		}
+		if newUsername != nil || newPassword != nil {
+			return revokeAdminSessions(tx, admin.ID)
+		}
+		return nil
+	})
+	if err != nil {
 		a.logger.Error("failed to update user profile", zap.Error(err))
 		return err
 	}
@@ -124,20 +147,118 @@ func (a *Authentication) UpdateProfile(oldUsername, oldPassword string, newUsern
 	return nil
 }
 
-func (a *Authentication) generateAccessToken(username string) (string, error) {
-	claims := jwt.MapClaims{
-		"sub": username,
-		"exp": time.Now().Add(accessTokenTTL).Unix(),
+func (a *Authentication) issueTokens(admin model.Admin, session model.AdminSession) (*schema.LoginResponse, error) {
+	scope, err := a.adminAccessScope(admin)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := a.generateAccessToken(admin, session.SessionID, scope)
+	if err != nil {
+		a.logger.Error("failed to generate access token", zap.Error(err))
+		return nil, err
+	}
+
+	refreshToken, err := a.generateRefreshToken(admin.Username, session.SessionID, session.RefreshTokenID)
+	if err != nil {
+		a.logger.Error("failed to generate refresh token", zap.Error(err))
+		return nil, err
+	}
+
+	expiresIn := int64(accessTokenTTL.Seconds())
+	return &schema.LoginResponse{
+		UserID:       admin.ID,
+		Username:     admin.Username,
+		Role:         admin.Role,
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    expiresIn,
+	}, nil
+}
+
+// adminAccessScope loads the scope of a reseller, the other roles are not restricted
+func (a *Authentication) adminAccessScope(admin model.Admin) (schema.AccessScope, error) {
+	if admin.Role != model.AdminRoleReseller {
+		return nil, nil
+	}
+
+	var scopes []model.AdminScope
+	if err := a.db.Where("admin_id = ?", admin.ID).Order("id").Find(&scopes).Error; err != nil {
+		a.logger.Error("failed to fetch admin scope", zap.Error(err))
+		return nil, err
+	}
+
+	return transformAdminScope(scopes), nil
+}
+
+func (a *Authentication) generateAccessToken(admin model.Admin, sessionID string, scope schema.AccessScope) (string, error) {
+	claims := schema.TokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   admin.Username,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(accessTokenTTL)),
+		},
+		SessionID: sessionID,
+		Role:      admin.Role,
+		Scope:     scope,
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString(a.AccessSecret)
 }
 
-func (a *Authentication) generateRefreshToken(username string) (string, error) {
+func (a *Authentication) generateRefreshToken(username, sessionID, tokenID string) (string, error) {
 	claims := jwt.MapClaims{
 		"sub": username,
+		"sid": sessionID,
+		"jti": tokenID,
 		"exp": time.Now().Add(refreshTokenTTL).Unix(),
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString(a.RefreshSecret)
 }
+
+// loadSigningKey returns the key tokens are signed with: AUTH_SIGNING_KEY when set, otherwise the key file, which is
+// generated on first start so tokens survive restarts
+func loadSigningKey(cfg config.AuthConfig) ([]byte, error) {
+	if cfg.SigningKey != "" {
+		if len(cfg.SigningKey) < 32 {
+			return nil, errors.New("AUTH_SIGNING_KEY must be at least 32 characters")
+		}
+		return []byte(cfg.SigningKey), nil
+	}
+
+	keyFile := cfg.SigningKeyFile
+	if keyFile == "" {
+		keyFile = filepath.Join(config.GetAppConfig().DataDirPath, "auth.key")
+	}
+
+	key, err := os.ReadFile(keyFile)
+	if err == nil {
+		key = bytes.TrimSpace(key)
+		if len(key) < 32 {
+			return nil, fmt.Errorf("signing key in %s is shorter than 32 bytes", keyFile)
+		}
+		return key, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	key = []byte(hex.EncodeToString(random))
+
+	if err := os.WriteFile(keyFile, key, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write signing key: %w", err)
+	}
+
+	return key, nil
+}
+
+// deriveSigningKey gives access and refresh tokens their own key so one can never pass as the other
+func deriveSigningKey(key []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/config"
+	"github.com/maahdima/mwp/api/dataservice/model"
+)
+
+func (e *testEnv) authService(t *testing.T) *Authentication {
+	t.Helper()
+
+	password, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.db.Create(&model.Admin{Username: "admin", Password: string(password), IsActive: true}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return NewAuthentication(e.db)
+}
+
+func TestRefreshRotatesToken(t *testing.T) {
+	env := newTestEnv(t)
+	auth := env.authService(t)
+
+	login, err := auth.Login("admin", "secret", SessionClient{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	refreshed, err := auth.Refresh(login.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refreshed.RefreshToken == login.RefreshToken {
+		t.Fatal("refresh token was not rotated")
+	}
+	if _, err := auth.ParseAccessToken(nil, refreshed.AccessToken); err != nil {
+		t.Fatalf("refreshed access token is rejected: %v", err)
+	}
+}
+
+func TestRefreshTokenReuseRevokesSession(t *testing.T) {
+	env := newTestEnv(t)
+	auth := env.authService(t)
+
+	login, err := auth.Login("admin", "secret", SessionClient{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	refreshed, err := auth.Refresh(login.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := auth.Refresh(login.RefreshToken); !errors.Is(err, common.ErrInvalidToken) {
+		t.Fatalf("reusing a rotated refresh token got %v, want ErrInvalidToken", err)
+	}
+
+	// the reuse means the token leaked, so the tokens of the legitimate client stop working as well
+	if _, err := auth.Refresh(refreshed.RefreshToken); !errors.Is(err, common.ErrInvalidToken) {
+		t.Fatalf("latest refresh token of a revoked session got %v, want ErrInvalidToken", err)
+	}
+	if _, err := auth.ParseAccessToken(nil, refreshed.AccessToken); !errors.Is(err, common.ErrInvalidToken) {
+		t.Fatalf("access token of a revoked session got %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestRefreshRejectsAccessToken(t *testing.T) {
+	env := newTestEnv(t)
+	auth := env.authService(t)
+
+	login, err := auth.Login("admin", "secret", SessionClient{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.Refresh(login.AccessToken); !errors.Is(err, common.ErrInvalidToken) {
+		t.Fatalf("refreshing with an access token got %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestLoadSigningKeyPersistsGeneratedKey(t *testing.T) {
+	cfg := config.AuthConfig{SigningKeyFile: t.TempDir() + "/auth.key"}
+
+	first, err := loadSigningKey(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := loadSigningKey(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(first) != string(second) {
+		t.Fatal("signing key changed between starts")
+	}
+
+	if _, err := loadSigningKey(config.AuthConfig{SigningKey: "short"}); err == nil {
+		t.Fatal("a signing key shorter than 32 characters was accepted")
+	}
+}
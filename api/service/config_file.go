@@ -3,43 +3,34 @@ package service
 import (
 	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
+	"net/netip"
+	"strings"
 
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 
 	"github.com/maahdima/mwp/api/common"
-	"github.com/maahdima/mwp/api/config"
 	"github.com/maahdima/mwp/api/dataservice/model"
 	"github.com/maahdima/mwp/api/utils"
+	"github.com/maahdima/mwp/api/utils/wireguard"
 )
 
-var (
-	peerConfigsPath string
-)
-
-func init() {
-	appCfg := config.GetAppConfig()
-	peerConfigsPath = filepath.Join(appCfg.PeerFilesDir, "config")
-	if err := os.MkdirAll(peerConfigsPath, os.ModePerm); err != nil {
-		panic(fmt.Sprintf("failed to create peer config directory: %v", err))
-	}
-}
-
 type ConfigGenerator struct {
-	db     *gorm.DB
-	logger *zap.Logger
+	db       *gorm.DB
+	renderer *PeerRenderer
+	logger   *zap.Logger
 }
 
-func NewConfigGenerator(db *gorm.DB) *ConfigGenerator {
+func NewConfigGenerator(db *gorm.DB, renderer *PeerRenderer) *ConfigGenerator {
 	return &ConfigGenerator{
-		db:     db,
-		logger: zap.L().Named("ConfigGenerator"),
+		db:       db,
+		renderer: renderer,
+		logger:   zap.L().Named("ConfigGenerator"),
 	}
 }
 
-func (c *ConfigGenerator) GetPeerConfig(id uint) (configPath string, err error) {
+// GetPeerConfig renders the WireGuard config of a peer
+func (c *ConfigGenerator) GetPeerConfig(id uint) (content []byte, err error) {
 	var peer model.Peer
 
 	if err = c.db.First(&peer, "id = ?", id).Error; err != nil {
@@ -51,12 +42,10 @@ func (c *ConfigGenerator) GetPeerConfig(id uint) (configPath string, err error)
 		return
 	}
 
-	configPath = fmt.Sprintf("%s/%s.conf", peerConfigsPath, peer.UUID)
-
-	return configPath, nil
+	return c.renderer.RenderConfig(peer)
 }
 
-func (c *ConfigGenerator) GetUserConfig(uuid string) (configPath string, err error) {
+func (c *ConfigGenerator) GetUserConfig(uuid string) (content []byte, err error) {
 	var peer model.Peer
 
 	if err = c.db.First(&peer, "uuid = ?", uuid).Error; err != nil {
@@ -68,51 +57,141 @@ func (c *ConfigGenerator) GetUserConfig(uuid string) (configPath string, err err
 		return
 	}
 
-	utils.IsPeerSharable(peer.IsShared, peer.ShareExpireTime)
-	if !peer.IsShared {
-		return "", common.ErrPeerNotShared
+	isSharable := utils.IsPeerSharable(peer.IsShared, peer.ShareExpireTime)
+	if !isSharable {
+		return nil, common.ErrPeerNotShared
+	}
+
+	return c.renderer.RenderConfig(peer)
+}
+
+// peerClientConfig renders the config of a peer, each setting of the peer wins over the client default of its
+// interface
+func peerClientConfig(privateKey string, peer model.Peer, iface model.Interface) string {
+	config := wireguard.ClientConfig{
+		PrivateKey:          privateKey,
+		Address:             peerClientAddress(peer),
+		DNS:                 peerClientDNS(peer, iface),
+		PublicKey:           iface.PublicKey,
+		Endpoint:            peer.Endpoint,
+		EndpointPort:        peer.EndpointPort,
+		AllowedIPs:          peerClientAllowedIPs(peer, iface),
+		PersistentKeepalive: peer.PersistentKeepalive,
+	}
+
+	if peer.MTU != nil {
+		config.MTU = *peer.MTU
+	} else if iface.ClientMTU != nil {
+		config.MTU = *iface.ClientMTU
 	}
 
-	configPath = fmt.Sprintf("%s/%s.conf", peerConfigsPath, peer.UUID)
+	include := !iface.OmitPresharedKey
+	if peer.IncludePresharedKey != nil {
+		include = *peer.IncludePresharedKey
+	}
+	if include {
+		config.PresharedKey = peer.PresharedKey
+	}
 
-	return configPath, nil
+	return config.String()
 }
 
-func (c *ConfigGenerator) BuildPeerConfig(config string, uuid string) error {
-	filePath := fmt.Sprintf("%s/%s.conf", peerConfigsPath, uuid)
+// peerClientDNS returns the DNS servers written into the config of a peer
+func peerClientDNS(peer model.Peer, iface model.Interface) string {
+	if peer.DNS != nil && *peer.DNS != "" {
+		return *peer.DNS
+	}
+	if iface.ClientDNS != nil && *iface.ClientDNS != "" {
+		return *iface.ClientDNS
+	}
+	return common.DefaultDns
+}
 
-	file, err := os.Create(filePath)
-	if err != nil {
-		return fmt.Errorf("failed to create config file: %w", err)
+// peerClientAddress returns the Address line of the config of a peer, every address it was allocated
+func peerClientAddress(peer model.Peer) string {
+	var addresses []string
+	for _, prefix := range utils.ParseAllowedAddresses(peer.AllowedAddress) {
+		addresses = append(addresses, prefix.String())
+	}
+	if len(addresses) == 0 {
+		return peer.AllowedAddress
 	}
-	defer file.Close()
+	return strings.Join(addresses, ", ")
+}
 
-	if _, err := file.WriteString(config); err != nil {
-		return fmt.Errorf("failed to write config to file: %w", err)
+// peerClientAllowedIPs returns the AllowedIPs written into the config of a peer, the whole traffic by default. A split
+// tunnel routes the networks of the peer, or of its interface when the peer lists none.
+func peerClientAllowedIPs(peer model.Peer, iface model.Interface) string {
+	mode := common.AllowedIpsModeFull
+	if peer.AllowedIPsMode != nil && *peer.AllowedIPsMode != "" {
+		mode = *peer.AllowedIPsMode
+	} else if iface.ClientAllowedIPsMode != nil && *iface.ClientAllowedIPsMode != "" {
+		mode = *iface.ClientAllowedIPsMode
 	}
 
-	return nil
+	switch mode {
+	case common.AllowedIpsModeExcludePrivate:
+		return common.AllowedIpsExcludeLocal
+	case common.AllowedIpsModeSplit:
+		routes := peer.AllowedIPs
+		if routes == nil || *routes == "" {
+			routes = iface.ClientAllowedIPs
+		}
+		if routes != nil && *routes != "" {
+			return *routes
+		}
+	}
+	return common.AllowedIpsIncludeLocal
 }
 
-func (c *ConfigGenerator) RemovePeerConfig(id uint) error {
-	var peer model.Peer
+// validateClientSettings checks the client config settings of an interface or a peer, a split tunnel needs routes of
+// its own or in fallbackRoutes
+func validateClientSettings(dns, mode, routes *string, mtu *int, fallbackRoutes *string) error {
+	if dns != nil && *dns != "" {
+		for _, server := range strings.Split(*dns, ",") {
+			if _, err := netip.ParseAddr(strings.TrimSpace(server)); err != nil {
+				return fmt.Errorf("%w: invalid DNS server %q", common.ErrInvalidClientConfig, strings.TrimSpace(server))
+			}
+		}
+	}
 
-	if err := c.db.First(&peer, "id = ?", id).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.logger.Error("peer not found in database", zap.Uint("id", id))
-			return err
+	if mode != nil && *mode != "" {
+		switch *mode {
+		case common.AllowedIpsModeFull, common.AllowedIpsModeExcludePrivate:
+		case common.AllowedIpsModeSplit:
+			if (routes == nil || *routes == "") && (fallbackRoutes == nil || *fallbackRoutes == "") {
+				return fmt.Errorf("%w: a split tunnel needs allowed IPs", common.ErrInvalidClientConfig)
+			}
+		default:
+			return fmt.Errorf("%w: unknown allowed IPs mode %q", common.ErrInvalidClientConfig, *mode)
 		}
-		c.logger.Error("failed to get peer from database", zap.Uint("id", id), zap.Error(err))
-		return err
 	}
 
-	configPath := fmt.Sprintf("%s/%s.conf", peerConfigsPath, peer.UUID)
+	if routes != nil && *routes != "" {
+		for _, route := range strings.Split(*routes, ",") {
+			if _, err := netip.ParsePrefix(strings.TrimSpace(route)); err != nil {
+				return fmt.Errorf("%w: invalid allowed IPs route %q", common.ErrInvalidClientConfig, strings.TrimSpace(route))
+			}
+		}
+	}
 
-	err := os.Remove(configPath)
-	if err != nil {
-		c.logger.Error("failed to remove Config file", zap.String("path", configPath), zap.Error(err))
-		return err
+	if mtu != nil && *mtu != 0 && (*mtu < 1280 || *mtu > 65535) {
+		return fmt.Errorf("%w: MTU %d is out of 1280-65535", common.ErrInvalidClientConfig, *mtu)
 	}
 
 	return nil
 }
+
+// clientListSetting normalizes a comma separated DNS server or route list, an empty list clears the setting
+func clientListSetting(list string) *string {
+	var entries []string
+	for _, entry := range strings.Split(list, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	return utils.Ptr(strings.Join(entries, ", "))
+}
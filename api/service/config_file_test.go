@@ -0,0 +1,124 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/dataservice/model"
+	"github.com/maahdima/mwp/api/http/schema"
+	"github.com/maahdima/mwp/api/utils"
+)
+
+func TestPeerClientConfigPrefersPeerSettings(t *testing.T) {
+	ifaceMTU, peerMTU := 1380, 1420
+	iface := model.Interface{
+		PublicKey:            "server-key",
+		ClientDNS:            utils.Ptr("9.9.9.9"),
+		ClientAllowedIPsMode: utils.Ptr(common.AllowedIpsModeSplit),
+		ClientAllowedIPs:     utils.Ptr("10.10.0.0/16"),
+		ClientMTU:            &ifaceMTU,
+		OmitPresharedKey:     true,
+	}
+	peer := model.Peer{
+		AllowedAddress:      "10.0.0.2/32,fd00::2/128",
+		Endpoint:            "vpn.example.com",
+		EndpointPort:        "51820",
+		PersistentKeepalive: "25",
+		PresharedKey:        "shared-key",
+	}
+
+	config := peerClientConfig("client-key", peer, iface)
+	for _, line := range []string{
+		"Address = 10.0.0.2/32, fd00::2/128",
+		"DNS = 9.9.9.9",
+		"MTU = 1380",
+		"AllowedIPs = 10.10.0.0/16",
+		"PersistentKeepalive = 25",
+	} {
+		if !strings.Contains(config, line) {
+			t.Fatalf("config lacks the interface default %q:\n%s", line, config)
+		}
+	}
+	if strings.Contains(config, "PresharedKey") {
+		t.Fatalf("config carries the preshared key although the interface omits it:\n%s", config)
+	}
+
+	include := true
+	peer.DNS = utils.Ptr("1.1.1.1")
+	peer.AllowedIPsMode = utils.Ptr(common.AllowedIpsModeFull)
+	peer.MTU = &peerMTU
+	peer.IncludePresharedKey = &include
+
+	config = peerClientConfig("client-key", peer, iface)
+	for _, line := range []string{
+		"DNS = 1.1.1.1",
+		"MTU = 1420",
+		"AllowedIPs = " + common.AllowedIpsIncludeLocal,
+		"PresharedKey = shared-key",
+	} {
+		if !strings.Contains(config, line) {
+			t.Fatalf("config lacks the peer setting %q:\n%s", line, config)
+		}
+	}
+}
+
+func TestCreatePeerUsesInterfaceClientDefaults(t *testing.T) {
+	env := newTestEnv(t)
+	iface := env.seedInterface(t, "wg0")
+	mtu := 1380
+	iface.ClientDNS = utils.Ptr("9.9.9.9")
+	iface.ClientMTU = &mtu
+	iface.ClientPersistentKeepalive = utils.Ptr("15")
+	iface.GeneratePresharedKey = true
+	if err := env.db.Save(&iface).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := env.peerService().CreatePeer(newCreatePeerRequest(t, iface, "alice", "10.0.0.2/32"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.HasPresharedKey {
+		t.Fatal("peer got no preshared key although the interface generates one")
+	}
+
+	var peer model.Peer
+	if err := env.db.First(&peer, resp.Id).Error; err != nil {
+		t.Fatal(err)
+	}
+	if routerPeer := env.router.Record(common.WGPeerPath, peer.PeerID); routerPeer["preshared-key"] != peer.PresharedKey {
+		t.Fatalf("router peer has preshared key %q, want the one stored in the panel", routerPeer["preshared-key"])
+	}
+
+	content, err := NewConfigGenerator(env.db, env.renderer).GetPeerConfig(peer.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"DNS = 9.9.9.9", "MTU = 1380", "PersistentKeepalive = 15", "PresharedKey = " + peer.PresharedKey} {
+		if !strings.Contains(string(content), line) {
+			t.Fatalf("config lacks %q:\n%s", line, content)
+		}
+	}
+}
+
+func TestCreatePeerRejectsInvalidClientConfig(t *testing.T) {
+	env := newTestEnv(t)
+	iface := env.seedInterface(t, "wg0")
+	peers := env.peerService()
+
+	mtu := 100
+	for name, apply := range map[string]func(req *schema.CreatePeerRequest){
+		"dns":        func(req *schema.CreatePeerRequest) { req.DNS = utils.Ptr("not-an-ip") },
+		"split mode": func(req *schema.CreatePeerRequest) { req.AllowedIPsMode = utils.Ptr(common.AllowedIpsModeSplit) },
+		"route":      func(req *schema.CreatePeerRequest) { req.AllowedIPs = utils.Ptr("10.0.0.0/33") },
+		"mtu":        func(req *schema.CreatePeerRequest) { req.MTU = &mtu },
+	} {
+		req := newCreatePeerRequest(t, iface, "alice", "10.0.0.2/32")
+		apply(req)
+		if _, err := peers.CreatePeer(req); !errors.Is(err, common.ErrInvalidClientConfig) {
+			t.Fatalf("%s: err = %v, want ErrInvalidClientConfig", name, err)
+		}
+	}
+}
@@ -80,19 +80,19 @@ func (d *DeviceData) GetDailyTrafficUsage(rangeParam string) (*[]schema.DailyTra
 	return &dailyTrafficUsages, nil
 }
 
-func (d *DeviceData) GetDeviceData() (*schema.DeviceStatsResponse, error) {
+func (d *DeviceData) GetDeviceData(server model.Server) (*schema.DeviceStatsResponse, error) {
 	serverStats, err := d.serverService.GetServersData()
 	if err != nil {
 		d.logger.Error("failed to fetch server stats", zap.Error(err))
 		return nil, err
 	}
 
-	interfaceStats, err := d.interfaceService.GetInterfacesData()
+	interfaceStats, err := d.interfaceService.GetInterfacesData(server)
 	if err != nil {
 		return nil, err
 	}
 
-	peerStats, err := d.peerService.GetPeersData()
+	peerStats, err := d.peerService.GetPeersData(server)
 	if err != nil {
 		return nil, err
 	}
@@ -102,22 +102,22 @@ func (d *DeviceData) GetDeviceData() (*schema.DeviceStatsResponse, error) {
 		return nil, err
 	}
 
-	info, err := d.getDeviceInfo()
+	info, err := d.getDeviceInfo(server)
 	if err != nil {
 		return nil, err
 	}
 
-	identity, err := d.getDeviceIdentity()
+	identity, err := d.getDeviceIdentity(server)
 	if err != nil {
 		return nil, err
 	}
 
-	ipv4, err := d.getDeviceIpAddress()
+	ipv4, err := d.getDeviceIpAddress(server)
 	if err != nil {
 		return nil, err
 	}
 
-	dns, err := d.getDNSConfig()
+	dns, err := d.getDNSConfig(server)
 	if err != nil {
 		return nil, err
 	}
@@ -146,8 +146,8 @@ func (d *DeviceData) getTrafficInfo() (*schema.TrafficInfo, error) {
 	}, nil
 }
 
-func (d *DeviceData) getDeviceInfo() (*schema.DeviceInfo, error) {
-	info, err := d.mikrotikAdaptor.FetchDeviceInfo(context.Background())
+func (d *DeviceData) getDeviceInfo(server model.Server) (*schema.DeviceInfo, error) {
+	info, err := d.mikrotikAdaptor.FetchDeviceInfo(context.Background(), server)
 	if err != nil {
 		d.logger.Error("failed to fetch device resource", zap.Error(err))
 		return nil, err
@@ -166,8 +166,8 @@ func (d *DeviceData) getDeviceInfo() (*schema.DeviceInfo, error) {
 	}, nil
 }
 
-func (d *DeviceData) getDeviceIdentity() (*schema.DeviceIdentity, error) {
-	identity, err := d.mikrotikAdaptor.FetchDeviceIdentity(context.Background())
+func (d *DeviceData) getDeviceIdentity(server model.Server) (*schema.DeviceIdentity, error) {
+	identity, err := d.mikrotikAdaptor.FetchDeviceIdentity(context.Background(), server)
 	if err != nil {
 		d.logger.Error("failed to fetch device identity", zap.Error(err))
 		return nil, err
@@ -178,10 +178,10 @@ func (d *DeviceData) getDeviceIdentity() (*schema.DeviceIdentity, error) {
 	}, nil
 }
 
-func (d *DeviceData) getDeviceIpAddress() (*schema.DeviceIPv4Address, error) {
+func (d *DeviceData) getDeviceIpAddress(server model.Server) (*schema.DeviceIPv4Address, error) {
 	deviceIpData := &schema.DeviceIPv4Address{}
 
-	ipv4Addresses, err := d.mikrotikAdaptor.FetchIPv4Addresses(context.Background())
+	ipv4Addresses, err := d.mikrotikAdaptor.FetchIPv4Addresses(context.Background(), server)
 	if err != nil {
 		d.logger.Error("failed to fetch IPv4 address", zap.Error(err))
 		return deviceIpData, err
@@ -193,13 +193,6 @@ func (d *DeviceData) getDeviceIpAddress() (*schema.DeviceIPv4Address, error) {
 		}
 	}
 
-	// TODO : multi-server support
-	var server model.Server
-	if err := d.db.First(&server).Error; err != nil {
-		d.logger.Error("failed to fetch server record from database", zap.Error(err))
-		return deviceIpData, err
-	}
-
 	transport := http.DefaultTransport.(*http.Transport).Clone()
 	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
 
@@ -244,8 +237,8 @@ func (d *DeviceData) getDeviceIpAddress() (*schema.DeviceIPv4Address, error) {
 	return deviceIpData, err
 }
 
-func (d *DeviceData) getDNSConfig() (*schema.DNSConfig, error) {
-	dns, err := d.mikrotikAdaptor.FetchDNSConfig(context.Background())
+func (d *DeviceData) getDNSConfig(server model.Server) (*schema.DNSConfig, error) {
+	dns, err := d.mikrotikAdaptor.FetchDNSConfig(context.Background(), server)
 	if err != nil {
 		d.logger.Error("failed to fetch dns config", zap.Error(err))
 		return nil, err
@@ -0,0 +1,718 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/maahdima/mwp/api/adaptor/mikrotik"
+	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/dataservice/model"
+	"github.com/maahdima/mwp/api/http/schema"
+	"github.com/maahdima/mwp/api/utils"
+)
+
+type driftState struct {
+	routerIfaces     []mikrotik.WireGuardInterface
+	routerPeers      []mikrotik.WireGuardPeer
+	routerSchedulers []mikrotik.Scheduler
+	routerQueues     []mikrotik.Queue
+	dbIfaces         []model.Interface
+	dbPeers          []model.Peer
+}
+
+type driftFields []schema.DriftField
+
+func (d *driftFields) compare(field, panel, router string) {
+	if panel != router {
+		*d = append(*d, schema.DriftField{Field: field, Panel: panel, Router: router})
+	}
+}
+
+func (d driftFields) has(field string) bool {
+	for _, f := range d {
+		if f.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// DetectDrift compares the interfaces, peers, schedulers and queues of a server field by field with the router.
+// PanelID is the database ID of the interface or peer, schedulers and queues carry the ID of the peer owning them.
+func (s *SyncService) DetectDrift(server model.Server) (*schema.DriftReportResponse, error) {
+	_, items, err := s.detectDrift(server)
+	if err != nil {
+		return nil, err
+	}
+
+	return &schema.DriftReportResponse{
+		ServerId:  server.ID,
+		CheckedAt: time.Now().Format(time.RFC3339),
+		Total:     len(items),
+		Items:     items,
+	}, nil
+}
+
+// DriftSnapshot holds the drift of a server together with the router and panel state it was detected on, so that
+// several of its items can be resolved without fetching every router menu again
+type DriftSnapshot struct {
+	Items []schema.DriftItem
+	state *driftState
+}
+
+// SnapshotDrift detects the drift of a server like DetectDrift and keeps the state it was detected on
+func (s *SyncService) SnapshotDrift(server model.Server) (*DriftSnapshot, error) {
+	state, items, err := s.detectDrift(server)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DriftSnapshot{Items: items, state: state}, nil
+}
+
+// ResolveDrift applies a single drift item in the requested direction, router wins updates the panel and panel
+// wins pushes the stored state back to the router
+func (s *SyncService) ResolveDrift(server model.Server, req *schema.ResolveDriftRequest) error {
+	if req.RouterID == "" && req.PanelID == 0 {
+		return fmt.Errorf("%w: router_id or panel_id is required", common.ErrDriftNotFound)
+	}
+
+	state, items, err := s.detectDrift(server)
+	if err != nil {
+		return err
+	}
+
+	item := findDriftItem(items, req)
+	if item == nil {
+		return common.ErrDriftNotFound
+	}
+
+	return s.resolveDrift(server, state, *item, req.Resolution)
+}
+
+// ResolveDriftItem applies an item of a snapshot like ResolveDrift, using the state of the snapshot instead of
+// detecting the drift again. The items of a snapshot concern distinct objects, so they can be resolved one by one.
+func (s *SyncService) ResolveDriftItem(server model.Server, snapshot *DriftSnapshot, item schema.DriftItem, resolution schema.DriftResolution) error {
+	return s.resolveDrift(server, snapshot.state, item, resolution)
+}
+
+func (s *SyncService) resolveDrift(server model.Server, state *driftState, item schema.DriftItem, resolution schema.DriftResolution) error {
+	var err error
+	switch item.Object {
+	case schema.DriftObjectInterface:
+		err = s.resolveInterfaceDrift(server, state, item, resolution)
+	case schema.DriftObjectPeer:
+		err = s.resolvePeerDrift(server, state, item, resolution)
+	case schema.DriftObjectScheduler:
+		err = s.resolveSchedulerDrift(server, state, item, resolution)
+	case schema.DriftObjectQueue:
+		err = s.resolveQueueDrift(server, state, item, resolution)
+	}
+	if err != nil {
+		s.logger.Error("failed to resolve drift", zap.String("object", string(item.Object)), zap.String("kind", string(item.Kind)),
+			zap.String("routerId", item.RouterID), zap.Uint("panelId", item.PanelID), zap.Error(err))
+		return err
+	}
+
+	s.logger.Info("resolved drift", zap.String("object", string(item.Object)), zap.String("kind", string(item.Kind)),
+		zap.String("routerId", item.RouterID), zap.Uint("panelId", item.PanelID), zap.String("resolution", string(resolution)))
+	return nil
+}
+
+func (s *SyncService) detectDrift(server model.Server) (*driftState, []schema.DriftItem, error) {
+	state, err := s.fetchDriftState(server)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	items := make([]schema.DriftItem, 0)
+	items = append(items, interfaceDrift(state)...)
+	items = append(items, peerDrift(state)...)
+	items = append(items, schedulerDrift(state)...)
+	items = append(items, queueDrift(state)...)
+
+	return state, items, nil
+}
+
+func (s *SyncService) fetchDriftState(server model.Server) (*driftState, error) {
+	var (
+		state driftState
+		err   error
+	)
+
+	if state.routerIfaces, err = s.fetchMikrotikInterfaces(server); err != nil {
+		return nil, err
+	}
+	if state.routerPeers, err = s.fetchMikrotikPeers(server); err != nil {
+		return nil, err
+	}
+	if state.routerSchedulers, err = s.mikrotikAdaptor.FetchSchedulers(context.Background(), server); err != nil {
+		s.logger.Error("failed to get schedulers from Mikrotik", zap.Error(err))
+		return nil, err
+	}
+	if state.routerQueues, err = s.mikrotikAdaptor.FetchSimpleQueues(context.Background(), server); err != nil {
+		s.logger.Error("failed to get simple queues from Mikrotik", zap.Error(err))
+		return nil, err
+	}
+	if state.dbIfaces, err = s.fetchDBInterfaces(server); err != nil {
+		return nil, err
+	}
+	if state.dbPeers, err = s.fetchDBPeers(server); err != nil {
+		return nil, err
+	}
+
+	return &state, nil
+}
+
+func interfaceDrift(state *driftState) []schema.DriftItem {
+	var items []schema.DriftItem
+
+	routerMap := make(map[string]mikrotik.WireGuardInterface, len(state.routerIfaces))
+	for _, iface := range state.routerIfaces {
+		routerMap[iface.ID] = iface
+	}
+
+	known := make(map[string]struct{}, len(state.dbIfaces))
+	for _, dbIface := range state.dbIfaces {
+		known[dbIface.InterfaceID] = struct{}{}
+
+		routerIface, ok := routerMap[dbIface.InterfaceID]
+		if !ok {
+			items = append(items, schema.DriftItem{Object: schema.DriftObjectInterface, Kind: schema.DriftMissingOnRouter, RouterID: dbIface.InterfaceID, PanelID: dbIface.ID, Name: dbIface.Name})
+			continue
+		}
+
+		var fields driftFields
+		fields.compare("name", dbIface.Name, routerIface.Name)
+		fields.compare("disabled", strconv.FormatBool(dbIface.Disabled), strconv.FormatBool(parseBool(routerIface.Disabled)))
+		fields.compare("comment", utils.DerefString(dbIface.Comment), utils.DerefString(routerIface.Comment))
+		fields.compare("listen_port", dbIface.ListenPort, routerIface.ListenPort)
+		fields.compare("public_key", dbIface.PublicKey, routerIface.PublicKey)
+		if len(fields) > 0 {
+			items = append(items, schema.DriftItem{Object: schema.DriftObjectInterface, Kind: schema.DriftChanged, RouterID: routerIface.ID, PanelID: dbIface.ID, Name: dbIface.Name, Fields: fields})
+		}
+	}
+
+	for _, routerIface := range state.routerIfaces {
+		if _, ok := known[routerIface.ID]; !ok {
+			items = append(items, schema.DriftItem{Object: schema.DriftObjectInterface, Kind: schema.DriftMissingInDB, RouterID: routerIface.ID, Name: routerIface.Name})
+		}
+	}
+
+	return items
+}
+
+func peerDrift(state *driftState) []schema.DriftItem {
+	var items []schema.DriftItem
+
+	routerMap := make(map[string]mikrotik.WireGuardPeer, len(state.routerPeers))
+	for _, peer := range state.routerPeers {
+		routerMap[peer.ID] = peer
+	}
+
+	known := make(map[string]struct{}, len(state.dbPeers))
+	for _, dbPeer := range state.dbPeers {
+		known[dbPeer.PeerID] = struct{}{}
+
+		routerPeer, ok := routerMap[dbPeer.PeerID]
+		if !ok {
+			items = append(items, schema.DriftItem{Object: schema.DriftObjectPeer, Kind: schema.DriftMissingOnRouter, RouterID: dbPeer.PeerID, PanelID: dbPeer.ID, Name: dbPeer.Name})
+			continue
+		}
+
+		var fields driftFields
+		fields.compare("name", dbPeer.Name, routerPeer.Name)
+		fields.compare("disabled", strconv.FormatBool(dbPeer.Disabled), strconv.FormatBool(parseBool(routerPeer.Disabled)))
+		fields.compare("comment", utils.DerefString(dbPeer.Comment), utils.DerefString(routerPeer.Comment))
+		fields.compare("interface", dbPeer.Interface, routerPeer.Interface)
+		fields.compare("allowed_address", dbPeer.AllowedAddress, routerPeer.AllowedAddress)
+		fields.compare("public_key", dbPeer.PublicKey, routerPeer.PublicKey)
+		if len(fields) > 0 {
+			items = append(items, schema.DriftItem{Object: schema.DriftObjectPeer, Kind: schema.DriftChanged, RouterID: routerPeer.ID, PanelID: dbPeer.ID, Name: dbPeer.Name, Fields: fields, Expired: isPeerExpired(dbPeer)})
+		}
+	}
+
+	for _, routerPeer := range state.routerPeers {
+		if _, ok := known[routerPeer.ID]; !ok {
+			items = append(items, schema.DriftItem{Object: schema.DriftObjectPeer, Kind: schema.DriftMissingInDB, RouterID: routerPeer.ID, Name: routerPeer.Name})
+		}
+	}
+
+	return items
+}
+
+// schedulerDrift only reports the router schedulers created by the panel, identified by their name or comment prefix
+func schedulerDrift(state *driftState) []schema.DriftItem {
+	var items []schema.DriftItem
+
+	routerMap := make(map[string]mikrotik.Scheduler, len(state.routerSchedulers))
+	for _, scheduler := range state.routerSchedulers {
+		routerMap[scheduler.ID] = scheduler
+	}
+
+	referenced := make(map[string]struct{})
+	for _, dbPeer := range state.dbPeers {
+		if dbPeer.SchedulerID == nil {
+			if dbPeer.ExpireTime != nil {
+				items = append(items, schema.DriftItem{Object: schema.DriftObjectScheduler, Kind: schema.DriftMissingOnRouter, PanelID: dbPeer.ID, Name: common.SchedulerName + dbPeer.Name})
+			}
+			continue
+		}
+		referenced[*dbPeer.SchedulerID] = struct{}{}
+
+		scheduler, ok := routerMap[*dbPeer.SchedulerID]
+		if !ok {
+			items = append(items, schema.DriftItem{Object: schema.DriftObjectScheduler, Kind: schema.DriftMissingOnRouter, RouterID: *dbPeer.SchedulerID, PanelID: dbPeer.ID, Name: common.SchedulerName + dbPeer.Name})
+			continue
+		}
+
+		var fields driftFields
+		fields.compare("start_date", utils.DerefString(dbPeer.ExpireTime), utils.DerefString(scheduler.StartDate))
+		fields.compare("on_event", common.SchedulerEvent+dbPeer.PeerID, utils.DerefString(scheduler.OnEvent))
+		if len(fields) > 0 {
+			items = append(items, schema.DriftItem{Object: schema.DriftObjectScheduler, Kind: schema.DriftChanged, RouterID: scheduler.ID, PanelID: dbPeer.ID, Name: scheduler.Name, Fields: fields})
+		}
+	}
+
+	for _, scheduler := range state.routerSchedulers {
+		if _, ok := referenced[scheduler.ID]; ok {
+			continue
+		}
+		if strings.HasPrefix(scheduler.Name, common.SchedulerName) || strings.HasPrefix(utils.DerefString(scheduler.Comment), common.SchedulerComment) {
+			items = append(items, schema.DriftItem{Object: schema.DriftObjectScheduler, Kind: schema.DriftMissingInDB, RouterID: scheduler.ID, Name: scheduler.Name})
+		}
+	}
+
+	return items
+}
+
+// queueDrift only reports the router queues created by the panel, identified by their name or comment prefix
+func queueDrift(state *driftState) []schema.DriftItem {
+	var items []schema.DriftItem
+
+	routerMap := make(map[string]mikrotik.Queue, len(state.routerQueues))
+	for _, queue := range state.routerQueues {
+		routerMap[queue.ID] = queue
+	}
+
+	referenced := make(map[string]struct{})
+	for _, dbPeer := range state.dbPeers {
+		if dbPeer.QueueID == nil {
+			continue
+		}
+		referenced[*dbPeer.QueueID] = struct{}{}
+
+		queue, ok := routerMap[*dbPeer.QueueID]
+		if !ok {
+			items = append(items, schema.DriftItem{Object: schema.DriftObjectQueue, Kind: schema.DriftMissingOnRouter, RouterID: *dbPeer.QueueID, PanelID: dbPeer.ID, Name: common.QueueName + dbPeer.Name})
+			continue
+		}
+
+		var fields driftFields
+		fields.compare("target", dbPeer.AllowedAddress, utils.DerefString(queue.Target))
+		panelLimit := maxLimit(dbPeer.DownloadBandwidth, dbPeer.UploadBandwidth)
+		routerLimit := utils.DerefString(queue.MaxLimit)
+		if !maxLimitsEqual(panelLimit, routerLimit) {
+			fields = append(fields, schema.DriftField{Field: "max_limit", Panel: panelLimit, Router: routerLimit})
+		}
+		if len(fields) > 0 {
+			items = append(items, schema.DriftItem{Object: schema.DriftObjectQueue, Kind: schema.DriftChanged, RouterID: queue.ID, PanelID: dbPeer.ID, Name: queue.Name, Fields: fields})
+		}
+	}
+
+	for _, queue := range state.routerQueues {
+		if _, ok := referenced[queue.ID]; ok {
+			continue
+		}
+		if strings.HasPrefix(queue.Name, common.QueueName) || strings.HasPrefix(utils.DerefString(queue.Comment), common.QueueComment) {
+			items = append(items, schema.DriftItem{Object: schema.DriftObjectQueue, Kind: schema.DriftMissingInDB, RouterID: queue.ID, Name: queue.Name})
+		}
+	}
+
+	return items
+}
+
+func findDriftItem(items []schema.DriftItem, req *schema.ResolveDriftRequest) *schema.DriftItem {
+	for i, item := range items {
+		if item.Object != req.Object {
+			continue
+		}
+		if req.RouterID != "" && item.RouterID != req.RouterID {
+			continue
+		}
+		if req.PanelID != 0 && item.PanelID != req.PanelID {
+			continue
+		}
+		return &items[i]
+	}
+
+	return nil
+}
+
+func (s *SyncService) resolveInterfaceDrift(server model.Server, state *driftState, item schema.DriftItem, resolution schema.DriftResolution) error {
+	ctx := context.Background()
+	dbIface, _ := findDBInterface(state, item.PanelID)
+	routerIface, _ := findRouterInterface(state, item.RouterID)
+
+	switch {
+	case item.Kind == schema.DriftMissingOnRouter && resolution == schema.DriftRouterWins:
+		for _, peer := range state.dbPeers {
+			if peer.Interface == dbIface.Name {
+				return fmt.Errorf("%w: interface %s still has peers, resolve them first", common.ErrDriftNotResolvable, dbIface.Name)
+			}
+		}
+		return s.db.Unscoped().Delete(&dbIface).Error
+
+	case item.Kind == schema.DriftMissingOnRouter && resolution == schema.DriftPanelWins:
+		created, err := s.mikrotikAdaptor.CreateWgInterface(ctx, server, mikrotik.WireGuardInterface{
+			Disabled:   strconv.FormatBool(dbIface.Disabled),
+			Comment:    dbIface.Comment,
+			ListenPort: dbIface.ListenPort,
+			Name:       dbIface.Name,
+			PrivateKey: dbIface.PrivateKey,
+		})
+		if err != nil {
+			return err
+		}
+		return s.db.Model(&dbIface).Update("interface_id", created.ID).Error
+
+	case item.Kind == schema.DriftMissingInDB && resolution == schema.DriftRouterWins,
+		item.Kind == schema.DriftChanged && resolution == schema.DriftRouterWins:
+		return s.syncNewAndUpdatedInterfaces(server, s.mapMikrotikInterfaces([]mikrotik.WireGuardInterface{routerIface}), s.mapDBInterfaces(state.dbIfaces))
+
+	case item.Kind == schema.DriftMissingInDB && resolution == schema.DriftPanelWins:
+		return s.mikrotikAdaptor.DeleteWgInterface(ctx, server, routerIface.ID)
+
+	case item.Kind == schema.DriftChanged && resolution == schema.DriftPanelWins:
+		update := mikrotik.WireGuardInterface{
+			Disabled:   strconv.FormatBool(dbIface.Disabled),
+			Comment:    emptyIfNil(dbIface.Comment),
+			ListenPort: dbIface.ListenPort,
+			Name:       dbIface.Name,
+		}
+		if driftFields(item.Fields).has("public_key") {
+			update.PrivateKey = dbIface.PrivateKey
+		}
+		_, err := s.mikrotikAdaptor.UpdateWgInterface(ctx, server, routerIface.ID, update)
+		return err
+	}
+
+	return fmt.Errorf("%w: unknown resolution %s", common.ErrDriftNotResolvable, resolution)
+}
+
+func (s *SyncService) resolvePeerDrift(server model.Server, state *driftState, item schema.DriftItem, resolution schema.DriftResolution) error {
+	ctx := context.Background()
+	dbPeer, _ := findDBPeer(state, item.PanelID)
+	routerPeer, _ := findRouterPeer(state, item.RouterID)
+
+	switch {
+	case item.Kind == schema.DriftMissingOnRouter && resolution == schema.DriftRouterWins:
+		return s.removeDBPeer(server, state, dbPeer)
+
+	case item.Kind == schema.DriftMissingOnRouter && resolution == schema.DriftPanelWins:
+		created, err := s.mikrotikAdaptor.CreateWgPeer(ctx, server, mikrotik.WireGuardPeer{
+			Disabled:            strconv.FormatBool(dbPeer.Disabled),
+			Comment:             dbPeer.Comment,
+			AllowedAddress:      dbPeer.AllowedAddress,
+			PersistentKeepAlive: nilIfEmpty(dbPeer.PersistentKeepalive),
+			Interface:           dbPeer.Interface,
+			Name:                dbPeer.Name,
+			PrivateKey:          &dbPeer.PrivateKey,
+			PublicKey:           dbPeer.PublicKey,
+			PresharedKey:        nilIfEmpty(dbPeer.PresharedKey),
+		})
+		if err != nil {
+			return err
+		}
+		return s.db.Model(&dbPeer).Update("peer_id", created.ID).Error
+
+	case item.Kind == schema.DriftMissingInDB && resolution == schema.DriftRouterWins:
+		if routerPeer.PrivateKey == nil {
+			return fmt.Errorf("%w: peer %s has no private key on the router", common.ErrDriftNotResolvable, routerPeer.Name)
+		}
+		return s.syncNewAndUpdatedPeers(server, s.mapMikrotikPeers([]mikrotik.WireGuardPeer{routerPeer}), s.mapDBPeers(state.dbPeers))
+
+	case item.Kind == schema.DriftMissingInDB && resolution == schema.DriftPanelWins:
+		return s.mikrotikAdaptor.DeleteWgPeer(ctx, server, routerPeer.ID)
+
+	case item.Kind == schema.DriftChanged && resolution == schema.DriftRouterWins:
+		updates := map[string]interface{}{
+			"name":            routerPeer.Name,
+			"disabled":        parseBool(routerPeer.Disabled),
+			"comment":         routerPeer.Comment,
+			"interface":       routerPeer.Interface,
+			"allowed_address": routerPeer.AllowedAddress,
+			"public_key":      routerPeer.PublicKey,
+		}
+		if disabled := parseBool(routerPeer.Disabled); disabled != dbPeer.Disabled {
+			updates["disabled_reason"] = peerDisabledReason(disabled, routerDisabledReason(dbPeer))
+		}
+		return s.db.Model(&dbPeer).Updates(updates).Error
+
+	case item.Kind == schema.DriftChanged && resolution == schema.DriftPanelWins:
+		_, err := s.mikrotikAdaptor.UpdateWgPeer(ctx, server, routerPeer.ID, mikrotik.WireGuardPeer{
+			Disabled:       strconv.FormatBool(dbPeer.Disabled),
+			Comment:        emptyIfNil(dbPeer.Comment),
+			AllowedAddress: dbPeer.AllowedAddress,
+			Interface:      dbPeer.Interface,
+			Name:           dbPeer.Name,
+			PublicKey:      dbPeer.PublicKey,
+		})
+		return err
+	}
+
+	return fmt.Errorf("%w: unknown resolution %s", common.ErrDriftNotResolvable, resolution)
+}
+
+func (s *SyncService) resolveSchedulerDrift(server model.Server, state *driftState, item schema.DriftItem, resolution schema.DriftResolution) error {
+	ctx := context.Background()
+	dbPeer, _ := findDBPeer(state, item.PanelID)
+	scheduler, _ := findRouterScheduler(state, item.RouterID)
+
+	switch {
+	case item.Kind == schema.DriftMissingOnRouter && resolution == schema.DriftRouterWins:
+		return s.db.Model(&dbPeer).Updates(map[string]interface{}{"scheduler_id": nil, "expire_time": nil}).Error
+
+	case item.Kind == schema.DriftMissingOnRouter && resolution == schema.DriftPanelWins:
+		schedulerID, err := s.scheduler.createScheduler(server, dbPeer.PeerID, dbPeer.Name, dbPeer.ExpireTime)
+		if err != nil {
+			return err
+		}
+		return s.db.Model(&dbPeer).Update("scheduler_id", schedulerID).Error
+
+	case item.Kind == schema.DriftMissingInDB && resolution == schema.DriftRouterWins:
+		peerID := strings.TrimPrefix(utils.DerefString(scheduler.OnEvent), common.SchedulerEvent)
+		owner, ok := findDBPeerByPeerID(state, peerID)
+		if !ok {
+			return fmt.Errorf("%w: scheduler %s does not belong to any peer", common.ErrDriftNotResolvable, scheduler.Name)
+		}
+		return s.db.Model(&owner).Updates(map[string]interface{}{"scheduler_id": scheduler.ID, "expire_time": scheduler.StartDate}).Error
+
+	case item.Kind == schema.DriftMissingInDB && resolution == schema.DriftPanelWins:
+		return s.mikrotikAdaptor.DeleteScheduler(ctx, server, scheduler.ID)
+
+	case item.Kind == schema.DriftChanged && resolution == schema.DriftRouterWins:
+		if driftFields(item.Fields).has("on_event") {
+			return fmt.Errorf("%w: scheduler %s no longer targets its peer, resolve it with the panel", common.ErrDriftNotResolvable, scheduler.Name)
+		}
+		return s.db.Model(&dbPeer).Update("expire_time", scheduler.StartDate).Error
+
+	case item.Kind == schema.DriftChanged && resolution == schema.DriftPanelWins:
+		_, err := s.mikrotikAdaptor.UpdateScheduler(ctx, server, scheduler.ID, mikrotik.Scheduler{
+			StartDate: dbPeer.ExpireTime,
+			OnEvent:   utils.Ptr(common.SchedulerEvent + dbPeer.PeerID),
+		})
+		return err
+	}
+
+	return fmt.Errorf("%w: unknown resolution %s", common.ErrDriftNotResolvable, resolution)
+}
+
+func (s *SyncService) resolveQueueDrift(server model.Server, state *driftState, item schema.DriftItem, resolution schema.DriftResolution) error {
+	ctx := context.Background()
+	dbPeer, _ := findDBPeer(state, item.PanelID)
+	queue, _ := findRouterQueue(state, item.RouterID)
+
+	switch {
+	case item.Kind == schema.DriftMissingOnRouter && resolution == schema.DriftRouterWins:
+		return s.db.Model(&dbPeer).Updates(map[string]interface{}{"queue_id": nil, "download_bandwidth": nil, "upload_bandwidth": nil}).Error
+
+	case item.Kind == schema.DriftMissingOnRouter && resolution == schema.DriftPanelWins:
+		queueID, err := s.queue.createQueue(server, dbPeer.Name, dbPeer.AllowedAddress, dbPeer.DownloadBandwidth, dbPeer.UploadBandwidth)
+		if err != nil {
+			return err
+		}
+		return s.db.Model(&dbPeer).Update("queue_id", queueID).Error
+
+	case item.Kind == schema.DriftMissingInDB && resolution == schema.DriftRouterWins:
+		var owner model.Peer
+		found := false
+		for _, peer := range state.dbPeers {
+			if peer.AllowedAddress == utils.DerefString(queue.Target) {
+				owner, found = peer, true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%w: queue %s does not target any peer", common.ErrDriftNotResolvable, queue.Name)
+		}
+		download, upload := splitMaxLimit(utils.DerefString(queue.MaxLimit))
+		return s.db.Model(&owner).Updates(map[string]interface{}{"queue_id": queue.ID, "download_bandwidth": download, "upload_bandwidth": upload}).Error
+
+	case item.Kind == schema.DriftMissingInDB && resolution == schema.DriftPanelWins:
+		return s.mikrotikAdaptor.DeleteSimpleQueue(ctx, server, queue.ID)
+
+	case item.Kind == schema.DriftChanged && resolution == schema.DriftRouterWins:
+		if driftFields(item.Fields).has("target") {
+			return fmt.Errorf("%w: queue %s no longer targets its peer, resolve it with the panel", common.ErrDriftNotResolvable, queue.Name)
+		}
+		download, upload := splitMaxLimit(utils.DerefString(queue.MaxLimit))
+		return s.db.Model(&dbPeer).Updates(map[string]interface{}{"download_bandwidth": download, "upload_bandwidth": upload}).Error
+
+	case item.Kind == schema.DriftChanged && resolution == schema.DriftPanelWins:
+		_, err := s.mikrotikAdaptor.UpdateSimpleQueue(ctx, server, queue.ID, mikrotik.Queue{
+			Target:   utils.Ptr(dbPeer.AllowedAddress),
+			MaxLimit: utils.Ptr(maxLimit(dbPeer.DownloadBandwidth, dbPeer.UploadBandwidth)),
+		})
+		return err
+	}
+
+	return fmt.Errorf("%w: unknown resolution %s", common.ErrDriftNotResolvable, resolution)
+}
+
+// removeDBPeer drops a peer that no longer exists on the router, together with its panel-owned scheduler and queue
+func (s *SyncService) removeDBPeer(server model.Server, state *driftState, peer model.Peer) error {
+	ctx := context.Background()
+
+	if peer.SchedulerID != nil {
+		if _, ok := findRouterScheduler(state, *peer.SchedulerID); ok {
+			if err := s.mikrotikAdaptor.DeleteScheduler(ctx, server, *peer.SchedulerID); err != nil {
+				return err
+			}
+		}
+	}
+	if peer.QueueID != nil {
+		if _, ok := findRouterQueue(state, *peer.QueueID); ok {
+			if err := s.mikrotikAdaptor.DeleteSimpleQueue(ctx, server, *peer.QueueID); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := deletePeerRecord(s.db, peer); err != nil {
+		return err
+	}
+
+	s.renderer.Forget(peer.UUID)
+	return nil
+}
+
+func findDBInterface(state *driftState, id uint) (model.Interface, bool) {
+	for _, iface := range state.dbIfaces {
+		if iface.ID == id {
+			return iface, true
+		}
+	}
+	return model.Interface{}, false
+}
+
+func findRouterInterface(state *driftState, id string) (mikrotik.WireGuardInterface, bool) {
+	for _, iface := range state.routerIfaces {
+		if iface.ID == id {
+			return iface, true
+		}
+	}
+	return mikrotik.WireGuardInterface{}, false
+}
+
+func findDBPeer(state *driftState, id uint) (model.Peer, bool) {
+	for _, peer := range state.dbPeers {
+		if peer.ID == id {
+			return peer, true
+		}
+	}
+	return model.Peer{}, false
+}
+
+func findDBPeerByPeerID(state *driftState, peerID string) (model.Peer, bool) {
+	for _, peer := range state.dbPeers {
+		if peer.PeerID == peerID {
+			return peer, true
+		}
+	}
+	return model.Peer{}, false
+}
+
+func findRouterPeer(state *driftState, id string) (mikrotik.WireGuardPeer, bool) {
+	for _, peer := range state.routerPeers {
+		if peer.ID == id {
+			return peer, true
+		}
+	}
+	return mikrotik.WireGuardPeer{}, false
+}
+
+func findRouterScheduler(state *driftState, id string) (mikrotik.Scheduler, bool) {
+	for _, scheduler := range state.routerSchedulers {
+		if scheduler.ID == id {
+			return scheduler, true
+		}
+	}
+	return mikrotik.Scheduler{}, false
+}
+
+func findRouterQueue(state *driftState, id string) (mikrotik.Queue, bool) {
+	for _, queue := range state.routerQueues {
+		if queue.ID == id {
+			return queue, true
+		}
+	}
+	return mikrotik.Queue{}, false
+}
+
+// maxLimit builds the max-limit the panel sets on a peer queue, see Queue.createQueue
+func maxLimit(download, upload *string) string {
+	d, u := "0", "0"
+	if download != nil {
+		d = *download
+	}
+	if upload != nil {
+		u = *upload
+	}
+	return d + "/" + u
+}
+
+// maxLimitsEqual compares two max-limits by value, RouterOS answers "10M/5M" as "10000000/5000000"
+func maxLimitsEqual(a, b string) bool {
+	aDownload, aUpload := splitMaxLimit(a)
+	bDownload, bUpload := splitMaxLimit(b)
+	return utils.ParseBandwidth(utils.DerefString(aDownload)) == utils.ParseBandwidth(utils.DerefString(bDownload)) &&
+		utils.ParseBandwidth(utils.DerefString(aUpload)) == utils.ParseBandwidth(utils.DerefString(bUpload))
+}
+
+// splitMaxLimit returns the download and upload part of a max-limit in the panel format (e.g. 10M), nil if unlimited
+func splitMaxLimit(limit string) (download, upload *string) {
+	parts := strings.SplitN(limit, "/", 2)
+	download = compactBandwidth(parts[0])
+	if len(parts) == 2 {
+		upload = compactBandwidth(parts[1])
+	}
+	return download, upload
+}
+
+func compactBandwidth(value string) *string {
+	bits := utils.ParseBandwidth(value)
+	switch {
+	case bits <= 0:
+		return nil
+	case bits%1e9 == 0:
+		return utils.Ptr(strconv.FormatInt(bits/1e9, 10) + "G")
+	case bits%1e6 == 0:
+		return utils.Ptr(strconv.FormatInt(bits/1e6, 10) + "M")
+	case bits%1e3 == 0:
+		return utils.Ptr(strconv.FormatInt(bits/1e3, 10) + "K")
+	default:
+		return utils.Ptr(strconv.FormatInt(bits, 10))
+	}
+}
+
+// emptyIfNil clears an optional router property instead of leaving it untouched when the panel has no value
+func emptyIfNil(value *string) *string {
+	if value == nil {
+		return utils.Ptr("")
+	}
+	return value
+}
+
+// nilIfEmpty leaves an optional router property at its default when the panel has no value
+func nilIfEmpty(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/dataservice/model"
+	"github.com/maahdima/mwp/api/http/schema"
+	"github.com/maahdima/mwp/api/utils"
+	"github.com/maahdima/mwp/api/utils/wireguard"
+)
+
+// createPeerMissingOnRouter creates a peer through the panel and then removes it on the router only
+func createPeerMissingOnRouter(t *testing.T, env *testEnv) model.Peer {
+	t.Helper()
+
+	iface := env.seedInterface(t, "wg0")
+	req := newCreatePeerRequest(t, iface, "alice", "10.0.0.2/32")
+	req.PersistentKeepAlive = utils.Ptr("00:00:30")
+	req.ExpireTime = utils.Ptr("2030-01-01")
+	presharedKey, err := wireguard.GeneratePresharedKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.PresharedKey = &presharedKey
+	resp, err := env.peerService().CreatePeer(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var peer model.Peer
+	if err := env.db.First(&peer, "id = ?", resp.Id).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := env.adaptor.DeleteWgPeer(context.Background(), env.server, peer.PeerID); err != nil {
+		t.Fatal(err)
+	}
+	return peer
+}
+
+func TestResolvePeerMissingOnRouterPanelWins(t *testing.T) {
+	env := newTestEnv(t)
+	peer := createPeerMissingOnRouter(t, env)
+
+	err := env.syncService().ResolveDrift(env.server, &schema.ResolveDriftRequest{
+		Object:     schema.DriftObjectPeer,
+		PanelID:    peer.ID,
+		Resolution: schema.DriftPanelWins,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := env.db.First(&peer, peer.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	recreated := env.router.Record(common.WGPeerPath, peer.PeerID)
+	if recreated == nil {
+		t.Fatal("peer was not recreated on the router")
+	}
+	if recreated["persistent-keepalive"] != peer.PersistentKeepalive || recreated["private-key"] != peer.PrivateKey ||
+		recreated["preshared-key"] != peer.PresharedKey {
+		t.Fatalf("recreated peer %v lost the settings stored in the panel", recreated)
+	}
+}
+
+func TestResolvePeerMissingOnRouterRouterWins(t *testing.T) {
+	env := newTestEnv(t)
+	peer := createPeerMissingOnRouter(t, env)
+
+	err := env.syncService().ResolveDrift(env.server, &schema.ResolveDriftRequest{
+		Object:     schema.DriftObjectPeer,
+		PanelID:    peer.ID,
+		Resolution: schema.DriftRouterWins,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var count int64
+	env.db.Unscoped().Model(&model.Peer{}).Where("id = ?", peer.ID).Count(&count)
+	if count != 0 {
+		t.Fatal("peer missing on the router was kept in the database")
+	}
+	if records := env.router.Records(common.SchedulerPath); len(records) != 0 {
+		t.Fatalf("router kept %d schedulers of the removed peer", len(records))
+	}
+	if records := env.router.Records(common.QueuePath); len(records) != 0 {
+		t.Fatalf("router kept %d queues of the removed peer", len(records))
+	}
+}
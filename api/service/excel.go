@@ -1,7 +1,11 @@
 package service
 
 import (
+	"encoding/csv"
+	"fmt"
+	"io"
 	"net"
+	"path/filepath"
 	"sort"
 	"strings"
 
@@ -10,7 +14,9 @@ import (
 
 	"github.com/xuri/excelize/v2"
 
+	"github.com/maahdima/mwp/api/common"
 	"github.com/maahdima/mwp/api/dataservice/model"
+	"github.com/maahdima/mwp/api/utils"
 )
 
 type ExcelGenerator struct {
@@ -89,6 +95,55 @@ func (e *ExcelGenerator) GetTrafficUsageReport() (filePath string, err error) {
 	return filePath, nil
 }
 
+// ReadRows reads every row of a .csv file or of the first sheet of a .xlsx file, the format is picked from the file name
+func (e *ExcelGenerator) ReadRows(fileName string, reader io.Reader) ([][]string, error) {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".csv":
+		csvReader := csv.NewReader(reader)
+		csvReader.FieldsPerRecord = -1
+		csvReader.TrimLeadingSpace = true
+
+		rows, err := csvReader.ReadAll()
+		if err != nil {
+			e.logger.Warn("failed to parse csv file", zap.Error(err))
+			return nil, fmt.Errorf("%w: %w", common.ErrInvalidImportFile, err)
+		}
+
+		// Excel saves CSV files as UTF-8 with a byte order mark
+		if len(rows) > 0 && len(rows[0]) > 0 {
+			rows[0][0] = strings.TrimPrefix(rows[0][0], "\ufeff")
+		}
+
+		return rows, nil
+	case ".xlsx":
+		excelFile, err := excelize.OpenReader(reader)
+		if err != nil {
+			e.logger.Warn("failed to open excel file", zap.Error(err))
+			return nil, fmt.Errorf("%w: %w", common.ErrInvalidImportFile, err)
+		}
+		defer func() {
+			if closeErr := excelFile.Close(); closeErr != nil {
+				e.logger.Warn("failed to close excel file", zap.Error(closeErr))
+			}
+		}()
+
+		sheets := excelFile.GetSheetList()
+		if len(sheets) == 0 {
+			return nil, nil
+		}
+
+		rows, err := excelFile.GetRows(sheets[0])
+		if err != nil {
+			e.logger.Warn("failed to read excel rows", zap.String("sheet", sheets[0]), zap.Error(err))
+			return nil, err
+		}
+
+		return rows, nil
+	default:
+		return nil, fmt.Errorf("%w: expected a .csv or .xlsx file", common.ErrInvalidImportFile)
+	}
+}
+
 func (e *ExcelGenerator) setHeaders(excelFile *excelize.File, sheetName string) error {
 	headers := []string{"Id", "Name", "Comment", "IP Address", "Traffic (GB)"}
 
@@ -165,19 +220,15 @@ func (e *ExcelGenerator) setColumnsData(excelFile *excelize.File, peers []model.
 	return nil
 }
 
-// parseIPAddress extracts and parses the IP address from AllowedAddress field (which may include CIDR notation)
+// parseIPAddress extracts and parses the first IP address from AllowedAddress field (which may include CIDR notation
+// and list an IPv6 address after the IPv4 one)
 func parseIPAddress(allowedAddress string) net.IP {
-	// Remove CIDR notation if present (e.g., "192.168.1.1/24" -> "192.168.1.1")
-	ipStr := allowedAddress
-	if idx := strings.Index(allowedAddress, "/"); idx != -1 {
-		ipStr = allowedAddress[:idx]
-	}
-
-	ip := net.ParseIP(ipStr)
-	if ip == nil {
+	prefixes := utils.ParseAllowedAddresses(allowedAddress)
+	if len(prefixes) == 0 {
 		return net.IPv4(0, 0, 0, 0)
 	}
 
+	ip := net.IP(prefixes[0].Addr().AsSlice())
 	if ip4 := ip.To4(); ip4 != nil {
 		return ip4
 	}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"strconv"
+	"testing"
+
+	"gorm.io/gorm"
+
+	"github.com/maahdima/mwp/api/adaptor/mikrotik"
+	"github.com/maahdima/mwp/api/adaptor/mikrotik/mikrotiktest"
+	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/config"
+	"github.com/maahdima/mwp/api/dataservice"
+	"github.com/maahdima/mwp/api/dataservice/model"
+	"github.com/maahdima/mwp/api/utils/wireguard"
+)
+
+// testEnv is a migrated database in a temporary data dir with one server backed by a fake router
+type testEnv struct {
+	db       *gorm.DB
+	router   *mikrotiktest.Server
+	server   model.Server
+	adaptor  *mikrotik.Adaptor
+	renderer *PeerRenderer
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+
+	t.Setenv("DATA_DIR", t.TempDir())
+	t.Setenv("DB_DIALECT", "sqlite")
+	if _, err := dataservice.LoadKeyring(config.GetEncryptionConfig()); err != nil {
+		t.Fatal(err)
+	}
+	db, err := dataservice.ConnectDB(config.GetDBConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if err := dataservice.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	router := mikrotiktest.NewServer()
+	t.Cleanup(router.Close)
+
+	server := router.ModelServer("r1")
+	if err := db.Create(&server).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return &testEnv{
+		db:       db,
+		router:   router,
+		server:   server,
+		adaptor:  mikrotik.NewAdaptor(common.NewMwpClients(db)),
+		renderer: NewPeerRenderer(db, config.GetPeerRenderConfig()),
+	}
+}
+
+func (e *testEnv) peerService() *WgPeer {
+	return NewWGPeer(e.db, e.adaptor, NewScheduler(e.adaptor), NewQueue(e.adaptor), e.renderer)
+}
+
+func (e *testEnv) syncService() *SyncService {
+	return NewSyncService(e.db, e.adaptor, NewScheduler(e.adaptor), NewQueue(e.adaptor), e.renderer)
+}
+
+// seedInterface creates a wireguard interface on the router and stores it
+func (e *testEnv) seedInterface(t *testing.T, name string) model.Interface {
+	t.Helper()
+
+	id, err := e.router.Seed(common.WGInterfacePath, mikrotiktest.Record{"name": name, "listen-port": "51820"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := e.router.Record(common.WGInterfacePath, id)
+
+	iface := model.Interface{
+		ServerID:    e.server.ID,
+		InterfaceID: id,
+		Name:        name,
+		PrivateKey:  record["private-key"],
+		PublicKey:   record["public-key"],
+		ListenPort:  record["listen-port"],
+	}
+	if err := e.db.Create(&iface).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return iface
+}
+
+// seedPool stores an IP pool of the given range for an interface
+func (e *testEnv) seedPool(t *testing.T, iface model.Interface, startIP, endIP string) model.IPPool {
+	t.Helper()
+
+	ipVersion, err := parsePoolRange(startIP, endIP)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := model.IPPool{Name: iface.Name + "-v" + strconv.Itoa(ipVersion), StartIP: startIP, EndIP: endIP, InterfaceID: iface.ID, IPVersion: ipVersion}
+	if err := e.db.Create(&pool).Error; err != nil {
+		t.Fatal(err)
+	}
+	return pool
+}
+
+// seedAddress adds an address of the interface on the router under the IPv4 or IPv6 address menu
+func (e *testEnv) seedAddress(t *testing.T, path string, iface model.Interface, address string) {
+	t.Helper()
+
+	if _, err := e.router.Seed(path, mikrotiktest.Record{"address": address, "interface": iface.Name}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newKeyPair returns a private key and the public key of it
+func newKeyPair(t *testing.T) (string, string) {
+	t.Helper()
+
+	raw, privateKey, err := wireguard.GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, err := wireguard.GeneratePublicKey(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return privateKey, publicKey
+}
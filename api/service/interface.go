@@ -9,8 +9,11 @@ import (
 	"gorm.io/gorm"
 
 	"github.com/maahdima/mwp/api/adaptor/mikrotik"
+	"github.com/maahdima/mwp/api/common"
 	"github.com/maahdima/mwp/api/dataservice/model"
 	"github.com/maahdima/mwp/api/http/schema"
+	"github.com/maahdima/mwp/api/utils"
+	"github.com/maahdima/mwp/api/utils/timehelper"
 )
 
 type WgInterface struct {
@@ -27,16 +30,16 @@ func NewWgInterface(db *gorm.DB, mikrotikAdaptor *mikrotik.Adaptor) *WgInterface
 	}
 }
 
-func (i *WgInterface) GetInterfaces() (*[]schema.InterfaceResponse, error) {
+func (i *WgInterface) GetInterfaces(server model.Server) (*[]schema.InterfaceResponse, error) {
 	var interfaces []model.Interface
-	if err := i.db.Order("created_at desc").Find(&interfaces).Error; err != nil {
+	if err := i.db.Where("server_id = ?", server.ID).Order("created_at desc").Find(&interfaces).Error; err != nil {
 		i.logger.Error("failed to get wireguard interfaces from database", zap.Error(err))
 		return nil, err
 	}
 
 	var wgInterfaces []schema.InterfaceResponse
 	for _, iface := range interfaces {
-		mtInterface, err := i.mikrotikAdaptor.FetchWgInterface(context.Background(), iface.InterfaceID)
+		mtInterface, err := i.mikrotikAdaptor.FetchWgInterface(context.Background(), server, iface.InterfaceID)
 		if err != nil {
 			i.logger.Error("failed to fetch wireguard interface from Mikrotik", zap.String("interfaceID", iface.InterfaceID), zap.Error(err))
 			return nil, fmt.Errorf("failed to fetch wireguard interface from Mikrotik: %w", err)
@@ -48,27 +51,32 @@ func (i *WgInterface) GetInterfaces() (*[]schema.InterfaceResponse, error) {
 	return &wgInterfaces, nil
 }
 
-func (i *WgInterface) CreateInterface(req *schema.CreateInterfaceRequest) (*schema.InterfaceResponse, error) {
+func (i *WgInterface) CreateInterface(server model.Server, req *schema.CreateInterfaceRequest) (*schema.InterfaceResponse, error) {
+	var clientSettings model.Interface
+	if err := applyClientSettings(&clientSettings, req.ClientInterfaceSettings); err != nil {
+		return nil, err
+	}
+
 	wgInterface := &mikrotik.WireGuardInterface{
 		Name:       req.Name,
 		Comment:    req.Comment,
 		ListenPort: req.ListenPort,
 	}
 
-	mtInterface, err := i.mikrotikAdaptor.CreateWgInterface(context.Background(), *wgInterface)
+	mtInterface, err := i.mikrotikAdaptor.CreateWgInterface(context.Background(), server, *wgInterface)
 	if err != nil {
 		i.logger.Error("failed to create wireguard interface", zap.Error(err))
 		return nil, err
 	}
 
-	dbInterface := model.Interface{
-		InterfaceID: mtInterface.ID,
-		Comment:     wgInterface.Comment,
-		Name:        wgInterface.Name,
-		PrivateKey:  mtInterface.PrivateKey,
-		PublicKey:   mtInterface.PublicKey,
-		ListenPort:  wgInterface.ListenPort,
-	}
+	dbInterface := clientSettings
+	dbInterface.ServerID = server.ID
+	dbInterface.InterfaceID = mtInterface.ID
+	dbInterface.Comment = wgInterface.Comment
+	dbInterface.Name = wgInterface.Name
+	dbInterface.PrivateKey = mtInterface.PrivateKey
+	dbInterface.PublicKey = mtInterface.PublicKey
+	dbInterface.ListenPort = wgInterface.ListenPort
 
 	if err := i.db.Create(&dbInterface).Error; err != nil {
 		i.logger.Error("failed to save wireguard interface to database", zap.Error(err))
@@ -81,7 +89,7 @@ func (i *WgInterface) CreateInterface(req *schema.CreateInterfaceRequest) (*sche
 
 func (i *WgInterface) ToggleInterfaceStatus(id uint) error {
 	var iface model.Interface
-	if err := i.db.First(&iface, id).Error; err != nil {
+	if err := i.db.Preload("Server").First(&iface, id).Error; err != nil {
 		i.logger.Error("failed to find wireguard interface in database", zap.Error(err))
 		return fmt.Errorf("failed to find wireguard interface in database: %w", err)
 	}
@@ -92,7 +100,7 @@ func (i *WgInterface) ToggleInterfaceStatus(id uint) error {
 		Disabled: disabled,
 	}
 
-	if _, err := i.mikrotikAdaptor.UpdateWgInterface(context.Background(), iface.InterfaceID, wgInterface); err != nil {
+	if _, err := i.mikrotikAdaptor.UpdateWgInterface(context.Background(), iface.Server, iface.InterfaceID, wgInterface); err != nil {
 		i.logger.Error("failed to update wireguard interface status", zap.Error(err))
 		return fmt.Errorf("failed to update wireguard interface status: %w", err)
 	}
@@ -107,11 +115,15 @@ func (i *WgInterface) ToggleInterfaceStatus(id uint) error {
 
 func (i *WgInterface) UpdateInterface(id uint, req *schema.UpdateInterfaceRequest) (*schema.InterfaceResponse, error) {
 	var iface model.Interface
-	if err := i.db.First(&iface, id).Error; err != nil {
+	if err := i.db.Preload("Server").First(&iface, id).Error; err != nil {
 		i.logger.Error("failed to get interface from database", zap.Error(err))
 		return nil, err
 	}
 
+	if err := applyClientSettings(&iface, req.ClientInterfaceSettings); err != nil {
+		return nil, err
+	}
+
 	wgInterface := mikrotik.WireGuardInterface{}
 
 	if req.Disabled != nil {
@@ -124,15 +136,17 @@ func (i *WgInterface) UpdateInterface(id uint, req *schema.UpdateInterfaceReques
 
 	wgInterface.Name = req.Name
 
-	mtInterface, err := i.mikrotikAdaptor.UpdateWgInterface(context.Background(), iface.InterfaceID, wgInterface)
+	mtInterface, err := i.mikrotikAdaptor.UpdateWgInterface(context.Background(), iface.Server, iface.InterfaceID, wgInterface)
 	if err != nil {
 		i.logger.Error("failed to update wireguard interface", zap.Error(err))
 		return nil, fmt.Errorf("failed to update wireguard interface: %w", err)
 	}
 
 	iface.Comment = req.Comment
-	iface.Name = wgInterface.Name
-	iface.ListenPort = wgInterface.ListenPort
+	// the listen port is not changed here, a settings only update leaves the name as well
+	if wgInterface.Name != "" {
+		iface.Name = wgInterface.Name
+	}
 
 	if err := i.db.Save(&iface).Error; err != nil {
 		i.logger.Error("failed to update wireguard interface in database", zap.Error(err))
@@ -145,12 +159,12 @@ func (i *WgInterface) UpdateInterface(id uint, req *schema.UpdateInterfaceReques
 
 func (i *WgInterface) DeleteInterface(id uint) error {
 	var iface model.Interface
-	if err := i.db.First(&iface, id).Error; err != nil {
+	if err := i.db.Preload("Server").First(&iface, id).Error; err != nil {
 		i.logger.Error("failed to find wireguard interface in database", zap.Error(err))
 		return fmt.Errorf("failed to find wireguard interface in database: %w", err)
 	}
 
-	if err := i.mikrotikAdaptor.DeleteWgInterface(context.Background(), iface.InterfaceID); err != nil {
+	if err := i.mikrotikAdaptor.DeleteWgInterface(context.Background(), iface.Server, iface.InterfaceID); err != nil {
 		i.logger.Error("failed to delete wireguard interface from Mikrotik", zap.Error(err))
 		return fmt.Errorf("failed to delete wireguard interface from Mikrotik: %w", err)
 	}
@@ -163,15 +177,15 @@ func (i *WgInterface) DeleteInterface(id uint) error {
 	return nil
 }
 
-func (i *WgInterface) GetInterfacesData() (*schema.InterfaceStatsResponse, error) {
+func (i *WgInterface) GetInterfacesData(server model.Server) (*schema.InterfaceStatsResponse, error) {
 	var totalInterfaces int64
-	if err := i.db.Model(&model.Interface{}).Count(&totalInterfaces).Error; err != nil {
+	if err := i.db.Model(&model.Interface{}).Where("server_id = ?", server.ID).Count(&totalInterfaces).Error; err != nil {
 		i.logger.Error("failed to count total interfaces", zap.Error(err))
 		return nil, fmt.Errorf("failed to count total interfaces: %w", err)
 	}
 
 	var activeInterfaces int64
-	if err := i.db.Model(&model.Interface{}).Where("disabled = ?", false).Count(&activeInterfaces).Error; err != nil {
+	if err := i.db.Model(&model.Interface{}).Where("server_id = ? AND disabled = ?", server.ID, false).Count(&activeInterfaces).Error; err != nil {
 		i.logger.Error("failed to count active interfaces", zap.Error(err))
 		return nil, fmt.Errorf("failed to count active interfaces: %w", err)
 	}
@@ -185,6 +199,7 @@ func (i *WgInterface) GetInterfacesData() (*schema.InterfaceStatsResponse, error
 func (i *WgInterface) transformInterfaceToResponse(wgInterface model.Interface, mtu, status string) schema.InterfaceResponse {
 	return schema.InterfaceResponse{
 		Id:          wgInterface.ID,
+		ServerId:    wgInterface.ServerID,
 		InterfaceID: wgInterface.InterfaceID,
 		Disabled:    wgInterface.Disabled,
 		Comment:     wgInterface.Comment,
@@ -192,5 +207,63 @@ func (i *WgInterface) transformInterfaceToResponse(wgInterface model.Interface,
 		ListenPort:  wgInterface.ListenPort,
 		MTU:         mtu,
 		IsRunning:   status == "true",
+		ClientInterfaceSettings: schema.ClientInterfaceSettings{
+			ClientDNS:                 wgInterface.ClientDNS,
+			ClientAllowedIPsMode:      wgInterface.ClientAllowedIPsMode,
+			ClientAllowedIPs:          wgInterface.ClientAllowedIPs,
+			ClientMTU:                 wgInterface.ClientMTU,
+			ClientPersistentKeepalive: wgInterface.ClientPersistentKeepalive,
+			GeneratePresharedKey:      &wgInterface.GeneratePresharedKey,
+			OmitPresharedKey:          &wgInterface.OmitPresharedKey,
+		},
+	}
+}
+
+// applyClientSettings copies the client config defaults given in req onto iface, an empty value clears a default.
+// Peer configs are rendered on demand, so a change shows up in the next download.
+func applyClientSettings(iface *model.Interface, req schema.ClientInterfaceSettings) error {
+	updated := *iface
+
+	if req.ClientDNS != nil {
+		updated.ClientDNS = clientListSetting(*req.ClientDNS)
 	}
+	if req.ClientAllowedIPsMode != nil {
+		updated.ClientAllowedIPsMode = nil
+		if *req.ClientAllowedIPsMode != "" {
+			updated.ClientAllowedIPsMode = req.ClientAllowedIPsMode
+		}
+	}
+	if req.ClientAllowedIPs != nil {
+		updated.ClientAllowedIPs = clientListSetting(*req.ClientAllowedIPs)
+	}
+	if req.ClientMTU != nil {
+		updated.ClientMTU = nil
+		if *req.ClientMTU != 0 {
+			updated.ClientMTU = req.ClientMTU
+		}
+	}
+	if req.ClientPersistentKeepalive != nil {
+		updated.ClientPersistentKeepalive = nil
+		if *req.ClientPersistentKeepalive != "" {
+			seconds, err := timehelper.ParseTime(*req.ClientPersistentKeepalive)
+			if err != nil {
+				return fmt.Errorf("%w: invalid keepalive %q, expected HH:MM:SS", common.ErrInvalidClientConfig, *req.ClientPersistentKeepalive)
+			}
+			updated.ClientPersistentKeepalive = utils.Ptr(strconv.Itoa(seconds))
+		}
+	}
+	if req.GeneratePresharedKey != nil {
+		updated.GeneratePresharedKey = *req.GeneratePresharedKey
+	}
+	if req.OmitPresharedKey != nil {
+		updated.OmitPresharedKey = *req.OmitPresharedKey
+	}
+
+	err := validateClientSettings(updated.ClientDNS, updated.ClientAllowedIPsMode, updated.ClientAllowedIPs, updated.ClientMTU, nil)
+	if err != nil {
+		return err
+	}
+
+	*iface = updated
+	return nil
 }
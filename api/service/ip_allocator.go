@@ -0,0 +1,209 @@
+package service
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"github.com/maahdima/mwp/api/dataservice/model"
+	"github.com/maahdima/mwp/api/utils"
+)
+
+// peerAddressLocks serializes picking and storing peer addresses per server, two peers created at the same time can
+// not be given the same address
+var peerAddressLocks = addressLocks{servers: make(map[uint]*sync.Mutex)}
+
+type addressLocks struct {
+	mu      sync.Mutex
+	servers map[uint]*sync.Mutex
+}
+
+// lock takes the address lock of the server and returns the function releasing it
+func (l *addressLocks) lock(serverID uint) func() {
+	l.mu.Lock()
+	lock, ok := l.servers[serverID]
+	if !ok {
+		lock = &sync.Mutex{}
+		l.servers[serverID] = lock
+	}
+	l.mu.Unlock()
+
+	lock.Lock()
+	return lock.Unlock
+}
+
+// addressRange is an inclusive range of addresses the allocator skips
+type addressRange struct {
+	start netip.Addr
+	end   netip.Addr
+}
+
+// poolAllocation is a pool with the addresses the allocator must skip in it
+type poolAllocation struct {
+	startIP    netip.Addr
+	endIP      netip.Addr
+	blocked    []addressRange
+	reservedIP netip.Addr
+}
+
+// allocatePeerAddress picks an address of every IP version the interface has pools of for the peer named peerName:
+// its static reservation when it has a free one, otherwise the lowest address of the first pool by priority that has
+// one held by no peer of the server, no reservation and none of pending. The pools of the interface must be loaded.
+func (w *WgPeer) allocatePeerAddress(iface model.Interface, peerName string, pending []netip.Prefix) (string, error) {
+	pools := append([]model.IPPool(nil), iface.IPPools...)
+	sort.Slice(pools, func(i, j int) bool {
+		if pools[i].IPVersion != pools[j].IPVersion {
+			return pools[i].IPVersion < pools[j].IPVersion
+		}
+		if pools[i].Priority != pools[j].Priority {
+			return pools[i].Priority < pools[j].Priority
+		}
+		return pools[i].ID < pools[j].ID
+	})
+
+	var peers []model.Peer
+	if err := w.db.Select("name", "allowed_address").Where("server_id = ?", iface.ServerID).Find(&peers).Error; err != nil {
+		w.logger.Error("failed to query peers from database", zap.Error(err))
+		return "", fmt.Errorf("failed to find peers: %w", err)
+	}
+
+	taken := append([]netip.Prefix(nil), pending...)
+	for _, peer := range peers {
+		taken = append(taken, utils.ParseAllowedAddresses(peer.AllowedAddress)...)
+	}
+
+	var versions []int
+	allocations := make(map[int][]poolAllocation)
+	for _, pool := range pools {
+		allocation, err := w.preparePoolAllocation(pool, peerName, taken)
+		if err != nil {
+			return "", err
+		}
+		if _, ok := allocations[pool.IPVersion]; !ok {
+			versions = append(versions, pool.IPVersion)
+		}
+		allocations[pool.IPVersion] = append(allocations[pool.IPVersion], allocation)
+	}
+
+	var addresses []string
+	for _, version := range versions {
+		nextIP, ok := nextPoolAddress(allocations[version])
+		if !ok {
+			w.logger.Error("IP pools exhausted",
+				zap.String("interface", iface.Name),
+				zap.Int("ip_version", version))
+			return "", fmt.Errorf("IP pool exhausted for interface %s", iface.Name)
+		}
+
+		for _, allocation := range allocations[version] {
+			if allocation.reservedIP.IsValid() && allocation.reservedIP != nextIP {
+				w.logger.Warn("reserved address is already in use, allocating another one",
+					zap.String("peer", peerName), zap.String("reserved_ip", allocation.reservedIP.String()))
+			}
+		}
+
+		addresses = append(addresses, netip.PrefixFrom(nextIP, nextIP.BitLen()).String())
+	}
+
+	return strings.Join(addresses, ","), nil
+}
+
+func (w *WgPeer) preparePoolAllocation(pool model.IPPool, peerName string, taken []netip.Prefix) (poolAllocation, error) {
+	startIP, endIP, err := parsePoolBounds(pool)
+	if err != nil {
+		w.logger.Error("invalid IP pool format",
+			zap.String("start_ip", pool.StartIP),
+			zap.String("end_ip", pool.EndIP))
+		return poolAllocation{}, fmt.Errorf("invalid IP pool format")
+	}
+	allocation := poolAllocation{startIP: startIP, endIP: endIP}
+
+	var reservations []model.IPReservation
+	if err := w.db.Find(&reservations, "pool_id = ?", pool.ID).Error; err != nil {
+		w.logger.Error("failed to query IP reservations from database", zap.Error(err))
+		return poolAllocation{}, err
+	}
+
+	for _, prefix := range taken {
+		if ip := prefix.Addr().Unmap(); ip.BitLen() == startIP.BitLen() {
+			allocation.blocked = append(allocation.blocked, addressRange{start: ip, end: ip})
+		}
+	}
+
+	for _, reservation := range reservations {
+		reserved, ok := parseReservation(reservation)
+		if !ok || reserved.start.BitLen() != startIP.BitLen() {
+			continue
+		}
+		if peerName != "" && reservation.PeerName != nil && *reservation.PeerName == peerName {
+			allocation.reservedIP = reserved.start
+			continue
+		}
+		allocation.blocked = append(allocation.blocked, reserved)
+	}
+
+	return allocation, nil
+}
+
+// nextPoolAddress picks a free static reservation of the pools of one IP version first, then the lowest free address
+// of the first pool that has one
+func nextPoolAddress(allocations []poolAllocation) (netip.Addr, bool) {
+	for _, allocation := range allocations {
+		if allocation.reservedIP.IsValid() && !isBlocked(allocation.reservedIP, allocation.blocked) {
+			return allocation.reservedIP, true
+		}
+	}
+
+	for _, allocation := range allocations {
+		if nextIP, ok := lowestFreeAddress(allocation.startIP, allocation.endIP, allocation.blocked); ok {
+			return nextIP, true
+		}
+	}
+
+	return netip.Addr{}, false
+}
+
+// lowestFreeAddress returns the first address from start to end outside the blocked ranges
+func lowestFreeAddress(start, end netip.Addr, blocked []addressRange) (netip.Addr, bool) {
+	sort.Slice(blocked, func(i, j int) bool { return blocked[i].start.Less(blocked[j].start) })
+
+	candidate := start
+	for _, r := range blocked {
+		if r.end.Less(candidate) {
+			continue
+		}
+		if candidate.Less(r.start) {
+			break
+		}
+		if candidate = r.end.Next(); !candidate.IsValid() {
+			return candidate, false
+		}
+	}
+
+	return candidate, !end.Less(candidate)
+}
+
+func isBlocked(ip netip.Addr, blocked []addressRange) bool {
+	for _, r := range blocked {
+		if !ip.Less(r.start) && !r.end.Less(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseReservation(reservation model.IPReservation) (addressRange, bool) {
+	start, err := utils.ParseAddress(reservation.StartIP)
+	if err != nil {
+		return addressRange{}, false
+	}
+	end, err := utils.ParseAddress(reservation.EndIP)
+	if err != nil || start.BitLen() != end.BitLen() || end.Less(start) {
+		return addressRange{}, false
+	}
+	return addressRange{start: start, end: end}, true
+}
@@ -1,35 +1,41 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"net"
+	"math"
+	"net/netip"
 	"strings"
 
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 
+	"github.com/maahdima/mwp/api/adaptor/mikrotik"
+	"github.com/maahdima/mwp/api/common"
 	"github.com/maahdima/mwp/api/dataservice/model"
 	"github.com/maahdima/mwp/api/http/schema"
 	"github.com/maahdima/mwp/api/utils"
 )
 
 type IPPool struct {
-	db     *gorm.DB
-	logger *zap.Logger
+	db              *gorm.DB
+	mikrotikAdaptor *mikrotik.Adaptor
+	logger          *zap.Logger
 }
 
-func NewIPPool(db *gorm.DB) *IPPool {
+func NewIPPool(db *gorm.DB, mikrotikAdaptor *mikrotik.Adaptor) *IPPool {
 	return &IPPool{
-		db:     db,
-		logger: zap.L().Named("IPPoolService"),
+		db:              db,
+		mikrotikAdaptor: mikrotikAdaptor,
+		logger:          zap.L().Named("IPPoolService"),
 	}
 }
 
 func (s *IPPool) GetIPPools() (*[]schema.IPPoolResponse, error) {
 	var dbPools []model.IPPool
 
-	if err := s.db.Find(&dbPools).Error; err != nil {
+	if err := s.db.Order("interface_id ASC, priority ASC, id ASC").Find(&dbPools).Error; err != nil {
 		s.logger.Error("failed to get IP pools", zap.Error(err))
 		return nil, err
 	}
@@ -55,21 +61,29 @@ func (s *IPPool) UpdateIPPool(id uint, req *schema.UpdateIPPoolRequest) (*schema
 		return nil, err
 	}
 
-	_, _, err := net.ParseCIDR(req.StartIP)
+	iface, err := s.getInterface(pool.InterfaceID)
 	if err != nil {
-		s.logger.Error("failed to parse start IP", zap.String("start_ip", pool.StartIP), zap.Error(err))
 		return nil, err
 	}
 
-	_, _, err = net.ParseCIDR(req.EndIP)
-	if err != nil {
-		s.logger.Error("failed to parse end IP", zap.String("end_ip", pool.EndIP), zap.Error(err))
+	startIP, endIP := req.StartIP, req.EndIP
+	if req.CIDR == "" && startIP == "" && endIP == "" {
+		startIP, endIP = pool.StartIP, pool.EndIP
+		if pool.CIDR != nil {
+			req.CIDR = *pool.CIDR
+		}
+	}
+	if err := s.resolvePoolRange(iface, &pool, req.CIDR, startIP, endIP); err != nil {
+		s.logger.Warn("invalid IP pool range", zap.String("cidr", req.CIDR), zap.String("start_ip", startIP), zap.String("end_ip", endIP), zap.Error(err))
 		return nil, err
 	}
 
-	pool.Name = req.Name
-	pool.StartIP = req.StartIP
-	pool.EndIP = req.EndIP
+	if req.Name != "" {
+		pool.Name = req.Name
+	}
+	if req.Priority != nil {
+		pool.Priority = *req.Priority
+	}
 
 	if err := s.db.Save(&pool).Error; err != nil {
 		s.logger.Error("failed to update IP pool", zap.Uint("id", id), zap.Error(err))
@@ -81,33 +95,33 @@ func (s *IPPool) UpdateIPPool(id uint, req *schema.UpdateIPPoolRequest) (*schema
 }
 
 func (s *IPPool) CreateIPPool(req *schema.CreateIPPoolRequest) (*schema.IPPoolResponse, error) {
-	_, _, err := net.ParseCIDR(req.StartIP)
+	iface, err := s.getInterface(req.InterfaceID)
 	if err != nil {
-		s.logger.Error("failed to parse start IP", zap.String("start_ip", req.StartIP), zap.Error(err))
 		return nil, err
 	}
 
-	_, _, err = net.ParseCIDR(req.EndIP)
-	if err != nil {
-		s.logger.Error("failed to parse end IP", zap.String("end_ip", req.EndIP), zap.Error(err))
-		return nil, err
+	pool := model.IPPool{
+		Name:        req.Name,
+		InterfaceID: iface.ID,
 	}
-
-	var iface model.Interface
-	if err := s.db.First(&iface, req.InterfaceID).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			s.logger.Warn("interface not found", zap.Uint("id", req.InterfaceID))
-			return nil, gorm.ErrRecordNotFound
-		}
-		s.logger.Error("failed to find interface", zap.Uint("id", req.InterfaceID), zap.Error(err))
+	if err := s.resolvePoolRange(iface, &pool, req.CIDR, req.StartIP, req.EndIP); err != nil {
+		s.logger.Warn("invalid IP pool range", zap.String("cidr", req.CIDR), zap.String("start_ip", req.StartIP), zap.String("end_ip", req.EndIP), zap.Error(err))
 		return nil, err
 	}
 
-	pool := model.IPPool{
-		Name:        req.Name,
-		StartIP:     req.StartIP,
-		EndIP:       req.EndIP,
-		InterfaceID: iface.ID,
+	// new pools are tried after the existing ones of the interface unless a priority is given
+	if req.Priority != nil {
+		pool.Priority = *req.Priority
+	} else {
+		var last model.IPPool
+		err := s.db.Where("interface_id = ?", iface.ID).Order("priority DESC").Limit(1).Find(&last).Error
+		if err != nil {
+			s.logger.Error("failed to find IP pools of interface", zap.Uint("interface_id", iface.ID), zap.Error(err))
+			return nil, err
+		}
+		if last.ID != 0 {
+			pool.Priority = last.Priority + 1
+		}
 	}
 
 	if err := s.db.Create(&pool).Error; err != nil {
@@ -131,7 +145,13 @@ func (s *IPPool) DeleteIPPool(id uint) error {
 		return err
 	}
 
-	if err := s.db.Unscoped().Delete(&pool).Error; err != nil {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("pool_id = ?", pool.ID).Delete(&model.IPReservation{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&pool).Error
+	})
+	if err != nil {
 		s.logger.Error("failed to delete IP pool", zap.Uint("id", id), zap.Error(err))
 		return err
 	}
@@ -139,20 +159,145 @@ func (s *IPPool) DeleteIPPool(id uint) error {
 	return nil
 }
 
+func (s *IPPool) getInterface(id uint) (model.Interface, error) {
+	var iface model.Interface
+	if err := s.db.Preload("Server").First(&iface, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.logger.Warn("interface not found", zap.Uint("id", id))
+			return iface, gorm.ErrRecordNotFound
+		}
+		s.logger.Error("failed to find interface", zap.Uint("id", id), zap.Error(err))
+		return iface, err
+	}
+	return iface, nil
+}
+
+// resolvePoolRange sets the range of the pool from a CIDR or from a start and end IP. The range must not overlap the
+// other pools of the server or hold the address of the interface on the router, a CIDR pool leaves that address out
+// when it is its first or last host.
+func (s *IPPool) resolvePoolRange(iface model.Interface, pool *model.IPPool, cidr, startIP, endIP string) error {
+	var start, end netip.Addr
+	if cidr != "" {
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(cidr))
+		if err != nil {
+			return fmt.Errorf("%w: invalid CIDR %q", common.ErrInvalidIPPool, cidr)
+		}
+		if start, end = cidrHosts(prefix.Masked()); !start.IsValid() {
+			return fmt.Errorf("%w: %s has no host addresses", common.ErrInvalidIPPool, prefix.Masked())
+		}
+		pool.CIDR = utils.Ptr(prefix.Masked().String())
+	} else {
+		if startIP == "" || endIP == "" {
+			return fmt.Errorf("%w: either a CIDR or a start and end IP are required", common.ErrInvalidIPPool)
+		}
+		if _, err := parsePoolRange(startIP, endIP); err != nil {
+			return err
+		}
+		start, _ = utils.ParseAddress(startIP)
+		end, _ = utils.ParseAddress(endIP)
+		pool.CIDR = nil
+	}
+
+	routerAddresses, err := s.interfaceAddresses(iface, start.Is4())
+	if err != nil {
+		return err
+	}
+	for _, routerAddress := range routerAddresses {
+		if routerAddress.Less(start) || end.Less(routerAddress) {
+			continue
+		}
+		switch {
+		case pool.CIDR != nil && routerAddress == start && start != end:
+			start = start.Next()
+		case pool.CIDR != nil && routerAddress == end && start != end:
+			end = end.Prev()
+		default:
+			return fmt.Errorf("%w: the range holds %s, the address of interface %s on the router", common.ErrInvalidIPPool, routerAddress, iface.Name)
+		}
+	}
+
+	var others []model.IPPool
+	err = s.db.Where("id <> ? AND interface_id IN (?)", pool.ID, s.db.Model(&model.Interface{}).Select("id").Where("server_id = ?", iface.ServerID)).
+		Find(&others).Error
+	if err != nil {
+		s.logger.Error("failed to find IP pools of server", zap.Uint("server_id", iface.ServerID), zap.Error(err))
+		return err
+	}
+	for _, other := range others {
+		otherStart, otherEnd, err := parsePoolBounds(other)
+		if err != nil || otherStart.BitLen() != start.BitLen() {
+			continue
+		}
+		if !end.Less(otherStart) && !otherEnd.Less(start) {
+			return fmt.Errorf("%w: the range overlaps pool %s (%s-%s)", common.ErrInvalidIPPool, other.Name, otherStart, otherEnd)
+		}
+	}
+
+	pool.StartIP = start.String()
+	pool.EndIP = end.String()
+	pool.IPVersion = 6
+	if start.Is4() {
+		pool.IPVersion = 4
+	}
+
+	return nil
+}
+
+// interfaceAddresses returns the addresses the router has on the interface, IPv4 or IPv6 ones
+func (s *IPPool) interfaceAddresses(iface model.Interface, ipv4 bool) ([]netip.Addr, error) {
+	fetch := s.mikrotikAdaptor.FetchIPv6Addresses
+	if ipv4 {
+		fetch = s.mikrotikAdaptor.FetchIPv4Addresses
+	}
+
+	routerAddresses, err := fetch(context.Background(), iface.Server)
+	if err != nil {
+		s.logger.Error("failed to fetch interface addresses from Mikrotik", zap.String("interface", iface.Name), zap.Error(err))
+		return nil, fmt.Errorf("failed to read the addresses of interface %s: %w", iface.Name, err)
+	}
+
+	var addresses []netip.Addr
+	for _, routerAddress := range routerAddresses {
+		if routerAddress.Interface != iface.Name {
+			continue
+		}
+		if addr, err := utils.ParseAddress(routerAddress.Address); err == nil {
+			addresses = append(addresses, addr)
+		}
+	}
+
+	return addresses, nil
+}
+
+// cidrHosts returns the first and last host of a prefix, an IPv4 subnet leaves out its network and broadcast address
+// and an IPv6 one its subnet-router anycast address
+func cidrHosts(prefix netip.Prefix) (netip.Addr, netip.Addr) {
+	start, end := prefix.Addr(), utils.LastAddr(prefix)
+	hostBits := start.BitLen() - prefix.Bits()
+
+	if start.Is4() && hostBits >= 2 {
+		return start.Next(), end.Prev()
+	}
+	if start.Is6() && hostBits >= 1 {
+		return start.Next(), end
+	}
+	return start, end
+}
+
 func (s *IPPool) transformPoolToResponse(pool model.IPPool) schema.IPPoolResponse {
-	startIP, _, err := net.ParseCIDR(pool.StartIP)
+	startIP, err := utils.ParseAddress(pool.StartIP)
 	if err != nil {
 		s.logger.Error("failed to parse start IP", zap.String("start_ip", pool.StartIP), zap.Error(err))
 		return schema.IPPoolResponse{}
 	}
 
-	endIP, _, err := net.ParseCIDR(pool.EndIP)
+	endIP, err := utils.ParseAddress(pool.EndIP)
 	if err != nil {
 		s.logger.Error("failed to parse end IP", zap.String("end_ip", pool.EndIP), zap.Error(err))
 		return schema.IPPoolResponse{}
 	}
 
-	totalIPs, usedIPs, remainingIPs, err := s.getIPCounts(startIP, endIP)
+	totalIPs, usedIPs, reservedIPs, remainingIPs, err := s.getIPCounts(pool, startIP, endIP)
 	if err != nil {
 		s.logger.Error("failed to get IP counts", zap.Error(err))
 		return schema.IPPoolResponse{}
@@ -161,52 +306,257 @@ func (s *IPPool) transformPoolToResponse(pool model.IPPool) schema.IPPoolRespons
 	return schema.IPPoolResponse{
 		Id:          pool.ID,
 		Name:        pool.Name,
+		InterfaceID: pool.InterfaceID,
+		IPVersion:   pool.IPVersion,
+		Priority:    pool.Priority,
+		CIDR:        pool.CIDR,
 		StartIP:     startIP.String(),
 		EndIP:       endIP.String(),
 		TotalIP:     totalIPs,
 		UsedIP:      usedIPs,
+		ReservedIP:  reservedIPs,
 		RemainingIP: remainingIPs,
 	}
 }
 
-func (s *IPPool) getIPCounts(startIP, endIP net.IP) (totalIPs, usedIPs, remainingIPs int, err error) {
-	start := utils.IPToUint32(startIP)
-	end := utils.IPToUint32(endIP)
-
-	if end < start {
+// getIPCounts counts the addresses of the pool held by the peers of its interface and by its reservations, a reserved
+// address given to its peer counts as used
+func (s *IPPool) getIPCounts(pool model.IPPool, startIP, endIP netip.Addr) (totalIPs, usedIPs, reservedIPs, remainingIPs int, err error) {
+	if endIP.Less(startIP) {
 		s.logger.Error("end IP is less than start IP", zap.String("start_ip", startIP.String()), zap.String("end_ip", endIP.String()))
-		return 0, 0, 0, fmt.Errorf("end IP cannot be before start IP")
+		return 0, 0, 0, 0, fmt.Errorf("end IP cannot be before start IP")
+	}
+
+	var iface model.Interface
+	if err := s.db.Select("id", "name", "server_id").First(&iface, pool.InterfaceID).Error; err != nil {
+		s.logger.Error("failed to fetch interface of IP pool", zap.Uint("interface_id", pool.InterfaceID), zap.Error(err))
+		return 0, 0, 0, 0, err
 	}
 
 	var peers []model.Peer
-	if err := s.db.Model(&model.Peer{}).
+	if err := s.db.Select("allowed_address").
+		Where("interface = ? AND server_id = ?", iface.Name, iface.ServerID).
 		Find(&peers).Error; err != nil {
 		s.logger.Error("failed to fetch peers", zap.Error(err))
-		return 0, 0, 0, err
+		return 0, 0, 0, 0, err
+	}
+
+	var reservations []model.IPReservation
+	if err := s.db.Find(&reservations, "pool_id = ?", pool.ID).Error; err != nil {
+		s.logger.Error("failed to fetch IP reservations", zap.Error(err))
+		return 0, 0, 0, 0, err
 	}
 
-	usedIPCount := 0
-	poolStartUint32 := utils.IPToUint32(startIP)
-	poolEndUint32 := utils.IPToUint32(endIP)
+	var reserved []addressRange
+	for _, reservation := range reservations {
+		r, ok := parseReservation(reservation)
+		if !ok || r.start.BitLen() != startIP.BitLen() || r.end.Less(startIP) || endIP.Less(r.start) {
+			continue
+		}
+		// a pool shrunk after the reservation was made only counts the part still inside it
+		if r.start.Less(startIP) {
+			r.start = startIP
+		}
+		if endIP.Less(r.end) {
+			r.end = endIP
+		}
+		reserved = append(reserved, r)
+		if size := utils.RangeSize(r.start, r.end); reservedIPs > math.MaxInt-size {
+			reservedIPs = math.MaxInt
+		} else {
+			reservedIPs += size
+		}
+	}
 
 	for _, peer := range peers {
-		ipStr := strings.Split(peer.AllowedAddress, "/")[0]
-		if ip := net.ParseIP(ipStr); ip != nil {
-			ipUint32 := utils.IPToUint32(ip)
-			if ipUint32 >= poolStartUint32 && ipUint32 <= poolEndUint32 {
-				usedIPCount++
+		for _, prefix := range utils.ParseAllowedAddresses(peer.AllowedAddress) {
+			ip := prefix.Addr().Unmap()
+			if ip.BitLen() != startIP.BitLen() || ip.Less(startIP) || endIP.Less(ip) {
+				continue
+			}
+			usedIPs++
+			if isBlocked(ip, reserved) {
+				reservedIPs--
 			}
 		}
 	}
 
-	totalIPs = int(end - start + 1)
-	usedIPs = usedIPCount
-	remainingIPs = totalIPs - usedIPs
+	totalIPs = utils.RangeSize(startIP, endIP)
+	remainingIPs = totalIPs - usedIPs - reservedIPs
 
 	if remainingIPs < 0 {
-		s.logger.Warn("remaining IPs is negative; resetting to zero", zap.Int("used", usedIPs), zap.Int("total", totalIPs))
+		s.logger.Warn("remaining IPs is negative; resetting to zero", zap.Int("used", usedIPs), zap.Int("reserved", reservedIPs), zap.Int("total", totalIPs))
 		remainingIPs = 0
 	}
 
-	return totalIPs, usedIPs, remainingIPs, nil
+	return totalIPs, usedIPs, reservedIPs, remainingIPs, nil
+}
+
+func (s *IPPool) GetIPReservations(poolID uint) (*[]schema.IPReservationResponse, error) {
+	if _, err := s.getPool(poolID); err != nil {
+		return nil, err
+	}
+
+	var dbReservations []model.IPReservation
+	if err := s.db.Order("id ASC").Find(&dbReservations, "pool_id = ?", poolID).Error; err != nil {
+		s.logger.Error("failed to get IP reservations", zap.Uint("pool_id", poolID), zap.Error(err))
+		return nil, err
+	}
+
+	reservations := make([]schema.IPReservationResponse, 0, len(dbReservations))
+	for _, reservation := range dbReservations {
+		reservations = append(reservations, transformReservationToResponse(reservation))
+	}
+
+	return &reservations, nil
+}
+
+// CreateIPReservation excludes a range of the pool from allocation, or reserves a single address for a peer name
+func (s *IPPool) CreateIPReservation(poolID uint, req *schema.CreateIPReservationRequest) (*schema.IPReservationResponse, error) {
+	pool, err := s.getPool(poolID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.EndIP == "" {
+		req.EndIP = req.StartIP
+	}
+	if req.PeerName != nil && *req.PeerName == "" {
+		req.PeerName = nil
+	}
+
+	reservation := model.IPReservation{
+		PoolID:   pool.ID,
+		StartIP:  req.StartIP,
+		EndIP:    req.EndIP,
+		PeerName: req.PeerName,
+		Comment:  req.Comment,
+	}
+	r, err := s.validateReservation(pool, reservation)
+	if err != nil {
+		s.logger.Warn("invalid IP reservation", zap.Uint("pool_id", poolID), zap.Error(err))
+		return nil, err
+	}
+	reservation.StartIP = r.start.String()
+	reservation.EndIP = r.end.String()
+
+	if err := s.db.Create(&reservation).Error; err != nil {
+		s.logger.Error("failed to create IP reservation", zap.Error(err))
+		return nil, err
+	}
+
+	resp := transformReservationToResponse(reservation)
+	return &resp, nil
+}
+
+func (s *IPPool) DeleteIPReservation(poolID, id uint) error {
+	var reservation model.IPReservation
+	if err := s.db.First(&reservation, "id = ? AND pool_id = ?", id, poolID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.logger.Warn("IP reservation not found", zap.Uint("pool_id", poolID), zap.Uint("id", id))
+			return gorm.ErrRecordNotFound
+		}
+		s.logger.Error("failed to find IP reservation", zap.Uint("id", id), zap.Error(err))
+		return err
+	}
+
+	if err := s.db.Unscoped().Delete(&reservation).Error; err != nil {
+		s.logger.Error("failed to delete IP reservation", zap.Uint("id", id), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (s *IPPool) getPool(id uint) (model.IPPool, error) {
+	var pool model.IPPool
+	if err := s.db.First(&pool, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.logger.Warn("IP pool not found", zap.Uint("id", id))
+			return pool, gorm.ErrRecordNotFound
+		}
+		s.logger.Error("failed to find IP pool", zap.Uint("id", id), zap.Error(err))
+		return pool, err
+	}
+	return pool, nil
+}
+
+// validateReservation checks that the reservation lies inside the pool, does not overlap another reservation of the
+// pool, and that a reservation for a peer name is a single address
+func (s *IPPool) validateReservation(pool model.IPPool, reservation model.IPReservation) (addressRange, error) {
+	startIP, endIP, err := parsePoolBounds(pool)
+	if err != nil {
+		return addressRange{}, fmt.Errorf("%w: the pool has an invalid range", common.ErrInvalidIPReservation)
+	}
+
+	r, ok := parseReservation(reservation)
+	if !ok {
+		return addressRange{}, fmt.Errorf("%w: start and end IP must be addresses of the same family in order", common.ErrInvalidIPReservation)
+	}
+	if r.start.BitLen() != startIP.BitLen() || r.start.Less(startIP) || endIP.Less(r.end) {
+		return addressRange{}, fmt.Errorf("%w: the range must lie inside the pool %s-%s", common.ErrInvalidIPReservation, startIP, endIP)
+	}
+	if reservation.PeerName != nil && r.start != r.end {
+		return addressRange{}, fmt.Errorf("%w: a reservation for a peer holds a single address", common.ErrInvalidIPReservation)
+	}
+
+	var others []model.IPReservation
+	if err := s.db.Find(&others, "pool_id = ?", pool.ID).Error; err != nil {
+		return addressRange{}, err
+	}
+	for _, other := range others {
+		if reservation.PeerName != nil && other.PeerName != nil && *other.PeerName == *reservation.PeerName {
+			return addressRange{}, fmt.Errorf("%w: the pool already holds an address for %s", common.ErrInvalidIPReservation, *reservation.PeerName)
+		}
+		o, ok := parseReservation(other)
+		if ok && !r.end.Less(o.start) && !o.end.Less(r.start) {
+			return addressRange{}, fmt.Errorf("%w: the range overlaps %s-%s", common.ErrInvalidIPReservation, o.start, o.end)
+		}
+	}
+
+	return r, nil
+}
+
+func transformReservationToResponse(reservation model.IPReservation) schema.IPReservationResponse {
+	return schema.IPReservationResponse{
+		Id:       reservation.ID,
+		StartIP:  reservation.StartIP,
+		EndIP:    reservation.EndIP,
+		PeerName: reservation.PeerName,
+		Comment:  reservation.Comment,
+	}
+}
+
+// parsePoolBounds returns the first and last address of a stored pool
+func parsePoolBounds(pool model.IPPool) (startIP, endIP netip.Addr, err error) {
+	if startIP, err = utils.ParseAddress(pool.StartIP); err != nil {
+		return startIP, endIP, err
+	}
+	if endIP, err = utils.ParseAddress(pool.EndIP); err != nil {
+		return startIP, endIP, err
+	}
+	return startIP, endIP, nil
+}
+
+// parsePoolRange checks that both ends of a pool are addresses of the same family in order and returns its IP version
+func parsePoolRange(startIP, endIP string) (int, error) {
+	start, err := utils.ParseAddress(startIP)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid start IP %q", common.ErrInvalidIPPool, startIP)
+	}
+	end, err := utils.ParseAddress(endIP)
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid end IP %q", common.ErrInvalidIPPool, endIP)
+	}
+	if start.Is4() != end.Is4() {
+		return 0, fmt.Errorf("%w: start and end IP must both be IPv4 or IPv6", common.ErrInvalidIPPool)
+	}
+	if end.Less(start) {
+		return 0, fmt.Errorf("%w: end IP cannot be before start IP", common.ErrInvalidIPPool)
+	}
+
+	if start.Is4() {
+		return 4, nil
+	}
+	return 6, nil
 }
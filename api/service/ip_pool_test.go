@@ -0,0 +1,230 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/dataservice/model"
+	"github.com/maahdima/mwp/api/http/schema"
+	"github.com/maahdima/mwp/api/utils"
+)
+
+func TestNewPeerAllowedAddressDualStack(t *testing.T) {
+	env := newTestEnv(t)
+	iface := env.seedInterface(t, "wg0")
+	env.seedPool(t, iface, "fd00::2", "fd00::ff")
+	env.seedPool(t, iface, "10.0.0.2", "10.0.0.254")
+	peers := env.peerService()
+
+	resp, err := peers.GetNewPeerAllowedAddress(iface.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.AllowedAddress != "10.0.0.2/32,fd00::2/128" {
+		t.Fatalf("allowed address = %q, want both stacks", resp.AllowedAddress)
+	}
+
+	if _, err := peers.CreatePeer(newCreatePeerRequest(t, iface, "alice", resp.AllowedAddress)); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err = peers.GetNewPeerAllowedAddress(iface.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.AllowedAddress != "10.0.0.3/32,fd00::3/128" {
+		t.Fatalf("allowed address = %q, want the next address of both pools", resp.AllowedAddress)
+	}
+}
+
+func TestCreateIPPoolFromCIDR(t *testing.T) {
+	env := newTestEnv(t)
+	iface := env.seedInterface(t, "wg0")
+	env.seedAddress(t, common.DeviceIPv4Path, iface, "10.0.0.1/24")
+	env.seedAddress(t, common.DeviceIPv6Path, iface, "fd00::1/64")
+	pools := NewIPPool(env.db, env.adaptor)
+
+	for _, want := range []struct{ cidr, startIP, endIP string }{
+		{"10.0.0.0/24", "10.0.0.2", "10.0.0.254"},
+		{"fd00::/120", "fd00::2", "fd00::ff"},
+	} {
+		pool, err := pools.CreateIPPool(&schema.CreateIPPoolRequest{Name: want.cidr, InterfaceID: iface.ID, CIDR: want.cidr})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pool.StartIP != want.startIP || pool.EndIP != want.endIP {
+			t.Fatalf("%s = %s-%s, want %s-%s without the router address", want.cidr, pool.StartIP, pool.EndIP, want.startIP, want.endIP)
+		}
+	}
+}
+
+func TestCreateIPPoolValidatesRange(t *testing.T) {
+	env := newTestEnv(t)
+	wg0 := env.seedInterface(t, "wg0")
+	wg1 := env.seedInterface(t, "wg1")
+	env.seedAddress(t, common.DeviceIPv4Path, wg0, "10.0.5.1/24")
+	pools := NewIPPool(env.db, env.adaptor)
+
+	if _, err := pools.CreateIPPool(&schema.CreateIPPoolRequest{Name: "first", InterfaceID: wg0.ID, StartIP: "10.0.0.2", EndIP: "10.0.0.100"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pools.CreateIPPool(&schema.CreateIPPoolRequest{Name: "second", InterfaceID: wg0.ID, StartIP: "10.0.1.2", EndIP: "10.0.1.100"}); err != nil {
+		t.Fatalf("a second IPv4 pool on the interface was refused: %v", err)
+	}
+
+	cases := []schema.CreateIPPoolRequest{
+		{Name: "overlapping", InterfaceID: wg1.ID, StartIP: "10.0.0.50", EndIP: "10.0.0.150"},
+		{Name: "router address", InterfaceID: wg0.ID, StartIP: "10.0.5.1", EndIP: "10.0.5.100"},
+		{Name: "mixed", InterfaceID: wg0.ID, StartIP: "10.1.0.2", EndIP: "fd02::ff"},
+		{Name: "reversed", InterfaceID: wg0.ID, StartIP: "fd03::ff", EndIP: "fd03::2"},
+		{Name: "no range", InterfaceID: wg0.ID},
+		{Name: "bad cidr", InterfaceID: wg0.ID, CIDR: "10.2.0.0/33"},
+	}
+	for _, req := range cases {
+		if _, err := pools.CreateIPPool(&req); !errors.Is(err, common.ErrInvalidIPPool) {
+			t.Fatalf("%s: err = %v, want ErrInvalidIPPool", req.Name, err)
+		}
+	}
+}
+
+func TestCreatePeerFallsThroughPoolsByPriority(t *testing.T) {
+	env := newTestEnv(t)
+	iface := env.seedInterface(t, "wg0")
+	pools := NewIPPool(env.db, env.adaptor)
+	peers := env.peerService()
+	first := -1
+
+	if _, err := pools.CreateIPPool(&schema.CreateIPPoolRequest{Name: "overflow", InterfaceID: iface.ID, StartIP: "10.0.1.2", EndIP: "10.0.1.100"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pools.CreateIPPool(&schema.CreateIPPoolRequest{Name: "main", InterfaceID: iface.ID, StartIP: "10.0.0.2", EndIP: "10.0.0.3", Priority: &first}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []struct{ name, address string }{
+		{"alice", "10.0.0.2/32"},
+		{"bob", "10.0.0.3/32"},
+		{"carol", "10.0.1.2/32"},
+	} {
+		resp, err := peers.CreatePeer(newCreatePeerRequest(t, iface, want.name, ""))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.AllowedAddress != want.address {
+			t.Fatalf("%s got %s, want %s", want.name, resp.AllowedAddress, want.address)
+		}
+	}
+}
+
+func TestIPPoolCountsIPv6Peers(t *testing.T) {
+	env := newTestEnv(t)
+	iface := env.seedInterface(t, "wg0")
+	env.seedPool(t, iface, "fd00::2", "fd00::11")
+	if _, err := env.peerService().CreatePeer(newCreatePeerRequest(t, iface, "alice", "fd00::2/128")); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := NewIPPool(env.db, env.adaptor).GetIPPools()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := (*resp)[0]
+	if pool.TotalIP != 16 || pool.UsedIP != 1 || pool.RemainingIP != 15 {
+		t.Fatalf("pool = %+v, want 1 of 16 addresses used", pool)
+	}
+}
+
+func TestCreatePeerAllocatesLowestFreeAddress(t *testing.T) {
+	env := newTestEnv(t)
+	iface := env.seedInterface(t, "wg0")
+	pool := env.seedPool(t, iface, "10.0.0.2", "10.0.0.10")
+	peers := env.peerService()
+
+	reservations := []model.IPReservation{
+		{PoolID: pool.ID, StartIP: "10.0.0.4", EndIP: "10.0.0.5"},
+		{PoolID: pool.ID, StartIP: "10.0.0.9", EndIP: "10.0.0.9", PeerName: utils.Ptr("erin")},
+	}
+	if err := env.db.Create(&reservations).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	created := make(map[string]uint)
+	for _, want := range []struct{ name, address string }{
+		{"alice", "10.0.0.2/32"},
+		{"bob", "10.0.0.3/32"},
+		{"carol", "10.0.0.6/32"},
+		{"erin", "10.0.0.9/32"},
+		{"dave", "10.0.0.7/32"},
+	} {
+		resp, err := peers.CreatePeer(newCreatePeerRequest(t, iface, want.name, ""))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.AllowedAddress != want.address {
+			t.Fatalf("%s got %s, want %s", want.name, resp.AllowedAddress, want.address)
+		}
+		created[want.name] = resp.Id
+	}
+
+	if err := peers.DeletePeer(created["bob"]); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := peers.CreatePeer(newCreatePeerRequest(t, iface, "frank", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.AllowedAddress != "10.0.0.3/32" {
+		t.Fatalf("frank got %s, want the gap bob left at 10.0.0.3/32", resp.AllowedAddress)
+	}
+}
+
+func TestCreateIPReservationValidatesRange(t *testing.T) {
+	env := newTestEnv(t)
+	iface := env.seedInterface(t, "wg0")
+	pool := env.seedPool(t, iface, "10.0.0.2", "10.0.0.254")
+	pools := NewIPPool(env.db, env.adaptor)
+
+	if _, err := pools.CreateIPReservation(pool.ID, &schema.CreateIPReservationRequest{StartIP: "10.0.0.10", EndIP: "10.0.0.20"}); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]schema.CreateIPReservationRequest{
+		"outside the pool":   {StartIP: "10.0.1.2"},
+		"overlapping":        {StartIP: "10.0.0.20", EndIP: "10.0.0.30"},
+		"range for a peer":   {StartIP: "10.0.0.40", EndIP: "10.0.0.41", PeerName: utils.Ptr("alice")},
+		"other address type": {StartIP: "fd00::2"},
+	}
+	for name, req := range cases {
+		if _, err := pools.CreateIPReservation(pool.ID, &req); !errors.Is(err, common.ErrInvalidIPReservation) {
+			t.Fatalf("%s: err = %v, want ErrInvalidIPReservation", name, err)
+		}
+	}
+}
+
+func TestIPPoolCountsPeersOfItsInterfaceAndReservations(t *testing.T) {
+	env := newTestEnv(t)
+	wg0 := env.seedInterface(t, "wg0")
+	wg1 := env.seedInterface(t, "wg1")
+	pool := env.seedPool(t, wg0, "10.0.0.2", "10.0.0.11")
+	peers := env.peerService()
+
+	if err := env.db.Create(&model.IPReservation{PoolID: pool.ID, StartIP: "10.0.0.2", EndIP: "10.0.0.4"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := peers.CreatePeer(newCreatePeerRequest(t, wg0, "alice", "10.0.0.5/32")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := peers.CreatePeer(newCreatePeerRequest(t, wg1, "bob", "10.0.0.6/32")); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := NewIPPool(env.db, env.adaptor).GetIPPools()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := (*resp)[0]
+	if got.TotalIP != 10 || got.UsedIP != 1 || got.ReservedIP != 3 || got.RemainingIP != 6 {
+		t.Fatalf("pool = %+v, want 1 used and 3 reserved of 10 addresses", got)
+	}
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/maahdima/mwp/api/adaptor/mikrotik"
+	"github.com/maahdima/mwp/api/dataservice/model"
+	"github.com/maahdima/mwp/api/utils"
+)
+
+// metricsScrapeTimeout bounds the router requests made while answering one scrape
+const metricsScrapeTimeout = 10 * time.Second
+
+// peerLabels name a peer series, peer names are not unique so the UUID tells peers of the same name apart
+var peerLabels = []string{"server", "interface", "peer", "peer_uuid"}
+
+var (
+	peerRxBytesDesc = prometheus.NewDesc("mwp_peer_rx_bytes_total",
+		"Bytes received from the peer (upload) since its usage was last reset.",
+		peerLabels, nil)
+	peerTxBytesDesc = prometheus.NewDesc("mwp_peer_tx_bytes_total",
+		"Bytes sent to the peer (download) since its usage was last reset.",
+		peerLabels, nil)
+	peerHandshakeAgeDesc = prometheus.NewDesc("mwp_peer_last_handshake_age_seconds",
+		"Seconds since the last handshake of the peer, absent when it never completed one.",
+		peerLabels, nil)
+	peerOnlineDesc = prometheus.NewDesc("mwp_peer_online",
+		"Whether the peer is enabled and completed a handshake recently.",
+		peerLabels, nil)
+	interfaceRxBytesDesc = prometheus.NewDesc("mwp_interface_rx_bytes_total",
+		"Bytes received on the WireGuard interface as counted by the router.",
+		[]string{"server", "interface"}, nil)
+	interfaceTxBytesDesc = prometheus.NewDesc("mwp_interface_tx_bytes_total",
+		"Bytes sent on the WireGuard interface as counted by the router.",
+		[]string{"server", "interface"}, nil)
+	routerUpDesc = prometheus.NewDesc("mwp_router_up",
+		"Whether the router answered the last scrape.",
+		[]string{"server"}, nil)
+)
+
+// MetricsCollector exposes the peer and interface series. Usage counters come from the database, handshakes and
+// interface bytes are read from every active router on each scrape.
+type MetricsCollector struct {
+	db          *gorm.DB
+	peerService *WgPeer
+	logger      *zap.Logger
+}
+
+func NewMetricsCollector(db *gorm.DB, peerService *WgPeer) *MetricsCollector {
+	return &MetricsCollector{
+		db:          db,
+		peerService: peerService,
+		logger:      zap.L().Named("MetricsCollector"),
+	}
+}
+
+func (m *MetricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- peerRxBytesDesc
+	ch <- peerTxBytesDesc
+	ch <- peerHandshakeAgeDesc
+	ch <- peerOnlineDesc
+	ch <- interfaceRxBytesDesc
+	ch <- interfaceTxBytesDesc
+	ch <- routerUpDesc
+}
+
+func (m *MetricsCollector) Collect(ch chan<- prometheus.Metric) {
+	var servers []model.Server
+	if err := m.db.Where("is_active = ?", true).Find(&servers).Error; err != nil {
+		m.logger.Error("failed to fetch active servers", zap.Error(err))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), metricsScrapeTimeout)
+	defer cancel()
+
+	for _, server := range servers {
+		m.collectServer(ctx, server, ch)
+	}
+}
+
+func (m *MetricsCollector) collectServer(ctx context.Context, server model.Server, ch chan<- prometheus.Metric) {
+	var peers []model.Peer
+	if err := m.db.Where("server_id = ?", server.ID).Find(&peers).Error; err != nil {
+		m.logger.Error("failed to fetch peers", zap.String("server", server.Name), zap.Error(err))
+		return
+	}
+
+	for _, peer := range peers {
+		ch <- prometheus.MustNewConstMetric(peerRxBytesDesc, prometheus.CounterValue, float64(peer.UploadUsage), server.Name, peer.Interface, peer.Name, peer.UUID)
+		ch <- prometheus.MustNewConstMetric(peerTxBytesDesc, prometheus.CounterValue, float64(peer.DownloadUsage), server.Name, peer.Interface, peer.Name, peer.UUID)
+	}
+
+	wgPeers, err := m.peerService.mikrotikAdaptor.FetchWgPeers(ctx, server)
+	if err != nil {
+		m.logger.Warn("failed to fetch peers from router", zap.String("server", server.Name), zap.Error(err))
+		ch <- prometheus.MustNewConstMetric(routerUpDesc, prometheus.GaugeValue, 0, server.Name)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(routerUpDesc, prometheus.GaugeValue, 1, server.Name)
+
+	wgPeerMap := make(map[string]mikrotik.WireGuardPeer, len(wgPeers))
+	for _, wgPeer := range wgPeers {
+		wgPeerMap[wgPeer.ID] = wgPeer
+	}
+
+	for _, peer := range peers {
+		wgPeer, found := wgPeerMap[peer.PeerID]
+		if !found {
+			continue
+		}
+
+		age, isOnline, err := m.peerService.handshakeData(&wgPeer)
+		if err != nil {
+			continue
+		}
+		if wgPeer.LastHandshake != nil {
+			ch <- prometheus.MustNewConstMetric(peerHandshakeAgeDesc, prometheus.GaugeValue, age.Seconds(), server.Name, peer.Interface, peer.Name, peer.UUID)
+		}
+		ch <- prometheus.MustNewConstMetric(peerOnlineDesc, prometheus.GaugeValue, boolToFloat(isOnline), server.Name, peer.Interface, peer.Name, peer.UUID)
+	}
+
+	var interfaces []model.Interface
+	if err := m.db.Where("server_id = ?", server.ID).Find(&interfaces).Error; err != nil {
+		m.logger.Error("failed to fetch interfaces", zap.String("server", server.Name), zap.Error(err))
+		return
+	}
+	if len(interfaces) == 0 {
+		return
+	}
+
+	routerInterfaces, err := m.peerService.mikrotikAdaptor.FetchInterfaces(ctx, server)
+	if err != nil {
+		m.logger.Warn("failed to fetch interfaces from router", zap.String("server", server.Name), zap.Error(err))
+		return
+	}
+	routerInterfaceMap := make(map[string]mikrotik.Interface, len(routerInterfaces))
+	for _, iface := range routerInterfaces {
+		routerInterfaceMap[iface.Name] = iface
+	}
+
+	for _, iface := range interfaces {
+		routerInterface, found := routerInterfaceMap[iface.Name]
+		if !found {
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(interfaceRxBytesDesc, prometheus.CounterValue, float64(utils.ParseStringToInt(routerInterface.RxByte)), server.Name, iface.Name)
+		ch <- prometheus.MustNewConstMetric(interfaceTxBytesDesc, prometheus.CounterValue, float64(utils.ParseStringToInt(routerInterface.TxByte)), server.Name, iface.Name)
+	}
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/maahdima/mwp/api/dataservice/model"
+)
+
+func TestMetricsCollectorLabelsPeersByUUID(t *testing.T) {
+	env := newTestEnv(t)
+	iface := env.seedInterface(t, "wg0")
+	peers := env.peerService()
+
+	first, err := peers.CreatePeer(newCreatePeerRequest(t, iface, "phone", "10.0.0.2/32"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// peer names are not unique, an imported peer may share the name of one created in the panel
+	second := model.Peer{
+		ServerID:       env.server.ID,
+		UUID:           "imported-phone",
+		PeerID:         "*FF",
+		Name:           "phone",
+		Interface:      "wg0",
+		AllowedAddress: "10.0.0.3/32",
+	}
+	if err := env.db.Create(&second).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `
+# HELP mwp_peer_rx_bytes_total Bytes received from the peer (upload) since its usage was last reset.
+# TYPE mwp_peer_rx_bytes_total counter
+mwp_peer_rx_bytes_total{interface="wg0",peer="phone",peer_uuid="` + first.UUID + `",server="r1"} 0
+mwp_peer_rx_bytes_total{interface="wg0",peer="phone",peer_uuid="` + second.UUID + `",server="r1"} 0
+`
+	collector := NewMetricsCollector(env.db, peers)
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(expected), "mwp_peer_rx_bytes_total"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,11 +1,9 @@
 package service
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
-	"net"
 	"sort"
 	"strconv"
 	"strings"
@@ -29,31 +27,34 @@ type WgPeer struct {
 	mikrotikAdaptor *mikrotik.Adaptor
 	scheduler       *Scheduler
 	queue           *Queue
-	configGenerator *ConfigGenerator
-	qrCodeGenerator *QRCodeGenerator
+	renderer        *PeerRenderer
 	logger          *zap.Logger
 }
 
-func NewWGPeer(db *gorm.DB, mikrotikAdaptor *mikrotik.Adaptor, scheduler *Scheduler, queue *Queue, configGenerator *ConfigGenerator, qrCodeGenerator *QRCodeGenerator) *WgPeer {
+func NewWGPeer(db *gorm.DB, mikrotikAdaptor *mikrotik.Adaptor, scheduler *Scheduler, queue *Queue, renderer *PeerRenderer) *WgPeer {
 	return &WgPeer{
 		db:              db,
 		mikrotikAdaptor: mikrotikAdaptor,
 		scheduler:       scheduler,
 		queue:           queue,
-		configGenerator: configGenerator,
-		qrCodeGenerator: qrCodeGenerator,
+		renderer:        renderer,
 		logger:          zap.L().Named("WgPeerService"),
 	}
 }
 
 func (w *WgPeer) TogglePeerStatus(id uint) error {
 	var peer model.Peer
-	if err := w.db.First(&peer, "id = ?", id).Error; err != nil {
+	if err := w.db.Preload("Server").First(&peer, "id = ?", id).Error; err != nil {
 		w.logger.Error("failed to find peer in database", zap.Error(err))
 		return fmt.Errorf("peer not found: %w", err)
 	}
 
-	disabled := strconv.FormatBool(!peer.Disabled)
+	return w.setPeerStatus(peer, !peer.Disabled, common.PeerDisabledByAdmin)
+}
+
+// setPeerStatus enables or disables a peer together with its scheduler and queue, reason is recorded when disabling
+func (w *WgPeer) setPeerStatus(peer model.Peer, isDisabled bool, reason string) error {
+	disabled := strconv.FormatBool(isDisabled)
 
 	wgPeer := mikrotik.WireGuardPeer{
 		Disabled: disabled,
@@ -65,25 +66,29 @@ func (w *WgPeer) TogglePeerStatus(id uint) error {
 		Disabled: disabled,
 	}
 
-	if _, err := w.mikrotikAdaptor.UpdateWgPeer(context.Background(), peer.PeerID, wgPeer); err != nil {
+	if _, err := w.mikrotikAdaptor.UpdateWgPeer(context.Background(), peer.Server, peer.PeerID, wgPeer); err != nil {
 		w.logger.Error("failed to update wireguard peer in Mikrotik", zap.Error(err))
 		return fmt.Errorf("failed to update wireguard peer: %w", err)
 	}
 
 	if peer.SchedulerID != nil {
-		if _, err := w.mikrotikAdaptor.UpdateScheduler(context.Background(), *peer.SchedulerID, wgScheduler); err != nil {
+		if _, err := w.mikrotikAdaptor.UpdateScheduler(context.Background(), peer.Server, *peer.SchedulerID, wgScheduler); err != nil {
 			w.logger.Error("failed to update scheduler for wireguard peer", zap.Error(err))
 			return fmt.Errorf("failed to update scheduler: %w", err)
 		}
 	}
 	if peer.QueueID != nil {
-		if _, err := w.mikrotikAdaptor.UpdateSimpleQueue(context.Background(), *peer.QueueID, wgQueue); err != nil {
+		if _, err := w.mikrotikAdaptor.UpdateSimpleQueue(context.Background(), peer.Server, *peer.QueueID, wgQueue); err != nil {
 			w.logger.Error("failed to update queue for wireguard peer", zap.Error(err))
 			return fmt.Errorf("failed to update queue: %w", err)
 		}
 	}
 
-	if err := w.db.Model(&peer).Update("disabled", disabled).Error; err != nil {
+	updates := map[string]interface{}{
+		"disabled":        isDisabled,
+		"disabled_reason": peerDisabledReason(isDisabled, reason),
+	}
+	if err := w.db.Model(&peer).Updates(updates).Error; err != nil {
 		w.logger.Error("failed to update peer status in database", zap.Error(err))
 		return fmt.Errorf("failed to update peer status in database: %w", err)
 	}
@@ -110,9 +115,11 @@ func (w *WgPeer) GetPeerCredentials() (*schema.PeerCredentialsResponse, error) {
 	}, nil
 }
 
-func (w *WgPeer) GetNewPeerAllowedAddress(interfaceId uint) (*schema.NewPeerAllowedAddressResponse, error) {
+// GetNewPeerAllowedAddress returns the lowest free address of every pool of the interface, an IPv4 /32 and an IPv6
+// /128 for a dual stack interface, as a RouterOS allowed-address list. A peer name picks up its static reservations.
+func (w *WgPeer) GetNewPeerAllowedAddress(interfaceId uint, peerName string) (*schema.NewPeerAllowedAddressResponse, error) {
 	var iface model.Interface
-	if err := w.db.Preload("IPPool").First(&iface, "id = ?", interfaceId).Error; err != nil {
+	if err := w.db.Preload("IPPools").First(&iface, "id = ?", interfaceId).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			w.logger.Error("interface not found in database", zap.Uint("interfaceId", interfaceId))
 			return nil, fmt.Errorf("interface %d not found", interfaceId)
@@ -121,78 +128,18 @@ func (w *WgPeer) GetNewPeerAllowedAddress(interfaceId uint) (*schema.NewPeerAllo
 		return nil, err
 	}
 
-	if iface.IPPool == nil {
+	if len(iface.IPPools) == 0 {
 		w.logger.Warn("no IP pool associated with interface", zap.Uint("interfaceId", interfaceId))
 		return &schema.NewPeerAllowedAddressResponse{AllowedAddress: ""}, nil
 	}
 
-	startIP := net.ParseIP(strings.TrimSuffix(iface.IPPool.StartIP, "/32")).To4()
-	endIP := net.ParseIP(strings.TrimSuffix(iface.IPPool.EndIP, "/32")).To4()
-
-	if startIP == nil || endIP == nil {
-		w.logger.Error("invalid IP pool format",
-			zap.String("start_ip", iface.IPPool.StartIP),
-			zap.String("end_ip", iface.IPPool.EndIP))
-		return nil, fmt.Errorf("invalid IP pool format")
-	}
-
-	var peers []model.Peer
-	if err := w.db.Find(&peers, "interface = ?", iface.Name).Error; err != nil {
-		w.logger.Error("failed to query peers from database", zap.Error(err))
-		return nil, fmt.Errorf("failed to find peers: %w", err)
-	}
-
-	var lastIP net.IP
-
-	if len(peers) == 0 {
-		w.logger.Info("no peers found for interface, assigning start IP", zap.Uint("interfaceId", interfaceId))
-		lastIP = make(net.IP, len(startIP))
-		copy(lastIP, startIP)
-		for i := len(lastIP) - 1; i >= 0; i-- {
-			lastIP[i]--
-			if lastIP[i] != 255 {
-				break
-			}
-		}
-	} else {
-		var highestIP net.IP
-		for _, peer := range peers {
-			currentIP, _, err := net.ParseCIDR(peer.AllowedAddress)
-			if err != nil {
-				w.logger.Warn("skipping peer with invalid allowed_address",
-					zap.String("allowed_address", peer.AllowedAddress), zap.Error(err))
-				continue
-			}
-			currentIP = currentIP.To4()
-			if currentIP == nil {
-				continue
-			}
-
-			if highestIP == nil || bytes.Compare(currentIP, highestIP) > 0 {
-				highestIP = currentIP
-			}
-		}
-		lastIP = highestIP
-	}
-
-	nextIP := make(net.IP, len(lastIP))
-	copy(nextIP, lastIP)
-	for i := len(nextIP) - 1; i >= 0; i-- {
-		nextIP[i]++
-		if nextIP[i] != 0 {
-			break
-		}
-	}
-
-	if bytes.Compare(nextIP, endIP) > 0 {
-		w.logger.Error("IP pool exhausted or next IP out of range",
-			zap.String("next_ip", nextIP.String()),
-			zap.String("end_ip", endIP.String()))
-		return nil, fmt.Errorf("IP pool exhausted for interface %d", interfaceId)
+	allowedAddress, err := w.allocatePeerAddress(iface, peerName, nil)
+	if err != nil {
+		return nil, err
 	}
 
 	return &schema.NewPeerAllowedAddressResponse{
-		AllowedAddress: fmt.Sprintf("%s/32", nextIP.String()),
+		AllowedAddress: allowedAddress,
 	}, nil
 }
 
@@ -261,7 +208,7 @@ func (w *WgPeer) UpdatePeerShareExpireTime(id uint, expireTime *string) error {
 
 func (w *WgPeer) GetPeerDetails(uuid string) (*schema.PeerDetailsResponse, error) {
 	var peer model.Peer
-	if err := w.db.First(&peer, "uuid = ?", uuid).Error; err != nil {
+	if err := w.db.Preload("Server").First(&peer, "uuid = ?", uuid).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			w.logger.Error("peer not found in database", zap.String("uuid", uuid))
 			return nil, err
@@ -284,7 +231,7 @@ func (w *WgPeer) GetPeerDetails(uuid string) (*schema.PeerDetailsResponse, error
 		usagePercent = utils.Ptr(fmt.Sprintf("%.1f", percent))
 	}
 
-	mtPeer, err := w.mikrotikAdaptor.FetchWgPeer(context.Background(), peer.PeerID)
+	mtPeer, err := w.mikrotikAdaptor.FetchWgPeer(context.Background(), peer.Server, peer.PeerID)
 	if err != nil {
 		w.logger.Error("failed to fetch wireguard peer from Mikrotik", zap.String("peer_id", peer.PeerID), zap.Error(err))
 		return nil, fmt.Errorf("failed to fetch wireguard peer: %w", err)
@@ -308,8 +255,8 @@ func (w *WgPeer) GetPeerDetails(uuid string) (*schema.PeerDetailsResponse, error
 	}, nil
 }
 
-func (w *WgPeer) GetPeers() (*[]schema.PeerResponse, error) {
-	peers, err := w.mikrotikAdaptor.FetchWgPeers(context.Background())
+func (w *WgPeer) GetPeers(server model.Server) (*[]schema.PeerResponse, error) {
+	peers, err := w.mikrotikAdaptor.FetchWgPeers(context.Background(), server)
 	if err != nil {
 		w.logger.Error("failed to fetch wireguard peers from Mikrotik", zap.Error(err))
 		return nil, fmt.Errorf("failed to fetch wireguard peers: %w", err)
@@ -319,7 +266,7 @@ func (w *WgPeer) GetPeers() (*[]schema.PeerResponse, error) {
 	for _, peer := range peers {
 		var dbPeer model.Peer
 
-		if err := w.db.Where("peer_id = ?", peer.ID).First(&dbPeer).Error; err != nil {
+		if err := w.db.Where("peer_id = ? AND server_id = ?", peer.ID, server.ID).First(&dbPeer).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
 				w.logger.Warn("peer found on Mikrotik but not in DB, skipping", zap.String("peer_id", peer.ID))
 				continue
@@ -350,31 +297,85 @@ func (w *WgPeer) CreatePeer(req *schema.CreatePeerRequest) (*schema.PeerResponse
 		return nil, err
 	}
 
-	if err := w.ensureAllowedAddressIsUnique(req.AllowedAddress); err != nil {
+	plan, err := w.applyPeerPlan(req)
+	if err != nil {
 		return nil, err
 	}
 
-	mtPeer, err := w.createMikrotikPeer(req, iface.Name)
-	if err != nil {
+	if err := validateClientSettings(req.DNS, req.AllowedIPsMode, req.AllowedIPs, req.MTU, iface.ClientAllowedIPs); err != nil {
 		return nil, err
 	}
 
-	schedulerId, err := w.scheduler.createScheduler(mtPeer.ID, mtPeer.Name, req.ExpireTime)
+	if req.PresharedKey == nil && iface.GeneratePresharedKey {
+		presharedKey, err := wireguard.GeneratePresharedKey()
+		if err != nil {
+			return nil, err
+		}
+		req.PresharedKey = &presharedKey
+	}
+
+	// hold the address until the peer is stored, a concurrent create must see it taken
+	unlock := peerAddressLocks.lock(iface.ServerID)
+	defer unlock()
+
+	if req.AllowedAddress == "" {
+		allowedAddress, err := w.GetNewPeerAllowedAddress(iface.ID, req.Name)
+		if err != nil {
+			return nil, err
+		}
+		if allowedAddress.AllowedAddress == "" {
+			return nil, fmt.Errorf("interface %s has no IP pool, an allowed address is required", iface.Name)
+		}
+		req.AllowedAddress = allowedAddress.AllowedAddress
+	}
+
+	if err := w.ensureAllowedAddressIsUnique(iface.ServerID, req.AllowedAddress); err != nil {
+		return nil, err
+	}
+
+	tx := newSaga("create peer", w.logger)
+
+	var mtPeer *mikrotik.WireGuardPeer
+	err = tx.run("create wireguard peer", func() (err error) {
+		mtPeer, err = w.createMikrotikPeer(iface.Server, req, iface.Name)
+		return err
+	}, func() error {
+		return w.mikrotikAdaptor.DeleteWgPeer(context.Background(), iface.Server, mtPeer.ID)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	queueId, err := w.queue.createQueue(mtPeer.Name, mtPeer.AllowedAddress, req.DownloadBandwidth, req.UploadBandwidth)
+	var schedulerId *string
+	err = tx.run("create scheduler", func() (err error) {
+		schedulerId, err = w.scheduler.createScheduler(iface.Server, mtPeer.ID, mtPeer.Name, req.ExpireTime)
+		return err
+	}, func() error {
+		return w.scheduler.deleteScheduler(iface.Server, schedulerId)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	dbPeer, err := w.buildAndStoreDbPeer(req, iface, mtPeer, schedulerId, queueId)
+	var queueId *string
+	err = tx.run("create queue", func() (err error) {
+		queueId, err = w.queue.createQueue(iface.Server, mtPeer.Name, mtPeer.AllowedAddress, req.DownloadBandwidth, req.UploadBandwidth)
+		return err
+	}, func() error {
+		return w.queue.deleteQueue(iface.Server, queueId)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	if err := w.generatePeerAssets(req.PrivateKey, dbPeer, iface.PublicKey); err != nil {
+	var dbPeer model.Peer
+	err = tx.run("store peer", func() (err error) {
+		dbPeer, err = w.buildAndStoreDbPeer(req, iface, plan, mtPeer, schedulerId, queueId)
+		return err
+	}, func() error {
+		return w.db.Unscoped().Delete(&dbPeer).Error
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -384,27 +385,63 @@ func (w *WgPeer) CreatePeer(req *schema.CreatePeerRequest) (*schema.PeerResponse
 
 func (w *WgPeer) UpdatePeer(id uint, req *schema.UpdatePeerRequest) (*schema.PeerResponse, error) {
 	var peer model.Peer
-	if err := w.db.First(&peer, "id = ?", id).Error; err != nil {
+	if err := w.db.Preload("Server").First(&peer, "id = ?", id).Error; err != nil {
 		w.logger.Error("failed to get peer from database", zap.Error(err))
 		return nil, err
 	}
 
-	if err := w.updateMikrotikPeer(peer.PeerID, req); err != nil {
+	iface, err := w.peerInterface(peer)
+	if err != nil {
+		return nil, err
+	}
+
+	clientSettings := applyPeerClientSettings(peer, req)
+	err = validateClientSettings(clientSettings.DNS, clientSettings.AllowedIPsMode, clientSettings.AllowedIPs, clientSettings.MTU, iface.ClientAllowedIPs)
+	if err != nil {
+		return nil, err
+	}
+
+	// snapshot the router side so a failed update can be reverted
+	mtPeer, err := w.mikrotikAdaptor.FetchWgPeer(context.Background(), peer.Server, peer.PeerID)
+	if err != nil {
+		w.logger.Error("failed to fetch wireguard peer from Mikrotik", zap.Error(err))
 		return nil, err
 	}
 
-	schedulerID, err := w.handleScheduler(&peer, req)
+	tx := newSaga("update peer", w.logger)
+
+	err = tx.run("update wireguard peer", func() error {
+		return w.updateMikrotikPeer(peer.Server, peer.PeerID, req)
+	}, func() error {
+		return w.restoreMikrotikPeer(peer.Server, peer.PeerID, *mtPeer)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	queueID, err := w.handleQueue(&peer, req)
+	schedulerID, err := w.handleScheduler(tx, &peer, req)
+	if err != nil {
+		return nil, err
+	}
+
+	queueID, err := w.handleQueue(tx, &peer, req)
 	if err != nil {
 		return nil, err
 	}
 
 	updateData := w.preparePeerUpdate(&peer, req, schedulerID, queueID)
-	if err := w.db.Model(&peer).Updates(updateData).Error; err != nil {
+	err = tx.run("update peer in database", func() error {
+		if err := w.db.Model(&peer).Updates(updateData).Error; err != nil {
+			return err
+		}
+		if req.PresharedKey == nil {
+			return nil
+		}
+		// a map update skips the serializer, the key goes through the model to be sealed
+		peer.PresharedKey = clientSettings.PresharedKey
+		return w.db.Model(&peer).Select("PresharedKey").Updates(&peer).Error
+	}, nil)
+	if err != nil {
 		return nil, err
 	}
 
@@ -414,53 +451,73 @@ func (w *WgPeer) UpdatePeer(id uint, req *schema.UpdatePeerRequest) (*schema.Pee
 
 func (w *WgPeer) DeletePeer(id uint) error {
 	var peer model.Peer
-	if err := w.db.First(&peer, "id = ?", id).Error; err != nil {
+	if err := w.db.Preload("Server").First(&peer, "id = ?", id).Error; err != nil {
 		w.logger.Error("failed to find peer in database", zap.Error(err))
 		return fmt.Errorf("peer not found: %w", err)
 	}
 
-	if err := w.scheduler.deleteScheduler(peer.SchedulerID); err != nil {
-		return fmt.Errorf("failed to delete scheduler: %w", err)
+	// snapshot the router side so the peer can be recreated as it was if a later step fails
+	mtPeer, err := w.mikrotikAdaptor.FetchWgPeer(context.Background(), peer.Server, peer.PeerID)
+	if err != nil {
+		w.logger.Error("failed to fetch wireguard peer from Mikrotik", zap.Error(err))
+		return fmt.Errorf("failed to fetch wireguard peer: %w", err)
 	}
 
-	if err := w.queue.deleteQueue(peer.QueueID); err != nil {
-		return fmt.Errorf("failed to delete simple queue: %w", err)
+	tx := newSaga("delete peer", w.logger)
+
+	err = tx.run("delete scheduler", func() error {
+		return w.scheduler.deleteScheduler(peer.Server, peer.SchedulerID)
+	}, func() error {
+		return w.recreateScheduler(&peer)
+	})
+	if err != nil {
+		return err
 	}
 
-	if err := w.mikrotikAdaptor.DeleteWgPeer(context.Background(), peer.PeerID); err != nil {
-		w.logger.Error("failed to delete wireguard peer from Mikrotik", zap.Error(err))
-		return fmt.Errorf("failed to delete wireguard peer: %w", err)
+	if peer.QueueID != nil {
+		err = tx.run("delete queue", func() error {
+			return w.queue.deleteQueue(peer.Server, peer.QueueID)
+		}, func() error {
+			return w.recreateQueue(&peer)
+		})
+		if err != nil {
+			return err
+		}
 	}
 
-	err := w.qrCodeGenerator.RemovePeerQRCode(id)
+	err = tx.run("delete wireguard peer", func() error {
+		return w.mikrotikAdaptor.DeleteWgPeer(context.Background(), peer.Server, peer.PeerID)
+	}, func() error {
+		return w.recreateMikrotikPeer(&peer, *mtPeer)
+	})
 	if err != nil {
-		w.logger.Error("failed to remove QR Code file", zap.Error(err))
 		return err
 	}
 
-	err = w.configGenerator.RemovePeerConfig(id)
+	err = tx.run("delete peer from database", func() error {
+		if err := deletePeerRecord(w.db, peer); err != nil {
+			w.logger.Error("failed to delete peer from database", zap.Error(err))
+			return err
+		}
+		return nil
+	}, nil)
 	if err != nil {
-		w.logger.Error("failed to remove peer config", zap.Error(err))
 		return err
 	}
 
-	if err := w.db.Unscoped().Delete(&peer).Error; err != nil {
-		w.logger.Error("failed to delete peer from database", zap.Error(err))
-		return fmt.Errorf("failed to delete peer from database: %w", err)
-	}
-
+	w.renderer.Forget(peer.UUID)
 	return nil
 }
 
-func (w *WgPeer) GetPeersData() (*schema.PeerStatsResponse, error) {
-	peers, err := w.mikrotikAdaptor.FetchWgPeers(context.Background())
+func (w *WgPeer) GetPeersData(server model.Server) (*schema.PeerStatsResponse, error) {
+	peers, err := w.mikrotikAdaptor.FetchWgPeers(context.Background(), server)
 	if err != nil {
 		w.logger.Error("failed to fetch peers from mikrotik", zap.Error(err))
 		return nil, fmt.Errorf("failed to fetch peers from mikrotik: %w", err)
 	}
 
 	var dbPeers []model.Peer
-	if err := w.db.Find(&dbPeers).Error; err != nil {
+	if err := w.db.Find(&dbPeers, "server_id = ?", server.ID).Error; err != nil {
 		w.logger.Error("failed to fetch peers from database", zap.Error(err))
 		return nil, fmt.Errorf("failed to fetch peers from database: %w", err)
 	}
@@ -533,7 +590,7 @@ func (w *WgPeer) GetPeersData() (*schema.PeerStatsResponse, error) {
 
 func (w *WgPeer) getInterface(id uint) (model.Interface, error) {
 	var iface model.Interface
-	if err := w.db.First(&iface, "id = ?", id).Error; err != nil {
+	if err := w.db.Preload("Server").First(&iface, "id = ?", id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			w.logger.Error("interface not found", zap.Uint("interfaceId", id))
 			return iface, fmt.Errorf("interface %d not found", id)
@@ -544,18 +601,29 @@ func (w *WgPeer) getInterface(id uint) (model.Interface, error) {
 	return iface, nil
 }
 
-func (w *WgPeer) ensureAllowedAddressIsUnique(address string) error {
-	var existing model.Peer
-	if err := w.db.Where("allowed_address = ?", address).First(&existing).Error; err == nil {
-		return fmt.Errorf("allowed address %s is already in use by peer %s", address, existing.Name)
-	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+// ensureAllowedAddressIsUnique checks every address of an allowed-address list against the addresses of the other
+// peers of the server, a dual stack peer holds one of each family
+func (w *WgPeer) ensureAllowedAddressIsUnique(serverID uint, address string) error {
+	var peers []model.Peer
+	if err := w.db.Select("name", "allowed_address").Where("server_id = ?", serverID).Find(&peers).Error; err != nil {
 		w.logger.Error("allowed address lookup failed", zap.Error(err))
 		return err
 	}
+
+	requested := utils.ParseAllowedAddresses(address)
+	for _, existing := range peers {
+		for _, existingPrefix := range utils.ParseAllowedAddresses(existing.AllowedAddress) {
+			for _, prefix := range requested {
+				if prefix.Masked() == existingPrefix.Masked() {
+					return fmt.Errorf("allowed address %s is already in use by peer %s", prefix, existing.Name)
+				}
+			}
+		}
+	}
 	return nil
 }
 
-func (w *WgPeer) createMikrotikPeer(req *schema.CreatePeerRequest, ifaceName string) (*mikrotik.WireGuardPeer, error) {
+func (w *WgPeer) createMikrotikPeer(server model.Server, req *schema.CreatePeerRequest, ifaceName string) (*mikrotik.WireGuardPeer, error) {
 	peer := &mikrotik.WireGuardPeer{
 		Comment:        req.Comment,
 		Name:           req.Name,
@@ -565,11 +633,14 @@ func (w *WgPeer) createMikrotikPeer(req *schema.CreatePeerRequest, ifaceName str
 		PrivateKey:     &req.PrivateKey,
 		PublicKey:      req.PublicKey,
 	}
-	return w.mikrotikAdaptor.CreateWgPeer(context.Background(), *peer)
+	return w.mikrotikAdaptor.CreateWgPeer(context.Background(), server, *peer)
 }
 
-func (w *WgPeer) buildAndStoreDbPeer(req *schema.CreatePeerRequest, iface model.Interface, mtPeer *mikrotik.WireGuardPeer, schedulerId, queueId *string) (model.Peer, error) {
+func (w *WgPeer) buildAndStoreDbPeer(req *schema.CreatePeerRequest, iface model.Interface, plan *model.PeerPlan, mtPeer *mikrotik.WireGuardPeer, schedulerId, queueId *string) (model.Peer, error) {
 	keepalive := common.DefaultKeepalive
+	if iface.ClientPersistentKeepalive != nil {
+		keepalive = *iface.ClientPersistentKeepalive
+	}
 	if req.PersistentKeepAlive != nil {
 		parsed, err := timehelper.ParseTime(*req.PersistentKeepAlive)
 		if err != nil {
@@ -594,9 +665,11 @@ func (w *WgPeer) buildAndStoreDbPeer(req *schema.CreatePeerRequest, iface model.
 	telegramUsername := normalizeTelegramUsername(req.TelegramUsername)
 
 	dbPeer := model.Peer{
+		ServerID:            iface.ServerID,
 		UUID:                uuid.New().String(),
 		PeerID:              mtPeer.ID,
 		Disabled:            disabled,
+		DisabledReason:      peerDisabledReason(disabled, common.PeerDisabledByAdmin),
 		Comment:             mtPeer.Comment,
 		Name:                mtPeer.Name,
 		PrivateKey:          *mtPeer.PrivateKey,
@@ -613,6 +686,26 @@ func (w *WgPeer) buildAndStoreDbPeer(req *schema.CreatePeerRequest, iface model.
 		TelegramUsername:    telegramUsername,
 		DownloadBandwidth:   req.DownloadBandwidth,
 		UploadBandwidth:     req.UploadBandwidth,
+		IncludePresharedKey: req.IncludePresharedKey,
+	}
+
+	if req.DNS != nil {
+		dbPeer.DNS = clientListSetting(*req.DNS)
+	}
+	if req.AllowedIPsMode != nil && *req.AllowedIPsMode != "" {
+		dbPeer.AllowedIPsMode = req.AllowedIPsMode
+	}
+	if req.AllowedIPs != nil {
+		dbPeer.AllowedIPs = clientListSetting(*req.AllowedIPs)
+	}
+	if req.MTU != nil && *req.MTU != 0 {
+		dbPeer.MTU = req.MTU
+	}
+	if req.PresharedKey != nil {
+		dbPeer.PresharedKey = *req.PresharedKey
+	}
+	if plan != nil {
+		dbPeer.PlanID = &plan.ID
 	}
 
 	if err := w.db.Create(&dbPeer).Error; err != nil {
@@ -623,16 +716,46 @@ func (w *WgPeer) buildAndStoreDbPeer(req *schema.CreatePeerRequest, iface model.
 	return dbPeer, nil
 }
 
-func (w *WgPeer) generatePeerAssets(privateKey string, peer model.Peer, ifacePubKey string) error {
-	peerConfig := fmt.Sprintf(wireguard.Template, privateKey, peer.AllowedAddress, common.DefaultDns, ifacePubKey, peer.Endpoint, peer.EndpointPort, common.AllowedIpsIncludeLocal, peer.PersistentKeepalive)
+// applyPeerPlan fills the settings left out of the request from the plan it refers to
+func (w *WgPeer) applyPeerPlan(req *schema.CreatePeerRequest) (*model.PeerPlan, error) {
+	if req.PlanId == nil {
+		return nil, nil
+	}
+
+	var plan model.PeerPlan
+	if err := w.db.First(&plan, "id = ?", *req.PlanId).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			w.logger.Error("peer plan not found", zap.Uint("planId", *req.PlanId))
+			return nil, fmt.Errorf("%w: plan %d not found", common.ErrInvalidPeerPlan, *req.PlanId)
+		}
+		w.logger.Error("db error while fetching peer plan", zap.Error(err))
+		return nil, err
+	}
 
-	if err := w.configGenerator.BuildPeerConfig(peerConfig, peer.UUID); err != nil {
-		return err
+	if req.TrafficLimit == nil && plan.TrafficLimit != nil {
+		req.TrafficLimit = utils.Ptr(bytesToGBString(*plan.TrafficLimit))
 	}
-	return w.qrCodeGenerator.BuildPeerQRCode(peerConfig, peer.UUID)
+	if req.DownloadBandwidth == nil && req.UploadBandwidth == nil {
+		req.DownloadBandwidth = plan.DownloadBandwidth
+		req.UploadBandwidth = plan.UploadBandwidth
+	}
+	if req.ExpireTime == nil && plan.ValidityDays != nil {
+		req.ExpireTime = extendExpireTime(nil, *plan.ValidityDays)
+	}
+	if req.PersistentKeepAlive == nil {
+		req.PersistentKeepAlive = plan.PersistentKeepalive
+	}
+	if req.DNS == nil {
+		req.DNS = plan.DNS
+	}
+	if req.AllowedIPsMode == nil {
+		req.AllowedIPsMode = plan.AllowedIPsMode
+	}
+
+	return &plan, nil
 }
 
-func (w *WgPeer) updateMikrotikPeer(peerID string, req *schema.UpdatePeerRequest) error {
+func (w *WgPeer) updateMikrotikPeer(server model.Server, peerID string, req *schema.UpdatePeerRequest) error {
 	wgPeer := mikrotik.WireGuardPeer{}
 
 	if req.Disabled != nil {
@@ -653,7 +776,7 @@ func (w *WgPeer) updateMikrotikPeer(peerID string, req *schema.UpdatePeerRequest
 		wgPeer.PresharedKey = req.PresharedKey
 	}
 
-	_, err := w.mikrotikAdaptor.UpdateWgPeer(context.Background(), peerID, wgPeer)
+	_, err := w.mikrotikAdaptor.UpdateWgPeer(context.Background(), server, peerID, wgPeer)
 	if err != nil {
 		w.logger.Error("failed to update wireguard peer in Mikrotik", zap.Error(err))
 	}
@@ -661,24 +784,37 @@ func (w *WgPeer) updateMikrotikPeer(peerID string, req *schema.UpdatePeerRequest
 	return err
 }
 
-func (w *WgPeer) handleScheduler(peer *model.Peer, req *schema.UpdatePeerRequest) (*string, error) {
+func (w *WgPeer) handleScheduler(tx *saga, peer *model.Peer, req *schema.UpdatePeerRequest) (*string, error) {
 	if req.ExpireTime == nil && peer.SchedulerID != nil {
-		err := w.scheduler.deleteScheduler(peer.SchedulerID)
+		err := tx.run("delete scheduler", func() error {
+			return w.scheduler.deleteScheduler(peer.Server, peer.SchedulerID)
+		}, func() error {
+			return w.recreateScheduler(peer)
+		})
 		if err != nil {
-			w.logger.Error("failed to delete scheduler for wireguard peer", zap.Error(err))
 			return peer.SchedulerID, err
 		}
 		return nil, nil
 	}
 
 	if req.ExpireTime != nil && peer.SchedulerID == nil {
-		return w.scheduler.createScheduler(peer.PeerID, peer.Name, req.ExpireTime)
+		var schedulerID *string
+		err := tx.run("create scheduler", func() (err error) {
+			schedulerID, err = w.scheduler.createScheduler(peer.Server, peer.PeerID, peer.Name, req.ExpireTime)
+			return err
+		}, func() error {
+			return w.scheduler.deleteScheduler(peer.Server, schedulerID)
+		})
+		return schedulerID, err
 	}
 
 	if req.ExpireTime != nil && peer.SchedulerID != nil {
-		err := w.scheduler.updateScheduler(peer.SchedulerID, req.ExpireTime)
+		err := tx.run("update scheduler", func() error {
+			return w.scheduler.updateScheduler(peer.Server, peer.SchedulerID, req.ExpireTime)
+		}, func() error {
+			return w.scheduler.updateScheduler(peer.Server, peer.SchedulerID, peer.ExpireTime)
+		})
 		if err != nil {
-			w.logger.Error("failed to update scheduler for wireguard peer", zap.Error(err))
 			return peer.SchedulerID, err
 		}
 	}
@@ -686,16 +822,19 @@ func (w *WgPeer) handleScheduler(peer *model.Peer, req *schema.UpdatePeerRequest
 	return peer.SchedulerID, nil
 }
 
-func (w *WgPeer) handleQueue(peer *model.Peer, req *schema.UpdatePeerRequest) (*string, error) {
+func (w *WgPeer) handleQueue(tx *saga, peer *model.Peer, req *schema.UpdatePeerRequest) (*string, error) {
 	download := req.DownloadBandwidth
 	upload := req.UploadBandwidth
 	queueID := peer.QueueID
 
 	if download == nil && upload == nil {
 		if queueID != nil {
-			err := w.queue.deleteQueue(queueID)
+			err := tx.run("delete queue", func() error {
+				return w.queue.deleteQueue(peer.Server, queueID)
+			}, func() error {
+				return w.recreateQueue(peer)
+			})
 			if err != nil {
-				w.logger.Error("failed to delete queue for wireguard peer", zap.Error(err))
 				return queueID, err
 			}
 		}
@@ -703,18 +842,26 @@ func (w *WgPeer) handleQueue(peer *model.Peer, req *schema.UpdatePeerRequest) (*
 	}
 
 	if queueID == nil {
-		newQueueID, err := w.queue.createQueue(peer.Name, peer.AllowedAddress, download, upload)
+		var newQueueID *string
+		err := tx.run("create queue", func() (err error) {
+			newQueueID, err = w.queue.createQueue(peer.Server, peer.Name, peer.AllowedAddress, download, upload)
+			return err
+		}, func() error {
+			return w.queue.deleteQueue(peer.Server, newQueueID)
+		})
 		if err != nil {
-			w.logger.Error("failed to create queue for wireguard peer", zap.Error(err))
 			return nil, err
 		}
 		return newQueueID, nil
 	}
 
 	if !w.bandwidthsEqual(peer.DownloadBandwidth, download) || !w.bandwidthsEqual(peer.UploadBandwidth, upload) {
-		err := w.queue.updateQueue(queueID, download, upload)
+		err := tx.run("update queue", func() error {
+			return w.queue.updateQueue(peer.Server, queueID, download, upload)
+		}, func() error {
+			return w.queue.updateQueue(peer.Server, queueID, peer.DownloadBandwidth, peer.UploadBandwidth)
+		})
 		if err != nil {
-			w.logger.Error("failed to update queue for wireguard peer", zap.Error(err))
 			return queueID, err
 		}
 	}
@@ -722,6 +869,118 @@ func (w *WgPeer) handleQueue(peer *model.Peer, req *schema.UpdatePeerRequest) (*
 	return queueID, nil
 }
 
+// restoreMikrotikPeer puts back the writable properties of a router peer snapshot
+func (w *WgPeer) restoreMikrotikPeer(server model.Server, peerID string, snapshot mikrotik.WireGuardPeer) error {
+	comment := snapshot.Comment
+	if comment == nil {
+		comment = utils.Ptr("")
+	}
+
+	_, err := w.mikrotikAdaptor.UpdateWgPeer(context.Background(), server, peerID, mikrotik.WireGuardPeer{
+		Disabled:            snapshot.Disabled,
+		Comment:             comment,
+		Name:                snapshot.Name,
+		AllowedAddress:      snapshot.AllowedAddress,
+		PersistentKeepAlive: snapshot.PersistentKeepAlive,
+		PresharedKey:        snapshot.PresharedKey,
+	})
+	return err
+}
+
+// recreateMikrotikPeer creates the router peer again from its snapshot and points the stored peer at the new ID
+func (w *WgPeer) recreateMikrotikPeer(peer *model.Peer, snapshot mikrotik.WireGuardPeer) error {
+	created, err := w.mikrotikAdaptor.CreateWgPeer(context.Background(), peer.Server, mikrotik.WireGuardPeer{
+		Disabled:            snapshot.Disabled,
+		Comment:             snapshot.Comment,
+		AllowedAddress:      snapshot.AllowedAddress,
+		PersistentKeepAlive: snapshot.PersistentKeepAlive,
+		Interface:           snapshot.Interface,
+		Name:                snapshot.Name,
+		PresharedKey:        snapshot.PresharedKey,
+		PrivateKey:          snapshot.PrivateKey,
+		PublicKey:           snapshot.PublicKey,
+		ClientEndpoint:      snapshot.ClientEndpoint,
+	})
+	if err != nil {
+		return err
+	}
+
+	peer.PeerID = created.ID
+	return w.db.Model(&model.Peer{}).Where("id = ?", peer.ID).Update("peer_id", created.ID).Error
+}
+
+// deletePeerRecord removes a peer from the database together with the rows that belong to it
+func deletePeerRecord(db *gorm.DB, peer model.Peer) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("peer_id = ?", peer.ID).Delete(&model.PeerTrafficSample{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&peer).Error
+	})
+}
+
+// recreateScheduler creates the expiry scheduler of a peer again and stores its new ID
+func (w *WgPeer) recreateScheduler(peer *model.Peer) error {
+	schedulerID, err := w.scheduler.createScheduler(peer.Server, peer.PeerID, peer.Name, peer.ExpireTime)
+	if err != nil {
+		return err
+	}
+
+	peer.SchedulerID = schedulerID
+	return w.db.Model(&model.Peer{}).Where("id = ?", peer.ID).Update("scheduler_id", schedulerID).Error
+}
+
+// recreateQueue creates the bandwidth queue of a peer again and stores its new ID
+func (w *WgPeer) recreateQueue(peer *model.Peer) error {
+	queueID, err := w.queue.createQueue(peer.Server, peer.Name, peer.AllowedAddress, peer.DownloadBandwidth, peer.UploadBandwidth)
+	if err != nil {
+		return err
+	}
+
+	peer.QueueID = queueID
+	return w.db.Model(&model.Peer{}).Where("id = ?", peer.ID).Update("queue_id", queueID).Error
+}
+
+// peerInterface returns the interface a peer belongs to, peers refer to it by name
+func (w *WgPeer) peerInterface(peer model.Peer) (model.Interface, error) {
+	var iface model.Interface
+	if err := w.db.Where("name = ? AND server_id = ?", peer.Interface, peer.ServerID).First(&iface).Error; err != nil {
+		w.logger.Error("failed to get interface of peer", zap.String("interface", peer.Interface), zap.Error(err))
+		return iface, err
+	}
+	return iface, nil
+}
+
+// applyPeerClientSettings returns peer with the client config settings and preshared key given in req, an empty value
+// clears a setting so the interface default applies again
+func applyPeerClientSettings(peer model.Peer, req *schema.UpdatePeerRequest) model.Peer {
+	if req.DNS != nil {
+		peer.DNS = clientListSetting(*req.DNS)
+	}
+	if req.AllowedIPsMode != nil {
+		peer.AllowedIPsMode = nil
+		if *req.AllowedIPsMode != "" {
+			peer.AllowedIPsMode = req.AllowedIPsMode
+		}
+	}
+	if req.AllowedIPs != nil {
+		peer.AllowedIPs = clientListSetting(*req.AllowedIPs)
+	}
+	if req.MTU != nil {
+		peer.MTU = nil
+		if *req.MTU != 0 {
+			peer.MTU = req.MTU
+		}
+	}
+	if req.IncludePresharedKey != nil {
+		peer.IncludePresharedKey = req.IncludePresharedKey
+	}
+	if req.PresharedKey != nil {
+		peer.PresharedKey = *req.PresharedKey
+	}
+	return peer
+}
+
 func (w *WgPeer) preparePeerUpdate(peer *model.Peer, req *schema.UpdatePeerRequest, schedulerID, queueID *string) map[string]interface{} {
 	updateData := map[string]interface{}{}
 
@@ -744,6 +1003,9 @@ func (w *WgPeer) preparePeerUpdate(peer *model.Peer, req *schema.UpdatePeerReque
 	}
 
 	updateData["disabled"] = req.Disabled
+	if req.Disabled != nil && *req.Disabled != peer.Disabled {
+		updateData["disabled_reason"] = peerDisabledReason(*req.Disabled, common.PeerDisabledByAdmin)
+	}
 	updateData["comment"] = req.Comment
 	updateData["name"] = req.Name
 	updateData["allowed_address"] = req.AllowedAddress
@@ -754,6 +1016,13 @@ func (w *WgPeer) preparePeerUpdate(peer *model.Peer, req *schema.UpdatePeerReque
 	updateData["scheduler_id"] = schedulerID
 	updateData["queue_id"] = queueID
 
+	clientSettings := applyPeerClientSettings(*peer, req)
+	updateData["dns"] = clientSettings.DNS
+	updateData["allowed_ips_mode"] = clientSettings.AllowedIPsMode
+	updateData["allowed_ips"] = clientSettings.AllowedIPs
+	updateData["mtu"] = clientSettings.MTU
+	updateData["include_preshared_key"] = clientSettings.IncludePresharedKey
+
 	return updateData
 }
 
@@ -769,21 +1038,30 @@ func (w *WgPeer) transformPeerToResponse(peer model.Peer) schema.PeerResponse {
 	}
 
 	return schema.PeerResponse{
-		Id:                peer.ID,
-		UUID:              peer.UUID,
-		Disabled:          peer.Disabled,
-		Comment:           peer.Comment,
-		TelegramUsername:  peer.TelegramUsername,
-		Name:              peer.Name,
-		Interface:         peer.Interface,
-		AllowedAddress:    peer.AllowedAddress,
-		TrafficLimit:      trafficLimit,
-		ExpireTime:        peer.ExpireTime,
-		DownloadBandwidth: peer.DownloadBandwidth,
-		UploadBandwidth:   peer.UploadBandwidth,
-		TotalUsage:        utils.BytesToGB(peer.DownloadUsage + peer.UploadUsage),
-		Status:            statuses,
-		IsShared:          peer.IsShared,
+		Id:                  peer.ID,
+		ServerId:            peer.ServerID,
+		UUID:                peer.UUID,
+		Disabled:            peer.Disabled,
+		DisabledReason:      peer.DisabledReason,
+		Comment:             peer.Comment,
+		TelegramUsername:    peer.TelegramUsername,
+		Name:                peer.Name,
+		Interface:           peer.Interface,
+		AllowedAddress:      peer.AllowedAddress,
+		TrafficLimit:        trafficLimit,
+		ExpireTime:          peer.ExpireTime,
+		DownloadBandwidth:   peer.DownloadBandwidth,
+		UploadBandwidth:     peer.UploadBandwidth,
+		TotalUsage:          utils.BytesToGB(peer.DownloadUsage + peer.UploadUsage),
+		Status:              statuses,
+		IsShared:            peer.IsShared,
+		PlanId:              peer.PlanID,
+		DNS:                 peer.DNS,
+		AllowedIPsMode:      peer.AllowedIPsMode,
+		AllowedIPs:          peer.AllowedIPs,
+		MTU:                 peer.MTU,
+		IncludePresharedKey: peer.IncludePresharedKey,
+		HasPresharedKey:     peer.PresharedKey != "",
 	}
 }
 
@@ -796,11 +1074,8 @@ func (w *WgPeer) transformPeerStatus(peer model.Peer) []schema.PeerStatus {
 		peerStatus = append(peerStatus, schema.ActivePeer)
 	}
 
-	if peer.ExpireTime != nil {
-		expireTime, err := time.Parse("2006-01-02", *peer.ExpireTime)
-		if err == nil && time.Now().After(expireTime) {
-			peerStatus = append(peerStatus, schema.ExpiredPeer)
-		}
+	if isPeerExpired(peer) {
+		peerStatus = append(peerStatus, schema.ExpiredPeer)
 	}
 
 	if peer.TrafficLimit != nil {
@@ -813,6 +1088,32 @@ func (w *WgPeer) transformPeerStatus(peer model.Peer) []schema.PeerStatus {
 	return peerStatus
 }
 
+// peerDisabledReason is the reason stored with a disabled flag, enabling a peer clears it
+func peerDisabledReason(disabled bool, reason string) *string {
+	if !disabled {
+		return nil
+	}
+	return &reason
+}
+
+// routerDisabledReason tells why the router disabled a peer the panel had enabled: its expiry scheduler once the peer
+// expired, an admin otherwise
+func routerDisabledReason(peer model.Peer) string {
+	if isPeerExpired(peer) {
+		return common.PeerDisabledByExpiry
+	}
+	return common.PeerDisabledByAdmin
+}
+
+// isPeerExpired reports whether the expire date of a peer has been reached
+func isPeerExpired(peer model.Peer) bool {
+	if peer.ExpireTime == nil {
+		return false
+	}
+	expireTime, err := time.Parse("2006-01-02", *peer.ExpireTime)
+	return err == nil && time.Now().After(expireTime)
+}
+
 func (w *WgPeer) handshakeData(peer *mikrotik.WireGuardPeer) (duration time.Duration, isOnline bool, err error) {
 	if peer.LastHandshake != nil {
 		duration, err = utils.ParseCustomDuration(*peer.LastHandshake)
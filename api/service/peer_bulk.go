@@ -0,0 +1,264 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/dataservice/model"
+	"github.com/maahdima/mwp/api/http/schema"
+	"github.com/maahdima/mwp/api/utils"
+)
+
+// PeerUsageResetter zeroes the traffic usage of a peer, the traffic calculator owns the counters
+type PeerUsageResetter interface {
+	ResetPeerUsage(id uint) error
+}
+
+// BulkUpdatePeers applies one action to the peers of a server picked by ID, by filter or by both, or to all of them
+// when asked for explicitly. Peers are handled concurrently, at most common.BulkPeerConcurrency at a time, and a
+// failing peer does not stop the others. With a reseller scope only the peers within it are selected.
+func (w *WgPeer) BulkUpdatePeers(server model.Server, req *schema.BulkPeerRequest, usage PeerUsageResetter, scope *schema.AccessScope) (*schema.BulkPeerResponse, error) {
+	if err := validateBulkRequest(req); err != nil {
+		return nil, err
+	}
+
+	peers, err := w.selectBulkPeers(server, req, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	results := w.forEachPeer(peers, func(peer model.Peer) error {
+		return w.applyBulkAction(peer, req, usage)
+	})
+
+	resp := &schema.BulkPeerResponse{
+		Action:  req.Action,
+		Total:   len(results),
+		Results: results,
+	}
+	for _, result := range results {
+		if result.Success {
+			resp.Succeeded++
+		} else {
+			resp.Failed++
+		}
+	}
+
+	w.logger.Info("bulk peer action finished",
+		zap.String("server", server.Name),
+		zap.String("action", string(req.Action)),
+		zap.Int("total", resp.Total),
+		zap.Int("failed", resp.Failed),
+	)
+
+	return resp, nil
+}
+
+// forEachPeer runs fn for every peer, at most common.BulkPeerConcurrency at a time, and reports the outcome per peer
+func (w *WgPeer) forEachPeer(peers []model.Peer, fn func(peer model.Peer) error) []schema.BulkPeerResult {
+	results := make([]schema.BulkPeerResult, len(peers))
+	sem := make(chan struct{}, common.BulkPeerConcurrency)
+	var wg sync.WaitGroup
+
+	for i, peer := range peers {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			result := schema.BulkPeerResult{Id: peer.ID, Name: peer.Name, Success: true}
+			if err := fn(peer); err != nil {
+				w.logger.Warn("peer operation failed", zap.Uint("id", peer.ID), zap.String("name", peer.Name), zap.Error(err))
+				result.Success = false
+				result.Error = err.Error()
+			}
+			results[i] = result
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+func validateBulkRequest(req *schema.BulkPeerRequest) error {
+	if req.Filter != nil && isEmptyBulkFilter(req.Filter) {
+		return fmt.Errorf("%w: filter has no criteria, set all to select every peer", common.ErrInvalidBulkRequest)
+	}
+	if req.All && (len(req.Ids) > 0 || req.Filter != nil) {
+		return fmt.Errorf("%w: all can not be combined with ids or filter", common.ErrInvalidBulkRequest)
+	}
+	if len(req.Ids) == 0 && req.Filter == nil && !req.All {
+		return fmt.Errorf("%w: either ids, filter or all must be given", common.ErrInvalidBulkRequest)
+	}
+
+	switch req.Action {
+	case schema.BulkExtendExpire:
+		if req.Days == nil {
+			return fmt.Errorf("%w: days is required to extend the expire time", common.ErrInvalidBulkRequest)
+		}
+	case schema.BulkSetTrafficLimit:
+		if req.TrafficLimit != nil {
+			if gb, err := strconv.ParseFloat(*req.TrafficLimit, 64); err != nil || gb <= 0 {
+				return fmt.Errorf("%w: invalid traffic limit %q", common.ErrInvalidBulkRequest, *req.TrafficLimit)
+			}
+		}
+	case schema.BulkSetBandwidth:
+		for _, bandwidth := range []*string{req.DownloadBandwidth, req.UploadBandwidth} {
+			if bandwidth != nil && !bandwidthPattern.MatchString(*bandwidth) {
+				return fmt.Errorf("%w: invalid bandwidth %q", common.ErrInvalidBulkRequest, *bandwidth)
+			}
+		}
+	}
+
+	return nil
+}
+
+func isEmptyBulkFilter(filter *schema.BulkPeerFilter) bool {
+	return filter.Interface == nil && filter.Status == nil && filter.Expired == nil && filter.OverLimit == nil
+}
+
+func (w *WgPeer) selectBulkPeers(server model.Server, req *schema.BulkPeerRequest, scope *schema.AccessScope) ([]model.Peer, error) {
+	query := w.db.Preload("Server").Where("server_id = ?", server.ID)
+	if len(req.Ids) > 0 {
+		query = query.Where("id IN ?", req.Ids)
+	}
+	if req.Filter != nil && req.Filter.Interface != nil {
+		query = query.Where("interface = ?", *req.Filter.Interface)
+	}
+	if scope != nil {
+		allowed, err := w.scopedInterfaceNames(server.ID, *scope)
+		if err != nil {
+			return nil, err
+		}
+		names := make([]string, 0, len(allowed))
+		for name := range allowed {
+			names = append(names, name)
+		}
+		query = query.Where("interface IN ?", names)
+	}
+
+	var peers []model.Peer
+	if err := query.Order("id").Find(&peers).Error; err != nil {
+		w.logger.Error("failed to fetch peers for bulk action", zap.Error(err))
+		return nil, err
+	}
+
+	if req.Filter == nil {
+		return peers, nil
+	}
+
+	selected := peers[:0]
+	for _, peer := range peers {
+		if w.matchesBulkFilter(peer, req.Filter) {
+			selected = append(selected, peer)
+		}
+	}
+
+	return selected, nil
+}
+
+func (w *WgPeer) matchesBulkFilter(peer model.Peer, filter *schema.BulkPeerFilter) bool {
+	statuses := w.transformPeerStatus(peer)
+
+	if filter.Status != nil && !slices.Contains(statuses, *filter.Status) {
+		return false
+	}
+	if filter.Expired != nil && slices.Contains(statuses, schema.ExpiredPeer) != *filter.Expired {
+		return false
+	}
+	if filter.OverLimit != nil && slices.Contains(statuses, schema.SuspendedPeer) != *filter.OverLimit {
+		return false
+	}
+
+	return true
+}
+
+func (w *WgPeer) applyBulkAction(peer model.Peer, req *schema.BulkPeerRequest, usage PeerUsageResetter) error {
+	switch req.Action {
+	case schema.BulkEnablePeers, schema.BulkDisablePeers:
+		disabled := req.Action == schema.BulkDisablePeers
+		if peer.Disabled == disabled {
+			return nil
+		}
+		return w.setPeerStatus(peer, disabled, common.PeerDisabledByAdmin)
+	case schema.BulkDeletePeers:
+		return w.DeletePeer(peer.ID)
+	case schema.BulkExtendExpire:
+		update := peerUpdateRequest(peer)
+		update.ExpireTime = extendExpireTime(peer.ExpireTime, *req.Days)
+		_, err := w.UpdatePeer(peer.ID, &update)
+		return err
+	case schema.BulkSetTrafficLimit:
+		update := peerUpdateRequest(peer)
+		update.TrafficLimit = req.TrafficLimit
+		_, err := w.UpdatePeer(peer.ID, &update)
+		return err
+	case schema.BulkSetBandwidth:
+		update := peerUpdateRequest(peer)
+		update.DownloadBandwidth = req.DownloadBandwidth
+		update.UploadBandwidth = req.UploadBandwidth
+		_, err := w.UpdatePeer(peer.ID, &update)
+		return err
+	case schema.BulkResetUsage:
+		return usage.ResetPeerUsage(peer.ID)
+	case schema.BulkEnableSharing:
+		if peer.IsShared {
+			return nil
+		}
+		return w.db.Model(&peer).Update("is_shared", true).Error
+	}
+
+	return fmt.Errorf("%w: unknown action %q", common.ErrInvalidBulkRequest, req.Action)
+}
+
+// peerUpdateRequest describes the current state of a peer, UpdatePeer replaces every field so a single field can
+// be changed by editing the result
+func peerUpdateRequest(peer model.Peer) schema.UpdatePeerRequest {
+	req := schema.UpdatePeerRequest{
+		Disabled:          &peer.Disabled,
+		Comment:           peer.Comment,
+		TelegramUsername:  peer.TelegramUsername,
+		Name:              peer.Name,
+		AllowedAddress:    peer.AllowedAddress,
+		ExpireTime:        peer.ExpireTime,
+		DownloadBandwidth: peer.DownloadBandwidth,
+		UploadBandwidth:   peer.UploadBandwidth,
+	}
+
+	if peer.PersistentKeepalive != "" {
+		req.PersistentKeepAlive = &peer.PersistentKeepalive
+	}
+	if peer.TrafficLimit != nil {
+		req.TrafficLimit = utils.Ptr(bytesToGBString(*peer.TrafficLimit))
+	}
+
+	return req
+}
+
+// bytesToGBString converts a traffic limit back to the GB value it was entered as, without rounding
+func bytesToGBString(b int64) string {
+	return strconv.FormatFloat(float64(b)/(1024*1024*1024), 'f', -1, 64)
+}
+
+// extendExpireTime adds days to an expire time, counting from today when the peer has none or already expired
+func extendExpireTime(expireTime *string, days int) *string {
+	today, _ := time.Parse("2006-01-02", time.Now().Format("2006-01-02"))
+	from := today
+
+	if expireTime != nil {
+		if current, err := time.Parse("2006-01-02", *expireTime); err == nil && current.After(today) {
+			from = current
+		}
+	}
+
+	extended := from.AddDate(0, 0, days).Format("2006-01-02")
+	return &extended
+}
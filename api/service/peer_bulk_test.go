@@ -0,0 +1,136 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/maahdima/mwp/api/adaptor/mikrotik/mikrotiktest"
+	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/dataservice/model"
+	"github.com/maahdima/mwp/api/http/schema"
+	"github.com/maahdima/mwp/api/utils"
+)
+
+// createBulkPeers creates alice, already expired, and bob and carol on wg0
+func createBulkPeers(t *testing.T, env *testEnv) []model.Peer {
+	t.Helper()
+
+	iface := env.seedInterface(t, "wg0")
+	peers := env.peerService()
+
+	var created []model.Peer
+	for _, peer := range []struct{ name, address, expireTime string }{
+		{"alice", "10.0.0.2/32", "2020-01-01"},
+		{"bob", "10.0.0.3/32", "2030-01-01"},
+		{"carol", "10.0.0.4/32", "2030-01-01"},
+	} {
+		req := newCreatePeerRequest(t, iface, peer.name, peer.address)
+		req.ExpireTime = utils.Ptr(peer.expireTime)
+		resp, err := peers.CreatePeer(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var dbPeer model.Peer
+		if err := env.db.First(&dbPeer, resp.Id).Error; err != nil {
+			t.Fatal(err)
+		}
+		created = append(created, dbPeer)
+	}
+	return created
+}
+
+func TestBulkDisableExpiredPeers(t *testing.T) {
+	env := newTestEnv(t)
+	created := createBulkPeers(t, env)
+
+	expired := true
+	resp, err := env.peerService().BulkUpdatePeers(env.server, &schema.BulkPeerRequest{
+		Filter: &schema.BulkPeerFilter{Expired: &expired},
+		Action: schema.BulkDisablePeers,
+	}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Total != 1 || resp.Succeeded != 1 || resp.Results[0].Id != created[0].ID {
+		t.Fatalf("bulk response %+v, want only the expired peer", resp)
+	}
+
+	for i, peer := range created {
+		wantDisabled := i == 0
+		if err := env.db.First(&peer, peer.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		if peer.Disabled != wantDisabled || (env.router.Record(common.WGPeerPath, peer.PeerID)["disabled"] == "true") != wantDisabled {
+			t.Fatalf("peer %s disabled %t, want %t", peer.Name, peer.Disabled, wantDisabled)
+		}
+	}
+}
+
+func TestBulkExtendExpireContinuesPastFailures(t *testing.T) {
+	env := newTestEnv(t)
+	created := createBulkPeers(t, env)
+	alice, bob, carol := created[0], created[1], created[2]
+
+	env.router.InjectFault(mikrotiktest.Error(http.MethodPatch, common.WGPeerPath+"/"+carol.PeerID, http.StatusInternalServerError))
+
+	days := 10
+	resp, err := env.peerService().BulkUpdatePeers(env.server, &schema.BulkPeerRequest{
+		Ids:    []uint{alice.ID, bob.ID, carol.ID},
+		Action: schema.BulkExtendExpire,
+		Days:   &days,
+	}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Succeeded != 2 || resp.Failed != 1 || resp.Results[2].Success {
+		t.Fatalf("bulk response %+v, want only carol to fail", resp)
+	}
+
+	today, _ := time.Parse("2006-01-02", time.Now().Format("2006-01-02"))
+	for _, want := range []struct {
+		peer       model.Peer
+		expireTime string
+	}{
+		{alice, today.AddDate(0, 0, 10).Format("2006-01-02")},
+		{bob, "2030-01-11"},
+		{carol, "2030-01-01"},
+	} {
+		peer := want.peer
+		if err := env.db.First(&peer, peer.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		if utils.DerefString(peer.ExpireTime) != want.expireTime {
+			t.Fatalf("peer %s expires %s, want %s", peer.Name, utils.DerefString(peer.ExpireTime), want.expireTime)
+		}
+	}
+}
+
+func TestBulkUpdatePeersRequiresSelection(t *testing.T) {
+	env := newTestEnv(t)
+
+	_, err := env.peerService().BulkUpdatePeers(env.server, &schema.BulkPeerRequest{Action: schema.BulkDisablePeers}, nil, nil)
+	if err == nil {
+		t.Fatal("a bulk action without ids or filter was accepted")
+	}
+}
+
+func TestBulkUpdatePeersRefusesEmptyFilter(t *testing.T) {
+	env := newTestEnv(t)
+	createBulkPeers(t, env)
+	peers := env.peerService()
+
+	_, err := peers.BulkUpdatePeers(env.server, &schema.BulkPeerRequest{Filter: &schema.BulkPeerFilter{}, Action: schema.BulkDisablePeers}, nil, nil)
+	if !errors.Is(err, common.ErrInvalidBulkRequest) {
+		t.Fatalf("got %v for an empty filter, want %v", err, common.ErrInvalidBulkRequest)
+	}
+
+	resp, err := peers.BulkUpdatePeers(env.server, &schema.BulkPeerRequest{All: true, Action: schema.BulkDisablePeers}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Succeeded != 3 {
+		t.Fatalf("all disabled %d peers, want 3", resp.Succeeded)
+	}
+}
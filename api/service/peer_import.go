@@ -0,0 +1,324 @@
+package service
+
+import (
+	"fmt"
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/dataservice/model"
+	"github.com/maahdima/mwp/api/http/schema"
+	"github.com/maahdima/mwp/api/utils"
+)
+
+// MaxImportRows caps the number of peers a single import file may describe
+const MaxImportRows = 1000
+
+// Columns recognised in the header row of an import file, matched case-insensitively with spaces and dashes read as
+// underscores. "bandwidth" is a shorthand for both directions, either "10M" or "10M/5M" (download/upload).
+const (
+	importColName              = "name"
+	importColInterface         = "interface"
+	importColTrafficLimit      = "traffic_limit"
+	importColExpireTime        = "expire_time"
+	importColBandwidth         = "bandwidth"
+	importColDownloadBandwidth = "download_bandwidth"
+	importColUploadBandwidth   = "upload_bandwidth"
+	importColTelegramUsername  = "telegram_username"
+	importColComment           = "comment"
+)
+
+var (
+	bandwidthPattern     = regexp.MustCompile(`^\d+(\.\d+)?[KMG]$`)
+	importHeaderReplacer = strings.NewReplacer(" ", "_", "-", "_")
+)
+
+type importRow struct {
+	result schema.ImportPeerRowResult
+	iface  *model.Interface
+	req    schema.CreatePeerRequest
+}
+
+// ImportPeers creates a peer for every row of an import file on the given server. Rows are validated first, an
+// invalid row never reaches the router and does not stop the others. With dryRun nothing is created and the report
+// carries the addresses the valid rows would get. A reseller scope hides the interfaces outside of it.
+func (w *WgPeer) ImportPeers(server model.Server, rows [][]string, endpoint string, dryRun bool, scope *schema.AccessScope) (*schema.ImportPeersResponse, error) {
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%w: the file is empty", common.ErrInvalidImportFile)
+	}
+	if len(rows)-1 > MaxImportRows {
+		return nil, fmt.Errorf("%w: more than %d rows", common.ErrInvalidImportFile, MaxImportRows)
+	}
+
+	columns, err := parseImportHeader(rows[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if endpoint == "" {
+		endpoint = server.IPAddress
+	}
+
+	var interfaces []model.Interface
+	if err := w.db.Preload("IPPools").Find(&interfaces, "server_id = ?", server.ID).Error; err != nil {
+		w.logger.Error("failed to fetch interfaces", zap.Error(err))
+		return nil, err
+	}
+	interfacesByName := make(map[string]*model.Interface, len(interfaces))
+	for i := range interfaces {
+		if scope != nil && !scope.AllowsInterface(interfaces[i].ServerID, interfaces[i].ID) {
+			continue
+		}
+		interfacesByName[interfaces[i].Name] = &interfaces[i]
+	}
+
+	var existingNames []string
+	if err := w.db.Model(&model.Peer{}).Where("server_id = ?", server.ID).Pluck("name", &existingNames).Error; err != nil {
+		w.logger.Error("failed to fetch peer names", zap.Error(err))
+		return nil, err
+	}
+	takenNames := make(map[string]bool, len(existingNames))
+	for _, name := range existingNames {
+		takenNames[name] = true
+	}
+
+	resp := &schema.ImportPeersResponse{
+		DryRun: dryRun,
+		Rows:   []schema.ImportPeerRowResult{},
+	}
+
+	var parsed []importRow
+	for i, cells := range rows[1:] {
+		if isBlankRow(cells) {
+			continue
+		}
+
+		row := w.parseImportRow(i+2, cells, columns, interfacesByName, takenNames)
+		row.req.Endpoint = endpoint
+		if row.result.Status == schema.ImportRowValid {
+			takenNames[row.req.Name] = true
+		}
+		parsed = append(parsed, row)
+	}
+
+	if dryRun {
+		w.planImportAddresses(parsed)
+	} else {
+		w.createImportedPeers(parsed)
+	}
+
+	for _, row := range parsed {
+		resp.Total++
+		switch row.result.Status {
+		case schema.ImportRowValid:
+			resp.Valid++
+		case schema.ImportRowInvalid:
+			resp.Invalid++
+		case schema.ImportRowCreated:
+			resp.Valid++
+			resp.Created++
+		case schema.ImportRowFailed:
+			resp.Valid++
+			resp.Failed++
+		}
+		resp.Rows = append(resp.Rows, row.result)
+	}
+
+	w.logger.Info("peer import finished",
+		zap.String("server", server.Name),
+		zap.Bool("dryRun", dryRun),
+		zap.Int("total", resp.Total),
+		zap.Int("invalid", resp.Invalid),
+		zap.Int("created", resp.Created),
+		zap.Int("failed", resp.Failed),
+	)
+
+	return resp, nil
+}
+
+func (w *WgPeer) parseImportRow(rowNumber int, cells []string, columns map[string]int, interfaces map[string]*model.Interface, takenNames map[string]bool) importRow {
+	cell := func(column string) string {
+		idx, ok := columns[column]
+		if !ok || idx >= len(cells) {
+			return ""
+		}
+		return strings.TrimSpace(cells[idx])
+	}
+	optional := func(column string) *string {
+		if value := cell(column); value != "" {
+			return &value
+		}
+		return nil
+	}
+
+	row := importRow{
+		result: schema.ImportPeerRowResult{
+			Row:       rowNumber,
+			Name:      cell(importColName),
+			Interface: cell(importColInterface),
+		},
+	}
+	var errs []string
+
+	row.req.Name = row.result.Name
+	if row.req.Name == "" {
+		errs = append(errs, "name is required")
+	} else if takenNames[row.req.Name] {
+		errs = append(errs, fmt.Sprintf("peer name %q is already in use", row.req.Name))
+	}
+
+	if row.result.Interface == "" {
+		errs = append(errs, "interface is required")
+	} else if iface, ok := interfaces[row.result.Interface]; !ok {
+		errs = append(errs, fmt.Sprintf("interface %q not found", row.result.Interface))
+	} else if len(iface.IPPools) == 0 {
+		errs = append(errs, fmt.Sprintf("interface %q has no IP pool", row.result.Interface))
+	} else {
+		row.iface = iface
+		row.req.InterfaceId = iface.ID
+	}
+
+	if limit := optional(importColTrafficLimit); limit != nil {
+		if gb, err := strconv.ParseFloat(*limit, 64); err != nil || gb <= 0 {
+			errs = append(errs, fmt.Sprintf("invalid traffic limit %q, expected a positive number of GB", *limit))
+		} else {
+			row.req.TrafficLimit = limit
+		}
+	}
+
+	if expire := optional(importColExpireTime); expire != nil {
+		if _, err := time.Parse("2006-01-02", *expire); err != nil {
+			errs = append(errs, fmt.Sprintf("invalid expire time %q, expected YYYY-MM-DD", *expire))
+		} else {
+			row.req.ExpireTime = expire
+		}
+	}
+
+	download, upload := optional(importColDownloadBandwidth), optional(importColUploadBandwidth)
+	if both := optional(importColBandwidth); both != nil {
+		down, up, found := strings.Cut(*both, "/")
+		if !found {
+			up = down
+		}
+		if download == nil {
+			download = &down
+		}
+		if upload == nil {
+			upload = &up
+		}
+	}
+	for i, bandwidth := range []*string{download, upload} {
+		if bandwidth == nil || bandwidthPattern.MatchString(*bandwidth) {
+			continue
+		}
+		if i == 1 && download != nil && *download == *bandwidth {
+			continue
+		}
+		errs = append(errs, fmt.Sprintf("invalid bandwidth %q, expected e.g. 10M", *bandwidth))
+	}
+	row.req.DownloadBandwidth = download
+	row.req.UploadBandwidth = upload
+
+	row.req.TelegramUsername = optional(importColTelegramUsername)
+	row.req.Comment = optional(importColComment)
+
+	row.result.Status = schema.ImportRowValid
+	if len(errs) > 0 {
+		row.result.Status = schema.ImportRowInvalid
+		row.result.Errors = errs
+	}
+
+	return row
+}
+
+// planImportAddresses predicts the addresses the valid rows would be given, one after the other from the lowest free
+// addresses of each interface
+func (w *WgPeer) planImportAddresses(rows []importRow) {
+	planned := make(map[uint][]netip.Prefix)
+
+	for i := range rows {
+		row := &rows[i]
+		if row.result.Status != schema.ImportRowValid {
+			continue
+		}
+
+		allowedAddress, err := w.allocatePeerAddress(*row.iface, row.req.Name, planned[row.iface.ID])
+		if err != nil {
+			row.result.Status = schema.ImportRowInvalid
+			row.result.Errors = append(row.result.Errors, err.Error())
+			continue
+		}
+
+		row.result.AllowedAddress = allowedAddress
+		planned[row.iface.ID] = append(planned[row.iface.ID], utils.ParseAllowedAddresses(allowedAddress)...)
+	}
+}
+
+func (w *WgPeer) createImportedPeers(rows []importRow) {
+	for i := range rows {
+		row := &rows[i]
+		if row.result.Status != schema.ImportRowValid {
+			continue
+		}
+
+		if err := w.createImportedPeer(row); err != nil {
+			w.logger.Warn("failed to import peer", zap.Int("row", row.result.Row), zap.String("name", row.req.Name), zap.Error(err))
+			row.result.Status = schema.ImportRowFailed
+			row.result.Errors = append(row.result.Errors, err.Error())
+		}
+	}
+}
+
+func (w *WgPeer) createImportedPeer(row *importRow) error {
+	credentials, err := w.GetPeerCredentials()
+	if err != nil {
+		return err
+	}
+
+	// the allowed address is left empty for CreatePeer to allocate it under the address lock
+	row.req.PrivateKey = credentials.PrivateKey
+	row.req.PublicKey = credentials.PublicKey
+
+	peer, err := w.CreatePeer(&row.req)
+	if err != nil {
+		return err
+	}
+	row.result.AllowedAddress = peer.AllowedAddress
+
+	row.result.Status = schema.ImportRowCreated
+	row.result.PeerId = &peer.Id
+
+	return nil
+}
+
+func parseImportHeader(header []string) (map[string]int, error) {
+	columns := make(map[string]int, len(header))
+	for i, title := range header {
+		key := importHeaderReplacer.Replace(strings.ToLower(strings.TrimSpace(title)))
+		if key != "" {
+			columns[key] = i
+		}
+	}
+
+	for _, required := range []string{importColName, importColInterface} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("%w: missing the %q column", common.ErrInvalidImportFile, required)
+		}
+	}
+
+	return columns, nil
+}
+
+func isBlankRow(cells []string) bool {
+	for _, cell := range cells {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/dataservice/model"
+	"github.com/maahdima/mwp/api/http/schema"
+)
+
+func readImportFile(t *testing.T, content string) [][]string {
+	t.Helper()
+
+	rows, err := NewExcelGenerator(nil).ReadRows("peers.csv", strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
+
+func TestImportPeers(t *testing.T) {
+	env := newTestEnv(t)
+	iface := env.seedInterface(t, "wg0")
+	env.seedPool(t, iface, "10.0.0.2", "10.0.0.254")
+	peers := env.peerService()
+
+	rows := readImportFile(t, "\ufeffName,Interface,Traffic Limit,Expire Time,Bandwidth\n"+
+		"alice,wg0,10,2030-01-01,10M/5M\n"+
+		"bob,wg0,,,\n"+
+		",,,,\n"+
+		"alice,wg0,,,\n"+
+		"carol,wg9,,,\n"+
+		"dave,wg0,-1,01/01/2030,fast\n")
+
+	plan, err := peers.ImportPeers(env.server, rows, "", true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Total != 5 || plan.Valid != 2 || plan.Invalid != 3 || plan.Created != 0 {
+		t.Fatalf("dry run report %+v, want 2 of 5 rows valid and none created", plan)
+	}
+	if plan.Rows[0].AllowedAddress != "10.0.0.2/32" || plan.Rows[1].AllowedAddress != "10.0.0.3/32" {
+		t.Fatalf("dry run planned %s and %s, want consecutive addresses", plan.Rows[0].AllowedAddress, plan.Rows[1].AllowedAddress)
+	}
+	if dave := plan.Rows[4]; len(dave.Errors) != 3 {
+		t.Fatalf("row %d reported %v, want the traffic limit, expire time and bandwidth errors", dave.Row, dave.Errors)
+	}
+	if records := env.router.Records(common.WGPeerPath); len(records) != 0 {
+		t.Fatalf("dry run created %d peers on the router", len(records))
+	}
+
+	resp, err := peers.ImportPeers(env.server, rows, "", false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Created != 2 || resp.Invalid != 3 || resp.Failed != 0 {
+		t.Fatalf("import report %+v, want the 2 valid rows created", resp)
+	}
+	for i, row := range resp.Rows[:2] {
+		if row.Status != schema.ImportRowCreated || row.PeerId == nil || row.AllowedAddress != plan.Rows[i].AllowedAddress {
+			t.Fatalf("row %d %+v does not match the dry run", row.Row, row)
+		}
+	}
+
+	var alice model.Peer
+	if err := env.db.First(&alice, *resp.Rows[0].PeerId).Error; err != nil {
+		t.Fatal(err)
+	}
+	if alice.Endpoint != env.server.IPAddress || alice.ExpireTime == nil || alice.DownloadBandwidth == nil || *alice.DownloadBandwidth != "10M" {
+		t.Fatalf("imported peer %+v does not carry the row", alice)
+	}
+}
+
+func TestImportPeersRejectsMissingColumn(t *testing.T) {
+	env := newTestEnv(t)
+
+	if _, err := env.peerService().ImportPeers(env.server, readImportFile(t, "name\nalice\n"), "", true, nil); err == nil {
+		t.Fatal("a file without the interface column was accepted")
+	}
+}
@@ -0,0 +1,282 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/dataservice/model"
+	"github.com/maahdima/mwp/api/http/schema"
+	"github.com/maahdima/mwp/api/utils"
+	"github.com/maahdima/mwp/api/utils/timehelper"
+)
+
+type PeerPlan struct {
+	db          *gorm.DB
+	peerService *WgPeer
+	logger      *zap.Logger
+}
+
+func NewPeerPlan(db *gorm.DB, peerService *WgPeer) *PeerPlan {
+	return &PeerPlan{
+		db:          db,
+		peerService: peerService,
+		logger:      zap.L().Named("PeerPlanService"),
+	}
+}
+
+func (p *PeerPlan) GetPeerPlans() (*[]schema.PeerPlanResponse, error) {
+	var plans []model.PeerPlan
+	if err := p.db.Order("id").Find(&plans).Error; err != nil {
+		p.logger.Error("failed to get peer plans", zap.Error(err))
+		return nil, err
+	}
+
+	resp := make([]schema.PeerPlanResponse, 0, len(plans))
+	for _, plan := range plans {
+		transformed, err := p.transformPlanToResponse(plan)
+		if err != nil {
+			return nil, err
+		}
+		resp = append(resp, transformed)
+	}
+
+	return &resp, nil
+}
+
+func (p *PeerPlan) CreatePeerPlan(req *schema.CreatePeerPlanRequest) (*schema.PeerPlanResponse, error) {
+	if err := validatePeerPlan(req); err != nil {
+		return nil, err
+	}
+
+	var plan model.PeerPlan
+	applyPeerPlanRequest(&plan, req)
+
+	if err := p.db.Create(&plan).Error; err != nil {
+		p.logger.Error("failed to create peer plan", zap.Error(err))
+		return nil, err
+	}
+
+	resp, err := p.transformPlanToResponse(plan)
+	if err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// UpdatePeerPlan replaces the settings of a plan. Peers keep the values they were created with unless Propagate is
+// set, then the new settings are pushed to every peer on the plan and the per-peer outcome is reported.
+func (p *PeerPlan) UpdatePeerPlan(id uint, req *schema.UpdatePeerPlanRequest) (*schema.UpdatePeerPlanResponse, error) {
+	if err := validatePeerPlan(&req.CreatePeerPlanRequest); err != nil {
+		return nil, err
+	}
+
+	var plan model.PeerPlan
+	if err := p.db.First(&plan, id).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			p.logger.Error("failed to find peer plan", zap.Uint("id", id), zap.Error(err))
+		}
+		return nil, err
+	}
+
+	applyPeerPlanRequest(&plan, &req.CreatePeerPlanRequest)
+	if err := p.db.Save(&plan).Error; err != nil {
+		p.logger.Error("failed to update peer plan", zap.Uint("id", id), zap.Error(err))
+		return nil, err
+	}
+
+	results := []schema.BulkPeerResult{}
+	if req.Propagate {
+		var err error
+		if results, err = p.propagatePeerPlan(plan); err != nil {
+			return nil, err
+		}
+	}
+
+	transformed, err := p.transformPlanToResponse(plan)
+	if err != nil {
+		return nil, err
+	}
+
+	return &schema.UpdatePeerPlanResponse{
+		Plan:    transformed,
+		Results: results,
+	}, nil
+}
+
+// DeletePeerPlan removes a plan, its peers keep their settings and are detached from it
+func (p *PeerPlan) DeletePeerPlan(id uint) error {
+	var plan model.PeerPlan
+	if err := p.db.First(&plan, id).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			p.logger.Error("failed to find peer plan", zap.Uint("id", id), zap.Error(err))
+		}
+		return err
+	}
+
+	err := p.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Peer{}).Where("plan_id = ?", plan.ID).Update("plan_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&plan).Error
+	})
+	if err != nil {
+		p.logger.Error("failed to delete peer plan", zap.Uint("id", id), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (p *PeerPlan) propagatePeerPlan(plan model.PeerPlan) ([]schema.BulkPeerResult, error) {
+	var peers []model.Peer
+	if err := p.db.Preload("Server").Where("plan_id = ?", plan.ID).Order("id").Find(&peers).Error; err != nil {
+		p.logger.Error("failed to fetch peers of plan", zap.Uint("planId", plan.ID), zap.Error(err))
+		return nil, err
+	}
+
+	var keepalive *string
+	if plan.PersistentKeepalive != nil {
+		seconds, _ := timehelper.ParseTime(*plan.PersistentKeepalive)
+		keepalive = utils.Ptr(strconv.Itoa(seconds))
+	}
+
+	interfaceKeepalives, err := p.interfaceKeepalives(peers)
+	if err != nil {
+		return nil, err
+	}
+
+	results := p.peerService.forEachPeer(peers, func(peer model.Peer) error {
+		update := peerUpdateRequest(peer)
+		update.TrafficLimit = nil
+		if plan.TrafficLimit != nil {
+			update.TrafficLimit = utils.Ptr(bytesToGBString(*plan.TrafficLimit))
+		}
+		update.DownloadBandwidth = plan.DownloadBandwidth
+		update.UploadBandwidth = plan.UploadBandwidth
+		// a setting the plan leaves out is cleared, the peer falls back to the default of its interface
+		update.PersistentKeepAlive = keepalive
+		if keepalive == nil {
+			update.PersistentKeepAlive = utils.Ptr(interfaceKeepalives[peerInterfaceKey(peer.ServerID, peer.Interface)])
+		}
+		update.DNS = utils.Ptr(utils.DerefString(plan.DNS))
+		update.AllowedIPsMode = utils.Ptr(utils.DerefString(plan.AllowedIPsMode))
+
+		_, err := p.peerService.UpdatePeer(peer.ID, &update)
+		return err
+	})
+
+	p.logger.Info("peer plan propagated", zap.String("plan", plan.Name), zap.Int("peers", len(results)))
+
+	return results, nil
+}
+
+// interfaceKeepalives returns the client keepalive of the interfaces of peers keyed by peerInterfaceKey, the default
+// keepalive for an interface that sets none
+func (p *PeerPlan) interfaceKeepalives(peers []model.Peer) (map[string]string, error) {
+	serverIDs := make([]uint, 0, len(peers))
+	for _, peer := range peers {
+		serverIDs = append(serverIDs, peer.ServerID)
+	}
+
+	var ifaces []model.Interface
+	if err := p.db.Select("server_id", "name", "client_persistent_keepalive").Where("server_id IN ?", serverIDs).Find(&ifaces).Error; err != nil {
+		p.logger.Error("failed to fetch interfaces of plan peers", zap.Error(err))
+		return nil, err
+	}
+
+	keepalives := make(map[string]string, len(peers))
+	for _, peer := range peers {
+		keepalives[peerInterfaceKey(peer.ServerID, peer.Interface)] = common.DefaultKeepalive
+	}
+	for _, iface := range ifaces {
+		if iface.ClientPersistentKeepalive != nil {
+			keepalives[peerInterfaceKey(iface.ServerID, iface.Name)] = *iface.ClientPersistentKeepalive
+		}
+	}
+
+	return keepalives, nil
+}
+
+func peerInterfaceKey(serverID uint, name string) string {
+	return fmt.Sprintf("%d/%s", serverID, name)
+}
+
+func (p *PeerPlan) transformPlanToResponse(plan model.PeerPlan) (schema.PeerPlanResponse, error) {
+	var peerCount int64
+	if err := p.db.Model(&model.Peer{}).Where("plan_id = ?", plan.ID).Count(&peerCount).Error; err != nil {
+		p.logger.Error("failed to count peers of plan", zap.Uint("planId", plan.ID), zap.Error(err))
+		return schema.PeerPlanResponse{}, err
+	}
+
+	var trafficLimit *string
+	if plan.TrafficLimit != nil {
+		trafficLimit = utils.Ptr(utils.BytesToGB(*plan.TrafficLimit))
+	}
+
+	return schema.PeerPlanResponse{
+		Id:                  plan.ID,
+		Name:                plan.Name,
+		Comment:             plan.Comment,
+		TrafficLimit:        trafficLimit,
+		DownloadBandwidth:   plan.DownloadBandwidth,
+		UploadBandwidth:     plan.UploadBandwidth,
+		ValidityDays:        plan.ValidityDays,
+		PersistentKeepalive: plan.PersistentKeepalive,
+		DNS:                 plan.DNS,
+		AllowedIPsMode:      plan.AllowedIPsMode,
+		PeerCount:           peerCount,
+	}, nil
+}
+
+func applyPeerPlanRequest(plan *model.PeerPlan, req *schema.CreatePeerPlanRequest) {
+	plan.Name = req.Name
+	plan.Comment = req.Comment
+	plan.TrafficLimit = nil
+	if req.TrafficLimit != nil {
+		trafficBytes := utils.GBToBytes(*req.TrafficLimit)
+		plan.TrafficLimit = &trafficBytes
+	}
+	plan.DownloadBandwidth = req.DownloadBandwidth
+	plan.UploadBandwidth = req.UploadBandwidth
+	plan.ValidityDays = req.ValidityDays
+	plan.PersistentKeepalive = req.PersistentKeepalive
+	plan.DNS = req.DNS
+	plan.AllowedIPsMode = req.AllowedIPsMode
+}
+
+func validatePeerPlan(req *schema.CreatePeerPlanRequest) error {
+	if req.TrafficLimit != nil {
+		if gb, err := strconv.ParseFloat(*req.TrafficLimit, 64); err != nil || gb <= 0 {
+			return fmt.Errorf("%w: invalid traffic limit %q", common.ErrInvalidPeerPlan, *req.TrafficLimit)
+		}
+	}
+
+	for _, bandwidth := range []*string{req.DownloadBandwidth, req.UploadBandwidth} {
+		if bandwidth != nil && !bandwidthPattern.MatchString(*bandwidth) {
+			return fmt.Errorf("%w: invalid bandwidth %q", common.ErrInvalidPeerPlan, *bandwidth)
+		}
+	}
+
+	if req.PersistentKeepalive != nil {
+		if _, err := timehelper.ParseTime(*req.PersistentKeepalive); err != nil {
+			return fmt.Errorf("%w: invalid keepalive %q, expected HH:MM:SS", common.ErrInvalidPeerPlan, *req.PersistentKeepalive)
+		}
+	}
+
+	if req.DNS != nil {
+		for _, server := range strings.Split(*req.DNS, ",") {
+			if net.ParseIP(strings.TrimSpace(server)) == nil {
+				return fmt.Errorf("%w: invalid DNS server %q", common.ErrInvalidPeerPlan, server)
+			}
+		}
+	}
+
+	return nil
+}
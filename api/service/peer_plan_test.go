@@ -0,0 +1,150 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/dataservice/model"
+	"github.com/maahdima/mwp/api/http/schema"
+	"github.com/maahdima/mwp/api/utils"
+)
+
+func newPlanRequest() schema.CreatePeerPlanRequest {
+	validity := 30
+	return schema.CreatePeerPlanRequest{
+		Name:                "gold",
+		TrafficLimit:        utils.Ptr("10"),
+		DownloadBandwidth:   utils.Ptr("10M"),
+		UploadBandwidth:     utils.Ptr("5M"),
+		ValidityDays:        &validity,
+		PersistentKeepalive: utils.Ptr("00:00:30"),
+		DNS:                 utils.Ptr("9.9.9.9"),
+		AllowedIPsMode:      utils.Ptr(common.AllowedIpsModeExcludePrivate),
+	}
+}
+
+func TestCreatePeerFromPlan(t *testing.T) {
+	env := newTestEnv(t)
+	iface := env.seedInterface(t, "wg0")
+	peers := env.peerService()
+
+	planReq := newPlanRequest()
+	plan, err := NewPeerPlan(env.db, peers).CreatePeerPlan(&planReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := newCreatePeerRequest(t, iface, "alice", "10.0.0.2/32")
+	req.PlanId = &plan.Id
+	req.UploadBandwidth = utils.Ptr("1M")
+	resp, err := peers.CreatePeer(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var peer model.Peer
+	if err := env.db.First(&peer, resp.Id).Error; err != nil {
+		t.Fatal(err)
+	}
+	expireTime := time.Now().AddDate(0, 0, 30).Format("2006-01-02")
+	if peer.PlanID == nil || *peer.PlanID != plan.Id || utils.DerefString(peer.ExpireTime) != expireTime ||
+		peer.TrafficLimit == nil || *peer.TrafficLimit != utils.GBToBytes("10") || peer.PersistentKeepalive != "30" ||
+		utils.DerefString(peer.DNS) != "9.9.9.9" {
+		t.Fatalf("peer %+v does not carry the settings of the plan", peer)
+	}
+	// bandwidth is taken from the request as soon as one direction is given
+	if peer.DownloadBandwidth != nil || utils.DerefString(peer.UploadBandwidth) != "1M" {
+		t.Fatalf("bandwidth %v/%v, want only the upload of the request", peer.DownloadBandwidth, peer.UploadBandwidth)
+	}
+}
+
+func TestUpdatePeerPlanPropagates(t *testing.T) {
+	env := newTestEnv(t)
+	iface := env.seedInterface(t, "wg0")
+	peers := env.peerService()
+	plans := NewPeerPlan(env.db, peers)
+
+	planReq := newPlanRequest()
+	plan, err := plans.CreatePeerPlan(&planReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := newCreatePeerRequest(t, iface, "alice", "10.0.0.2/32")
+	req.PlanId = &plan.Id
+	resp, err := peers.CreatePeer(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	planReq.TrafficLimit = utils.Ptr("20")
+	planReq.DownloadBandwidth = utils.Ptr("20M")
+	updated, err := plans.UpdatePeerPlan(plan.Id, &schema.UpdatePeerPlanRequest{CreatePeerPlanRequest: planReq, Propagate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updated.Results) != 1 || !updated.Results[0].Success || updated.Plan.PeerCount != 1 {
+		t.Fatalf("propagation results %+v, want the peer updated", updated.Results)
+	}
+
+	var peer model.Peer
+	if err := env.db.First(&peer, resp.Id).Error; err != nil {
+		t.Fatal(err)
+	}
+	if *peer.TrafficLimit != utils.GBToBytes("20") || utils.DerefString(peer.DownloadBandwidth) != "20M" {
+		t.Fatalf("peer %+v did not get the new settings of the plan", peer)
+	}
+	if queue := env.router.Record(common.QueuePath, *peer.QueueID); queue["max-limit"] != "20M/5M" {
+		t.Fatalf("queue limit %s, want the new bandwidth of the plan", queue["max-limit"])
+	}
+}
+
+func TestCreatePeerWithUnknownPlan(t *testing.T) {
+	env := newTestEnv(t)
+	iface := env.seedInterface(t, "wg0")
+
+	req := newCreatePeerRequest(t, iface, "alice", "10.0.0.2/32")
+	unknown := uint(42)
+	req.PlanId = &unknown
+	if _, err := env.peerService().CreatePeer(req); !errors.Is(err, common.ErrInvalidPeerPlan) {
+		t.Fatalf("got %v, want %v", err, common.ErrInvalidPeerPlan)
+	}
+}
+
+func TestUpdatePeerPlanClearsLeftOutSettings(t *testing.T) {
+	env := newTestEnv(t)
+	iface := env.seedInterface(t, "wg0")
+	iface.ClientPersistentKeepalive = utils.Ptr("15")
+	if err := env.db.Save(&iface).Error; err != nil {
+		t.Fatal(err)
+	}
+	peers := env.peerService()
+	plans := NewPeerPlan(env.db, peers)
+
+	planReq := newPlanRequest()
+	plan, err := plans.CreatePeerPlan(&planReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := newCreatePeerRequest(t, iface, "alice", "10.0.0.2/32")
+	req.PlanId = &plan.Id
+	resp, err := peers.CreatePeer(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	planReq.PersistentKeepalive, planReq.DNS, planReq.AllowedIPsMode = nil, nil, nil
+	if _, err := plans.UpdatePeerPlan(plan.Id, &schema.UpdatePeerPlanRequest{CreatePeerPlanRequest: planReq, Propagate: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	var peer model.Peer
+	if err := env.db.First(&peer, resp.Id).Error; err != nil {
+		t.Fatal(err)
+	}
+	if peer.PersistentKeepalive != "15" || peer.DNS != nil || peer.AllowedIPsMode != nil {
+		t.Fatalf("keepalive %s, dns %v, mode %v, want the settings the plan left out back at the interface defaults",
+			peer.PersistentKeepalive, peer.DNS, peer.AllowedIPsMode)
+	}
+}
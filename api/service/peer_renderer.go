@@ -0,0 +1,157 @@
+package service
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"strconv"
+	"sync"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/maahdima/mwp/api/config"
+	"github.com/maahdima/mwp/api/dataservice/model"
+)
+
+const defaultQRCodeCacheSize = 1000
+
+// PeerRenderer renders the config and QR code of a peer from the database whenever they are asked for, nothing is
+// stored on disk. QR codes are cached by the config they encode, so a change to the peer or its interface misses the
+// cache and replaces the entry.
+type PeerRenderer struct {
+	db     *gorm.DB
+	cache  *qrCodeCache
+	logger *zap.Logger
+}
+
+func NewPeerRenderer(db *gorm.DB, cfg config.PeerRenderConfig) *PeerRenderer {
+	size, err := strconv.Atoi(cfg.QRCodeCacheSize)
+	if err != nil || size < 0 {
+		size = defaultQRCodeCacheSize
+	}
+
+	renderer := &PeerRenderer{
+		db:     db,
+		logger: zap.L().Named("PeerRenderer"),
+	}
+	if size > 0 {
+		renderer.cache = newQRCodeCache(size)
+	}
+
+	return renderer
+}
+
+// RenderConfig returns the WireGuard config of a peer as its settings and those of its interface are now
+func (r *PeerRenderer) RenderConfig(peer model.Peer) ([]byte, error) {
+	var iface model.Interface
+	if err := r.db.Where("name = ? AND server_id = ?", peer.Interface, peer.ServerID).First(&iface).Error; err != nil {
+		r.logger.Error("failed to get interface of peer", zap.String("interface", peer.Interface), zap.Error(err))
+		return nil, err
+	}
+
+	return []byte(peerClientConfig(peer.PrivateKey, peer, iface)), nil
+}
+
+// RenderQRCode returns the config of a peer encoded as a JPEG QR code
+func (r *PeerRenderer) RenderQRCode(peer model.Peer) ([]byte, error) {
+	content, err := r.RenderConfig(peer)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256(content)
+	if image, ok := r.cache.get(peer.UUID, digest); ok {
+		return image, nil
+	}
+
+	image, err := renderQRCodeJPEG(string(content))
+	if err != nil {
+		r.logger.Error("failed to render QR code", zap.String("uuid", peer.UUID), zap.Error(err))
+		return nil, err
+	}
+	r.cache.put(peer.UUID, digest, image)
+
+	return image, nil
+}
+
+// Forget drops the cached QR code of a deleted peer
+func (r *PeerRenderer) Forget(uuid string) {
+	r.cache.remove(uuid)
+}
+
+// qrCodeCache keeps the most recently rendered QR code of up to size peers, each with the digest of the config it
+// encodes. A nil cache holds nothing.
+type qrCodeCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type qrCodeCacheEntry struct {
+	uuid   string
+	digest [sha256.Size]byte
+	image  []byte
+}
+
+func newQRCodeCache(size int) *qrCodeCache {
+	return &qrCodeCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (c *qrCodeCache) get(uuid string, digest [sha256.Size]byte) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[uuid]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*qrCodeCacheEntry)
+	if entry.digest != digest {
+		return nil, false
+	}
+
+	c.order.MoveToFront(element)
+	return entry.image, true
+}
+
+func (c *qrCodeCache) put(uuid string, digest [sha256.Size]byte, image []byte) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[uuid]; ok {
+		element.Value = &qrCodeCacheEntry{uuid: uuid, digest: digest, image: image}
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[uuid] = c.order.PushFront(&qrCodeCacheEntry{uuid: uuid, digest: digest, image: image})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*qrCodeCacheEntry).uuid)
+	}
+}
+
+func (c *qrCodeCache) remove(uuid string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[uuid]; ok {
+		c.order.Remove(element)
+		delete(c.entries, uuid)
+	}
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/config"
+	"github.com/maahdima/mwp/api/dataservice/model"
+	"github.com/maahdima/mwp/api/utils"
+)
+
+// createRenderedPeer creates a peer through the panel and returns it as stored
+func createRenderedPeer(t *testing.T, env *testEnv) (model.Interface, model.Peer) {
+	t.Helper()
+
+	iface := env.seedInterface(t, "wg0")
+	resp, err := env.peerService().CreatePeer(newCreatePeerRequest(t, iface, "alice", "10.0.0.2/32"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var peer model.Peer
+	if err := env.db.First(&peer, resp.Id).Error; err != nil {
+		t.Fatal(err)
+	}
+	return iface, peer
+}
+
+func TestPeerConfigFollowsInterfaceSettings(t *testing.T) {
+	env := newTestEnv(t)
+	iface, peer := createRenderedPeer(t, env)
+	configs := NewConfigGenerator(env.db, env.renderer)
+
+	content, err := configs.GetPeerConfig(peer.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "PrivateKey = "+peer.PrivateKey) || !strings.Contains(string(content), "DNS = "+common.DefaultDns) {
+		t.Fatalf("config does not match the stored peer:\n%s", content)
+	}
+
+	iface.ClientDNS = utils.Ptr("9.9.9.9")
+	if err := env.db.Save(&iface).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	content, err = configs.GetPeerConfig(peer.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "DNS = 9.9.9.9") {
+		t.Fatalf("config kept the old DNS after the interface changed:\n%s", content)
+	}
+}
+
+func TestPeerQRCodeIsRenderedAgainAfterAChange(t *testing.T) {
+	env := newTestEnv(t)
+	iface, peer := createRenderedPeer(t, env)
+	qrCodes := NewQRCodeGenerator(env.db, env.renderer)
+
+	first, err := qrCodes.GetPeerQRCode(peer.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cached, err := qrCodes.GetPeerQRCode(peer.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if &first[0] != &cached[0] {
+		t.Fatal("an unchanged peer was not served from the cache")
+	}
+
+	iface.ClientDNS = utils.Ptr("9.9.9.9")
+	if err := env.db.Save(&iface).Error; err != nil {
+		t.Fatal(err)
+	}
+	changed, err := qrCodes.GetPeerQRCode(peer.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(first, changed) {
+		t.Fatal("the QR code did not change with the config")
+	}
+}
+
+func TestUserConfigNeedsSharedPeer(t *testing.T) {
+	env := newTestEnv(t)
+	_, peer := createRenderedPeer(t, env)
+
+	if _, err := NewConfigGenerator(env.db, env.renderer).GetUserConfig(peer.UUID); !errors.Is(err, common.ErrPeerNotShared) {
+		t.Fatalf("config err = %v, want ErrPeerNotShared", err)
+	}
+	if _, err := NewQRCodeGenerator(env.db, env.renderer).GetUserQRCode(peer.UUID); !errors.Is(err, common.ErrPeerNotShared) {
+		t.Fatalf("QR code err = %v, want ErrPeerNotShared", err)
+	}
+}
+
+func TestQRCodeCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := newQRCodeCache(2)
+	digest := sha256.Sum256([]byte("config"))
+
+	cache.put("a", digest, []byte("a"))
+	cache.put("b", digest, []byte("b"))
+	if _, ok := cache.get("a", digest); !ok {
+		t.Fatal("a was not cached")
+	}
+	cache.put("c", digest, []byte("c"))
+
+	if _, ok := cache.get("b", digest); ok {
+		t.Fatal("b was kept although it was used least recently")
+	}
+	if _, ok := cache.get("a", sha256.Sum256([]byte("other config"))); ok {
+		t.Fatal("a was served for a config it does not encode")
+	}
+
+	cache.remove("c")
+	if _, ok := cache.get("c", digest); ok {
+		t.Fatal("c was kept after it was removed")
+	}
+}
+
+func TestPeerRendererWithoutCache(t *testing.T) {
+	env := newTestEnv(t)
+	_, peer := createRenderedPeer(t, env)
+	renderer := NewPeerRenderer(env.db, config.PeerRenderConfig{QRCodeCacheSize: "0"})
+
+	image, err := renderer.RenderQRCode(peer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(image) == 0 {
+		t.Fatal("rendered an empty QR code")
+	}
+	renderer.Forget(peer.UUID)
+}
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/dataservice/model"
+	"github.com/maahdima/mwp/api/http/schema"
+	"github.com/maahdima/mwp/api/utils"
+)
+
+// RenewPeer extends the expiry of a peer by the given days or by the validity of a plan, optionally resets its
+// usage, enables it again when expiry or quota had disabled it and records the renewal. A peer an admin disabled
+// stays disabled
+func (w *WgPeer) RenewPeer(id uint, req *schema.RenewPeerRequest, usage PeerUsageResetter) (*schema.PeerRenewalResponse, error) {
+	var peer model.Peer
+	if err := w.db.Preload("Server").First(&peer, "id = ?", id).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			w.logger.Error("failed to find peer in database", zap.Error(err))
+		}
+		return nil, err
+	}
+
+	days, err := w.renewalDays(req)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := w.transformPeerStatus(peer)
+	expired := slices.Contains(statuses, schema.ExpiredPeer)
+	overLimit := slices.Contains(statuses, schema.SuspendedPeer)
+	reason := disabledReason(peer, overLimit)
+	byAdmin := peer.Disabled && reason == common.PeerDisabledByAdmin
+	reEnable := !byAdmin && (expired || (peer.Disabled && overLimit)) && !(overLimit && !req.ResetUsage)
+
+	renewal := model.PeerRenewal{
+		PeerID:             peer.ID,
+		ServerID:           peer.ServerID,
+		PeerName:           peer.Name,
+		PlanID:             req.PlanId,
+		Days:               days,
+		PreviousExpireTime: peer.ExpireTime,
+		NewExpireTime:      *extendExpireTime(peer.ExpireTime, days),
+		UsageReset:         req.ResetUsage,
+		PreviousUsage:      peer.DownloadUsage + peer.UploadUsage,
+		ReEnabled:          reEnable,
+	}
+
+	tx := newSaga("renew peer", w.logger)
+
+	snapshot := peerUpdateRequest(peer)
+	err = tx.run("extend expire time", func() error {
+		update := peerUpdateRequest(peer)
+		update.ExpireTime = &renewal.NewExpireTime
+		if _, err := w.UpdatePeer(peer.ID, &update); err != nil {
+			return err
+		}
+		// the update may have replaced the scheduler or queue of the peer
+		return w.db.Preload("Server").First(&peer, "id = ?", peer.ID).Error
+	}, func() error {
+		_, err := w.UpdatePeer(peer.ID, &snapshot)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if reEnable {
+		err = tx.run("enable peer", func() error {
+			return w.setPeerStatus(peer, false, "")
+		}, func() error {
+			return w.setPeerStatus(peer, true, reason)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if req.ResetUsage {
+		err = tx.run("reset usage", func() error {
+			return usage.ResetPeerUsage(peer.ID)
+		}, func() error {
+			return w.db.Model(&model.Peer{}).Where("id = ?", peer.ID).Updates(map[string]interface{}{
+				"download_usage": peer.DownloadUsage,
+				"upload_usage":   peer.UploadUsage,
+				"last_tx":        peer.LastTx,
+				"last_rx":        peer.LastRx,
+			}).Error
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.run("record renewal", func() error {
+		return w.db.Transaction(func(db *gorm.DB) error {
+			err := db.Model(&model.Peer{}).Where("id = ?", peer.ID).Updates(map[string]interface{}{
+				"first_notify":  false,
+				"second_notify": false,
+				"third_notify":  false,
+			}).Error
+			if err != nil {
+				return err
+			}
+			return db.Create(&renewal).Error
+		})
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	w.logger.Info("peer renewed",
+		zap.Uint("id", peer.ID),
+		zap.Int("days", days),
+		zap.String("expireTime", renewal.NewExpireTime),
+		zap.Bool("usageReset", req.ResetUsage),
+		zap.Bool("reEnabled", reEnable),
+	)
+
+	resp := transformRenewalToResponse(renewal)
+	return &resp, nil
+}
+
+// disabledReason is why a peer is disabled, peers disabled before the reason was recorded are put down to their quota
+// when over it, to their expiry once expired and to an admin otherwise
+func disabledReason(peer model.Peer, overLimit bool) string {
+	if peer.DisabledReason != nil {
+		return *peer.DisabledReason
+	}
+	if overLimit {
+		return common.PeerDisabledByTrafficLimit
+	}
+	if isPeerExpired(peer) {
+		return common.PeerDisabledByExpiry
+	}
+	return common.PeerDisabledByAdmin
+}
+
+// GetPeerRenewals lists the renewals of a peer, newest first
+func (w *WgPeer) GetPeerRenewals(id uint) (*[]schema.PeerRenewalResponse, error) {
+	var renewals []model.PeerRenewal
+	if err := w.db.Where("peer_id = ?", id).Order("id DESC").Find(&renewals).Error; err != nil {
+		w.logger.Error("failed to get peer renewals", zap.Uint("id", id), zap.Error(err))
+		return nil, err
+	}
+
+	resp := make([]schema.PeerRenewalResponse, 0, len(renewals))
+	for _, renewal := range renewals {
+		resp = append(resp, transformRenewalToResponse(renewal))
+	}
+
+	return &resp, nil
+}
+
+func (w *WgPeer) renewalDays(req *schema.RenewPeerRequest) (int, error) {
+	if req.Days != nil {
+		return *req.Days, nil
+	}
+	if req.PlanId == nil {
+		return 0, fmt.Errorf("%w: either days or plan_id must be given", common.ErrInvalidRenewal)
+	}
+
+	var plan model.PeerPlan
+	if err := w.db.First(&plan, "id = ?", *req.PlanId).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, fmt.Errorf("%w: peer plan %d not found", common.ErrInvalidRenewal, *req.PlanId)
+		}
+		w.logger.Error("db error while fetching peer plan", zap.Error(err))
+		return 0, err
+	}
+	if plan.ValidityDays == nil {
+		return 0, fmt.Errorf("%w: peer plan %s has no validity", common.ErrInvalidRenewal, plan.Name)
+	}
+
+	return *plan.ValidityDays, nil
+}
+
+func transformRenewalToResponse(renewal model.PeerRenewal) schema.PeerRenewalResponse {
+	return schema.PeerRenewalResponse{
+		Id:                 renewal.ID,
+		PeerId:             renewal.PeerID,
+		PeerName:           renewal.PeerName,
+		PlanId:             renewal.PlanID,
+		Days:               renewal.Days,
+		PreviousExpireTime: renewal.PreviousExpireTime,
+		NewExpireTime:      renewal.NewExpireTime,
+		UsageReset:         renewal.UsageReset,
+		PreviousUsage:      utils.BytesToGB(renewal.PreviousUsage),
+		ReEnabled:          renewal.ReEnabled,
+		RenewedAt:          time.Unix(int64(renewal.CreatedAt), 0).Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/maahdima/mwp/api/adaptor/mikrotik/mikrotiktest"
+	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/dataservice/model"
+	"github.com/maahdima/mwp/api/http/schema"
+	"github.com/maahdima/mwp/api/utils"
+)
+
+// usageResetter zeroes the usage in the database like the traffic calculator does
+type usageResetter struct {
+	env *testEnv
+}
+
+func (u usageResetter) ResetPeerUsage(id uint) error {
+	return u.env.db.Model(&model.Peer{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"download_usage": 0,
+		"upload_usage":   0,
+	}).Error
+}
+
+// createDisabledPeer creates a peer and disables it the way its expiry scheduler or the traffic job would
+func createDisabledPeer(t *testing.T, env *testEnv, expireTime string, trafficLimit *string) model.Peer {
+	t.Helper()
+
+	iface := env.seedInterface(t, "wg0")
+	req := newCreatePeerRequest(t, iface, "alice", "10.0.0.2/32")
+	req.ExpireTime = utils.Ptr(expireTime)
+	req.TrafficLimit = trafficLimit
+	req.DownloadBandwidth = utils.Ptr("10M")
+	req.UploadBandwidth = utils.Ptr("5M")
+	resp, err := env.peerService().CreatePeer(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var peer model.Peer
+	if err := env.db.First(&peer, resp.Id).Error; err != nil {
+		t.Fatal(err)
+	}
+	updates := map[string]interface{}{"disabled": true}
+	if trafficLimit != nil {
+		updates["download_usage"] = *peer.TrafficLimit + 1
+	}
+	if err := env.db.Model(&peer).Updates(updates).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := env.router.Set(common.WGPeerPath, peer.PeerID, mikrotiktest.Record{"disabled": "true"}); err != nil {
+		t.Fatal(err)
+	}
+	return peer
+}
+
+func TestRenewExpiredPeer(t *testing.T) {
+	env := newTestEnv(t)
+	peer := createDisabledPeer(t, env, "2020-01-01", nil)
+	peers := env.peerService()
+
+	days := 30
+	renewal, err := peers.RenewPeer(peer.ID, &schema.RenewPeerRequest{Days: &days}, usageResetter{env})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expireTime := time.Now().AddDate(0, 0, 30).Format("2006-01-02")
+	if !renewal.ReEnabled || renewal.NewExpireTime != expireTime || utils.DerefString(renewal.PreviousExpireTime) != "2020-01-01" {
+		t.Fatalf("renewal %+v, want the peer re-enabled until %s", renewal, expireTime)
+	}
+
+	if err := env.db.First(&peer, peer.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if peer.Disabled || env.router.Record(common.WGPeerPath, peer.PeerID)["disabled"] != "false" {
+		t.Fatal("renewed peer is still disabled")
+	}
+	if scheduler := env.router.Record(common.SchedulerPath, *peer.SchedulerID); scheduler["start-date"] != expireTime {
+		t.Fatalf("scheduler starts %s, want %s", scheduler["start-date"], expireTime)
+	}
+
+	history, err := peers.GetPeerRenewals(peer.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*history) != 1 || (*history)[0].Id != renewal.Id {
+		t.Fatalf("renewal history %+v, want the renewal", *history)
+	}
+}
+
+func TestRenewPeerOverLimitNeedsUsageReset(t *testing.T) {
+	env := newTestEnv(t)
+	peer := createDisabledPeer(t, env, "2030-01-01", utils.Ptr("1"))
+	peers := env.peerService()
+
+	days := 30
+	renewal, err := peers.RenewPeer(peer.ID, &schema.RenewPeerRequest{Days: &days}, usageResetter{env})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renewal.ReEnabled {
+		t.Fatal("peer over its quota was enabled without resetting its usage")
+	}
+
+	renewal, err = peers.RenewPeer(peer.ID, &schema.RenewPeerRequest{Days: &days, ResetUsage: true}, usageResetter{env})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := env.db.First(&peer, peer.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !renewal.ReEnabled || peer.Disabled || peer.DownloadUsage != 0 {
+		t.Fatalf("renewal with a usage reset left the peer disabled %t with usage %d", peer.Disabled, peer.DownloadUsage)
+	}
+}
+
+func TestRenewPeerKeepsAdminDisabledPeerDisabled(t *testing.T) {
+	env := newTestEnv(t)
+	iface := env.seedInterface(t, "wg0")
+	peers := env.peerService()
+
+	req := newCreatePeerRequest(t, iface, "alice", "10.0.0.2/32")
+	req.ExpireTime = utils.Ptr("2020-01-01")
+	resp, err := peers.CreatePeer(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := peers.TogglePeerStatus(resp.Id); err != nil {
+		t.Fatal(err)
+	}
+
+	days := 30
+	renewal, err := peers.RenewPeer(resp.Id, &schema.RenewPeerRequest{Days: &days}, usageResetter{env})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var peer model.Peer
+	if err := env.db.First(&peer, resp.Id).Error; err != nil {
+		t.Fatal(err)
+	}
+	if renewal.ReEnabled || !peer.Disabled || utils.DerefString(peer.DisabledReason) != common.PeerDisabledByAdmin {
+		t.Fatalf("renewal enabled a peer an admin disabled: disabled %t, reason %v", peer.Disabled, peer.DisabledReason)
+	}
+}
+
+func TestRenewExpiredPeerWithoutReasonOrQueue(t *testing.T) {
+	env := newTestEnv(t)
+	iface := env.seedInterface(t, "wg0")
+	peers := env.peerService()
+
+	req := newCreatePeerRequest(t, iface, "alice", "10.0.0.2/32")
+	req.ExpireTime = utils.Ptr("2020-01-01")
+	resp, err := peers.CreatePeer(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// disabled by its scheduler before the reason was recorded
+	var peer model.Peer
+	if err := env.db.First(&peer, resp.Id).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := env.db.Model(&peer).Updates(map[string]interface{}{"disabled": true, "disabled_reason": nil}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := env.router.Set(common.WGPeerPath, peer.PeerID, mikrotiktest.Record{"disabled": "true"}); err != nil {
+		t.Fatal(err)
+	}
+
+	days := 30
+	renewal, err := peers.RenewPeer(resp.Id, &schema.RenewPeerRequest{Days: &days}, usageResetter{env})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := env.db.First(&peer, resp.Id).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !renewal.ReEnabled || peer.Disabled || peer.DisabledReason != nil {
+		t.Fatalf("renewal left an expired peer disabled %t with reason %v", peer.Disabled, peer.DisabledReason)
+	}
+}
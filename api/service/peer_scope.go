@@ -0,0 +1,78 @@
+package service
+
+import (
+	"errors"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/maahdima/mwp/api/dataservice/model"
+	"github.com/maahdima/mwp/api/http/schema"
+)
+
+// PeerInScope reports whether the interface of a peer is within a reseller scope
+func (w *WgPeer) PeerInScope(id uint, scope schema.AccessScope) (bool, error) {
+	var peer model.Peer
+	if err := w.db.First(&peer, id).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			w.logger.Error("failed to find peer in database", zap.Error(err))
+		}
+		return false, err
+	}
+
+	allowed, err := w.scopedInterfaceNames(peer.ServerID, scope)
+	if err != nil {
+		return false, err
+	}
+
+	return allowed[peer.Interface], nil
+}
+
+// InterfaceInScope reports whether an interface is within a reseller scope
+func (w *WgPeer) InterfaceInScope(id uint, scope schema.AccessScope) (bool, error) {
+	var iface model.Interface
+	if err := w.db.First(&iface, id).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			w.logger.Error("failed to find interface in database", zap.Error(err))
+		}
+		return false, err
+	}
+
+	return scope.AllowsInterface(iface.ServerID, iface.ID), nil
+}
+
+// FilterPeersInScope keeps the peers of one server whose interface is within a reseller scope
+func (w *WgPeer) FilterPeersInScope(serverID uint, peers []schema.PeerResponse, scope schema.AccessScope) ([]schema.PeerResponse, error) {
+	allowed, err := w.scopedInterfaceNames(serverID, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := make([]schema.PeerResponse, 0, len(peers))
+	for _, peer := range peers {
+		if allowed[peer.Interface] {
+			filtered = append(filtered, peer)
+		}
+	}
+
+	return filtered, nil
+}
+
+// scopedInterfaceNames returns the names of the interfaces of a server that are within a scope, a nil scope allows
+// every interface
+func (w *WgPeer) scopedInterfaceNames(serverID uint, scope schema.AccessScope) (map[string]bool, error) {
+	var interfaces []model.Interface
+	if err := w.db.Where("server_id = ?", serverID).Find(&interfaces).Error; err != nil {
+		w.logger.Error("failed to fetch interfaces", zap.Error(err))
+		return nil, err
+	}
+
+	allowed := make(map[string]bool, len(interfaces))
+	for _, iface := range interfaces {
+		if scope == nil || scope.AllowsInterface(iface.ServerID, iface.ID) {
+			allowed[iface.Name] = true
+		}
+	}
+
+	return allowed, nil
+}
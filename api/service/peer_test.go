@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/maahdima/mwp/api/adaptor/mikrotik/mikrotiktest"
+	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/dataservice/model"
+	"github.com/maahdima/mwp/api/http/schema"
+	"github.com/maahdima/mwp/api/utils"
+)
+
+func newCreatePeerRequest(t *testing.T, iface model.Interface, name, address string) *schema.CreatePeerRequest {
+	privateKey, publicKey := newKeyPair(t)
+	return &schema.CreatePeerRequest{
+		Name:           name,
+		InterfaceId:    iface.ID,
+		PrivateKey:     privateKey,
+		PublicKey:      publicKey,
+		AllowedAddress: address,
+		Endpoint:       "vpn.example.com",
+	}
+}
+
+func TestCreatePeer(t *testing.T) {
+	env := newTestEnv(t)
+	iface := env.seedInterface(t, "wg0")
+	peers := env.peerService()
+
+	req := newCreatePeerRequest(t, iface, "alice", "10.0.0.2/32")
+	req.ExpireTime = utils.Ptr("2030-01-01")
+	req.DownloadBandwidth = utils.Ptr("10M")
+	req.UploadBandwidth = utils.Ptr("5M")
+
+	resp, err := peers.CreatePeer(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var peer model.Peer
+	if err := env.db.First(&peer, "id = ?", resp.Id).Error; err != nil {
+		t.Fatal(err)
+	}
+	if peer.SchedulerID == nil || peer.QueueID == nil {
+		t.Fatal("scheduler or queue of the peer was not stored")
+	}
+
+	wgPeer := env.router.Record(common.WGPeerPath, peer.PeerID)
+	if wgPeer == nil {
+		t.Fatalf("peer %s is missing on the router", peer.PeerID)
+	}
+	if wgPeer["interface"] != "wg0" || wgPeer["public-key"] != req.PublicKey || wgPeer["allowed-address"] != peer.AllowedAddress {
+		t.Fatalf("router peer %v does not match the request", wgPeer)
+	}
+
+	scheduler := env.router.Record(common.SchedulerPath, *peer.SchedulerID)
+	if scheduler == nil || scheduler["on-event"] != common.SchedulerEvent+peer.PeerID {
+		t.Fatalf("scheduler %v does not disable the peer", scheduler)
+	}
+	queue := env.router.Record(common.QueuePath, *peer.QueueID)
+	if queue == nil || queue["target"] != peer.AllowedAddress {
+		t.Fatalf("queue %v does not target the peer", queue)
+	}
+}
+
+func TestCreatePeerRejectsTakenAddress(t *testing.T) {
+	env := newTestEnv(t)
+	iface := env.seedInterface(t, "wg0")
+	peers := env.peerService()
+
+	if _, err := peers.CreatePeer(newCreatePeerRequest(t, iface, "alice", "10.0.0.2/32")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := peers.CreatePeer(newCreatePeerRequest(t, iface, "bob", "10.0.0.2/32")); err == nil {
+		t.Fatal("a second peer with the same allowed address was created")
+	}
+	if records := env.router.Records(common.WGPeerPath); len(records) != 1 {
+		t.Fatalf("router has %d peers, want 1", len(records))
+	}
+}
+
+func TestCreatePeerRollsBackOnRouterFailure(t *testing.T) {
+	env := newTestEnv(t)
+	iface := env.seedInterface(t, "wg0")
+	peers := env.peerService()
+
+	env.router.InjectFault(mikrotiktest.Error(http.MethodPut, common.QueuePath, http.StatusInternalServerError))
+
+	req := newCreatePeerRequest(t, iface, "alice", "10.0.0.2/32")
+	req.ExpireTime = utils.Ptr("2030-01-01")
+	if _, err := peers.CreatePeer(req); err == nil {
+		t.Fatal("creating a peer succeeded although the router refused its queue")
+	}
+
+	if records := env.router.Records(common.WGPeerPath); len(records) != 0 {
+		t.Fatalf("router kept %d peers after the rollback", len(records))
+	}
+	if records := env.router.Records(common.SchedulerPath); len(records) != 0 {
+		t.Fatalf("router kept %d schedulers after the rollback", len(records))
+	}
+	var count int64
+	env.db.Model(&model.Peer{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("database kept %d peers after the rollback", count)
+	}
+}
+
+func TestDeletePeerRollsBackOnRouterFailure(t *testing.T) {
+	env := newTestEnv(t)
+	iface := env.seedInterface(t, "wg0")
+	peers := env.peerService()
+
+	req := newCreatePeerRequest(t, iface, "alice", "10.0.0.2/32")
+	req.ExpireTime = utils.Ptr("2030-01-01")
+	resp, err := peers.CreatePeer(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env.router.InjectFault(mikrotiktest.Error(http.MethodDelete, common.WGPeerPath, http.StatusInternalServerError))
+	if err := peers.DeletePeer(resp.Id); err == nil {
+		t.Fatal("deleting a peer succeeded although the router refused it")
+	}
+
+	var peer model.Peer
+	if err := env.db.First(&peer, "id = ?", resp.Id).Error; err != nil {
+		t.Fatalf("peer was removed from the database: %v", err)
+	}
+	if peer.SchedulerID == nil || env.router.Record(common.SchedulerPath, *peer.SchedulerID) == nil {
+		t.Fatal("scheduler of the peer was not recreated")
+	}
+	if peer.QueueID == nil || env.router.Record(common.QueuePath, *peer.QueueID) == nil {
+		t.Fatal("queue of the peer was not recreated")
+	}
+}
+
+func TestDeletePeerWithoutQueueRollsBackWithoutQueue(t *testing.T) {
+	env := newTestEnv(t)
+	iface := env.seedInterface(t, "wg0")
+	peers := env.peerService()
+
+	resp, err := peers.CreatePeer(newCreatePeerRequest(t, iface, "alice", "10.0.0.2/32"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var peer model.Peer
+	if err := env.db.First(&peer, "id = ?", resp.Id).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := env.adaptor.DeleteSimpleQueue(context.Background(), env.server, *peer.QueueID); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.db.Model(&model.Peer{}).Where("id = ?", resp.Id).Update("queue_id", nil).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	env.router.InjectFault(mikrotiktest.Error(http.MethodDelete, common.WGPeerPath, http.StatusInternalServerError))
+	if err := peers.DeletePeer(resp.Id); err == nil {
+		t.Fatal("deleting a peer succeeded although the router refused it")
+	}
+
+	if records := env.router.Records(common.QueuePath); len(records) != 0 {
+		t.Fatalf("rollback created %d queues for a peer that had none", len(records))
+	}
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/dataservice/model"
+	"github.com/maahdima/mwp/api/http/schema"
+	"github.com/maahdima/mwp/api/utils"
+)
+
+// GetPeerTrafficHistory returns the traffic of a peer between from and to, bucketed by granularity. from and to
+// accept RFC3339 or YYYY-MM-DD (a date as to includes the whole day) and default to the last day, or the last 30
+// days for the day granularity.
+func (w *WgPeer) GetPeerTrafficHistory(id uint, fromParam, toParam, granularity string) (*schema.PeerTrafficHistoryResponse, error) {
+	if granularity == "" {
+		granularity = model.TrafficGranularityHour
+	}
+	if granularity != model.TrafficGranularityRaw && granularity != model.TrafficGranularityHour && granularity != model.TrafficGranularityDay {
+		return nil, fmt.Errorf("%w: granularity must be raw, hour or day", common.ErrInvalidTrafficQuery)
+	}
+
+	to := time.Now()
+	if toParam != "" {
+		parsed, isDate, err := parseTrafficTime(toParam)
+		if err != nil {
+			return nil, err
+		}
+		to = parsed
+		if isDate {
+			to = to.AddDate(0, 0, 1)
+		}
+	}
+
+	from := to.Add(-24 * time.Hour)
+	if granularity == model.TrafficGranularityDay {
+		from = to.AddDate(0, 0, -30)
+	}
+	if fromParam != "" {
+		parsed, _, err := parseTrafficTime(fromParam)
+		if err != nil {
+			return nil, err
+		}
+		from = parsed
+	}
+
+	if !from.Before(to) {
+		return nil, fmt.Errorf("%w: from must be before to", common.ErrInvalidTrafficQuery)
+	}
+
+	var peer model.Peer
+	if err := w.db.First(&peer, "id = ?", id).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			w.logger.Error("failed to find peer in database", zap.Error(err))
+		}
+		return nil, err
+	}
+
+	var samples []model.PeerTrafficSample
+	err := w.db.
+		Where("peer_id = ? AND granularity = ? AND bucket_start >= ? AND bucket_start < ?", id, granularity, from.Unix(), to.Unix()).
+		Order("bucket_start").
+		Find(&samples).Error
+	if err != nil {
+		w.logger.Error("failed to get peer traffic samples", zap.Uint("id", id), zap.Error(err))
+		return nil, err
+	}
+
+	resp := &schema.PeerTrafficHistoryResponse{
+		PeerId:      peer.ID,
+		Granularity: granularity,
+		From:        from.Format(time.RFC3339),
+		To:          to.Format(time.RFC3339),
+		Points:      make([]schema.PeerTrafficPoint, 0, len(samples)),
+	}
+
+	var download, upload int64
+	for _, sample := range samples {
+		download += sample.DownloadUsage
+		upload += sample.UploadUsage
+
+		resp.Points = append(resp.Points, schema.PeerTrafficPoint{
+			Time:          time.Unix(sample.BucketStart, 0).Format(time.RFC3339),
+			DownloadUsage: utils.BytesToGB(sample.DownloadUsage),
+			UploadUsage:   utils.BytesToGB(sample.UploadUsage),
+			TotalUsage:    utils.BytesToGB(sample.DownloadUsage + sample.UploadUsage),
+			DownloadBytes: sample.DownloadUsage,
+			UploadBytes:   sample.UploadUsage,
+		})
+	}
+
+	resp.DownloadUsage = utils.BytesToGB(download)
+	resp.UploadUsage = utils.BytesToGB(upload)
+	resp.TotalUsage = utils.BytesToGB(download + upload)
+
+	return resp, nil
+}
+
+// parseTrafficTime parses an RFC3339 time or a local YYYY-MM-DD date and reports which one it was
+func parseTrafficTime(value string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, false, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, true, nil
+	}
+	return time.Time{}, false, fmt.Errorf("%w: invalid time %q, expected RFC3339 or YYYY-MM-DD", common.ErrInvalidTrafficQuery, value)
+}
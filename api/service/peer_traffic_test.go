@@ -0,0 +1,92 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/dataservice/model"
+)
+
+func (e *testEnv) seedTrafficSample(t *testing.T, peerID uint, granularity string, start time.Time, download, upload int64) {
+	t.Helper()
+
+	sample := model.PeerTrafficSample{
+		PeerID:        peerID,
+		Granularity:   granularity,
+		BucketStart:   start.Unix(),
+		DownloadUsage: download,
+		UploadUsage:   upload,
+	}
+	if err := e.db.Create(&sample).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetPeerTrafficHistory(t *testing.T) {
+	env := newTestEnv(t)
+	iface := env.seedInterface(t, "wg0")
+	peers := env.peerService()
+
+	resp, err := peers.CreatePeer(newCreatePeerRequest(t, iface, "alice", "10.0.0.2/32"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	day := time.Date(2026, 3, 10, 0, 0, 0, 0, time.Local)
+	env.seedTrafficSample(t, resp.Id, model.TrafficGranularityHour, day.Add(1*time.Hour), 100, 10)
+	env.seedTrafficSample(t, resp.Id, model.TrafficGranularityHour, day.Add(5*time.Hour), 200, 20)
+	env.seedTrafficSample(t, resp.Id, model.TrafficGranularityHour, day.AddDate(0, 0, 1), 400, 40)
+	env.seedTrafficSample(t, resp.Id, model.TrafficGranularityDay, day, 300, 30)
+
+	history, err := peers.GetPeerTrafficHistory(resp.Id, "2026-03-10", "2026-03-10", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if history.Granularity != model.TrafficGranularityHour || len(history.Points) != 2 {
+		t.Fatalf("got %d %s points, want the 2 hours of the day", len(history.Points), history.Granularity)
+	}
+	if history.Points[0].DownloadBytes != 100 || history.Points[1].DownloadBytes != 200 {
+		t.Fatalf("points are not ordered by time: %+v", history.Points)
+	}
+}
+
+func TestGetPeerTrafficHistoryRejectsInvalidQuery(t *testing.T) {
+	env := newTestEnv(t)
+	peers := env.peerService()
+
+	queries := []struct{ from, to, granularity string }{
+		{"", "", "minute"},
+		{"yesterday", "", ""},
+		{"2026-03-11", "2026-03-10", ""},
+	}
+	for _, q := range queries {
+		_, err := peers.GetPeerTrafficHistory(1, q.from, q.to, q.granularity)
+		if !errors.Is(err, common.ErrInvalidTrafficQuery) {
+			t.Fatalf("query %+v: got %v, want ErrInvalidTrafficQuery", q, err)
+		}
+	}
+}
+
+func TestDeletePeerRemovesTrafficHistory(t *testing.T) {
+	env := newTestEnv(t)
+	iface := env.seedInterface(t, "wg0")
+	peers := env.peerService()
+
+	resp, err := peers.CreatePeer(newCreatePeerRequest(t, iface, "alice", "10.0.0.2/32"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.seedTrafficSample(t, resp.Id, model.TrafficGranularityRaw, time.Now(), 100, 10)
+
+	if err := peers.DeletePeer(resp.Id); err != nil {
+		t.Fatal(err)
+	}
+
+	var count int64
+	env.db.Unscoped().Model(&model.PeerTrafficSample{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("database kept %d traffic samples of the deleted peer", count)
+	}
+}
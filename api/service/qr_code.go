@@ -1,10 +1,9 @@
 package service
 
 import (
+	"bytes"
 	"errors"
-	"fmt"
-	"os"
-	"path/filepath"
+	"io"
 
 	"github.com/yeqown/go-qrcode/v2"
 	"github.com/yeqown/go-qrcode/writer/standard"
@@ -12,36 +11,26 @@ import (
 	"gorm.io/gorm"
 
 	"github.com/maahdima/mwp/api/common"
-	"github.com/maahdima/mwp/api/config"
 	"github.com/maahdima/mwp/api/dataservice/model"
 	"github.com/maahdima/mwp/api/utils"
 )
 
-var (
-	peerQrCodesPath string
-)
-
 type QRCodeGenerator struct {
-	db     *gorm.DB
-	logger *zap.Logger
-}
-
-func init() {
-	appCfg := config.GetAppConfig()
-	peerQrCodesPath = filepath.Join(appCfg.PeerFilesDir, "qrcode")
-	if err := os.MkdirAll(peerQrCodesPath, os.ModePerm); err != nil {
-		panic(fmt.Sprintf("failed to create QR code directory: %v", err))
-	}
+	db       *gorm.DB
+	renderer *PeerRenderer
+	logger   *zap.Logger
 }
 
-func NewQRCodeGenerator(db *gorm.DB) *QRCodeGenerator {
+func NewQRCodeGenerator(db *gorm.DB, renderer *PeerRenderer) *QRCodeGenerator {
 	return &QRCodeGenerator{
-		db:     db,
-		logger: zap.L().Named("QRCodeGenerator"),
+		db:       db,
+		renderer: renderer,
+		logger:   zap.L().Named("QRCodeGenerator"),
 	}
 }
 
-func (q *QRCodeGenerator) GetPeerQRCode(id uint) (qrcodePath string, err error) {
+// GetPeerQRCode renders the QR code image of a peer config
+func (q *QRCodeGenerator) GetPeerQRCode(id uint) (image []byte, err error) {
 	var peer model.Peer
 
 	if err = q.db.First(&peer, "id = ?", id).Error; err != nil {
@@ -53,12 +42,10 @@ func (q *QRCodeGenerator) GetPeerQRCode(id uint) (qrcodePath string, err error)
 		return
 	}
 
-	qrcodePath = fmt.Sprintf("%s/%s.jpeg", peerQrCodesPath, peer.UUID)
-
-	return qrcodePath, nil
+	return q.renderer.RenderQRCode(peer)
 }
 
-func (q *QRCodeGenerator) GetUserQRCode(uuid string) (qrcodePath string, err error) {
+func (q *QRCodeGenerator) GetUserQRCode(uuid string) (image []byte, err error) {
 	var peer model.Peer
 
 	if err = q.db.First(&peer, "uuid = ?", uuid).Error; err != nil {
@@ -72,55 +59,44 @@ func (q *QRCodeGenerator) GetUserQRCode(uuid string) (qrcodePath string, err err
 
 	isSharable := utils.IsPeerSharable(peer.IsShared, peer.ShareExpireTime)
 	if !isSharable {
-		return "", common.ErrPeerNotShared
+		return nil, common.ErrPeerNotShared
 	}
 
-	qrcodePath = fmt.Sprintf("%s/%s.jpeg", peerQrCodesPath, peer.UUID)
-
-	return qrcodePath, nil
+	return q.renderer.RenderQRCode(peer)
 }
 
-func (q *QRCodeGenerator) BuildPeerQRCode(config string, uuid string) error {
-	qrc, err := qrcode.New(config)
-	if err != nil {
-		fmt.Printf("could not generate QRCode: %v", err)
-		return err
-	}
-
-	filePath := fmt.Sprintf("%s/%s.jpeg", peerQrCodesPath, uuid)
-
-	w, err := standard.New(filePath)
+// renderQRCodeJPEG encodes content as a QR code JPEG in memory, the format peer QR codes are served in
+func renderQRCodeJPEG(content string) ([]byte, error) {
+	qrc, err := qrcode.New(content)
 	if err != nil {
-		fmt.Printf("standard.New failed: %v", err)
-		return err
+		return nil, err
 	}
 
-	if err = qrc.Save(w); err != nil {
-		fmt.Printf("could not save image: %v", err)
+	var buf bytes.Buffer
+	if err := qrc.Save(standard.NewWithWriter(nopWriteCloser{&buf})); err != nil {
+		return nil, err
 	}
 
-	return nil
+	return buf.Bytes(), nil
 }
 
-func (q *QRCodeGenerator) RemovePeerQRCode(id uint) error {
-	var peer model.Peer
-
-	if err := q.db.First(&peer, "id = ?", id).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			q.logger.Error("peer not found in database", zap.Uint("id", id))
-			return err
-		}
-		q.logger.Error("failed to get peer from database", zap.Uint("id", id), zap.Error(err))
-		return err
+// renderQRCodePNG encodes content as a QR code PNG in memory
+func renderQRCodePNG(content string) ([]byte, error) {
+	qrc, err := qrcode.New(content)
+	if err != nil {
+		return nil, err
 	}
 
-	qrcodePath := fmt.Sprintf("%s/%s.jpeg", peerQrCodesPath, peer.UUID)
-
-	err := os.Remove(qrcodePath)
-	if err != nil {
-		q.logger.Error("failed to remove QRCode", zap.String("path", qrcodePath), zap.Error(err))
-		return err
+	var buf bytes.Buffer
+	if err := qrc.Save(standard.NewWithWriter(nopWriteCloser{&buf}, standard.WithBuiltinImageEncoder(standard.PNG_FORMAT))); err != nil {
+		return nil, err
 	}
 
-	return nil
+	return buf.Bytes(), nil
 }
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/maahdima/mwp/api/config"
+	"github.com/maahdima/mwp/api/dataservice/model"
+)
+
+// RateLimiter locks out client keys that fail too often. The counters live in the database so a restart does not
+// hand attackers a fresh set of attempts.
+type RateLimiter struct {
+	db       *gorm.DB
+	notifier *TelegramNotifier
+	logger   *zap.Logger
+	mu       sync.Mutex
+
+	enabled          bool
+	LoginMaxAttempts int
+	ShareMaxAttempts int
+	window           time.Duration
+	lockoutBase      time.Duration
+	lockoutMax       time.Duration
+}
+
+func NewRateLimiter(db *gorm.DB, cfg config.RateLimitConfig, notifier *TelegramNotifier) *RateLimiter {
+	seconds := func(value string, fallback int) time.Duration {
+		return time.Duration(positiveInt(value, fallback)) * time.Second
+	}
+
+	return &RateLimiter{
+		db:               db,
+		notifier:         notifier,
+		logger:           zap.L().Named("RateLimiter"),
+		enabled:          cfg.Enabled,
+		LoginMaxAttempts: positiveInt(cfg.LoginMaxAttempts, 5),
+		ShareMaxAttempts: positiveInt(cfg.ShareMaxAttempts, 20),
+		window:           seconds(cfg.AttemptWindow, 900),
+		lockoutBase:      seconds(cfg.LockoutBase, 60),
+		lockoutMax:       seconds(cfg.LockoutMax, 3600),
+	}
+}
+
+// LockedFor returns how long the longest lockout among keys still lasts, zero when none is locked out
+func (r *RateLimiter) LockedFor(keys ...string) (time.Duration, error) {
+	if !r.enabled || len(keys) == 0 {
+		return 0, nil
+	}
+
+	var lockedUntil *int64
+	err := r.db.Model(&model.LoginThrottle{}).
+		Where("client_key IN ?", keys).
+		Select("MAX(locked_until)").
+		Scan(&lockedUntil).Error
+	if err != nil {
+		r.logger.Error("failed to check lockouts", zap.Error(err))
+		return 0, err
+	}
+	if lockedUntil == nil {
+		return 0, nil
+	}
+
+	return max(time.Until(time.Unix(*lockedUntil, 0)), 0), nil
+}
+
+// Fail records a failure for each key. The maxAttempts-th failure within the window locks the key out, for the base
+// lockout the first time and twice as long as the previous lockout after that.
+func (r *RateLimiter) Fail(maxAttempts int, keys ...string) error {
+	if !r.enabled {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for _, key := range keys {
+		var throttle model.LoginThrottle
+		err := r.db.Where("client_key = ?", key).First(&throttle).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			r.logger.Error("failed to fetch throttle", zap.String("key", key), zap.Error(err))
+			return err
+		}
+		throttle.ClientKey = key
+
+		lastFailure := time.Unix(throttle.LastFailureAt, 0)
+		if now.Sub(lastFailure) > r.window {
+			throttle.Failures = 0
+		}
+		// a quiet period as long as the longest lockout forgives the previous lockouts
+		if throttle.LockedUntil > 0 && now.Sub(time.Unix(throttle.LockedUntil, 0)) > r.lockoutMax {
+			throttle.Lockouts = 0
+		}
+
+		throttle.Failures++
+		throttle.LastFailureAt = now.Unix()
+
+		var lockout time.Duration
+		if throttle.Failures >= maxAttempts {
+			lockout = min(r.lockoutBase<<min(throttle.Lockouts, 20), r.lockoutMax)
+			throttle.Failures = 0
+			throttle.Lockouts++
+			throttle.LockedUntil = now.Add(lockout).Unix()
+		}
+
+		if err := r.db.Save(&throttle).Error; err != nil {
+			r.logger.Error("failed to save throttle", zap.String("key", key), zap.Error(err))
+			return err
+		}
+
+		if lockout > 0 {
+			r.lockedOut(key, lockout, throttle.Lockouts)
+		}
+	}
+
+	return nil
+}
+
+// Reset forgets the failures and lockouts of keys, after a successful login
+func (r *RateLimiter) Reset(keys ...string) error {
+	if !r.enabled || len(keys) == 0 {
+		return nil
+	}
+
+	err := r.db.Unscoped().Where("client_key IN ?", keys).Delete(&model.LoginThrottle{}).Error
+	if err != nil {
+		r.logger.Error("failed to reset throttles", zap.Strings("keys", keys), zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (r *RateLimiter) lockedOut(key string, lockout time.Duration, lockouts int) {
+	r.logger.Warn("client locked out", zap.String("key", key), zap.Duration("duration", lockout), zap.Int("lockouts", lockouts))
+
+	// rows whose lockout ran out long ago would only be reset by the next failure anyway
+	stale := time.Now().Add(-max(r.window, r.lockoutMax)).Unix()
+	err := r.db.Unscoped().
+		Where("last_failure_at < ? AND locked_until < ?", stale, stale).
+		Delete(&model.LoginThrottle{}).Error
+	if err != nil {
+		r.logger.Warn("failed to prune throttles", zap.Error(err))
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		message := fmt.Sprintf("MWP: %s was locked out for %s after repeated failures.", key, lockout)
+		if err := r.notifier.NotifyAdmins(ctx, message); err != nil {
+			r.logger.Warn("failed to notify lockout", zap.String("key", key), zap.Error(err))
+		}
+	}()
+}
+
+func positiveInt(value string, fallback int) int {
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed <= 0 {
+		return fallback
+	}
+	return parsed
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/maahdima/mwp/api/config"
+	"github.com/maahdima/mwp/api/dataservice/model"
+)
+
+func newTestRateLimiter(env *testEnv) *RateLimiter {
+	return NewRateLimiter(env.db, config.RateLimitConfig{
+		Enabled:          true,
+		LoginMaxAttempts: "3",
+		AttemptWindow:    "900",
+		LockoutBase:      "60",
+		LockoutMax:       "200",
+	}, NewTelegramNotifier(config.TelegramConfig{}))
+}
+
+// failUntilLocked fails key until it is locked out and returns the lockout
+func failUntilLocked(t *testing.T, limiter *RateLimiter, key string) time.Duration {
+	t.Helper()
+
+	for range limiter.LoginMaxAttempts {
+		if lockedFor, _ := limiter.LockedFor(key); lockedFor > 0 {
+			t.Fatalf("%s locked out before %d failures", key, limiter.LoginMaxAttempts)
+		}
+		if err := limiter.Fail(limiter.LoginMaxAttempts, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lockedFor, err := limiter.LockedFor(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lockedFor
+}
+
+// expireLockout moves the lockout of key into the past, as if it had been waited out
+func expireLockout(t *testing.T, env *testEnv, key string) {
+	t.Helper()
+
+	err := env.db.Model(&model.LoginThrottle{}).
+		Where("client_key = ?", key).
+		Update("locked_until", time.Now().Add(-time.Second).Unix()).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRateLimiterLockoutBacksOff(t *testing.T) {
+	env := newTestEnv(t)
+	limiter := newTestRateLimiter(env)
+	const key = "login-ip:192.0.2.1"
+
+	for _, want := range []time.Duration{60 * time.Second, 120 * time.Second, 200 * time.Second} {
+		lockedFor := failUntilLocked(t, limiter, key)
+		if lockedFor <= want-2*time.Second || lockedFor > want {
+			t.Fatalf("locked out for %s, want %s", lockedFor, want)
+		}
+		expireLockout(t, env, key)
+	}
+}
+
+func TestRateLimiterResetForgetsFailures(t *testing.T) {
+	env := newTestEnv(t)
+	limiter := newTestRateLimiter(env)
+	const key = "login-user:admin"
+
+	failUntilLocked(t, limiter, key)
+	if err := limiter.Reset(key); err != nil {
+		t.Fatal(err)
+	}
+	if lockedFor, _ := limiter.LockedFor(key); lockedFor != 0 {
+		t.Fatalf("reset key is still locked out for %s", lockedFor)
+	}
+
+	// the next lockout starts from the base again
+	if lockedFor := failUntilLocked(t, limiter, key); lockedFor > 60*time.Second {
+		t.Fatalf("locked out for %s after a reset, want the base lockout", lockedFor)
+	}
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// saga runs a multistep operation spanning the router and the database. Every completed step registers a
+// compensation, and when a later step fails the completed ones are undone in reverse order so no orphan
+// objects stay behind on the router and the database keeps matching it.
+type saga struct {
+	name      string
+	completed []sagaStep
+	logger    *zap.Logger
+}
+
+type sagaStep struct {
+	name       string
+	compensate func() error
+}
+
+func newSaga(name string, logger *zap.Logger) *saga {
+	return &saga{
+		name:   name,
+		logger: logger.With(zap.String("saga", name)),
+	}
+}
+
+// run executes action and, once it succeeded, keeps compensate (which may be nil) to undo it on a later failure.
+// When action fails every completed step is compensated and the returned error carries the failures of both.
+func (s *saga) run(step string, action func() error, compensate func() error) error {
+	if err := action(); err != nil {
+		s.logger.Error("saga step failed, rolling back", zap.String("step", step), zap.Error(err))
+
+		stepErr := fmt.Errorf("%s: %s: %w", s.name, step, err)
+		if rollbackErr := s.rollback(); rollbackErr != nil {
+			return errors.Join(stepErr, rollbackErr)
+		}
+
+		return stepErr
+	}
+
+	if compensate != nil {
+		s.completed = append(s.completed, sagaStep{name: step, compensate: compensate})
+	}
+
+	return nil
+}
+
+// rollback compensates the completed steps in reverse order, a failing compensation does not stop the others
+func (s *saga) rollback() error {
+	var errs []error
+
+	for i := len(s.completed) - 1; i >= 0; i-- {
+		step := s.completed[i]
+		if err := step.compensate(); err != nil {
+			s.logger.Error("failed to compensate saga step, manual cleanup may be needed", zap.String("step", step.name), zap.Error(err))
+			errs = append(errs, fmt.Errorf("rollback %s: %w", step.name, err))
+			continue
+		}
+		s.logger.Info("compensated saga step", zap.String("step", step.name))
+	}
+	s.completed = nil
+
+	return errors.Join(errs...)
+}
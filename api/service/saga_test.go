@@ -0,0 +1,61 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestSagaRollsBackInReverseOrder(t *testing.T) {
+	var undone []string
+	undo := func(step string) func() error {
+		return func() error {
+			undone = append(undone, step)
+			return nil
+		}
+	}
+	ok := func() error { return nil }
+	failure := errors.New("router refused")
+
+	tx := newSaga("test", zap.NewNop())
+	if err := tx.run("first", ok, undo("first")); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.run("no compensation", ok, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.run("second", ok, undo("second")); err != nil {
+		t.Fatal(err)
+	}
+
+	err := tx.run("third", func() error { return failure }, undo("third"))
+	if !errors.Is(err, failure) {
+		t.Fatalf("got %v, want it to wrap %v", err, failure)
+	}
+	if want := []string{"second", "first"}; !reflect.DeepEqual(undone, want) {
+		t.Fatalf("compensated %v, want %v", undone, want)
+	}
+}
+
+func TestSagaReportsFailedCompensations(t *testing.T) {
+	stepErr := errors.New("step failed")
+	compensateErr := errors.New("compensation failed")
+	compensated := false
+
+	tx := newSaga("test", zap.NewNop())
+	_ = tx.run("first", func() error { return nil }, func() error {
+		compensated = true
+		return nil
+	})
+	_ = tx.run("second", func() error { return nil }, func() error { return compensateErr })
+
+	err := tx.run("third", func() error { return stepErr }, nil)
+	if !errors.Is(err, stepErr) || !errors.Is(err, compensateErr) {
+		t.Fatalf("got %v, want both the step and the compensation error", err)
+	}
+	if !compensated {
+		t.Fatal("a failing compensation stopped the ones before it")
+	}
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"fmt"
 	"strconv"
 
 	"github.com/maahdima/mwp/api/adaptor/mikrotik"
@@ -49,6 +50,7 @@ func (s *Server) ToggleServerStatus(id uint) (*schema.ServerResponse, error) {
 		Name:      server.Name,
 		IPAddress: server.IPAddress,
 		APIPort:   strconv.Itoa(server.APIPort),
+		IsSSL:     server.IsSSL,
 		IsActive:  server.IsActive,
 		Status:    schema.AvailableServer,
 	}, nil
@@ -61,34 +63,38 @@ func (s *Server) CreateServer(req *schema.CreateServerRequest) (*schema.ServerRe
 		return nil, err
 	}
 
-	s.mwpClients.SetClient(req)
-
-	_, err = s.mikrotikAdaptor.FetchDeviceIdentity(context.Background())
-	if err != nil {
-		s.logger.Error("failed to connect to device when creating a new server", zap.Error(err))
-		return nil, err
-	}
-
 	server := model.Server{
 		Comment:   req.Comment,
 		Name:      req.Name,
 		IPAddress: req.IPAddress,
 		APIPort:   apiPort,
+		IsSSL:     *req.IsSSL,
 		Username:  req.Username,
 		Password:  req.Password,
 	}
 
+	_, err = s.mikrotikAdaptor.FetchDeviceIdentity(context.Background(), server)
+	if err != nil {
+		s.logger.Error("failed to connect to device when creating a new server", zap.Error(err))
+		return nil, err
+	}
+
 	if err := s.db.Create(&server).Error; err != nil {
 		s.logger.Error("failed to create server record", zap.Error(err))
 		return nil, err
 	}
 
+	if err := s.mwpClients.SetClient(server); err != nil {
+		return nil, err
+	}
+
 	return &schema.ServerResponse{
 		Id:        server.ID,
 		Comment:   server.Comment,
 		Name:      server.Name,
 		IPAddress: server.IPAddress,
 		APIPort:   strconv.Itoa(server.APIPort),
+		IsSSL:     server.IsSSL,
 		IsActive:  server.IsActive,
 		Status:    schema.AvailableServer,
 	}, nil
@@ -105,10 +111,9 @@ func (s *Server) GetServers() (*[]schema.ServerResponse, error) {
 	for _, server := range servers {
 		var serverStatus schema.ServerStatus
 
-		_, err := s.mikrotikAdaptor.FetchDeviceIdentity(context.Background())
+		_, err := s.mikrotikAdaptor.FetchDeviceIdentity(context.Background(), server)
 		if err != nil {
 			serverStatus = schema.NotAvailableServer
-			continue
 		} else {
 			serverStatus = schema.AvailableServer
 		}
@@ -119,6 +124,7 @@ func (s *Server) GetServers() (*[]schema.ServerResponse, error) {
 			Name:      server.Name,
 			IPAddress: server.IPAddress,
 			APIPort:   strconv.Itoa(server.APIPort),
+			IsSSL:     server.IsSSL,
 			IsActive:  server.IsActive,
 			Status:    serverStatus,
 		})
@@ -148,17 +154,26 @@ func (s *Server) UpdateServer(id uint, req *schema.UpdateServerRequest) (*schema
 	server.Username = *req.Username
 	server.Password = *req.Password
 
+	if req.IsSSL != nil {
+		server.IsSSL = *req.IsSSL
+	}
+
 	if err := s.db.Save(&server).Error; err != nil {
 		s.logger.Error("failed to update server record", zap.Error(err))
 		return nil, err
 	}
 
+	if err := s.mwpClients.SetClient(server); err != nil {
+		return nil, err
+	}
+
 	return &schema.ServerResponse{
 		Id:        server.ID,
 		Comment:   server.Comment,
 		Name:      server.Name,
 		IPAddress: server.IPAddress,
 		APIPort:   strconv.Itoa(server.APIPort),
+		IsSSL:     server.IsSSL,
 		IsActive:  server.IsActive,
 		Status:    schema.AvailableServer,
 	}, nil
@@ -171,11 +186,23 @@ func (s *Server) DeleteServer(id uint) error {
 		return err
 	}
 
+	var interfaceCount int64
+	if err := s.db.Model(&model.Interface{}).Where("server_id = ?", server.ID).Count(&interfaceCount).Error; err != nil {
+		s.logger.Error("failed to count server interfaces", zap.Error(err))
+		return err
+	}
+
+	if interfaceCount > 0 {
+		return fmt.Errorf("%w: %s has %d interface(s)", common.ErrServerInUse, server.Name, interfaceCount)
+	}
+
 	if err := s.db.Unscoped().Delete(&server).Error; err != nil {
 		s.logger.Error("failed to delete server record", zap.Error(err))
 		return err
 	}
 
+	s.mwpClients.DeleteClient(server.ID)
+
 	return nil
 }
 
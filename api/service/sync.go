@@ -13,47 +13,44 @@ import (
 	"github.com/maahdima/mwp/api/adaptor/mikrotik"
 	"github.com/maahdima/mwp/api/common"
 	"github.com/maahdima/mwp/api/dataservice/model"
-	"github.com/maahdima/mwp/api/utils/wireguard"
+	"github.com/maahdima/mwp/api/utils"
 )
 
 type SyncService struct {
 	db              *gorm.DB
 	mikrotikAdaptor *mikrotik.Adaptor
-	configService   *ConfigGenerator
-	qrCodeService   *QRCodeGenerator
+	scheduler       *Scheduler
+	queue           *Queue
+	renderer        *PeerRenderer
 	logger          *zap.Logger
 }
 
-func NewSyncService(db *gorm.DB, mikrotikAdaptor *mikrotik.Adaptor, configService *ConfigGenerator, qrCodeService *QRCodeGenerator) *SyncService {
+func NewSyncService(db *gorm.DB, mikrotikAdaptor *mikrotik.Adaptor, scheduler *Scheduler, queue *Queue, renderer *PeerRenderer) *SyncService {
 	return &SyncService{
 		db:              db,
 		mikrotikAdaptor: mikrotikAdaptor,
-		configService:   configService,
-		qrCodeService:   qrCodeService,
+		scheduler:       scheduler,
+		queue:           queue,
+		renderer:        renderer,
 		logger:          zap.L().Named("SyncService"),
 	}
 }
 
-func (s *SyncService) SyncPeers() error {
-	mikrotikPeers, err := s.fetchMikrotikPeers()
+func (s *SyncService) SyncPeers(server model.Server) error {
+	mikrotikPeers, err := s.fetchMikrotikPeers(server)
 	if err != nil {
 		return err
 	}
 
-	dbPeers, err := s.fetchDBPeers()
+	dbPeers, err := s.fetchDBPeers(server)
 	if err != nil {
 		return err
 	}
 
-	if len(mikrotikPeers) == len(dbPeers) {
-		s.logger.Info("no changes detected in peers, skipping sync")
-		return nil
-	}
-
 	mikrotikMap := s.mapMikrotikPeers(mikrotikPeers)
 	dbMap := s.mapDBPeers(dbPeers)
 
-	if err := s.syncNewAndUpdatedPeers(mikrotikMap, dbMap); err != nil {
+	if err := s.syncNewAndUpdatedPeers(server, mikrotikMap, dbMap); err != nil {
 		return err
 	}
 
@@ -65,26 +62,21 @@ func (s *SyncService) SyncPeers() error {
 	return nil
 }
 
-func (s *SyncService) SyncInterfaces() error {
-	mikrotikIfaces, err := s.fetchMikrotikInterfaces()
+func (s *SyncService) SyncInterfaces(server model.Server) error {
+	mikrotikIfaces, err := s.fetchMikrotikInterfaces(server)
 	if err != nil {
 		return err
 	}
 
-	dbIfaces, err := s.fetchDBInterfaces()
+	dbIfaces, err := s.fetchDBInterfaces(server)
 	if err != nil {
 		return err
 	}
 
-	if len(mikrotikIfaces) == len(dbIfaces) {
-		s.logger.Info("no changes detected in interfaces, skipping sync")
-		return nil
-	}
-
 	mikrotikMap := s.mapMikrotikInterfaces(mikrotikIfaces)
 	dbMap := s.mapDBInterfaces(dbIfaces)
 
-	if err := s.syncNewAndUpdatedInterfaces(mikrotikMap, dbMap); err != nil {
+	if err := s.syncNewAndUpdatedInterfaces(server, mikrotikMap, dbMap); err != nil {
 		return err
 	}
 
@@ -96,17 +88,17 @@ func (s *SyncService) SyncInterfaces() error {
 	return nil
 }
 
-func (s *SyncService) fetchMikrotikPeers() ([]mikrotik.WireGuardPeer, error) {
-	peers, err := s.mikrotikAdaptor.FetchWgPeers(context.Background())
+func (s *SyncService) fetchMikrotikPeers(server model.Server) ([]mikrotik.WireGuardPeer, error) {
+	peers, err := s.mikrotikAdaptor.FetchWgPeers(context.Background(), server)
 	if err != nil {
 		s.logger.Error("failed to get peers from Mikrotik", zap.Error(err))
 	}
 	return peers, err
 }
 
-func (s *SyncService) fetchDBPeers() ([]model.Peer, error) {
+func (s *SyncService) fetchDBPeers(server model.Server) ([]model.Peer, error) {
 	var peers []model.Peer
-	err := s.db.Find(&peers).Error
+	err := s.db.Where("server_id = ?", server.ID).Find(&peers).Error
 	if err != nil {
 		s.logger.Error("failed to get peers from database", zap.Error(err))
 	}
@@ -129,32 +121,36 @@ func (s *SyncService) mapDBPeers(peers []model.Peer) map[string]model.Peer {
 	return m
 }
 
-func (s *SyncService) syncNewAndUpdatedPeers(peers map[string]mikrotik.WireGuardPeer, dbMap map[string]model.Peer) error {
+func (s *SyncService) syncNewAndUpdatedPeers(server model.Server, peers map[string]mikrotik.WireGuardPeer, dbMap map[string]model.Peer) error {
 	for id, peer := range peers {
 		if peer.PrivateKey == nil {
 			s.logger.Error("missing private key", zap.String("peer", id))
 			continue
 		}
 
-		server, err := s.fetchServer()
-		if err != nil {
-			return err
-		}
-
-		dbIface, err := s.fetchInterface(peer.Interface, id)
+		dbIface, err := s.fetchInterface(server, peer.Interface, id)
 		if err != nil {
 			return err
 		}
 
 		dbPeer, exists := dbMap[id]
 		if !exists {
+			// the endpoint is only known to the panel, keep the one of existing peers
 			dbPeer = model.Peer{
+				ServerID:            server.ID,
 				UUID:                uuid.New().String(),
 				PeerID:              peer.ID,
 				PersistentKeepalive: common.DefaultKeepalive,
+				Endpoint:            server.IPAddress,
+			}
+			if dbIface.ClientPersistentKeepalive != nil {
+				dbPeer.PersistentKeepalive = *dbIface.ClientPersistentKeepalive
 			}
 		}
 
+		if disabled := parseBool(peer.Disabled); disabled != dbPeer.Disabled {
+			dbPeer.DisabledReason = peerDisabledReason(disabled, routerDisabledReason(dbPeer))
+		}
 		dbPeer.Disabled = parseBool(peer.Disabled)
 		dbPeer.Comment = peer.Comment
 		dbPeer.Name = peer.Name
@@ -162,20 +158,8 @@ func (s *SyncService) syncNewAndUpdatedPeers(peers map[string]mikrotik.WireGuard
 		dbPeer.PublicKey = peer.PublicKey
 		dbPeer.Interface = dbIface.Name
 		dbPeer.AllowedAddress = peer.AllowedAddress
-		dbPeer.Endpoint = server.IPAddress
 		dbPeer.EndpointPort = dbIface.ListenPort
-
-		config := s.buildConfig(peer, dbPeer, dbIface)
-
-		if err := s.configService.BuildPeerConfig(config, dbPeer.UUID); err != nil {
-			s.logger.Error("failed to build peer config", zap.String("id", id), zap.Error(err))
-			return err
-		}
-
-		if err := s.qrCodeService.BuildPeerQRCode(config, dbPeer.UUID); err != nil {
-			s.logger.Error("failed to build QR code", zap.String("id", id), zap.Error(err))
-			return err
-		}
+		dbPeer.PresharedKey = utils.DerefString(peer.PresharedKey)
 
 		if err := s.db.Save(&dbPeer).Error; err != nil {
 			s.logger.Error("failed to upsert peer", zap.String("id", id), zap.Error(err))
@@ -188,28 +172,20 @@ func (s *SyncService) syncNewAndUpdatedPeers(peers map[string]mikrotik.WireGuard
 func (s *SyncService) removeStalePeers(mikrotikMap map[string]mikrotik.WireGuardPeer, dbMap map[string]model.Peer) error {
 	for id, peer := range dbMap {
 		if _, found := mikrotikMap[id]; !found {
-			if err := s.db.Unscoped().Delete(&peer).Error; err != nil {
+			if err := deletePeerRecord(s.db, peer); err != nil {
 				s.logger.Error("failed to delete peer", zap.String("peerId", id), zap.Error(err))
 				return err
 			}
+			s.renderer.Forget(peer.UUID)
 			s.logger.Info("deleted stale peer from DB", zap.String("peerId", id))
 		}
 	}
 	return nil
 }
 
-func (s *SyncService) fetchServer() (model.Server, error) {
-	var server model.Server
-	err := s.db.First(&server).Error
-	if err != nil {
-		s.logger.Error("failed to get server", zap.Error(err))
-	}
-	return server, err
-}
-
-func (s *SyncService) fetchInterface(name, peerID string) (model.Interface, error) {
+func (s *SyncService) fetchInterface(server model.Server, name, peerID string) (model.Interface, error) {
 	var iface model.Interface
-	err := s.db.Where("name = ?", name).First(&iface).Error
+	err := s.db.Where("name = ? AND server_id = ?", name, server.ID).First(&iface).Error
 	if err != nil {
 		if errors.Is(gorm.ErrRecordNotFound, err) {
 			s.logger.Error("interface not found", zap.String("peerId", peerID), zap.String("interfaceId", name))
@@ -222,6 +198,7 @@ func (s *SyncService) fetchInterface(name, peerID string) (model.Interface, erro
 
 func (s *SyncService) buildDBPeer(peer mikrotik.WireGuardPeer, server model.Server, iface model.Interface) model.Peer {
 	return model.Peer{
+		ServerID:            server.ID,
 		UUID:                uuid.New().String(),
 		PeerID:              peer.ID,
 		Disabled:            parseBool(peer.Disabled),
@@ -237,30 +214,17 @@ func (s *SyncService) buildDBPeer(peer mikrotik.WireGuardPeer, server model.Serv
 	}
 }
 
-func (s *SyncService) buildConfig(peer mikrotik.WireGuardPeer, dbPeer model.Peer, iface model.Interface) string {
-	return fmt.Sprintf(wireguard.Template,
-		*peer.PrivateKey,
-		dbPeer.AllowedAddress,
-		common.DefaultDns,
-		iface.PublicKey,
-		dbPeer.Endpoint,
-		dbPeer.EndpointPort,
-		common.AllowedIpsIncludeLocal,
-		dbPeer.PersistentKeepalive,
-	)
-}
-
-func (s *SyncService) fetchMikrotikInterfaces() ([]mikrotik.WireGuardInterface, error) {
-	ifaces, err := s.mikrotikAdaptor.FetchWgInterfaces(context.Background())
+func (s *SyncService) fetchMikrotikInterfaces(server model.Server) ([]mikrotik.WireGuardInterface, error) {
+	ifaces, err := s.mikrotikAdaptor.FetchWgInterfaces(context.Background(), server)
 	if err != nil {
 		s.logger.Error("failed to get interfaces from Mikrotik", zap.Error(err))
 	}
 	return ifaces, err
 }
 
-func (s *SyncService) fetchDBInterfaces() ([]model.Interface, error) {
+func (s *SyncService) fetchDBInterfaces(server model.Server) ([]model.Interface, error) {
 	var ifaces []model.Interface
-	err := s.db.Find(&ifaces).Error
+	err := s.db.Where("server_id = ?", server.ID).Find(&ifaces).Error
 	if err != nil {
 		s.logger.Error("failed to get interfaces from database", zap.Error(err))
 	}
@@ -283,11 +247,12 @@ func (s *SyncService) mapDBInterfaces(ifaces []model.Interface) map[string]model
 	return m
 }
 
-func (s *SyncService) syncNewAndUpdatedInterfaces(ifaceMap map[string]mikrotik.WireGuardInterface, dbMap map[string]model.Interface) error {
+func (s *SyncService) syncNewAndUpdatedInterfaces(server model.Server, ifaceMap map[string]mikrotik.WireGuardInterface, dbMap map[string]model.Interface) error {
 	for id, mikrotikIface := range ifaceMap {
 		dbIface, exists := dbMap[id]
 		if !exists {
 			dbIface = model.Interface{
+				ServerID:    server.ID,
 				InterfaceID: mikrotikIface.ID,
 			}
 		}
@@ -310,7 +275,7 @@ func (s *SyncService) syncNewAndUpdatedInterfaces(ifaceMap map[string]mikrotik.W
 func (s *SyncService) removeStaleInterfaces(mikrotikMap map[string]mikrotik.WireGuardInterface, dbMap map[string]model.Interface) error {
 	for id, dbIface := range dbMap {
 		if _, exists := mikrotikMap[id]; !exists {
-			if err := s.db.Delete(dbIface.ID).Unscoped().Error; err != nil {
+			if err := s.db.Unscoped().Delete(&dbIface).Error; err != nil {
 				s.logger.Error("failed to delete interface", zap.String("interfaceId", id), zap.Error(err))
 				return err
 			}
@@ -4,11 +4,12 @@ import (
 	"errors"
 
 	"github.com/maahdima/mwp/api/adaptor/mikrotik"
+	"github.com/maahdima/mwp/api/dataservice/model"
 	"github.com/maahdima/mwp/api/http/schema"
 )
 
-func (s *SyncService) GetSyncInterfaces() ([]schema.SyncInterfacePreviewResponse, error) {
-	ifaces, err := s.fetchMikrotikInterfaces()
+func (s *SyncService) GetSyncInterfaces(server model.Server) ([]schema.SyncInterfacePreviewResponse, error) {
+	ifaces, err := s.fetchMikrotikInterfaces(server)
 	if err != nil {
 		return nil, err
 	}
@@ -16,8 +17,8 @@ func (s *SyncService) GetSyncInterfaces() ([]schema.SyncInterfacePreviewResponse
 	return mapSyncInterfaces(ifaces), nil
 }
 
-func (s *SyncService) GetSyncPeers(interfaceName string) ([]schema.SyncPeerPreviewResponse, error) {
-	peers, err := s.fetchMikrotikPeers()
+func (s *SyncService) GetSyncPeers(server model.Server, interfaceName string) ([]schema.SyncPeerPreviewResponse, error) {
+	peers, err := s.fetchMikrotikPeers(server)
 	if err != nil {
 		return nil, err
 	}
@@ -35,12 +36,12 @@ func (s *SyncService) GetSyncPeers(interfaceName string) ([]schema.SyncPeerPrevi
 	return mapSyncPeers(peers), nil
 }
 
-func (s *SyncService) SyncSelectedInterfaces(interfaceIDs []string) error {
+func (s *SyncService) SyncSelectedInterfaces(server model.Server, interfaceIDs []string) error {
 	if len(interfaceIDs) == 0 {
 		return errors.New("no interfaces selected")
 	}
 
-	mikrotikIfaces, err := s.fetchMikrotikInterfaces()
+	mikrotikIfaces, err := s.fetchMikrotikInterfaces(server)
 	if err != nil {
 		return err
 	}
@@ -51,20 +52,20 @@ func (s *SyncService) SyncSelectedInterfaces(interfaceIDs []string) error {
 		return nil
 	}
 
-	dbIfaces, err := s.fetchDBInterfaces()
+	dbIfaces, err := s.fetchDBInterfaces(server)
 	if err != nil {
 		return err
 	}
 
-	return s.syncNewAndUpdatedInterfaces(s.mapMikrotikInterfaces(selectedIfaces), s.mapDBInterfaces(dbIfaces))
+	return s.syncNewAndUpdatedInterfaces(server, s.mapMikrotikInterfaces(selectedIfaces), s.mapDBInterfaces(dbIfaces))
 }
 
-func (s *SyncService) SyncSelectedPeers(peerIDs []string) error {
+func (s *SyncService) SyncSelectedPeers(server model.Server, peerIDs []string) error {
 	if len(peerIDs) == 0 {
 		return errors.New("no peers selected")
 	}
 
-	mikrotikPeers, err := s.fetchMikrotikPeers()
+	mikrotikPeers, err := s.fetchMikrotikPeers(server)
 	if err != nil {
 		return err
 	}
@@ -75,12 +76,12 @@ func (s *SyncService) SyncSelectedPeers(peerIDs []string) error {
 		return nil
 	}
 
-	dbPeers, err := s.fetchDBPeers()
+	dbPeers, err := s.fetchDBPeers(server)
 	if err != nil {
 		return err
 	}
 
-	return s.syncNewAndUpdatedPeers(s.mapMikrotikPeers(selectedPeers), s.mapDBPeers(dbPeers))
+	return s.syncNewAndUpdatedPeers(server, s.mapMikrotikPeers(selectedPeers), s.mapDBPeers(dbPeers))
 }
 
 func parseOptionalBool(value *string) bool {
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/maahdima/mwp/api/adaptor/mikrotik/mikrotiktest"
+	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/dataservice/model"
+)
+
+func TestSyncRoundTrip(t *testing.T) {
+	env := newTestEnv(t)
+	sync := env.syncService()
+
+	if _, err := env.router.Seed(common.WGInterfacePath, mikrotiktest.Record{"name": "wg0", "listen-port": "51820"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sync.SyncInterfaces(env.server); err != nil {
+		t.Fatal(err)
+	}
+	var iface model.Interface
+	if err := env.db.First(&iface, "name = ?", "wg0").Error; err != nil {
+		t.Fatalf("interface was not synced: %v", err)
+	}
+	if iface.ListenPort != "51820" {
+		t.Fatalf("listen port %s, want 51820", iface.ListenPort)
+	}
+
+	ids := make(map[string]string)
+	keys := make(map[string]string)
+	for _, peer := range []struct{ name, address string }{{"alice", "10.0.0.2/32"}, {"bob", "10.0.0.3/32"}} {
+		privateKey, publicKey := newKeyPair(t)
+		id, err := env.router.Seed(common.WGPeerPath, mikrotiktest.Record{
+			"interface":       "wg0",
+			"name":            peer.name,
+			"private-key":     privateKey,
+			"public-key":      publicKey,
+			"allowed-address": peer.address,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[peer.name] = id
+		keys[peer.name] = privateKey
+	}
+
+	if err := sync.SyncPeers(env.server); err != nil {
+		t.Fatal(err)
+	}
+	var alice model.Peer
+	if err := env.db.First(&alice, "peer_id = ?", ids["alice"]).Error; err != nil {
+		t.Fatalf("peer was not synced: %v", err)
+	}
+	if alice.Name != "alice" || alice.Interface != "wg0" || alice.AllowedAddress != "10.0.0.2/32" || alice.EndpointPort != "51820" {
+		t.Fatalf("synced peer %+v does not match the router", alice)
+	}
+	if alice.PrivateKey != keys["alice"] {
+		t.Fatal("private key of the router was not stored")
+	}
+
+	if err := env.router.Set(common.WGPeerPath, ids["alice"], mikrotiktest.Record{"disabled": "true", "name": "alice-2"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.adaptor.DeleteWgPeer(context.Background(), env.server, ids["bob"]); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sync.SyncPeers(env.server); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.db.First(&alice, "peer_id = ?", ids["alice"]).Error; err != nil {
+		t.Fatal(err)
+	}
+	if alice.Name != "alice-2" || !alice.Disabled {
+		t.Fatalf("changes on the router were not synced: name %s, disabled %t", alice.Name, alice.Disabled)
+	}
+	if alice.DisabledReason == nil || *alice.DisabledReason != common.PeerDisabledByAdmin {
+		t.Fatalf("disabled reason %v, want %s", alice.DisabledReason, common.PeerDisabledByAdmin)
+	}
+	var count int64
+	env.db.Model(&model.Peer{}).Where("peer_id = ?", ids["bob"]).Count(&count)
+	if count != 0 {
+		t.Fatal("peer deleted on the router was kept in the database")
+	}
+}
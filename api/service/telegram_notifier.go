@@ -17,11 +17,12 @@ import (
 )
 
 type TelegramNotifier struct {
-	enabled    bool
-	botToken   string
-	apiBaseURL string
-	client     *http.Client
-	logger     *zap.Logger
+	enabled     bool
+	botToken    string
+	apiBaseURL  string
+	adminChatID string
+	client      *http.Client
+	logger      *zap.Logger
 }
 
 type telegramSendMessageRequest struct {
@@ -40,9 +41,10 @@ func NewTelegramNotifier(cfg config.TelegramConfig) *TelegramNotifier {
 	enabled := cfg.Enabled && cfg.BotToken != "" && apiBaseURL != ""
 
 	return &TelegramNotifier{
-		enabled:    enabled,
-		botToken:   cfg.BotToken,
-		apiBaseURL: apiBaseURL,
+		enabled:     enabled,
+		botToken:    cfg.BotToken,
+		apiBaseURL:  apiBaseURL,
+		adminChatID: strings.TrimSpace(cfg.AdminChatID),
 		client: &http.Client{
 			Timeout: 5 * time.Second,
 		},
@@ -71,8 +73,21 @@ func (t *TelegramNotifier) NotifyPeerUsage(ctx context.Context, peerName, telegr
 		utils.BytesToGB(limit),
 	)
 
+	return t.sendMessage(ctx, username, message)
+}
+
+// NotifyAdmins sends an alert to the admin chat, it does nothing when no chat is configured
+func (t *TelegramNotifier) NotifyAdmins(ctx context.Context, message string) error {
+	if !t.enabled || t.adminChatID == "" {
+		return nil
+	}
+
+	return t.sendMessage(ctx, t.adminChatID, message)
+}
+
+func (t *TelegramNotifier) sendMessage(ctx context.Context, chatID, message string) error {
 	payload := telegramSendMessageRequest{
-		ChatID:                username,
+		ChatID:                chatID,
 		Text:                  message,
 		DisableWebPagePreview: true,
 	}
@@ -0,0 +1,301 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/dataservice/model"
+	"github.com/maahdima/mwp/api/http/schema"
+	"github.com/maahdima/mwp/api/utils/totp"
+)
+
+const (
+	totpIssuer         = "MWP"
+	challengeTokenTTL  = 5 * time.Minute
+	recoveryCodeCount  = 10
+	recoveryCodeLength = 10
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// LoginTwoFactor completes a login started by Login for an admin with two-factor authentication
+func (a *Authentication) LoginTwoFactor(challengeToken, code string, client SessionClient) (*schema.LoginResponse, error) {
+	claims := jwt.MapClaims{}
+	if _, err := parseToken(challengeToken, a.ChallengeSecret, claims); err != nil {
+		return nil, err
+	}
+	username, _ := claims["sub"].(string)
+
+	admin, err := a.findAdmin(username)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, common.ErrInvalidToken
+		}
+		return nil, err
+	}
+	if !admin.IsActive || !admin.TOTPEnabled {
+		return nil, common.ErrInvalidToken
+	}
+
+	if err := a.verifySecondFactor(admin, code); err != nil {
+		return nil, err
+	}
+
+	session, err := a.createSession(admin, client)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.issueTokens(admin, session)
+}
+
+// SetupTwoFactor starts the enrolment of an admin with a new secret, nothing changes for the login until
+// EnableTwoFactor confirms that the authenticator app produces valid codes
+func (a *Authentication) SetupTwoFactor(username string) (*schema.TwoFactorSetupResponse, error) {
+	admin, err := a.findAdmin(username)
+	if err != nil {
+		return nil, err
+	}
+	if admin.TOTPEnabled {
+		return nil, fmt.Errorf("%w: it is already enabled", common.ErrTwoFactorState)
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		a.logger.Error("failed to generate totp secret", zap.Error(err))
+		return nil, err
+	}
+
+	// a struct update goes through the encrypted serializer of the secret, a map would store it in plaintext
+	admin.TOTPSecret = secret
+	admin.TOTPLastStep = 0
+	if err := a.db.Model(&admin).Select("totp_secret", "totp_last_step").Updates(&admin).Error; err != nil {
+		a.logger.Error("failed to save totp secret", zap.Error(err))
+		return nil, err
+	}
+
+	uri := totp.URI(totpIssuer, admin.Username, secret)
+	qrCode, err := renderQRCodePNG(uri)
+	if err != nil {
+		a.logger.Error("failed to render totp qr code", zap.Error(err))
+		return nil, err
+	}
+
+	return &schema.TwoFactorSetupResponse{
+		Secret: secret,
+		URI:    uri,
+		QRCode: "data:image/png;base64," + base64.StdEncoding.EncodeToString(qrCode),
+	}, nil
+}
+
+// EnableTwoFactor turns two-factor authentication on once code matches the secret from SetupTwoFactor and returns
+// the recovery codes
+func (a *Authentication) EnableTwoFactor(username, code string) (*schema.RecoveryCodesResponse, error) {
+	admin, err := a.findAdmin(username)
+	if err != nil {
+		return nil, err
+	}
+	if admin.TOTPEnabled || admin.TOTPSecret == "" {
+		return nil, fmt.Errorf("%w: run the setup first", common.ErrTwoFactorState)
+	}
+
+	step, ok := totp.Validate(admin.TOTPSecret, code, time.Now(), admin.TOTPLastStep)
+	if !ok {
+		return nil, common.ErrInvalidOTP
+	}
+
+	var codes []string
+	err = a.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&admin).Updates(map[string]interface{}{"totp_enabled": true, "totp_last_step": step}).Error
+		if err != nil {
+			return err
+		}
+		codes, err = replaceRecoveryCodes(tx, admin.ID)
+		return err
+	})
+	if err != nil {
+		a.logger.Error("failed to enable two-factor authentication", zap.Error(err))
+		return nil, err
+	}
+
+	a.logger.Info("two-factor authentication enabled", zap.String("username", admin.Username))
+
+	return &schema.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// DisableTwoFactor turns two-factor authentication off, it takes the password and a current code
+func (a *Authentication) DisableTwoFactor(username, password, code string) error {
+	admin, err := a.findAdmin(username)
+	if err != nil {
+		return err
+	}
+	if !admin.TOTPEnabled {
+		return fmt.Errorf("%w: it is not enabled", common.ErrTwoFactorState)
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(admin.Password), []byte(password)); err != nil {
+		return common.ErrInvalidCredentials
+	}
+	if err := a.verifySecondFactor(admin, code); err != nil {
+		return err
+	}
+
+	err = a.db.Transaction(func(tx *gorm.DB) error {
+		admin.TOTPSecret = ""
+		admin.TOTPEnabled = false
+		admin.TOTPLastStep = 0
+		err := tx.Model(&admin).Select("totp_secret", "totp_enabled", "totp_last_step").Updates(&admin).Error
+		if err != nil {
+			return err
+		}
+		return tx.Unscoped().Where("admin_id = ?", admin.ID).Delete(&model.AdminRecoveryCode{}).Error
+	})
+	if err != nil {
+		a.logger.Error("failed to disable two-factor authentication", zap.Error(err))
+		return err
+	}
+
+	a.logger.Info("two-factor authentication disabled", zap.String("username", admin.Username))
+
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of an admin, the previous ones stop working
+func (a *Authentication) RegenerateRecoveryCodes(username, code string) (*schema.RecoveryCodesResponse, error) {
+	admin, err := a.findAdmin(username)
+	if err != nil {
+		return nil, err
+	}
+	if !admin.TOTPEnabled {
+		return nil, fmt.Errorf("%w: it is not enabled", common.ErrTwoFactorState)
+	}
+
+	if err := a.verifySecondFactor(admin, code); err != nil {
+		return nil, err
+	}
+
+	var codes []string
+	err = a.db.Transaction(func(tx *gorm.DB) error {
+		codes, err = replaceRecoveryCodes(tx, admin.ID)
+		return err
+	})
+	if err != nil {
+		a.logger.Error("failed to regenerate recovery codes", zap.Error(err))
+		return nil, err
+	}
+
+	return &schema.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+func (a *Authentication) twoFactorChallenge(admin model.Admin) (*schema.LoginResponse, error) {
+	claims := jwt.MapClaims{
+		"sub": admin.Username,
+		"exp": time.Now().Add(challengeTokenTTL).Unix(),
+	}
+	challenge, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(a.ChallengeSecret)
+	if err != nil {
+		a.logger.Error("failed to generate challenge token", zap.Error(err))
+		return nil, err
+	}
+
+	return &schema.LoginResponse{
+		UserID:            admin.ID,
+		Username:          admin.Username,
+		Role:              admin.Role,
+		TwoFactorRequired: true,
+		ChallengeToken:    challenge,
+	}, nil
+}
+
+// verifySecondFactor accepts a TOTP code newer than the last accepted one, or an unused recovery code. Both are
+// consumed with a conditional update so concurrent logins cannot use the same code.
+func (a *Authentication) verifySecondFactor(admin model.Admin, code string) error {
+	if step, ok := totp.Validate(admin.TOTPSecret, code, time.Now(), admin.TOTPLastStep); ok {
+		result := a.db.Model(&model.Admin{}).
+			Where("id = ? AND totp_last_step < ?", admin.ID, step).
+			Update("totp_last_step", step)
+		if result.Error != nil {
+			a.logger.Error("failed to record totp step", zap.Error(result.Error))
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return common.ErrInvalidOTP
+		}
+		return nil
+	}
+
+	result := a.db.Model(&model.AdminRecoveryCode{}).
+		Where("admin_id = ? AND code_hash = ? AND used_at IS NULL", admin.ID, hashRecoveryCode(admin.RecoveryCodeSalt, code)).
+		Update("used_at", time.Now().Unix())
+	if result.Error != nil {
+		a.logger.Error("failed to use recovery code", zap.Error(result.Error))
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		a.logger.Warn("invalid two-factor code", zap.String("username", admin.Username))
+		return common.ErrInvalidOTP
+	}
+
+	a.logger.Info("recovery code used", zap.String("username", admin.Username))
+
+	return nil
+}
+
+// replaceRecoveryCodes stores a new set of recovery codes under a new salt, so equal codes of two admins or two sets
+// never share a hash
+func replaceRecoveryCodes(tx *gorm.DB, adminID uint) ([]string, error) {
+	if err := tx.Unscoped().Where("admin_id = ?", adminID).Delete(&model.AdminRecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	salt := hex.EncodeToString(random)
+	if err := tx.Model(&model.Admin{}).Where("id = ?", adminID).Update("recovery_code_salt", salt).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	rows := make([]model.AdminRecoveryCode, 0, recoveryCodeCount)
+	for range recoveryCodeCount {
+		raw := make([]byte, 8)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(raw)[:recoveryCodeLength])
+		code = code[:recoveryCodeLength/2] + "-" + code[recoveryCodeLength/2:]
+
+		codes = append(codes, code)
+		rows = append(rows, model.AdminRecoveryCode{AdminID: adminID, CodeHash: hashRecoveryCode(salt, code)})
+	}
+
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// hashRecoveryCode ignores case, spaces and dashes so the codes can be typed the way they are read
+func hashRecoveryCode(salt, code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	mac := hmac.New(sha256.New, []byte(salt))
+	mac.Write([]byte(normalized))
+	return hex.EncodeToString(mac.Sum(nil))
+}
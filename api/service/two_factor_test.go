@@ -0,0 +1,166 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maahdima/mwp/api/common"
+	"github.com/maahdima/mwp/api/utils/envelope"
+	"github.com/maahdima/mwp/api/utils/totp"
+)
+
+// totpCode computes the code an authenticator app shows for secret at the given time
+func totpCode(t *testing.T, secret string, at time.Time) string {
+	t.Helper()
+
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(at.Unix()/int64(totp.Period.Seconds())))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%06d", value%1000000)
+}
+
+// enableTwoFactor enrols the test admin with the code shown at the given time and returns its secret and recovery
+// codes
+func enableTwoFactor(t *testing.T, auth *Authentication, at time.Time) (string, []string) {
+	t.Helper()
+
+	setup, err := auth.SetupTwoFactor("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	codes, err := auth.EnableTwoFactor("admin", totpCode(t, setup.Secret, at))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return setup.Secret, codes.RecoveryCodes
+}
+
+func loginChallenge(t *testing.T, auth *Authentication) string {
+	t.Helper()
+
+	login, err := auth.Login("admin", "secret", SessionClient{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !login.TwoFactorRequired || login.AccessToken != "" {
+		t.Fatalf("login %+v of an enrolled admin issued tokens without the second factor", login)
+	}
+	return login.ChallengeToken
+}
+
+func TestLoginTwoFactorRejectsReplayedCode(t *testing.T) {
+	env := newTestEnv(t)
+	auth := env.authService(t)
+	enrolled := time.Now()
+	secret, _ := enableTwoFactor(t, auth, enrolled)
+
+	// the enrolment consumed its step, the same code cannot log in
+	if _, err := auth.LoginTwoFactor(loginChallenge(t, auth), totpCode(t, secret, enrolled), SessionClient{}); !errors.Is(err, common.ErrInvalidOTP) {
+		t.Fatalf("code used for the enrolment got %v, want ErrInvalidOTP", err)
+	}
+
+	next := totpCode(t, secret, time.Now().Add(totp.Period))
+	login, err := auth.LoginTwoFactor(loginChallenge(t, auth), next, SessionClient{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if login.AccessToken == "" {
+		t.Fatal("second factor login issued no access token")
+	}
+
+	if _, err := auth.LoginTwoFactor(loginChallenge(t, auth), next, SessionClient{}); !errors.Is(err, common.ErrInvalidOTP) {
+		t.Fatalf("replayed code got %v, want ErrInvalidOTP", err)
+	}
+}
+
+func TestRecoveryCodeIsSingleUse(t *testing.T) {
+	env := newTestEnv(t)
+	auth := env.authService(t)
+	_, codes := enableTwoFactor(t, auth, time.Now())
+
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("got %d recovery codes, want %d", len(codes), recoveryCodeCount)
+	}
+
+	// codes are accepted the way they are typed, in upper case and without the dash
+	typed := strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))
+	if _, err := auth.LoginTwoFactor(loginChallenge(t, auth), typed, SessionClient{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.LoginTwoFactor(loginChallenge(t, auth), codes[0], SessionClient{}); !errors.Is(err, common.ErrInvalidOTP) {
+		t.Fatalf("used recovery code got %v, want ErrInvalidOTP", err)
+	}
+}
+
+func TestRegenerateRecoveryCodesReplacesSalt(t *testing.T) {
+	env := newTestEnv(t)
+	auth := env.authService(t)
+	enrolled := time.Now()
+	secret, codes := enableTwoFactor(t, auth, enrolled)
+
+	before, err := auth.findAdmin("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before.RecoveryCodeSalt == "" {
+		t.Fatal("recovery codes were stored without a salt")
+	}
+
+	if _, err := auth.RegenerateRecoveryCodes("admin", totpCode(t, secret, enrolled.Add(totp.Period))); err != nil {
+		t.Fatal(err)
+	}
+	after, err := auth.findAdmin("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.RecoveryCodeSalt == before.RecoveryCodeSalt {
+		t.Fatal("regenerated recovery codes kept the previous salt")
+	}
+
+	if _, err := auth.LoginTwoFactor(loginChallenge(t, auth), codes[0], SessionClient{}); !errors.Is(err, common.ErrInvalidOTP) {
+		t.Fatalf("replaced recovery code got %v, want ErrInvalidOTP", err)
+	}
+}
+
+func TestTOTPSecretIsEncrypted(t *testing.T) {
+	env := newTestEnv(t)
+	auth := env.authService(t)
+
+	setup, err := auth.SetupTwoFactor("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var raw string
+	if err := env.db.Table("admins").Select("totp_secret").Where("username = ?", "admin").Scan(&raw).Error; err != nil {
+		t.Fatal(err)
+	}
+	if raw == setup.Secret || !envelope.IsSealed(raw) {
+		t.Fatalf("totp secret stored as %q", raw)
+	}
+
+	admin, err := auth.findAdmin("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if admin.TOTPSecret != setup.Secret {
+		t.Fatal("totp secret does not read back")
+	}
+}
@@ -0,0 +1,244 @@
+package envelope
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// Prefix starts every sealed value, anything without it is plaintext written before encryption was enabled
+const Prefix = "enc:v1:"
+
+// MinKeyLength is the shortest master key accepted, keys are hashed to the 32 bytes AES-256 needs
+const MinKeyLength = 32
+
+var (
+	ErrUnknownKey = errors.New("value is sealed with an unknown master key")
+	ErrMalformed  = errors.New("malformed sealed value")
+)
+
+var encoding = base64.RawStdEncoding
+
+var defaultKeyring atomic.Pointer[Keyring]
+
+// Keyring seals values under its current master key and opens values sealed under any of its keys, the previous
+// ones are kept until every value is rewrapped
+type Keyring struct {
+	current *masterKey
+	keys    map[string]*masterKey
+}
+
+type masterKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+func NewKeyring(current string, previous ...string) (*Keyring, error) {
+	key, err := newMasterKey(current)
+	if err != nil {
+		return nil, err
+	}
+
+	k := &Keyring{current: key, keys: map[string]*masterKey{key.id: key}}
+	for _, raw := range previous {
+		old, err := newMasterKey(raw)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := k.keys[old.id]; !ok {
+			k.keys[old.id] = old
+		}
+	}
+
+	return k, nil
+}
+
+// SetDefault makes k the keyring used by Default, it is set once the master key is loaded at startup
+func SetDefault(k *Keyring) {
+	defaultKeyring.Store(k)
+}
+
+// Default returns the keyring of the running process, nil until SetDefault is called
+func Default() *Keyring {
+	return defaultKeyring.Load()
+}
+
+// KeyID identifies the current master key without revealing it
+func (k *Keyring) KeyID() string {
+	return k.current.id
+}
+
+// Seal encrypts plaintext with a fresh data key and stores that data key encrypted under the current master key.
+// The result is "enc:v1:<key id>:<wrapped data key>:<ciphertext>".
+func (k *Keyring) Seal(plaintext []byte) (string, error) {
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(dataAEAD, plaintext, nil)
+	if err != nil {
+		return "", err
+	}
+
+	wrapped, err := seal(k.current.aead, dataKey, []byte(k.current.id))
+	if err != nil {
+		return "", err
+	}
+
+	return Prefix + k.current.id + ":" + encoding.EncodeToString(wrapped) + ":" + encoding.EncodeToString(ciphertext), nil
+}
+
+// Open decrypts a value produced by Seal, values without the prefix are returned as they are
+func (k *Keyring) Open(value string) ([]byte, error) {
+	if !IsSealed(value) {
+		return []byte(value), nil
+	}
+
+	keyID, wrapped, ciphertext, err := parse(value)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey, err := k.unwrap(keyID, wrapped)
+	if err != nil {
+		return nil, err
+	}
+
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return open(dataAEAD, ciphertext, nil)
+}
+
+// Rewrap brings a value under the current master key. Plaintext is sealed, values sealed under a previous key get
+// their data key re-encrypted while the ciphertext stays untouched. It reports whether the value changed.
+func (k *Keyring) Rewrap(value string) (string, bool, error) {
+	if !IsSealed(value) {
+		sealed, err := k.Seal([]byte(value))
+		return sealed, err == nil, err
+	}
+
+	keyID, wrapped, ciphertext, err := parse(value)
+	if err != nil {
+		return "", false, err
+	}
+	if keyID == k.current.id {
+		return value, false, nil
+	}
+
+	dataKey, err := k.unwrap(keyID, wrapped)
+	if err != nil {
+		return "", false, err
+	}
+
+	rewrapped, err := seal(k.current.aead, dataKey, []byte(k.current.id))
+	if err != nil {
+		return "", false, err
+	}
+
+	return Prefix + k.current.id + ":" + encoding.EncodeToString(rewrapped) + ":" + encoding.EncodeToString(ciphertext), true, nil
+}
+
+// IsSealed tells sealed values apart from plaintext
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, Prefix)
+}
+
+// GenerateKey returns a random master key, hex encoded
+func GenerateKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("failed to generate master key: %w", err)
+	}
+
+	return hex.EncodeToString(key), nil
+}
+
+func (k *Keyring) unwrap(keyID string, wrapped []byte) ([]byte, error) {
+	key, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w %s", ErrUnknownKey, keyID)
+	}
+
+	return open(key.aead, wrapped, []byte(keyID))
+}
+
+func newMasterKey(raw string) (*masterKey, error) {
+	raw = strings.TrimSpace(raw)
+	if len(raw) < MinKeyLength {
+		return nil, fmt.Errorf("master key must be at least %d characters", MinKeyLength)
+	}
+
+	key := sha256.Sum256([]byte(raw))
+	aead, err := newAEAD(key[:])
+	if err != nil {
+		return nil, err
+	}
+
+	// the id is hashed apart from the key so it cannot be used to check guesses against the key itself
+	id := sha256.Sum256([]byte("mwp-key-id:" + raw))
+
+	return &masterKey{id: hex.EncodeToString(id[:4]), aead: aead}, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// seal returns the nonce followed by the ciphertext
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(aead cipher.AEAD, sealed, additionalData []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrMalformed
+	}
+
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt sealed value: %w", err)
+	}
+
+	return plaintext, nil
+}
+
+func parse(value string) (keyID string, wrapped, ciphertext []byte, err error) {
+	parts := strings.Split(strings.TrimPrefix(value, Prefix), ":")
+	if len(parts) != 3 {
+		return "", nil, nil, ErrMalformed
+	}
+
+	if wrapped, err = encoding.DecodeString(parts[1]); err != nil {
+		return "", nil, nil, ErrMalformed
+	}
+	if ciphertext, err = encoding.DecodeString(parts[2]); err != nil {
+		return "", nil, nil, ErrMalformed
+	}
+
+	return parts[0], wrapped, ciphertext, nil
+}
@@ -0,0 +1,116 @@
+package envelope
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const (
+	oldKey = "0123456789abcdef0123456789abcdef-old"
+	newKey = "0123456789abcdef0123456789abcdef-new"
+)
+
+func TestSealOpen(t *testing.T) {
+	keyring, err := NewKeyring(newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealed, err := keyring.Seal([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsSealed(sealed) || strings.Contains(sealed, "secret") {
+		t.Fatalf("value is not sealed: %s", sealed)
+	}
+
+	opened, err := keyring.Open(sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(opened) != "secret" {
+		t.Fatalf("opened %q, want %q", opened, "secret")
+	}
+}
+
+func TestOpenPlaintext(t *testing.T) {
+	keyring, err := NewKeyring(newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opened, err := keyring.Open("plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(opened) != "plain" {
+		t.Fatalf("opened %q, want %q", opened, "plain")
+	}
+}
+
+func TestRewrap(t *testing.T) {
+	old, err := NewKeyring(oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := old.Seal([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := NewKeyring(newKey, oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rewrapped, changed, err := rotated.Rewrap(sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Fatal("value sealed under the previous key was not rewrapped")
+	}
+	if ciphertext(rewrapped) != ciphertext(sealed) {
+		t.Fatal("rewrapping changed the ciphertext")
+	}
+	if _, changed, _ := rotated.Rewrap(rewrapped); changed {
+		t.Fatal("value sealed under the current key was rewrapped again")
+	}
+
+	current, err := NewKeyring(newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opened, err := current.Open(rewrapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(opened) != "secret" {
+		t.Fatalf("opened %q, want %q", opened, "secret")
+	}
+	if _, err := current.Open(sealed); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("opening a value of a forgotten key returned %v, want %v", err, ErrUnknownKey)
+	}
+}
+
+func TestOpenMalformed(t *testing.T) {
+	keyring, err := NewKeyring(newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := keyring.Open(Prefix + "broken"); !errors.Is(err, ErrMalformed) {
+		t.Fatalf("got %v, want %v", err, ErrMalformed)
+	}
+}
+
+func TestShortKey(t *testing.T) {
+	if _, err := NewKeyring("short"); err == nil {
+		t.Fatal("a key shorter than MinKeyLength was accepted")
+	}
+}
+
+func ciphertext(sealed string) string {
+	parts := strings.Split(sealed, ":")
+	return parts[len(parts)-1]
+}
@@ -11,6 +11,8 @@ import (
 	"time"
 
 	"go.uber.org/zap"
+
+	"github.com/maahdima/mwp/api/metrics"
 )
 
 type Config struct {
@@ -19,6 +21,7 @@ type Config struct {
 	Password           string        // Password for Basic Authentication.
 	InsecureSkipVerify bool          // If true, the client will skip TLS certificate verification. Equivalent to 'curl -k'.
 	Timeout            time.Duration // Request timeout. Defaults to 30 seconds if not set.
+	Name               string        // Identifies the router in metrics. Defaults to the BaseURL.
 }
 
 type Client struct {
@@ -35,6 +38,9 @@ func NewClient(config Config) (*Client, error) {
 	if config.Timeout == 0 {
 		config.Timeout = 5 * time.Second
 	}
+	if config.Name == "" {
+		config.Name = config.BaseURL
+	}
 
 	transport := http.DefaultTransport.(*http.Transport).Clone()
 
@@ -54,7 +60,12 @@ func NewClient(config Config) (*Client, error) {
 	}, nil
 }
 
-func (c *Client) Do(req *http.Request, respBody interface{}) error {
+func (c *Client) Do(req *http.Request, respBody interface{}) (err error) {
+	start := time.Now()
+	defer func() {
+		metrics.ObserveRouterRequest(c.config.Name, req.Method, req.URL.Path, time.Since(start), err)
+	}()
+
 	if c.config.Username != "" || c.config.Password != "" {
 		req.SetBasicAuth(c.config.Username, c.config.Password)
 	}
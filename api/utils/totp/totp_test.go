@@ -0,0 +1,43 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key of the RFC 6238 test vectors, base32 encoded
+var rfcSecret = encoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestValidateRFCVector(t *testing.T) {
+	// the RFC lists 94287082 for 8 digits at T=59, six digits keep the last six
+	step, ok := Validate(rfcSecret, "287082", time.Unix(59, 0), 0)
+	if !ok || step != 1 {
+		t.Fatalf("RFC 6238 vector: step %d, ok %t", step, ok)
+	}
+}
+
+func TestValidateRejectsUsedSteps(t *testing.T) {
+	key, err := encoding.DecodeString(rfcSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1_700_000_000, 0)
+	current := now.Unix() / int64(Period.Seconds())
+	code := generate(key, current)
+
+	step, ok := Validate(rfcSecret, code, now, 0)
+	if !ok || step != current {
+		t.Fatalf("fresh code: step %d, ok %t", step, ok)
+	}
+	if _, ok := Validate(rfcSecret, code, now, step); ok {
+		t.Fatal("a code was accepted twice")
+	}
+
+	previous := generate(key, current-1)
+	if _, ok := Validate(rfcSecret, previous, now, 0); !ok {
+		t.Fatal("code of the previous step was rejected despite the skew")
+	}
+	if _, ok := Validate(rfcSecret, generate(key, current-2), now, 0); ok {
+		t.Fatal("code two steps old was accepted")
+	}
+}
@@ -2,10 +2,13 @@ package utils
 
 import (
 	"fmt"
+	"math"
+	"math/big"
 	"math/rand"
-	"net"
+	"net/netip"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/labstack/gommon/log"
@@ -68,6 +71,33 @@ func GBToBytes(s string) int64 {
 	return int64(gb * 1024 * 1024 * 1024)
 }
 
+// ParseBandwidth Convert a RouterOS rate such as "10M", "512k" or "2000000" to bits per second
+func ParseBandwidth(s string) int64 {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0
+	}
+
+	multiplier := 1.0
+	switch s[len(s)-1] {
+	case 'k', 'K':
+		multiplier = 1e3
+	case 'm', 'M':
+		multiplier = 1e6
+	case 'g', 'G':
+		multiplier = 1e9
+	}
+	if multiplier != 1 {
+		s = s[:len(s)-1]
+	}
+
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0
+	}
+	return int64(value * multiplier)
+}
+
 func IsPeerSharable(isShared bool, shareExpireTime *string) bool {
 	if !isShared {
 		log.Errorf("peer is not shared")
@@ -99,12 +129,68 @@ func RandomString(n int) string {
 	return string(s)
 }
 
-func IPToUint32(ip net.IP) uint32 {
-	ip = ip.To4()
-	if ip == nil {
+// ParseAllowedAddresses splits a RouterOS allowed-address list such as "10.0.0.2/32,fd00::2/128", entries that are not
+// valid prefixes are skipped
+func ParseAllowedAddresses(allowedAddress string) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, item := range strings.Split(allowedAddress, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(item); err == nil {
+			prefixes = append(prefixes, prefix)
+		} else if addr, err := netip.ParseAddr(item); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+		}
+	}
+	return prefixes
+}
+
+// ParseAddress parses an address written alone or with a prefix length, as IP pools store them
+func ParseAddress(address string) (netip.Addr, error) {
+	address = strings.TrimSpace(address)
+	if prefix, err := netip.ParsePrefix(address); err == nil {
+		return prefix.Addr().Unmap(), nil
+	}
+	addr, err := netip.ParseAddr(address)
+	return addr.Unmap(), err
+}
+
+// LastAddr returns the highest address of a prefix
+func LastAddr(prefix netip.Prefix) netip.Addr {
+	addr := prefix.Masked().Addr().As16()
+	hostBits := prefix.Addr().BitLen() - prefix.Bits()
+	for i := len(addr) - 1; i >= 0 && hostBits > 0; i-- {
+		if hostBits >= 8 {
+			addr[i] = 0xff
+			hostBits -= 8
+		} else {
+			addr[i] |= byte(1<<hostBits) - 1
+			hostBits = 0
+		}
+	}
+
+	last := netip.AddrFrom16(addr)
+	if prefix.Addr().Is4() {
+		return last.Unmap()
+	}
+	return last
+}
+
+// RangeSize returns how many addresses lie between start and end inclusive, capped at math.MaxInt for IPv6 ranges
+func RangeSize(start, end netip.Addr) int {
+	if !start.IsValid() || start.BitLen() != end.BitLen() || end.Less(start) {
 		return 0
 	}
-	return uint32(ip[0])<<24 | uint32(ip[1])<<16 | uint32(ip[2])<<8 | uint32(ip[3])
+
+	startBytes, endBytes := start.As16(), end.As16()
+	size := new(big.Int).Sub(new(big.Int).SetBytes(endBytes[:]), new(big.Int).SetBytes(startBytes[:]))
+	size.Add(size, big.NewInt(1))
+	if !size.IsInt64() || size.Int64() > math.MaxInt {
+		return math.MaxInt
+	}
+	return int(size.Int64())
 }
 
 func FormatDuration(d time.Duration) string {
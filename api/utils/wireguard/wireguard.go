@@ -4,20 +4,51 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"fmt"
+	"strings"
 
 	"golang.org/x/crypto/curve25519"
 )
 
-var Template = `[Interface]
-PrivateKey = %s
-Address = %s
-DNS = %s
+// ClientConfig is the wg-quick config of a peer, the optional settings left empty are not written
+type ClientConfig struct {
+	PrivateKey          string
+	Address             string
+	DNS                 string
+	MTU                 int
+	PublicKey           string
+	PresharedKey        string
+	Endpoint            string
+	EndpointPort        string
+	AllowedIPs          string
+	PersistentKeepalive string
+}
+
+func (c ClientConfig) String() string {
+	var b strings.Builder
+
+	b.WriteString("[Interface]\n")
+	fmt.Fprintf(&b, "PrivateKey = %s\n", c.PrivateKey)
+	fmt.Fprintf(&b, "Address = %s\n", c.Address)
+	if c.DNS != "" {
+		fmt.Fprintf(&b, "DNS = %s\n", c.DNS)
+	}
+	if c.MTU > 0 {
+		fmt.Fprintf(&b, "MTU = %d\n", c.MTU)
+	}
+
+	b.WriteString("\n[Peer]\n")
+	fmt.Fprintf(&b, "PublicKey = %s\n", c.PublicKey)
+	if c.PresharedKey != "" {
+		fmt.Fprintf(&b, "PresharedKey = %s\n", c.PresharedKey)
+	}
+	fmt.Fprintf(&b, "Endpoint = %s:%s\n", c.Endpoint, c.EndpointPort)
+	fmt.Fprintf(&b, "AllowedIPs = %s", c.AllowedIPs)
+	if c.PersistentKeepalive != "" && c.PersistentKeepalive != "0" {
+		fmt.Fprintf(&b, "\nPersistentKeepalive = %s", c.PersistentKeepalive)
+	}
 
-[Peer]
-PublicKey = %s
-Endpoint = %s:%s
-AllowedIPs = %s
-PersistentKeepalive = %s`
+	return b.String()
+}
 
 func GeneratePrivateKey() ([]byte, string, error) {
 	var privateKey [32]byte
@@ -45,3 +76,14 @@ func GeneratePublicKey(privateKey []byte) (string, error) {
 	pubKeyBase64 := base64.StdEncoding.EncodeToString(pubKey[:])
 	return pubKeyBase64, nil
 }
+
+// GeneratePresharedKey returns a random symmetric key, the same value goes to the router and the client config
+func GeneratePresharedKey() (string, error) {
+	var key [32]byte
+
+	if _, err := rand.Read(key[:]); err != nil {
+		return "", fmt.Errorf("failed to generate preshared key: %w", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(key[:]), nil
+}